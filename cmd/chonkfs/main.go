@@ -5,23 +5,30 @@ import (
 	"io"
 	"log"
 	"os"
+	"slices"
 	"time"
 
 	"github.com/hanwen/go-fuse/v2/fs"
 	"github.com/lerenn/chonkfs/pkg/chonker"
 	"github.com/lerenn/chonkfs/pkg/fuse"
 	"github.com/lerenn/chonkfs/pkg/storage"
-	"github.com/lerenn/chonkfs/pkg/storage/disk"
-	"github.com/lerenn/chonkfs/pkg/storage/layer"
-	"github.com/lerenn/chonkfs/pkg/storage/mem"
+	"github.com/lerenn/chonkfs/pkg/storage/torrent"
 	"github.com/spf13/cobra"
 )
 
 var (
-	diskPath  string
-	mntPath   string
-	debug     bool
-	chunkSize int
+	diskPath   string
+	diskPacked bool
+	writeBack  time.Duration
+	cacheSize  int
+	prefetch   int
+	tierFlags  []string
+	configPath string
+	mntPath    string
+	debug      bool
+	chunkSize  int
+	torrents   []string
+	streams    bool
 )
 
 var rootCmd = &cobra.Command{
@@ -44,20 +51,27 @@ var rootCmd = &cobra.Command{
 		}
 
 		// Create backend
-		var err error
-		var be storage.Directory
-		switch {
-		case diskPath != "":
-			be, err = layer.NewDirectory(mem.NewDirectory(), disk.NewDirectory(diskPath))
-			if err != nil {
-				return err
-			}
-		default:
-			be = mem.NewDirectory()
+		configs, err := tiersConfig()
+		if err != nil {
+			return err
+		}
+		be, closers, err := newBackend(cmd.Context(), configs)
+		defer closeAll(closers)
+		if err != nil {
+			return err
+		}
+
+		// Verify the files of the torrents
+		be, torrentStreams, err := withTorrents(be, torrents, streams)
+		if err != nil {
+			return err
 		}
 
 		// Create chonker
-		c, err := chonker.NewDirectory(cmd.Context(), be, chonker.WithDirectoryLogger(logger))
+		c, err := chonker.NewDirectory(cmd.Context(), be,
+			chonker.WithDirectoryLogger(logger),
+			chonker.WithDirectoryPrefetch(prefetch),
+			chonker.WithDirectoryStreams(torrentStreams...))
 		if err != nil {
 			return err
 		}
@@ -71,8 +85,6 @@ var rootCmd = &cobra.Command{
 		to := time.Duration(1)
 		server, err := fs.Mount(mntPath, w, &fs.Options{
 			Logger:       logger,
-			UID:          uint32(os.Getuid()),
-			GID:          uint32(os.Getgid()),
 			EntryTimeout: &to,
 			AttrTimeout:  &to,
 		})
@@ -86,12 +98,127 @@ var rootCmd = &cobra.Command{
 	},
 }
 
+// tiersConfig returns the storage tiers, from the config file, the tier
+// flags, or the disk and cache flags.
+func tiersConfig() ([]tierConfig, error) {
+	switch {
+	case configPath != "":
+		c, err := loadConfig(configPath)
+		if err != nil {
+			return nil, err
+		}
+		return c.Tiers, checkTiers(c.Tiers)
+	case len(tierFlags) > 0:
+		configs := make([]tierConfig, 0, len(tierFlags))
+		for _, t := range tierFlags {
+			c, err := parseTier(t)
+			if err != nil {
+				return nil, err
+			}
+			configs = append(configs, c)
+		}
+		return configs, checkTiers(configs)
+	case diskPath != "":
+		return []tierConfig{
+			{
+				Backend:       "mem",
+				MaxSize:       cacheSize,
+				WriteBack:     duration(writeBack),
+				PromoteOnRead: true,
+			},
+			{
+				Backend: "disk",
+				Path:    diskPath,
+				Packed:  diskPacked,
+			},
+		}, nil
+	default:
+		return []tierConfig{{Backend: "mem"}}, nil
+	}
+}
+
+// withTorrents wraps the storage to verify the files of the torrents, if any.
+// With streams, the files of multi-file v1 torrents are windows in one stream
+// instead, so the pieces spanning several files are stored and verified once.
+func withTorrents(s storage.Directory, paths []string, streams bool) (storage.Directory, []chonker.Stream, error) {
+	if len(paths) == 0 {
+		return s, nil, nil
+	}
+
+	metainfos := make([]*torrent.Metainfo, 0, len(paths))
+	chonkerStreams := make([]chonker.Stream, 0, len(paths))
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		m, err := torrent.ParseMetainfo(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("torrent %q: %w", p, err)
+		}
+
+		// Keep a file per torrent file if the torrent is not a multi-file
+		// one, or if its pieces can't span files (v2)
+		sm, err := m.Stream(chonker.StreamFileName(m.Name))
+		if !streams || err != nil || !slices.ContainsFunc(m.Entries, func(e torrent.Entry) bool {
+			return len(e.Path) > 1
+		}) {
+			metainfos = append(metainfos, m)
+			continue
+		}
+
+		metainfos = append(metainfos, sm)
+		chonkerStreams = append(chonkerStreams, torrentStream(m, sm))
+	}
+
+	return torrent.NewDirectory(s, metainfos...), chonkerStreams, nil
+}
+
+// torrentStream returns the stream of a multi-file torrent, from its stream
+// metainfo.
+func torrentStream(m, sm *torrent.Metainfo) chonker.Stream {
+	s := chonker.Stream{
+		Name:      m.Name,
+		ChunkSize: sm.PieceLength,
+		Length:    sm.Entries[0].Length,
+		Files:     make([]chonker.StreamFile, 0, len(m.Entries)),
+	}
+
+	for _, e := range m.Entries {
+		s.Files = append(s.Files, chonker.StreamFile{
+			Path:   e.Path[1:],
+			Offset: e.Offset,
+			Length: e.Length,
+		})
+	}
+
+	return s
+}
+
 func main() {
 	var errCode int
 
 	// Set flags
 	rootCmd.PersistentFlags().StringVarP(&mntPath, "mnt", "m", "", "Set mount path")
 	rootCmd.PersistentFlags().StringVarP(&diskPath, "disk", "d", "", "Set disk path")
+	rootCmd.PersistentFlags().BoolVarP(&diskPacked, "disk-packed", "p", false,
+		"Store all chunks of a file in one data file on disk")
+	rootCmd.PersistentFlags().DurationVarP(&writeBack, "write-back", "w", 0,
+		"Write on disk in the background at this interval, instead of on each write")
+	rootCmd.PersistentFlags().IntVarP(&cacheSize, "cache-size", "c", 0,
+		"Limit the memory used to cache disk chunks, in bytes (0 for no limit)")
+	rootCmd.PersistentFlags().IntVarP(&prefetch, "prefetch", "r", 0,
+		"Read in advance this number of chunks on sequential reads (0 to disable)")
+	rootCmd.PersistentFlags().StringArrayVarP(&tierFlags, "tier", "t", nil,
+		"Add a storage tier, from the fastest to the slowest, like 'disk,path=/data,write-back=5s' "+
+			"(replaces the disk and cache options)")
+	rootCmd.PersistentFlags().StringVarP(&configPath, "config", "f", "",
+		"Read the storage tiers from a JSON config file")
+	rootCmd.PersistentFlags().StringArrayVarP(&torrents, "torrent", "T", nil,
+		"Verify the pieces of the files of this .torrent file, from the root of the mount point")
+	rootCmd.PersistentFlags().BoolVarP(&streams, "torrent-stream", "S", false,
+		"Store the files of multi-file torrents as one stream, for the pieces spanning several files")
 	rootCmd.PersistentFlags().BoolVarP(&debug, "debug", "D", false, "Enable debug mode")
 	rootCmd.PersistentFlags().IntVarP(&chunkSize, "chunk-size", "s", fuse.DefaultChunkSize, "Set chunk size")
 
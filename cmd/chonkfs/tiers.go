@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jlaffaye/ftp"
+	"github.com/lerenn/chonkfs/pkg/storage"
+	"github.com/lerenn/chonkfs/pkg/storage/checksum"
+	"github.com/lerenn/chonkfs/pkg/storage/crypt"
+	"github.com/lerenn/chonkfs/pkg/storage/disk"
+	ftpstorage "github.com/lerenn/chonkfs/pkg/storage/ftp"
+	"github.com/lerenn/chonkfs/pkg/storage/kv"
+	"github.com/lerenn/chonkfs/pkg/storage/mem"
+	"github.com/lerenn/chonkfs/pkg/storage/s3"
+	"github.com/lerenn/chonkfs/pkg/storage/tier"
+	"github.com/lerenn/chonkfs/pkg/storage/webdav"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"go.etcd.io/bbolt"
+)
+
+// duration is a time.Duration that is written as a string ("5s") in config files.
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+
+	return nil
+}
+
+// config is the content of a config file.
+type config struct {
+	Tiers []tierConfig `json:"tiers"`
+}
+
+// tierConfig is the configuration of a tier, from the fastest to the slowest.
+type tierConfig struct {
+	// Backend is the storage of the tier: mem, disk, kv, ftp, s3 or webdav.
+	Backend string `json:"backend"`
+
+	// Path is the directory for disk, the database file for kv and the remote
+	// directory for ftp.
+	Path string `json:"path"`
+	// Packed stores all chunks of a file in one data file, for disk.
+	Packed bool `json:"packed"`
+	// MaxSize limits the memory used, in bytes, for mem. It cannot be set on
+	// the last tier, as the evicted chunks are read again from the tiers below.
+	MaxSize int `json:"maxSize"`
+	// Address is the server address for ftp, the endpoint for s3 and the URL
+	// for webdav.
+	Address string `json:"address"`
+	// User is the user (or access key for s3) used to log in.
+	User string `json:"user"`
+	// Password is the password (or secret key for s3) used to log in.
+	Password string `json:"password"`
+	// Bucket is the bucket for s3.
+	Bucket string `json:"bucket"`
+	// Prefix is the prefix of the keys for s3.
+	Prefix string `json:"prefix"`
+	// Secure uses TLS, for s3.
+	Secure bool `json:"secure"`
+
+	// Checksum stores a checksum with each chunk of this tier, verified on
+	// read, so corrupted chunks are read from the tiers below.
+	Checksum bool `json:"checksum"`
+	// KeyFile encrypts the chunks of this tier with the key in this file.
+	KeyFile string `json:"keyFile"`
+	// EncryptNames also encrypts the names of the files and directories, when
+	// there is a key file.
+	EncryptNames bool `json:"encryptNames"`
+
+	// WriteBack writes on this tier only and flushes to the tiers below at
+	// this interval.
+	WriteBack duration `json:"writeBack"`
+	// PromoteOnRead copies on this tier the chunks read from the tiers below.
+	PromoteOnRead bool `json:"promoteOnRead"`
+	// ReadOnly rejects any modification of this tier, which must be the last.
+	ReadOnly bool `json:"readOnly"`
+}
+
+// loadConfig reads a JSON config file.
+func loadConfig(path string) (config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config{}, err
+	}
+
+	var c config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return config{}, fmt.Errorf("invalid config file %q: %w", path, err)
+	}
+
+	return c, nil
+}
+
+// parseTier parses a tier from the command line, written as the backend
+// followed by comma separated options, like "mem,max-size=1024,promote-on-read".
+//
+//nolint:cyclop
+func parseTier(s string) (tierConfig, error) {
+	elems := strings.Split(s, ",")
+	c := tierConfig{Backend: elems[0]}
+
+	for _, elem := range elems[1:] {
+		key, value, hasValue := strings.Cut(elem, "=")
+		if !hasValue {
+			value = "true"
+		}
+
+		var err error
+		switch key {
+		case "path":
+			c.Path = value
+		case "packed":
+			c.Packed, err = strconv.ParseBool(value)
+		case "max-size":
+			c.MaxSize, err = strconv.Atoi(value)
+		case "address":
+			c.Address = value
+		case "user":
+			c.User = value
+		case "password":
+			c.Password = value
+		case "bucket":
+			c.Bucket = value
+		case "prefix":
+			c.Prefix = value
+		case "secure":
+			c.Secure, err = strconv.ParseBool(value)
+		case "checksum":
+			c.Checksum, err = strconv.ParseBool(value)
+		case "key-file":
+			c.KeyFile = value
+		case "encrypt-names":
+			c.EncryptNames, err = strconv.ParseBool(value)
+		case "write-back":
+			var d time.Duration
+			d, err = time.ParseDuration(value)
+			c.WriteBack = duration(d)
+		case "promote-on-read":
+			c.PromoteOnRead, err = strconv.ParseBool(value)
+		case "read-only":
+			c.ReadOnly, err = strconv.ParseBool(value)
+		default:
+			return tierConfig{}, fmt.Errorf("unknown option %q in tier %q", key, s)
+		}
+
+		if err != nil {
+			return tierConfig{}, fmt.Errorf("invalid option %q in tier %q: %w", key, s, err)
+		}
+	}
+
+	return c, nil
+}
+
+// checkTiers checks the tiers can be stacked in the given order.
+func checkTiers(configs []tierConfig) error {
+	// The chunks evicted from a tier with a max size are missing until they
+	// are read again from the tiers below, so the last one must keep them
+	if len(configs) > 0 && configs[len(configs)-1].MaxSize > 0 {
+		return fmt.Errorf("tier %q: max size cannot be set on the last tier", configs[len(configs)-1].Backend)
+	}
+
+	return nil
+}
+
+// closerFunc turns a function into an io.Closer.
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
+
+// build creates the tier, with a closer to release its resources (if any),
+// even on error.
+func (c tierConfig) build(ctx context.Context) (tier.Tier, io.Closer, error) {
+	s, closer, err := c.storage(ctx)
+	if err != nil {
+		return tier.Tier{}, closer, err
+	}
+
+	// Verify the chunks of the tier if requested
+	if c.Checksum {
+		s = checksum.NewDirectory(s)
+	}
+
+	// Encrypt the tier if there is a key
+	if c.KeyFile != "" {
+		if s, err = c.encrypt(s); err != nil {
+			return tier.Tier{}, closer, err
+		}
+	}
+
+	return tier.Tier{
+		Storage:       s,
+		WriteBack:     time.Duration(c.WriteBack),
+		PromoteOnRead: c.PromoteOnRead,
+		ReadOnly:      c.ReadOnly,
+	}, closer, nil
+}
+
+// encrypt wraps the storage to encrypt it with the key from the key file.
+func (c tierConfig) encrypt(s storage.Directory) (storage.Directory, error) {
+	key, err := os.ReadFile(c.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.EncryptNames {
+		return crypt.NewDirectory(s, key, crypt.WithEncryptedNames())
+	}
+	return crypt.NewDirectory(s, key)
+}
+
+//nolint:cyclop
+func (c tierConfig) storage(ctx context.Context) (storage.Directory, io.Closer, error) {
+	switch c.Backend {
+	case "mem":
+		return mem.NewDirectory(mem.WithMaxSize(c.MaxSize)), nil, nil
+	case "disk":
+		if c.Packed {
+			return disk.NewDirectory(c.Path, disk.WithPackedChunks()), nil, nil
+		}
+		return disk.NewDirectory(c.Path), nil, nil
+	case "kv":
+		db, err := bbolt.Open(c.Path, 0600, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		d, err := kv.NewDirectory(db)
+		return d, db, err
+	case "ftp":
+		conn, err := ftp.Dial(c.Address, ftp.DialWithContext(ctx))
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := conn.Login(c.User, c.Password); err != nil {
+			return nil, closerFunc(conn.Quit), err
+		}
+		return ftpstorage.NewDirectory(conn, c.Path), closerFunc(conn.Quit), nil
+	case "s3":
+		client, err := minio.New(c.Address, &minio.Options{
+			Creds:  credentials.NewStaticV4(c.User, c.Password, ""),
+			Secure: c.Secure,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return s3.NewDirectory(client, c.Bucket, c.Prefix), nil, nil
+	case "webdav":
+		d, err := webdav.NewDirectory(http.DefaultClient, c.Address)
+		return d, nil, err
+	default:
+		return nil, nil, fmt.Errorf("unknown backend %q", c.Backend)
+	}
+}
+
+// newBackend creates the tiered storage, with the closers of the tiers
+// resources that should be closed even on error.
+func newBackend(ctx context.Context, configs []tierConfig) (storage.Directory, []io.Closer, error) {
+	tiers := make([]tier.Tier, 0, len(configs))
+	closers := make([]io.Closer, 0, len(configs))
+	for _, c := range configs {
+		t, closer, err := c.build(ctx)
+		if closer != nil {
+			closers = append(closers, closer)
+		}
+		if err != nil {
+			return nil, closers, fmt.Errorf("tier %q: %w", c.Backend, err)
+		}
+
+		tiers = append(tiers, t)
+	}
+
+	d, err := tier.NewDirectory(ctx, tiers...)
+	return d, closers, err
+}
+
+func closeAll(closers []io.Closer) {
+	for _, c := range closers {
+		_ = c.Close()
+	}
+}
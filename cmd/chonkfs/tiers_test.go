@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestTiersSuite(t *testing.T) {
+	suite.Run(t, new(TiersSuite))
+}
+
+type TiersSuite struct {
+	suite.Suite
+}
+
+func (suite *TiersSuite) parseTiers(tiers ...string) []tierConfig {
+	configs := make([]tierConfig, 0, len(tiers))
+	for _, t := range tiers {
+		c, err := parseTier(t)
+		suite.Require().NoError(err)
+		configs = append(configs, c)
+	}
+	return configs
+}
+
+func (suite *TiersSuite) TestMaxSizeOnLastTier() {
+	suite.Require().Error(checkTiers(suite.parseTiers("mem,max-size=8")))
+	suite.Require().Error(checkTiers(suite.parseTiers("mem", "mem,max-size=8")))
+}
+
+func (suite *TiersSuite) TestMaxSizeAboveAnotherTier() {
+	suite.Require().NoError(checkTiers(suite.parseTiers("mem,max-size=8", "disk,path=/tmp")))
+	suite.Require().NoError(checkTiers(suite.parseTiers("mem")))
+}
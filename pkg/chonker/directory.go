@@ -8,6 +8,7 @@ import (
 	"log"
 	"maps"
 	"slices"
+	"time"
 
 	"github.com/lerenn/chonkfs/pkg/info"
 	"github.com/lerenn/chonkfs/pkg/storage"
@@ -24,12 +25,24 @@ func WithDirectoryLogger(logger *log.Logger) directoryOption {
 	}
 }
 
+// WithDirectoryPrefetch is an option to prefetch the given number of chunks
+// after the ones read sequentially in the files. Zero disables the prefetch.
+//
+//nolint:revive
+func WithDirectoryPrefetch(window int) directoryOption {
+	return func(dir *directory) {
+		dir.prefetchWindow = window
+	}
+}
+
 var _ Directory = (*directory)(nil)
 
 type directory struct {
-	storage storage.Directory
-	opts    []directoryOption
-	logger  *log.Logger
+	storage        storage.Directory
+	opts           []directoryOption
+	logger         *log.Logger
+	prefetchWindow int
+	streams        []Stream
 }
 
 // NewDirectory creates a new directory.
@@ -50,16 +63,38 @@ func NewDirectory(_ context.Context, d storage.Directory, opts ...directoryOptio
 }
 
 // GetAttributes returns the attributes of the directory.
-func (dir *directory) GetAttributes(_ context.Context) (DirectoryAttributes, error) {
-	return DirectoryAttributes{}, nil
+func (dir *directory) GetAttributes(ctx context.Context) (DirectoryAttributes, error) {
+	dirInfo, err := dir.storage.GetInfo(ctx)
+	if err != nil {
+		return DirectoryAttributes{}, fmt.Errorf("%w: %w", ErrChonker, err)
+	}
+
+	return DirectoryAttributes{Attributes: dirInfo.Attributes}, nil
 }
 
-// SetAttributes sets the attributes of the directory.
-func (dir *directory) SetAttributes(_ context.Context, _ DirectoryAttributes) error {
+// SetAttributes sets the mode, owner and times of the directory, the change
+// time being set to now.
+func (dir *directory) SetAttributes(ctx context.Context, attr DirectoryAttributes) error {
+	attr.Ctime = time.Now()
+	if err := dir.storage.SetAttributes(ctx, attr.Attributes); err != nil {
+		return fmt.Errorf("%w: %w", ErrChonker, err)
+	}
 	return nil
 }
 
+// newAttributes returns the attributes of a new file or directory, with all
+// its times set to now.
+func newAttributes() info.Attributes {
+	now := time.Now()
+	return info.Attributes{Atime: now, Mtime: now, Ctime: now}
+}
+
 func (dir *directory) checkIfFileOrDirectoryAlreadyExists(ctx context.Context, name string) error {
+	// Check in streams
+	if _, ok := dir.getStream(name); ok {
+		return ErrAlreadyExists
+	}
+
 	// Check in directories
 	_, err := dir.storage.GetDirectory(ctx, name)
 	if err != nil && !errors.Is(err, storage.ErrDirectoryNotFound) {
@@ -90,10 +125,12 @@ func (dir *directory) CreateDirectory(ctx context.Context, name string) (Directo
 	nd, err := dir.storage.CreateDirectory(ctx, name)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %w", ErrChonker, err)
+	} else if err := nd.SetAttributes(ctx, newAttributes()); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrChonker, err)
 	}
 
 	// Create a new directory
-	d, err := NewDirectory(ctx, nd, dir.opts...)
+	d, err := NewDirectory(ctx, nd, dir.childOptions()...)
 	if err != nil {
 		return nil, err
 	}
@@ -103,6 +140,11 @@ func (dir *directory) CreateDirectory(ctx context.Context, name string) (Directo
 
 // GetDirectory returns a child directory of the directory.
 func (dir *directory) GetDirectory(ctx context.Context, name string) (Directory, error) {
+	// Check if this is a stream
+	if s, ok := dir.getStream(name); ok {
+		return &streamDirectory{parent: dir, stream: s}, nil
+	}
+
 	// Check if this is not already a file
 	_, err := dir.storage.GetFile(ctx, name)
 	if err != nil && !errors.Is(err, storage.ErrFileNotFound) && !errors.Is(err, storage.ErrIsDirectory) {
@@ -120,11 +162,18 @@ func (dir *directory) GetDirectory(ctx context.Context, name string) (Directory,
 		return nil, fmt.Errorf("%w: %w", ErrChonker, err)
 	}
 
-	return NewDirectory(ctx, d, dir.opts...)
+	return NewDirectory(ctx, d, dir.childOptions()...)
 }
 
 // GetFile returns a child file of the directory.
 func (dir *directory) GetFile(ctx context.Context, name string) (File, error) {
+	// Check if this is a stream
+	if _, ok := dir.getStream(name); ok {
+		return nil, fmt.Errorf("%w: %w: %q", ErrChonker, storage.ErrIsDirectory, name)
+	} else if dir.isStreamFile(name) {
+		return nil, ErrNoEntry
+	}
+
 	// Get and check if it exists
 	f, err := dir.storage.GetFile(ctx, name)
 	if err != nil {
@@ -140,7 +189,7 @@ func (dir *directory) GetFile(ctx context.Context, name string) (File, error) {
 		return nil, fmt.Errorf("%w: %w", ErrChonker, err)
 	}
 
-	return NewFile(ctx, f, info.ChunkSize)
+	return NewFile(ctx, f, info.ChunkSize, dir.fileOptions()...)
 }
 
 // CreateFile creates a child file of the directory.
@@ -154,13 +203,20 @@ func (dir *directory) CreateFile(ctx context.Context, name string, chunkSize int
 	sf, err := dir.storage.CreateFile(ctx, name, info.File{
 		ChunkSize: chunkSize,
 	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrChonker, err)
+	} else if err := sf.SetAttributes(ctx, newAttributes()); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrChonker, err)
+	}
+
+	// Get the chunk size, as the storage can choose another one
+	fileInfo, err := sf.GetInfo(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %w", ErrChonker, err)
 	}
 
 	// Create file
-	f, err := NewFile(ctx, sf, chunkSize,
-		WithFileLogger(dir.logger))
+	f, err := NewFile(ctx, sf, fileInfo.ChunkSize, dir.fileOptions()...)
 	if err != nil {
 		return nil, err
 	}
@@ -168,13 +224,34 @@ func (dir *directory) CreateFile(ctx context.Context, name string, chunkSize int
 	return f, nil
 }
 
+// childOptions returns the options of the child directories, that don't have
+// the streams of their parent.
+func (dir *directory) childOptions() []directoryOption {
+	return append(slices.Clone(dir.opts), WithDirectoryStreams())
+}
+
+func (dir *directory) fileOptions() []fileOption {
+	return []fileOption{
+		WithFileLogger(dir.logger),
+		WithFilePrefetch(dir.prefetchWindow),
+	}
+}
+
 // RemoveDirectory removes a child directory of the directory.
 func (dir *directory) RemoveDirectory(ctx context.Context, name string) error {
+	if _, ok := dir.getStream(name); ok {
+		return fmt.Errorf("%w: %q is a stream", ErrNotPermitted, name)
+	}
+
 	return dir.storage.RemoveDirectory(ctx, name)
 }
 
 // RemoveFile removes a child file of the directory.
 func (dir *directory) RemoveFile(ctx context.Context, name string) error {
+	if dir.isStreamFile(name) {
+		return ErrNoEntry
+	}
+
 	return dir.storage.RemoveFile(ctx, name)
 }
 
@@ -185,7 +262,8 @@ func (dir *directory) ListFiles(ctx context.Context) ([]string, error) {
 		return nil, fmt.Errorf("%w: %w", ErrChonker, err)
 	}
 
-	return slices.Collect(maps.Keys(m)), nil
+	// Hide the files storing the streams
+	return slices.DeleteFunc(slices.Collect(maps.Keys(m)), dir.isStreamFile), nil
 }
 
 // RenameFile renames a child file of the directory.
@@ -196,7 +274,17 @@ func (dir *directory) RenameFile(
 	newName string,
 	noReplace bool,
 ) error {
-	err := dir.storage.RenameFile(ctx, name, newParent.(*directory).storage, newName, noReplace)
+	// Check that the new parent and the entries are not part of streams
+	np, ok := newParent.(*directory)
+	if !ok {
+		return fmt.Errorf("%w: %q is in a stream", ErrNotPermitted, newName)
+	} else if dir.isStreamFile(name) || np.isStreamFile(newName) {
+		return ErrNoEntry
+	} else if _, ok := np.getStream(newName); ok {
+		return ErrAlreadyExists
+	}
+
+	err := dir.storage.RenameFile(ctx, name, np.storage, newName, noReplace)
 	switch {
 	case err == nil:
 		return nil
@@ -216,7 +304,13 @@ func (dir *directory) ListDirectories(ctx context.Context) ([]string, error) {
 		return nil, fmt.Errorf("%w: %w", ErrChonker, err)
 	}
 
-	return slices.Collect(maps.Keys(m)), nil
+	// Add the streams
+	dirs := slices.Collect(maps.Keys(m))
+	for _, s := range dir.streams {
+		dirs = append(dirs, s.Name)
+	}
+
+	return dirs, nil
 }
 
 // RenameDirectory renames a child directory of the directory.
@@ -227,7 +321,17 @@ func (dir *directory) RenameDirectory(
 	newName string,
 	noReplace bool,
 ) error {
-	err := dir.storage.RenameDirectory(ctx, name, newParent.(*directory).storage, newName, noReplace)
+	// Check that the new parent and the entries are not part of streams
+	np, ok := newParent.(*directory)
+	if !ok {
+		return fmt.Errorf("%w: %q is in a stream", ErrNotPermitted, newName)
+	} else if _, ok := dir.getStream(name); ok {
+		return fmt.Errorf("%w: %q is a stream", ErrNotPermitted, name)
+	} else if _, ok := np.getStream(newName); ok {
+		return ErrAlreadyExists
+	}
+
+	err := dir.storage.RenameDirectory(ctx, name, np.storage, newName, noReplace)
 	switch {
 	case err == nil:
 		return nil
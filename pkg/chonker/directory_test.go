@@ -3,7 +3,9 @@ package chonker
 import (
 	"context"
 	"testing"
+	"time"
 
+	"github.com/lerenn/chonkfs/pkg/storage"
 	"github.com/lerenn/chonkfs/pkg/storage/mem"
 	"github.com/stretchr/testify/suite"
 )
@@ -64,3 +66,67 @@ func (suite *DirectorySuite) TestGetFile() {
 	suite.Require().NoError(err)
 	suite.Require().NotNil(f)
 }
+
+func (suite *DirectorySuite) TestAttributes() {
+	// Create a directory
+	d, err := NewDirectory(context.Background(), mem.NewDirectory())
+	suite.Require().NoError(err)
+	before := time.Now()
+	_, err = d.CreateDirectory(context.Background(), "DirA")
+	suite.Require().NoError(err)
+
+	// Check the times are set on creation
+	dir, err := d.GetDirectory(context.Background(), "DirA")
+	suite.Require().NoError(err)
+	attr, err := dir.GetAttributes(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().False(attr.Mtime.Before(before))
+
+	// Set the mode, owner and times
+	attr.Mode, attr.UID, attr.GID = 0o750, 1000, 100
+	attr.Mtime = time.Unix(1700000001, 0).UTC()
+	suite.Require().NoError(dir.SetAttributes(context.Background(), attr))
+
+	dir, err = d.GetDirectory(context.Background(), "DirA")
+	suite.Require().NoError(err)
+	set, err := dir.GetAttributes(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal(uint32(0o750), set.Mode)
+	suite.Require().Equal(uint32(1000), set.UID)
+	suite.Require().Equal(attr.Mtime, set.Mtime)
+	suite.Require().False(set.Ctime.Before(attr.Atime))
+}
+
+func (suite *DirectorySuite) TestXattrsNotSupported() {
+	// Create a directory on a storage without extended attributes
+	d, err := NewDirectory(context.Background(), noXattrDirectory{mem.NewDirectory()})
+	suite.Require().NoError(err)
+
+	// Check the extended attributes are not supported
+	_, err = d.GetXattr(context.Background(), "user.a")
+	suite.Require().ErrorIs(err, ErrNotSupported)
+	err = d.SetXattr(context.Background(), "user.a", []byte("A"), XattrOptions{})
+	suite.Require().ErrorIs(err, ErrNotSupported)
+}
+
+// noXattrDirectory is a directory whose storage cannot store the extended
+// attributes.
+type noXattrDirectory struct {
+	storage.Directory
+}
+
+func (noXattrDirectory) GetXattr(_ context.Context, _ string) ([]byte, error) {
+	return nil, storage.ErrXattrNotSupported
+}
+
+func (noXattrDirectory) ListXattrs(_ context.Context) ([]string, error) {
+	return nil, storage.ErrXattrNotSupported
+}
+
+func (noXattrDirectory) SetXattr(_ context.Context, _ string, _ []byte) error {
+	return storage.ErrXattrNotSupported
+}
+
+func (noXattrDirectory) RemoveXattr(_ context.Context, _ string) error {
+	return storage.ErrXattrNotSupported
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"log"
 	"syscall"
+
+	"github.com/lerenn/chonkfs/pkg/storage"
 )
 
 var (
@@ -16,6 +18,16 @@ var (
 	ErrAlreadyExists = fmt.Errorf("%w: already exists", ErrChonker)
 	// ErrNoEntry happens when the requested entry doesn't exist.
 	ErrNoEntry = fmt.Errorf("%w: no entry", ErrChonker)
+	// ErrNoData happens when there is no data or hole after the requested offset.
+	ErrNoData = fmt.Errorf("%w: no data after offset", ErrChonker)
+	// ErrNotPermitted happens when the operation is not permitted on the entry.
+	ErrNotPermitted = fmt.Errorf("%w: operation not permitted", ErrChonker)
+	// ErrNoAttribute happens when the requested extended attribute doesn't exist.
+	ErrNoAttribute = fmt.Errorf("%w: no such attribute", ErrChonker)
+	// ErrNotSupported happens when the operation is not supported by the storage.
+	ErrNotSupported = fmt.Errorf("%w: operation not supported", ErrChonker)
+	// ErrInterrupted happens when the operation is cancelled before its end.
+	ErrInterrupted = fmt.Errorf("%w: interrupted", ErrChonker)
 )
 
 // ToSyscallErrnoOptions is the options for ToSyscallErrno.
@@ -30,6 +42,16 @@ func ToSyscallErrno(err error, opts ToSyscallErrnoOptions) syscall.Errno {
 		return syscall.Errno(0)
 	}
 
+	// Check errors from storage that have a specific meaning
+	switch {
+	case errors.Is(err, storage.ErrChunkFetchTimeout):
+		return syscall.EAGAIN
+	case errors.Is(err, storage.ErrChunkFetchFailed), errors.Is(err, storage.ErrChunkCorrupted):
+		return syscall.EIO
+	case errors.Is(err, storage.ErrReadOnly):
+		return syscall.EROFS
+	}
+
 	// Check that the error is wrapped by ErrChonker
 	if !errors.Is(err, ErrChonker) {
 		// Default to EIO
@@ -49,6 +71,16 @@ func ToSyscallErrno(err error, opts ToSyscallErrnoOptions) syscall.Errno {
 		return syscall.EEXIST
 	case errors.Is(err, ErrNoEntry):
 		return syscall.ENOENT
+	case errors.Is(err, ErrNoData):
+		return syscall.ENXIO
+	case errors.Is(err, ErrNotPermitted):
+		return syscall.EPERM
+	case errors.Is(err, ErrNoAttribute):
+		return syscall.ENODATA
+	case errors.Is(err, ErrNotSupported):
+		return syscall.ENOTSUP
+	case errors.Is(err, ErrInterrupted):
+		return syscall.EINTR
 	default:
 		return syscall.EIO
 	}
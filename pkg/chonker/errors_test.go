@@ -0,0 +1,48 @@
+package chonker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+
+	"github.com/lerenn/chonkfs/pkg/storage"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestErrorsSuite(t *testing.T) {
+	suite.Run(t, new(ErrorsSuite))
+}
+
+type ErrorsSuite struct {
+	suite.Suite
+}
+
+func (suite *ErrorsSuite) TestToSyscallErrno() {
+	cases := []struct {
+		err   error
+		errno syscall.Errno
+	}{
+		{nil, syscall.Errno(0)},
+		{errors.New("unknown"), syscall.EIO},
+		{storage.ErrChunkFetchTimeout, syscall.EAGAIN},
+		{storage.ErrChunkFetchFailed, syscall.EIO},
+		{storage.ErrChunkCorrupted, syscall.EIO},
+		{fmt.Errorf("%w: file", storage.ErrReadOnly), syscall.EROFS},
+		{ErrNotDirectory, syscall.ENOTDIR},
+		{ErrAlreadyExists, syscall.EEXIST},
+		{ErrNoEntry, syscall.ENOENT},
+		{ErrNoData, syscall.ENXIO},
+		{ErrNotPermitted, syscall.EPERM},
+		{ErrNoAttribute, syscall.ENODATA},
+		{ErrNotSupported, syscall.ENOTSUP},
+		{ErrInterrupted, syscall.EINTR},
+		{interruptedError(fmt.Errorf("fetch: %w", context.Canceled)), syscall.EINTR},
+		{ErrChonker, syscall.EIO},
+	}
+
+	for _, c := range cases {
+		suite.Require().Equal(c.errno, ToSyscallErrno(c.err, ToSyscallErrnoOptions{}), "error: %v", c.err)
+	}
+}
@@ -2,9 +2,14 @@ package chonker
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"log"
+	"sync"
+	"time"
 
+	"github.com/lerenn/chonkfs/pkg/info"
 	"github.com/lerenn/chonkfs/pkg/storage"
 )
 
@@ -21,12 +26,29 @@ func WithFileLogger(logger *log.Logger) fileOption {
 	}
 }
 
+// WithFilePrefetch is an option to prefetch the given number of chunks after
+// the ones read sequentially. Zero disables the prefetch.
+//
+//nolint:revive
+func WithFilePrefetch(window int) fileOption {
+	return func(fl *file) {
+		fl.prefetchWindow = window
+	}
+}
+
 type file struct {
 	storage   storage.File
 	chunkSize int
 
-	opts   []fileOption
-	logger *log.Logger
+	opts           []fileOption
+	logger         *log.Logger
+	prefetchWindow int
+	prefetcher     *prefetcher
+
+	// modified is the time of the last modification, kept in memory until it
+	// is saved with the other attributes, to not save them on every write.
+	modifiedMutex sync.Mutex
+	modified      time.Time
 }
 
 // NewFile creates a new file.
@@ -49,55 +71,132 @@ func NewFile(
 		opt(f)
 	}
 
+	// Create the prefetcher if needed
+	if f.prefetchWindow > 0 {
+		f.prefetcher = newPrefetcher(s, chunkSize, f.prefetchWindow, f.logger)
+	}
+
 	return f, nil
 }
 
 // GetAttributes returns the attributes of the file.
 func (f *file) GetAttributes(ctx context.Context) (FileAttributes, error) {
-	info, err := f.storage.GetInfo(ctx)
+	fileInfo, err := f.storage.GetInfo(ctx)
 	if err != nil {
 		return FileAttributes{}, err
 	}
 
+	// Use the modification time not saved yet
+	f.modifiedMutex.Lock()
+	if !f.modified.IsZero() {
+		fileInfo.Mtime, fileInfo.Ctime = f.modified, f.modified
+	}
+	f.modifiedMutex.Unlock()
+
 	return FileAttributes{
-		Size: info.Size,
+		Attributes:    fileInfo.Attributes,
+		Size:          fileInfo.Size,
+		AllocatedSize: fileInfo.AllocatedSize,
 	}, nil
 }
 
-// SetAttributes sets the attributes of the file.
-func (f *file) SetAttributes(_ context.Context, _ FileAttributes) error {
-	// Nothing to do (yet)
+// SetAttributes sets the mode, owner and times of the file, the change time
+// being set to now. The sizes are ignored, as the file is resized by writing
+// or truncating it.
+func (f *file) SetAttributes(ctx context.Context, attr FileAttributes) error {
+	f.modifiedMutex.Lock()
+	defer f.modifiedMutex.Unlock()
+
+	attr.Ctime = time.Now()
+	if err := f.storage.SetAttributes(ctx, attr.Attributes); err != nil {
+		return err
+	}
+
+	// The modification time is saved with the attributes
+	f.modified = time.Time{}
 	return nil
 }
 
-// Read reads the file at the given offset.
-func (f *file) Read(ctx context.Context, dest []byte, off int) ([]byte, error) {
-	return f.readAccrossChunks(ctx, dest, off)
+// touch sets the modification and change times of the file to now, in memory
+// until they are saved on sync or with the attributes. The access time is not
+// updated on read, like with the noatime mount option.
+func (f *file) touch() {
+	f.modifiedMutex.Lock()
+	defer f.modifiedMutex.Unlock()
+
+	f.modified = time.Now()
 }
 
-// TODO: Refactor this function
-//
-//nolint:cyclop
-func (f *file) readAccrossChunks(ctx context.Context, dest []byte, off int) ([]byte, error) {
+// saveModified saves the modification time kept in memory, if any.
+func (f *file) saveModified(ctx context.Context) error {
+	f.modifiedMutex.Lock()
+	defer f.modifiedMutex.Unlock()
+
+	if f.modified.IsZero() {
+		return nil
+	}
+
+	fileInfo, err := f.storage.GetInfo(ctx)
+	if err != nil {
+		return err
+	}
+
+	fileInfo.Mtime, fileInfo.Ctime = f.modified, f.modified
+	if err := f.storage.SetAttributes(ctx, fileInfo.Attributes); err != nil {
+		return err
+	}
+
+	f.modified = time.Time{}
+	return nil
+}
+
+// interruptedError turns an error from a cancelled storage operation, like
+// a chunk fetch whose request has been interrupted, into a chonker error.
+func interruptedError(err error) error {
+	if errors.Is(err, context.Canceled) {
+		return fmt.Errorf("%w: %w", ErrInterrupted, err)
+	}
+	return err
+}
+
+// Read reads the file at the given offset.
+func (f *file) Read(ctx context.Context, dest []byte, off int) ([]byte, error) {
 	// Get info from the underlayer
-	info, err := f.storage.GetInfo(ctx)
+	fileInfo, err := f.storage.GetInfo(ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	data, err := f.readAccrossChunks(ctx, fileInfo, dest, off)
+	if err != nil {
+		return nil, interruptedError(err)
+	}
+
+	// Signal the read chunks to the prefetcher
+	if f.prefetcher != nil && len(data) > 0 {
+		f.prefetcher.read(off/f.chunkSize, (off+len(data)-1)/f.chunkSize, fileInfo.ChunksCount)
+	}
+
+	return data, nil
+}
+
+// TODO: Refactor this function
+//
+//nolint:cyclop
+func (f *file) readAccrossChunks(ctx context.Context, fileInfo info.File, dest []byte, off int) ([]byte, error) {
 	// Check if the offset is valid
 	chunkNb := off / f.chunkSize
-	if chunkNb >= info.ChunksCount {
+	if chunkNb >= fileInfo.ChunksCount {
 		return []byte{}, nil
-	} else if chunkNb == info.ChunksCount-1 {
-		if off%f.chunkSize >= info.LastChunkSize {
+	} else if chunkNb == fileInfo.ChunksCount-1 {
+		if off%f.chunkSize >= fileInfo.LastChunkSize {
 			return []byte{}, nil
 		}
 	}
 
 	// Loop across chunks
 	read := 0
-	for ; read < len(dest) && chunkNb < info.ChunksCount; chunkNb++ {
+	for ; read < len(dest) && chunkNb < fileInfo.ChunksCount; chunkNb++ {
 		if read == 0 {
 			r, err := f.storage.ReadChunk(ctx, chunkNb, dest, off%f.chunkSize)
 			if err != nil {
@@ -123,6 +222,8 @@ func (f *file) readAccrossChunks(ctx context.Context, dest []byte, off int) ([]b
 
 // Write writes the data at the given offset.
 func (f *file) Write(ctx context.Context, data []byte, off int, opts WriteOptions) (written int, err error) {
+	defer func() { err = interruptedError(err) }()
+
 	// Check if there is enough space, and allocate what's missing
 	if err := f.resizeChunks(ctx, off+len(data)); err != nil {
 		return 0, err
@@ -134,6 +235,7 @@ func (f *file) Write(ctx context.Context, data []byte, off int, opts WriteOption
 			return 0, err
 		}
 
+		f.touch()
 		return len(data), nil
 	}
 
@@ -150,6 +252,9 @@ func (f *file) Write(ctx context.Context, data []byte, off int, opts WriteOption
 		}
 	}
 
+	// Update the times
+	f.touch()
+
 	return written, nil
 }
 
@@ -200,6 +305,7 @@ func (f *file) Truncate(ctx context.Context, newSize int) error {
 		}
 	}
 
+	f.touch()
 	return nil
 }
 
@@ -301,8 +407,79 @@ func (f *file) writeAccrossChunks(ctx context.Context, data []byte, off int) (wr
 	return written, nil
 }
 
-// Sync saves the file to the storage.
-func (f *file) Sync(_ context.Context) error {
-	// TODO: Save to a embedded backend if the option for direct io is not set
+// SeekData returns the offset of the first data at or after the given offset.
+func (f *file) SeekData(ctx context.Context, off int) (int, error) {
+	return f.seekChunk(ctx, off, true)
+}
+
+// SeekHole returns the offset of the first hole at or after the given offset,
+// the end of the file being considered as a hole.
+func (f *file) SeekHole(ctx context.Context, off int) (int, error) {
+	return f.seekChunk(ctx, off, false)
+}
+
+func (f *file) seekChunk(ctx context.Context, off int, hasData bool) (int, error) {
+	info, err := f.storage.GetInfo(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Check if the offset is in the file
+	if off < 0 || off >= info.Size {
+		return 0, fmt.Errorf("%w: %d", ErrNoData, off)
+	}
+
+	// Look for the first chunk with or without data
+	for chunkNb := off / f.chunkSize; chunkNb < info.ChunksCount; chunkNb++ {
+		chunkHasData, err := f.chunkHasData(ctx, chunkNb)
+		if err != nil {
+			return 0, err
+		}
+
+		if chunkHasData == hasData {
+			return max(off, chunkNb*f.chunkSize), nil
+		}
+	}
+
+	// There is only the end of the file left
+	if hasData {
+		return 0, fmt.Errorf("%w: %d", ErrNoData, off)
+	}
+	return info.Size, nil
+}
+
+func (f *file) chunkHasData(ctx context.Context, index int) (bool, error) {
+	// If the storage cannot tell, consider there is data
+	sf, ok := f.storage.(storage.SparseFile)
+	if !ok {
+		return true, nil
+	}
+
+	// Chunks that are not present are holes
+	hasData, err := sf.HasChunkData(ctx, index)
+	if errors.Is(err, storage.ErrChunkNotFound) {
+		return false, nil
+	}
+	return hasData, err
+}
+
+// Stop cancels the prefetch of the file, once it is released or removed.
+func (f *file) Stop() {
+	if f.prefetcher != nil {
+		f.prefetcher.close()
+	}
+}
+
+// Sync saves the modification time, and the file to the storage if the
+// storage keeps data before writing it.
+func (f *file) Sync(ctx context.Context) error {
+	if err := f.saveModified(ctx); err != nil {
+		return err
+	}
+
+	if s, ok := f.storage.(storage.Syncer); ok {
+		return s.Sync(ctx)
+	}
+
 	return nil
 }
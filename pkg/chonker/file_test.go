@@ -3,6 +3,7 @@ package chonker
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/lerenn/chonkfs/pkg/storage/mem"
 	"github.com/stretchr/testify/suite"
@@ -78,3 +79,135 @@ func (suite *FileSuite) TestReadWithBiggerBufferThanData() {
 	suite.Require().Equal([]byte("1234"), readBuf[:len(buf)])
 	suite.Require().Equal(len(buf), len(readBuf))
 }
+
+func (suite *FileSuite) TestSeekDataAndHole() {
+	f, err := suite.Directory.CreateFile(context.Background(), "File-TestSeekDataAndHole.txt", 4)
+	suite.Require().NoError(err)
+
+	// Write after two empty chunks
+	_, err = f.Write(context.Background(), []byte("123"), 9, WriteOptions{})
+	suite.Require().NoError(err)
+
+	// Check data is found on the last chunk
+	off, err := f.SeekData(context.Background(), 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal(8, off)
+	off, err = f.SeekData(context.Background(), 10)
+	suite.Require().NoError(err)
+	suite.Require().Equal(10, off)
+
+	// Check holes are found at the start and at the end
+	off, err = f.SeekHole(context.Background(), 1)
+	suite.Require().NoError(err)
+	suite.Require().Equal(1, off)
+	off, err = f.SeekHole(context.Background(), 8)
+	suite.Require().NoError(err)
+	suite.Require().Equal(12, off)
+
+	// Check there is nothing after the end
+	_, err = f.SeekData(context.Background(), 12)
+	suite.Require().ErrorIs(err, ErrNoData)
+	_, err = f.SeekHole(context.Background(), 12)
+	suite.Require().ErrorIs(err, ErrNoData)
+}
+
+func (suite *FileSuite) TestAttributes() {
+	before := time.Now()
+	f, err := suite.Directory.CreateFile(context.Background(), "File-TestAttributes.txt", 4)
+	suite.Require().NoError(err)
+
+	// Check the times are set on creation
+	attr, err := f.GetAttributes(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().False(attr.Mtime.Before(before))
+	suite.Require().Equal(attr.Mtime, attr.Atime)
+	suite.Require().Equal(attr.Mtime, attr.Ctime)
+
+	// Set the mode, owner and times
+	attr.Mode, attr.UID, attr.GID = 0o640, 1000, 100
+	attr.Atime = time.Unix(1700000000, 0).UTC()
+	attr.Mtime = time.Unix(1700000001, 0).UTC()
+	suite.Require().NoError(f.SetAttributes(context.Background(), attr))
+
+	f, err = suite.Directory.GetFile(context.Background(), "File-TestAttributes.txt")
+	suite.Require().NoError(err)
+	set, err := f.GetAttributes(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal(uint32(0o640), set.Mode)
+	suite.Require().Equal(uint32(1000), set.UID)
+	suite.Require().Equal(uint32(100), set.GID)
+	suite.Require().Equal(attr.Atime, set.Atime)
+	suite.Require().Equal(attr.Mtime, set.Mtime)
+	suite.Require().True(set.Ctime.After(attr.Mtime))
+
+	// Check a write updates the modification time only
+	_, err = f.Write(context.Background(), []byte("chonk"), 0, WriteOptions{})
+	suite.Require().NoError(err)
+	written, err := f.GetAttributes(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal(attr.Atime, written.Atime)
+	suite.Require().True(written.Mtime.After(attr.Mtime))
+	suite.Require().Equal(written.Mtime, written.Ctime)
+	suite.Require().Equal(uint32(0o640), written.Mode)
+}
+
+func (suite *FileSuite) TestModificationTimeSavedOnSync() {
+	s := mem.NewDirectory()
+	d, err := NewDirectory(context.Background(), s)
+	suite.Require().NoError(err)
+	f, err := d.CreateFile(context.Background(), "File", 4)
+	suite.Require().NoError(err)
+	sf, err := s.GetFile(context.Background(), "File")
+	suite.Require().NoError(err)
+	created, err := sf.GetInfo(context.Background())
+	suite.Require().NoError(err)
+
+	// Check a write changes the times without saving them
+	_, err = f.Write(context.Background(), []byte("chonk"), 0, WriteOptions{})
+	suite.Require().NoError(err)
+	written, err := f.GetAttributes(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().True(written.Mtime.After(created.Mtime))
+	stored, err := sf.GetInfo(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal(created.Mtime, stored.Mtime)
+
+	// Check they are saved on sync
+	suite.Require().NoError(f.Sync(context.Background()))
+	stored, err = sf.GetInfo(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal(written.Mtime, stored.Mtime)
+	suite.Require().Equal(written.Ctime, stored.Ctime)
+}
+
+func (suite *FileSuite) TestXattrs() {
+	f, err := suite.Directory.CreateFile(context.Background(), "File-TestXattrs.txt", 4)
+	suite.Require().NoError(err)
+
+	// Check a missing attribute
+	_, err = f.GetXattr(context.Background(), "user.a")
+	suite.Require().ErrorIs(err, ErrNoAttribute)
+	err = f.SetXattr(context.Background(), "user.a", []byte("A"), XattrOptions{Replace: true})
+	suite.Require().ErrorIs(err, ErrNoAttribute)
+	suite.Require().ErrorIs(f.RemoveXattr(context.Background(), "user.a"), ErrNoAttribute)
+
+	// Create an attribute
+	suite.Require().NoError(f.SetXattr(context.Background(), "user.a", []byte("A"), XattrOptions{Create: true}))
+	err = f.SetXattr(context.Background(), "user.a", []byte("B"), XattrOptions{Create: true})
+	suite.Require().ErrorIs(err, ErrAlreadyExists)
+
+	// Replace it
+	suite.Require().NoError(f.SetXattr(context.Background(), "user.a", []byte("B"), XattrOptions{Replace: true}))
+	value, err := f.GetXattr(context.Background(), "user.a")
+	suite.Require().NoError(err)
+	suite.Require().Equal([]byte("B"), value)
+	names, err := f.ListXattrs(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal([]string{"user.a"}, names)
+
+	// Remove it
+	suite.Require().NoError(f.RemoveXattr(context.Background(), "user.a"))
+	names, err = f.ListXattrs(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Empty(names)
+}
@@ -2,6 +2,8 @@ package chonker
 
 import (
 	"context"
+
+	"github.com/lerenn/chonkfs/pkg/info"
 )
 
 // Directory is the structure of chonker regrouping the files.
@@ -11,6 +13,13 @@ type Directory interface {
 	GetAttributes(ctx context.Context) (DirectoryAttributes, error)
 	SetAttributes(ctx context.Context, attr DirectoryAttributes) error
 
+	// Extended attributes (optional)
+
+	GetXattr(ctx context.Context, name string) ([]byte, error)
+	ListXattrs(ctx context.Context) ([]string, error)
+	SetXattr(ctx context.Context, name string, value []byte, opts XattrOptions) error
+	RemoveXattr(ctx context.Context, name string) error
+
 	// Children directories
 
 	CreateDirectory(ctx context.Context, name string) (Directory, error)
@@ -35,21 +44,50 @@ type File interface {
 	GetAttributes(ctx context.Context) (FileAttributes, error)
 	SetAttributes(ctx context.Context, attr FileAttributes) error
 
+	// Extended attributes (optional)
+
+	GetXattr(ctx context.Context, name string) ([]byte, error)
+	ListXattrs(ctx context.Context) ([]string, error)
+	SetXattr(ctx context.Context, name string, value []byte, opts XattrOptions) error
+	RemoveXattr(ctx context.Context, name string) error
+
 	// Data
 
 	Read(ctx context.Context, dest []byte, off int) ([]byte, error)
 	Sync(ctx context.Context) error
 	Truncate(ctx context.Context, size int) error
 	Write(ctx context.Context, data []byte, off int, opts WriteOptions) (written int, errno error)
+
+	// Sparse
+
+	SeekData(ctx context.Context, off int) (int, error)
+	SeekHole(ctx context.Context, off int) (int, error)
+
+	// Release
+
+	Stop()
 }
 
 // DirectoryAttributes contains the directory attributes.
 type DirectoryAttributes struct {
+	info.Attributes
 }
 
 // FileAttributes contains the file attributes.
 type FileAttributes struct {
-	Size int
+	info.Attributes
+
+	Size          int
+	AllocatedSize int
+}
+
+// XattrOptions represents the options usable for setting an extended
+// attribute.
+type XattrOptions struct {
+	// Create fails if the attribute already exists.
+	Create bool
+	// Replace fails if the attribute doesn't exist.
+	Replace bool
 }
 
 // WriteOptions represents the options usable for writing.
@@ -0,0 +1,101 @@
+package chonker
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+// prefetcher reads in the background the chunks following a sequential
+// access, so they are ready in the storage (the upperlayer of a layer for
+// example) when they are actually read.
+type prefetcher struct {
+	storage   storage.File
+	chunkSize int
+	window    int
+	logger    *log.Logger
+
+	mutex     sync.Mutex
+	lastChunk int
+	nextChunk int
+	ctx       context.Context //nolint:containedctx
+	cancel    context.CancelFunc
+}
+
+func newPrefetcher(s storage.File, chunkSize, window int, logger *log.Logger) *prefetcher {
+	return &prefetcher{
+		storage:   s,
+		chunkSize: chunkSize,
+		window:    window,
+		logger:    logger,
+		lastChunk: -1,
+	}
+}
+
+// read signals that the chunks from first to last of the file with the given
+// number of chunks have been read, starting the prefetch of the next chunks if
+// the access is sequential, or stopping it if it is not.
+func (p *prefetcher) read(first, last, chunksCount int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	// Check if the access is sequential
+	sequential := first == p.lastChunk || first == p.lastChunk+1
+	p.lastChunk = last
+	if !sequential {
+		p.stop()
+		return
+	}
+
+	// Get the chunks that are not prefetched yet
+	start, end := max(p.nextChunk, last+1), min(last+p.window, chunksCount-1)
+	if start > end {
+		return
+	}
+	p.nextChunk = end + 1
+
+	// Prefetch them in the background, cancelled together when the access
+	// is not sequential anymore
+	if p.cancel == nil {
+		p.ctx, p.cancel = context.WithCancel(context.Background())
+	}
+	go p.prefetch(p.ctx, start, end)
+}
+
+// close cancels the running prefetches, when the file is released or removed.
+func (p *prefetcher) close() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.stop()
+	p.lastChunk = -1
+}
+
+// stop cancels the running prefetches. The prefetcher must be locked.
+func (p *prefetcher) stop() {
+	if p.cancel != nil {
+		p.cancel()
+		p.ctx, p.cancel = nil, nil
+	}
+	p.nextChunk = 0
+}
+
+func (p *prefetcher) prefetch(ctx context.Context, start, end int) {
+	// Don't go past the end of the file, if it has been truncated since
+	fileInfo, err := p.storage.GetInfo(ctx)
+	if err != nil {
+		p.logger.Printf("Prefetch(chunks=%d-%d): %v\n", start, end, err)
+		return
+	}
+	end = min(end, fileInfo.ChunksCount-1)
+
+	buf := make([]byte, p.chunkSize)
+	for index := start; index <= end && ctx.Err() == nil; index++ {
+		if _, err := p.storage.ReadChunk(ctx, index, buf, 0); err != nil {
+			p.logger.Printf("Prefetch(chunk=%d): %v\n", index, err)
+			return
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package chonker
+
+import (
+	"context"
+	"io"
+	"log"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+	"github.com/lerenn/chonkfs/pkg/storage/mem"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestPrefetcherSuite(t *testing.T) {
+	suite.Run(t, new(PrefetcherSuite))
+}
+
+// readsRecorder is a file storage keeping track of the read chunks.
+type readsRecorder struct {
+	storage.File
+
+	mutex sync.Mutex
+	reads []int
+}
+
+func (r *readsRecorder) ReadChunk(ctx context.Context, index int, data []byte, offset int) (int, error) {
+	r.mutex.Lock()
+	r.reads = append(r.reads, index)
+	r.mutex.Unlock()
+
+	return r.File.ReadChunk(ctx, index, data, offset)
+}
+
+func (r *readsRecorder) hasRead(index int) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return slices.Contains(r.reads, index)
+}
+
+type PrefetcherSuite struct {
+	Storage    *readsRecorder
+	Prefetcher *prefetcher
+	suite.Suite
+}
+
+func (suite *PrefetcherSuite) SetupTest() {
+	f, err := mem.NewDirectory().CreateFile(context.Background(), "file", info.File{
+		ChunkSize: 4,
+	})
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.ResizeChunksNb(context.Background(), 10))
+
+	suite.Storage = &readsRecorder{File: f}
+	suite.Prefetcher = newPrefetcher(suite.Storage, 4, 3, log.New(io.Discard, "", 0))
+}
+
+func (suite *PrefetcherSuite) TestSequentialReads() {
+	// Read the first chunk and check the next ones are prefetched
+	suite.Prefetcher.read(0, 0, 10)
+	suite.Require().Eventually(func() bool {
+		return suite.Storage.hasRead(1) && suite.Storage.hasRead(2) && suite.Storage.hasRead(3)
+	}, time.Second, time.Millisecond)
+	suite.Require().False(suite.Storage.hasRead(4))
+
+	// Read the next chunk and check only the new one is prefetched
+	suite.Prefetcher.read(1, 1, 10)
+	suite.Require().Eventually(func() bool {
+		return suite.Storage.hasRead(4)
+	}, time.Second, time.Millisecond)
+}
+
+func (suite *PrefetcherSuite) TestRandomReads() {
+	// Read sequentially, then jump in the file
+	suite.Prefetcher.read(0, 0, 10)
+	ctx := suite.Prefetcher.ctx
+	suite.Prefetcher.read(7, 7, 10)
+
+	// Check the prefetch has been cancelled and nothing new is prefetched
+	suite.Require().ErrorIs(ctx.Err(), context.Canceled)
+	suite.Require().Nil(suite.Prefetcher.cancel)
+
+	// Check it restarts on the next sequential read
+	suite.Prefetcher.read(8, 8, 10)
+	suite.Require().Eventually(func() bool {
+		return suite.Storage.hasRead(9)
+	}, time.Second, time.Millisecond)
+}
+
+func (suite *PrefetcherSuite) TestReadsAtTheEnd() {
+	// Read sequentially up to the chunk before the last one
+	suite.Prefetcher.read(7, 7, 10)
+	suite.Prefetcher.read(8, 8, 10)
+
+	// Check only the existing chunks are prefetched
+	suite.Require().Eventually(func() bool {
+		return suite.Storage.hasRead(9)
+	}, time.Second, time.Millisecond)
+	suite.Require().False(suite.Storage.hasRead(10))
+	suite.Require().Equal(10, suite.Prefetcher.nextChunk)
+}
+
+func (suite *PrefetcherSuite) TestClose() {
+	// Read sequentially, then close the prefetcher
+	suite.Prefetcher.read(0, 0, 10)
+	ctx := suite.Prefetcher.ctx
+	suite.Prefetcher.close()
+
+	// Check the prefetch has been cancelled
+	suite.Require().ErrorIs(ctx.Err(), context.Canceled)
+	suite.Require().Nil(suite.Prefetcher.cancel)
+
+	// Check the next read is not considered as sequential
+	suite.Prefetcher.read(1, 1, 10)
+	suite.Require().Nil(suite.Prefetcher.cancel)
+}
@@ -0,0 +1,394 @@
+package chonker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+// Stream is a directory which files are windows in one logical stream of
+// chunks, stored as a single file, like the files of a multi-file torrent
+// which pieces span several files.
+type Stream struct {
+	// Name is the name of the directory of the files.
+	Name string
+	// ChunkSize is the chunk size of the stream.
+	ChunkSize int
+	// Length is the length of the stream.
+	Length int
+	// Files are the files of the directory.
+	Files []StreamFile
+}
+
+// StreamFile is a file of a stream.
+type StreamFile struct {
+	// Path is the path of the file, from the directory of the stream.
+	Path []string
+	// Offset is the offset of the file in the stream.
+	Offset int
+	// Length is the length of the file.
+	Length int
+}
+
+// StreamFileName returns the name of the file storing the stream, next to its
+// directory.
+func StreamFileName(name string) string {
+	return "." + name + ".stream"
+}
+
+// WithDirectoryStreams is an option to add directories which files are
+// windows in streams. The files of the streams cannot be created, removed,
+// renamed, resized or have their attributes changed, and they share the times
+// of the stream.
+//
+//nolint:revive
+func WithDirectoryStreams(streams ...Stream) directoryOption {
+	return func(dir *directory) {
+		dir.streams = streams
+	}
+}
+
+// getStream returns the stream of the directory with the name, if any.
+func (dir *directory) getStream(name string) (*Stream, bool) {
+	for i := range dir.streams {
+		if dir.streams[i].Name == name {
+			return &dir.streams[i], true
+		}
+	}
+	return nil, false
+}
+
+// isStreamFile returns true if this is the file storing a stream.
+func (dir *directory) isStreamFile(name string) bool {
+	for _, s := range dir.streams {
+		if StreamFileName(s.Name) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// openStream returns the file storing the stream, creating it if it doesn't
+// exist yet.
+func (dir *directory) openStream(ctx context.Context, s *Stream) (*file, error) {
+	sf, err := dir.storage.GetFile(ctx, StreamFileName(s.Name))
+	if errors.Is(err, storage.ErrFileNotFound) {
+		sf, err = dir.storage.CreateFile(ctx, StreamFileName(s.Name), info.File{
+			ChunkSize: s.ChunkSize,
+		})
+		if err == nil {
+			err = sf.SetAttributes(ctx, newAttributes())
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrChonker, err)
+	}
+
+	// Get the chunk size, as the storage can choose another one
+	fileInfo, err := sf.GetInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrChonker, err)
+	}
+
+	// Allocate the stream as holes
+	f, err := NewFile(ctx, sf, fileInfo.ChunkSize, dir.fileOptions()...)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.(*file).resizeChunks(ctx, s.Length); err != nil {
+		return nil, err
+	}
+
+	return f.(*file), nil
+}
+
+var _ Directory = (*streamDirectory)(nil)
+
+// streamDirectory is a directory of a stream, or one of its children.
+type streamDirectory struct {
+	parent *directory
+	stream *Stream
+	path   []string
+}
+
+// children returns the names of the files and directories in the directory.
+func (d *streamDirectory) children() (files, dirs []string) {
+	for _, f := range d.stream.Files {
+		if len(f.Path) <= len(d.path) || !slices.Equal(f.Path[:len(d.path)], d.path) {
+			continue
+		}
+
+		name := f.Path[len(d.path)]
+		if len(f.Path) == len(d.path)+1 {
+			files = append(files, name)
+		} else if !slices.Contains(dirs, name) {
+			dirs = append(dirs, name)
+		}
+	}
+
+	return files, dirs
+}
+
+// GetAttributes returns the attributes of the directory, which are unset as
+// the directory only exists in the stream.
+func (d *streamDirectory) GetAttributes(_ context.Context) (DirectoryAttributes, error) {
+	return DirectoryAttributes{}, nil
+}
+
+// SetAttributes fails, as the directories of a stream are fixed.
+func (d *streamDirectory) SetAttributes(_ context.Context, _ DirectoryAttributes) error {
+	return fmt.Errorf("%w: %q is a stream directory", ErrNotPermitted, d.stream.Name)
+}
+
+// GetXattr fails, as the directory has no extended attributes.
+func (d *streamDirectory) GetXattr(_ context.Context, name string) ([]byte, error) {
+	return nil, fmt.Errorf("%w: %q", ErrNoAttribute, name)
+}
+
+// ListXattrs returns no extended attributes, as the directory only exists in
+// the stream.
+func (d *streamDirectory) ListXattrs(_ context.Context) ([]string, error) {
+	return []string{}, nil
+}
+
+// SetXattr fails, as the directories of a stream are fixed.
+func (d *streamDirectory) SetXattr(_ context.Context, _ string, _ []byte, _ XattrOptions) error {
+	return fmt.Errorf("%w: %q is a stream directory", ErrNotPermitted, d.stream.Name)
+}
+
+// RemoveXattr fails, as the directories of a stream are fixed.
+func (d *streamDirectory) RemoveXattr(_ context.Context, _ string) error {
+	return fmt.Errorf("%w: %q is a stream directory", ErrNotPermitted, d.stream.Name)
+}
+
+// CreateDirectory fails, as the directories of a stream are fixed.
+func (d *streamDirectory) CreateDirectory(_ context.Context, name string) (Directory, error) {
+	if files, dirs := d.children(); slices.Contains(dirs, name) || slices.Contains(files, name) {
+		return nil, ErrAlreadyExists
+	}
+	return nil, fmt.Errorf("%w: %q is in a stream", ErrNotPermitted, name)
+}
+
+// GetDirectory returns a child directory of the directory.
+func (d *streamDirectory) GetDirectory(_ context.Context, name string) (Directory, error) {
+	files, dirs := d.children()
+	switch {
+	case slices.Contains(dirs, name):
+		return &streamDirectory{
+			parent: d.parent,
+			stream: d.stream,
+			path:   append(slices.Clone(d.path), name),
+		}, nil
+	case slices.Contains(files, name):
+		return nil, ErrNotDirectory
+	default:
+		return nil, ErrNoEntry
+	}
+}
+
+// RemoveDirectory fails, as the directories of a stream are fixed.
+func (d *streamDirectory) RemoveDirectory(_ context.Context, name string) error {
+	return fmt.Errorf("%w: %q is in a stream", ErrNotPermitted, name)
+}
+
+// ListDirectories returns the list of directories in the directory.
+func (d *streamDirectory) ListDirectories(_ context.Context) ([]string, error) {
+	_, dirs := d.children()
+	return dirs, nil
+}
+
+// RenameDirectory fails, as the directories of a stream are fixed.
+func (d *streamDirectory) RenameDirectory(_ context.Context, name string, _ Directory, _ string, _ bool) error {
+	return fmt.Errorf("%w: %q is in a stream", ErrNotPermitted, name)
+}
+
+// CreateFile fails, as the files of a stream are fixed.
+func (d *streamDirectory) CreateFile(_ context.Context, name string, _ int) (File, error) {
+	if files, dirs := d.children(); slices.Contains(dirs, name) || slices.Contains(files, name) {
+		return nil, ErrAlreadyExists
+	}
+	return nil, fmt.Errorf("%w: %q is in a stream", ErrNotPermitted, name)
+}
+
+// GetFile returns a child file of the directory, as a window in the stream.
+func (d *streamDirectory) GetFile(ctx context.Context, name string) (File, error) {
+	p := append(slices.Clone(d.path), name)
+	i := slices.IndexFunc(d.stream.Files, func(f StreamFile) bool {
+		return slices.Equal(f.Path, p)
+	})
+	if _, dirs := d.children(); slices.Contains(dirs, name) {
+		return nil, fmt.Errorf("%w: %w: %q", ErrChonker, storage.ErrIsDirectory, name)
+	} else if i < 0 {
+		return nil, ErrNoEntry
+	}
+
+	stream, err := d.parent.openStream(ctx, d.stream)
+	if err != nil {
+		return nil, err
+	}
+
+	return &windowFile{
+		stream: stream,
+		offset: d.stream.Files[i].Offset,
+		length: d.stream.Files[i].Length,
+	}, nil
+}
+
+// RemoveFile fails, as the files of a stream are fixed.
+func (d *streamDirectory) RemoveFile(_ context.Context, name string) error {
+	return fmt.Errorf("%w: %q is in a stream", ErrNotPermitted, name)
+}
+
+// ListFiles returns the list of files in the directory.
+func (d *streamDirectory) ListFiles(_ context.Context) ([]string, error) {
+	files, _ := d.children()
+	return files, nil
+}
+
+// RenameFile fails, as the files of a stream are fixed.
+func (d *streamDirectory) RenameFile(_ context.Context, name string, _ Directory, _ string, _ bool) error {
+	return fmt.Errorf("%w: %q is in a stream", ErrNotPermitted, name)
+}
+
+var _ File = (*windowFile)(nil)
+
+// windowFile is a file of a stream, with a fixed length.
+type windowFile struct {
+	stream *file
+	offset int
+	length int
+}
+
+// GetAttributes returns the attributes of the file, with the mode, owner and
+// times of the stream.
+func (w *windowFile) GetAttributes(ctx context.Context) (FileAttributes, error) {
+	attr, err := w.stream.GetAttributes(ctx)
+	if err != nil {
+		return FileAttributes{}, err
+	}
+
+	// Count the data of the chunks of the window
+	allocated := 0
+	for index := w.offset / w.stream.chunkSize; index*w.stream.chunkSize < w.offset+w.length; index++ {
+		hasData, err := w.stream.chunkHasData(ctx, index)
+		if err != nil {
+			return FileAttributes{}, err
+		} else if hasData {
+			start := max(index*w.stream.chunkSize, w.offset)
+			end := min((index+1)*w.stream.chunkSize, w.offset+w.length)
+			allocated += end - start
+		}
+	}
+
+	attr.Size, attr.AllocatedSize = w.length, allocated
+	return attr, nil
+}
+
+// SetAttributes fails, as the files of a stream share its attributes.
+func (w *windowFile) SetAttributes(_ context.Context, _ FileAttributes) error {
+	return fmt.Errorf("%w: file is in a stream", ErrNotPermitted)
+}
+
+// GetXattr returns the value of an extended attribute of the stream.
+func (w *windowFile) GetXattr(ctx context.Context, name string) ([]byte, error) {
+	return w.stream.GetXattr(ctx, name)
+}
+
+// ListXattrs returns the names of the extended attributes of the stream.
+func (w *windowFile) ListXattrs(ctx context.Context) ([]string, error) {
+	return w.stream.ListXattrs(ctx)
+}
+
+// SetXattr fails, as the files of a stream share its extended attributes.
+func (w *windowFile) SetXattr(_ context.Context, _ string, _ []byte, _ XattrOptions) error {
+	return fmt.Errorf("%w: file is in a stream", ErrNotPermitted)
+}
+
+// RemoveXattr fails, as the files of a stream share its extended attributes.
+func (w *windowFile) RemoveXattr(_ context.Context, _ string) error {
+	return fmt.Errorf("%w: file is in a stream", ErrNotPermitted)
+}
+
+// Read reads the file at the given offset.
+func (w *windowFile) Read(ctx context.Context, dest []byte, off int) ([]byte, error) {
+	if off >= w.length {
+		return []byte{}, nil
+	}
+
+	return w.stream.Read(ctx, dest[:min(len(dest), w.length-off)], w.offset+off)
+}
+
+// Write writes the data at the given offset, that should be in the file.
+func (w *windowFile) Write(ctx context.Context, data []byte, off int, opts WriteOptions) (int, error) {
+	if opts.Append || off < 0 || off+len(data) > w.length {
+		return 0, fmt.Errorf("%w: write after the end of a stream file", ErrNotPermitted)
+	}
+
+	written, err := w.stream.writeAccrossChunks(ctx, data, w.offset+off)
+	if err != nil {
+		return 0, err
+	}
+
+	// Check if truncate is needed
+	if opts.Truncate {
+		if err := w.Truncate(ctx, off+len(data)); err != nil {
+			return 0, err
+		}
+	}
+
+	return written, nil
+}
+
+// Truncate fails if the file would be shortened, as its length is fixed.
+func (w *windowFile) Truncate(_ context.Context, size int) error {
+	if size < w.length {
+		return fmt.Errorf("%w: truncate of a stream file", ErrNotPermitted)
+	}
+	return nil
+}
+
+// Sync saves the stream to the storage.
+func (w *windowFile) Sync(ctx context.Context) error {
+	return w.stream.Sync(ctx)
+}
+
+// Stop cancels the prefetch of the stream, once the file is released or
+// removed.
+func (w *windowFile) Stop() {
+	w.stream.Stop()
+}
+
+// SeekData returns the offset of the first data at or after the given offset.
+func (w *windowFile) SeekData(ctx context.Context, off int) (int, error) {
+	return w.seekChunk(ctx, off, true)
+}
+
+// SeekHole returns the offset of the first hole at or after the given offset,
+// the end of the file being considered as a hole.
+func (w *windowFile) SeekHole(ctx context.Context, off int) (int, error) {
+	return w.seekChunk(ctx, off, false)
+}
+
+func (w *windowFile) seekChunk(ctx context.Context, off int, hasData bool) (int, error) {
+	// Check if the offset is in the file
+	if off < 0 || off >= w.length {
+		return 0, fmt.Errorf("%w: %d", ErrNoData, off)
+	}
+
+	found, err := w.stream.seekChunk(ctx, w.offset+off, hasData)
+	if err != nil {
+		return 0, err
+	}
+
+	// What is after the file is not part of it, only its end
+	if found < w.offset+w.length {
+		return found - w.offset, nil
+	} else if hasData {
+		return 0, fmt.Errorf("%w: %d", ErrNoData, off)
+	}
+	return w.length, nil
+}
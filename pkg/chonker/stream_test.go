@@ -0,0 +1,173 @@
+package chonker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lerenn/chonkfs/pkg/storage"
+	"github.com/lerenn/chonkfs/pkg/storage/mem"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestStreamSuite(t *testing.T) {
+	suite.Run(t, new(StreamSuite))
+}
+
+type StreamSuite struct {
+	suite.Suite
+	storage storage.Directory
+	dir     Directory
+}
+
+func (suite *StreamSuite) SetupTest() {
+	suite.storage = mem.NewDirectory()
+
+	// Create a stream of 3 files spanning chunks of 4 bytes
+	d, err := NewDirectory(context.Background(), suite.storage, WithDirectoryStreams(Stream{
+		Name:      "torrent",
+		ChunkSize: 4,
+		Length:    10,
+		Files: []StreamFile{
+			{Path: []string{"a.txt"}, Offset: 0, Length: 3},
+			{Path: []string{"sub", "b.txt"}, Offset: 3, Length: 6},
+			{Path: []string{"sub", "c.txt"}, Offset: 9, Length: 1},
+		},
+	}))
+	suite.Require().NoError(err)
+	suite.dir = d
+}
+
+func (suite *StreamSuite) getFile(path ...string) File {
+	d, err := suite.dir.GetDirectory(context.Background(), "torrent")
+	suite.Require().NoError(err)
+	for _, name := range path[:len(path)-1] {
+		d, err = d.GetDirectory(context.Background(), name)
+		suite.Require().NoError(err)
+	}
+
+	f, err := d.GetFile(context.Background(), path[len(path)-1])
+	suite.Require().NoError(err)
+	return f
+}
+
+func (suite *StreamSuite) TestList() {
+	dirs, err := suite.dir.ListDirectories(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal([]string{"torrent"}, dirs)
+
+	d, err := suite.dir.GetDirectory(context.Background(), "torrent")
+	suite.Require().NoError(err)
+
+	files, err := d.ListFiles(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal([]string{"a.txt"}, files)
+
+	dirs, err = d.ListDirectories(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal([]string{"sub"}, dirs)
+
+	// The file storing the stream is hidden
+	f := suite.getFile("a.txt")
+	_, err = f.Write(context.Background(), []byte("abc"), 0, WriteOptions{})
+	suite.Require().NoError(err)
+
+	files, err = suite.dir.ListFiles(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Empty(files)
+}
+
+func (suite *StreamSuite) TestWriteReadAcrossFiles() {
+	// Write the files
+	for _, w := range []struct {
+		path []string
+		data string
+	}{
+		{[]string{"a.txt"}, "abc"},
+		{[]string{"sub", "b.txt"}, "defghi"},
+		{[]string{"sub", "c.txt"}, "j"},
+	} {
+		written, err := suite.getFile(w.path...).Write(context.Background(), []byte(w.data), 0, WriteOptions{})
+		suite.Require().NoError(err)
+		suite.Require().Equal(len(w.data), written)
+	}
+
+	// The stream is stored once, in a single file
+	sf, err := suite.storage.GetFile(context.Background(), StreamFileName("torrent"))
+	suite.Require().NoError(err)
+	data := make([]byte, 4)
+	read, err := sf.ReadChunk(context.Background(), 0, data, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal("abcd", string(data[:read]))
+
+	// Read a file spanning several chunks
+	f := suite.getFile("sub", "b.txt")
+	res, err := f.Read(context.Background(), make([]byte, 10), 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal("defghi", string(res))
+
+	res, err = f.Read(context.Background(), make([]byte, 10), 6)
+	suite.Require().NoError(err)
+	suite.Require().Empty(res)
+
+	attr, err := f.GetAttributes(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal(6, attr.Size)
+}
+
+func (suite *StreamSuite) TestWriteOutsideFile() {
+	f := suite.getFile("a.txt")
+
+	_, err := f.Write(context.Background(), []byte("abcd"), 0, WriteOptions{})
+	suite.Require().ErrorIs(err, ErrNotPermitted)
+
+	_, err = f.Write(context.Background(), []byte("a"), 0, WriteOptions{Append: true})
+	suite.Require().ErrorIs(err, ErrNotPermitted)
+
+	suite.Require().ErrorIs(f.Truncate(context.Background(), 1), ErrNotPermitted)
+	suite.Require().NoError(f.Truncate(context.Background(), 3))
+}
+
+func (suite *StreamSuite) TestSeek() {
+	// Write only the last file, in the last chunk
+	_, err := suite.getFile("sub", "c.txt").Write(context.Background(), []byte("j"), 0, WriteOptions{})
+	suite.Require().NoError(err)
+
+	// The second file only has data in its part of the last chunk
+	f := suite.getFile("sub", "b.txt")
+	off, err := f.SeekData(context.Background(), 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal(5, off)
+
+	off, err = f.SeekHole(context.Background(), 5)
+	suite.Require().NoError(err)
+	suite.Require().Equal(6, off)
+
+	// The first file has no data
+	_, err = suite.getFile("a.txt").SeekData(context.Background(), 0)
+	suite.Require().ErrorIs(err, ErrNoData)
+}
+
+func (suite *StreamSuite) TestNotPermitted() {
+	d, err := suite.dir.GetDirectory(context.Background(), "torrent")
+	suite.Require().NoError(err)
+
+	_, err = d.CreateFile(context.Background(), "new.txt", 4)
+	suite.Require().ErrorIs(err, ErrNotPermitted)
+	_, err = d.CreateFile(context.Background(), "a.txt", 4)
+	suite.Require().ErrorIs(err, ErrAlreadyExists)
+	suite.Require().ErrorIs(d.RemoveFile(context.Background(), "a.txt"), ErrNotPermitted)
+	suite.Require().ErrorIs(suite.dir.RemoveDirectory(context.Background(), "torrent"), ErrNotPermitted)
+	suite.Require().ErrorIs(d.RenameFile(context.Background(), "a.txt", suite.dir, "a.txt", false), ErrNotPermitted)
+	suite.Require().ErrorIs(d.SetAttributes(context.Background(), DirectoryAttributes{}), ErrNotPermitted)
+	err = suite.getFile("a.txt").SetAttributes(context.Background(), FileAttributes{})
+	suite.Require().ErrorIs(err, ErrNotPermitted)
+	suite.Require().ErrorIs(d.SetXattr(context.Background(), "user.a", nil, XattrOptions{}), ErrNotPermitted)
+	err = suite.getFile("a.txt").SetXattr(context.Background(), "user.a", nil, XattrOptions{})
+	suite.Require().ErrorIs(err, ErrNotPermitted)
+
+	// Regular files cannot be moved into the stream
+	_, err = suite.dir.CreateFile(context.Background(), "other.txt", 4)
+	suite.Require().NoError(err)
+	err = suite.dir.RenameFile(context.Background(), "other.txt", d, "other.txt", false)
+	suite.Require().ErrorIs(err, ErrNotPermitted)
+}
@@ -0,0 +1,97 @@
+package chonker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+// xattrError turns an error from an extended attributes storage into a
+// chonker error.
+func xattrError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, storage.ErrXattrNotFound):
+		return fmt.Errorf("%w: %w", ErrNoAttribute, err)
+	case errors.Is(err, storage.ErrXattrNotSupported):
+		return fmt.Errorf("%w: %w", ErrNotSupported, err)
+	default:
+		return fmt.Errorf("%w: %w", ErrChonker, err)
+	}
+}
+
+func getXattr(ctx context.Context, x storage.Xattrer, name string) ([]byte, error) {
+	value, err := x.GetXattr(ctx, name)
+	return value, xattrError(err)
+}
+
+func listXattrs(ctx context.Context, x storage.Xattrer) ([]string, error) {
+	names, err := x.ListXattrs(ctx)
+	return names, xattrError(err)
+}
+
+func setXattr(ctx context.Context, x storage.Xattrer, name string, value []byte, opts XattrOptions) error {
+	// Check if the attribute exists, if requested
+	if opts.Create || opts.Replace {
+		_, err := x.GetXattr(ctx, name)
+		if err != nil && !errors.Is(err, storage.ErrXattrNotFound) {
+			return xattrError(err)
+		}
+
+		exists := err == nil
+		if opts.Create && exists {
+			return fmt.Errorf("%w: attribute %q", ErrAlreadyExists, name)
+		} else if opts.Replace && !exists {
+			return xattrError(err)
+		}
+	}
+
+	return xattrError(x.SetXattr(ctx, name, value))
+}
+
+func removeXattr(ctx context.Context, x storage.Xattrer, name string) error {
+	return xattrError(x.RemoveXattr(ctx, name))
+}
+
+// GetXattr returns the value of an extended attribute of the file.
+func (f *file) GetXattr(ctx context.Context, name string) ([]byte, error) {
+	return getXattr(ctx, f.storage, name)
+}
+
+// ListXattrs returns the names of the extended attributes of the file.
+func (f *file) ListXattrs(ctx context.Context) ([]string, error) {
+	return listXattrs(ctx, f.storage)
+}
+
+// SetXattr sets an extended attribute of the file.
+func (f *file) SetXattr(ctx context.Context, name string, value []byte, opts XattrOptions) error {
+	return setXattr(ctx, f.storage, name, value, opts)
+}
+
+// RemoveXattr removes an extended attribute of the file.
+func (f *file) RemoveXattr(ctx context.Context, name string) error {
+	return removeXattr(ctx, f.storage, name)
+}
+
+// GetXattr returns the value of an extended attribute of the directory.
+func (dir *directory) GetXattr(ctx context.Context, name string) ([]byte, error) {
+	return getXattr(ctx, dir.storage, name)
+}
+
+// ListXattrs returns the names of the extended attributes of the directory.
+func (dir *directory) ListXattrs(ctx context.Context) ([]string, error) {
+	return listXattrs(ctx, dir.storage)
+}
+
+// SetXattr sets an extended attribute of the directory.
+func (dir *directory) SetXattr(ctx context.Context, name string, value []byte, opts XattrOptions) error {
+	return setXattr(ctx, dir.storage, name, value, opts)
+}
+
+// RemoveXattr removes an extended attribute of the directory.
+func (dir *directory) RemoveXattr(ctx context.Context, name string) error {
+	return removeXattr(ctx, dir.storage, name)
+}
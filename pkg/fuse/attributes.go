@@ -0,0 +1,127 @@
+package fuse
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/lerenn/chonkfs/pkg/info"
+)
+
+// attributesMask are the attributes that can be set, besides the size.
+const attributesMask = fuse.FATTR_MODE | fuse.FATTR_UID | fuse.FATTR_GID | fuse.FATTR_ATIME | fuse.FATTR_MTIME
+
+// defaultOwner is the owner of the files and directories whose mode and owner
+// were never stored: the user running the file system.
+var defaultOwner = fuse.Owner{Uid: uint32(os.Getuid()), Gid: uint32(os.Getgid())}
+
+// withDefaults returns the attributes, with the default mode and owner if they
+// were never stored. The mode is always stored with the owner, so a zero mode
+// means none of them were.
+func withDefaults(attr info.Attributes, defaultMode uint32) info.Attributes {
+	if attr.Mode == 0 {
+		attr.Mode = defaultMode
+		attr.UID, attr.GID = defaultOwner.Uid, defaultOwner.Gid
+	}
+	return attr
+}
+
+// fillAttr fills the attributes for the FUSE system.
+func fillAttr(out *fuse.Attr, attr info.Attributes, defaultMode uint32) {
+	attr = withDefaults(attr, defaultMode)
+	out.Mode = attr.Mode
+	out.Owner = fuse.Owner{Uid: attr.UID, Gid: attr.GID}
+	out.SetTimes(timeOrNil(attr.Atime), timeOrNil(attr.Mtime), timeOrNil(attr.Ctime))
+}
+
+// fillStatx fills the stats for the FUSE system.
+func fillStatx(out *fuse.Statx, attr info.Attributes, defaultMode uint32) {
+	attr = withDefaults(attr, defaultMode)
+	out.Mode = uint16(attr.Mode)
+	out.Uid, out.Gid = attr.UID, attr.GID
+	out.Atime = sxTime(attr.Atime)
+	out.Mtime = sxTime(attr.Mtime)
+	out.Ctime = sxTime(attr.Ctime)
+}
+
+// updateAttributes changes the attributes set by the FUSE system, keeping the
+// type bits in the mode. Like chmod, chown and utimes, only the owner can
+// change the mode, the group and the times, and only root can change the
+// owner. Anybody can set the times to now.
+func updateAttributes(
+	ctx context.Context,
+	attr *info.Attributes,
+	in *fuse.SetAttrIn,
+	fileType, defaultMode uint32,
+) syscall.Errno {
+	*attr = withDefaults(*attr, defaultMode)
+	if errno := checkSetAttributes(ctx, *attr, in); errno != fs.OK {
+		return errno
+	}
+
+	if mode, ok := in.GetMode(); ok {
+		attr.Mode = fileType | mode
+	}
+	if uid, ok := in.GetUID(); ok {
+		attr.UID = uid
+	}
+	if gid, ok := in.GetGID(); ok {
+		attr.GID = gid
+	}
+	if atime, ok := in.GetATime(); ok {
+		attr.Atime = atime
+	}
+	if mtime, ok := in.GetMTime(); ok {
+		attr.Mtime = mtime
+	}
+
+	return fs.OK
+}
+
+// checkSetAttributes checks the caller is allowed to set the attributes.
+func checkSetAttributes(ctx context.Context, attr info.Attributes, in *fuse.SetAttrIn) syscall.Errno {
+	caller, ok := fuse.FromContext(ctx)
+	if !ok || caller.Uid == 0 {
+		return fs.OK
+	}
+
+	if uid, ok := in.GetUID(); ok && uid != attr.UID {
+		return syscall.EPERM
+	}
+
+	_, setMode := in.GetMode()
+	_, setGID := in.GetGID()
+	setTimes := in.Valid&(fuse.FATTR_ATIME|fuse.FATTR_ATIME_NOW) == fuse.FATTR_ATIME ||
+		in.Valid&(fuse.FATTR_MTIME|fuse.FATTR_MTIME_NOW) == fuse.FATTR_MTIME
+	if (setMode || setGID || setTimes) && caller.Uid != attr.UID {
+		return syscall.EPERM
+	}
+
+	return fs.OK
+}
+
+// createdAttributes sets the mode of a created file or directory, and the
+// caller as its owner.
+func createdAttributes(ctx context.Context, attr *info.Attributes, fileType, mode uint32) {
+	attr.Mode = fileType | mode&^syscall.S_IFMT
+	if caller, ok := fuse.FromContext(ctx); ok {
+		attr.UID, attr.GID = caller.Uid, caller.Gid
+	}
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func sxTime(t time.Time) fuse.SxTime {
+	if t.IsZero() {
+		return fuse.SxTime{}
+	}
+	return fuse.SxTime{Sec: uint64(t.Unix()), Nsec: uint32(t.Nanosecond())}
+}
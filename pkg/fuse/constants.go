@@ -3,4 +3,8 @@ package fuse
 const (
 	// DefaultChunkSize is the default chunk size.
 	DefaultChunkSize = 16 * 1024
+
+	// blockSize is the size of the blocks counted in the attributes, as
+	// expected by stat(2).
+	blockSize = 512
 )
@@ -93,8 +93,8 @@ func (d *Directory) Create(
 	ctx context.Context,
 	name string,
 	_ uint32,
-	_ uint32,
-	_ *fuse.EntryOut,
+	mode uint32,
+	out *fuse.EntryOut,
 ) (node *fs.Inode, fh fs.FileHandle, fuseFlags uint32, errno syscall.Errno) {
 	d.PreHook()
 	defer d.PostHook()
@@ -108,23 +108,62 @@ func (d *Directory) Create(
 		})
 	}
 
+	// Set its mode and owner
+	if err := d.setCreatedFileAttributes(ctx, backendChildFile, mode, out); err != nil {
+		return nil, nil, 0, chonker.ToSyscallErrno(err, chonker.ToSyscallErrnoOptions{
+			Logger: d.logger,
+		})
+	}
+
 	// Create chonkfs File
 	f := NewFile(backendChildFile,
 		WithFileLogger(d.logger),
 		WithFileChunkSize(d.chunkSize),
 		WithFileName(name))
+	f.openHandle()
 
 	// Return an inode with the chonkfs directory
 	return d.NewInode(ctx, f, fs.StableAttr{Mode: syscall.S_IFREG}), f, fuse.FOPEN_DIRECT_IO, fs.OK
 }
 
+// setCreatedFileAttributes sets the mode and the owner of a created file, and
+// returns its attributes to the FUSE system.
+func (d *Directory) setCreatedFileAttributes(
+	ctx context.Context,
+	f chonker.File,
+	mode uint32,
+	out *fuse.EntryOut,
+) error {
+	attr, err := f.GetAttributes(ctx)
+	if err != nil {
+		return err
+	}
+
+	createdAttributes(ctx, &attr.Attributes, syscall.S_IFREG, mode)
+	if err := f.SetAttributes(ctx, attr); err != nil {
+		return err
+	}
+
+	fillAttr(&out.Attr, attr.Attributes, fileMode)
+	out.Blksize = uint32(d.chunkSize)
+	return nil
+}
+
 // Getattr returns the attributes of the directory for the FUSE system.
-func (d *Directory) Getattr(_ context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+func (d *Directory) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
 	d.PreHook()
 	defer d.PostHook()
 	d.logger.Printf("Directory.Getattr(...)\n")
 
-	out.Mode = dirMode
+	// Get attributes from backend
+	attr, err := d.backend.GetAttributes(ctx)
+	if err != nil {
+		return chonker.ToSyscallErrno(err, chonker.ToSyscallErrnoOptions{
+			Logger: d.logger,
+		})
+	}
+
+	fillAttr(&out.Attr, attr.Attributes, dirMode)
 	out.Blksize = uint32(d.chunkSize)
 
 	return fs.OK
@@ -132,7 +171,7 @@ func (d *Directory) Getattr(_ context.Context, _ fs.FileHandle, out *fuse.AttrOu
 
 // Statx returns the stats of the directory for the FUSE system.
 func (d *Directory) Statx(
-	_ context.Context,
+	ctx context.Context,
 	_ fs.FileHandle,
 	_ uint32,
 	_ uint32,
@@ -142,7 +181,15 @@ func (d *Directory) Statx(
 	defer d.PostHook()
 	d.logger.Printf("Directory.Statx(...)\n")
 
-	out.Mode = dirMode
+	// Get attributes from backend
+	attr, err := d.backend.GetAttributes(ctx)
+	if err != nil {
+		return chonker.ToSyscallErrno(err, chonker.ToSyscallErrnoOptions{
+			Logger: d.logger,
+		})
+	}
+
+	fillStatx(&out.Statx, attr.Attributes, dirMode)
 	out.Blksize = uint32(d.chunkSize)
 
 	return fs.OK
@@ -190,7 +237,7 @@ func (d *Directory) lookUpDirectory(
 		})
 
 	// Set mode from backend
-	_, err := backendChildDir.GetAttributes(ctx)
+	attr, err := backendChildDir.GetAttributes(ctx)
 	if err != nil {
 		return nil, chonker.ToSyscallErrno(err, chonker.ToSyscallErrnoOptions{
 			Logger: d.logger,
@@ -198,8 +245,8 @@ func (d *Directory) lookUpDirectory(
 	}
 
 	// Add info
+	fillAttr(&out.Attr, attr.Attributes, dirMode)
 	out.Blksize = uint32(d.chunkSize)
-	out.Mode = dirMode
 
 	// Return the inode
 	return ino, fs.OK
@@ -230,10 +277,10 @@ func (d *Directory) lookUpFile(
 	}
 
 	// Add info
+	fillAttr(&out.Attr, attr.Attributes, fileMode)
 	out.Size = uint64(attr.Size)
-	out.Blocks = uint64((attr.Size-1)/d.chunkSize + 1)
+	out.Blocks = blocksCount(attr.AllocatedSize)
 	out.Blksize = uint32(d.chunkSize)
-	out.Mode = fileMode
 
 	// Return the inode
 	return ino, fs.OK
@@ -243,8 +290,8 @@ func (d *Directory) lookUpFile(
 func (d *Directory) Mkdir(
 	ctx context.Context,
 	name string,
-	_ uint32,
-	_ *fuse.EntryOut,
+	mode uint32,
+	out *fuse.EntryOut,
 ) (*fs.Inode, syscall.Errno) {
 	d.PreHook()
 	defer d.PostHook()
@@ -258,12 +305,42 @@ func (d *Directory) Mkdir(
 		})
 	}
 
+	// Set its mode and owner
+	if err := d.setCreatedDirectoryAttributes(ctx, backendChildDir, mode, out); err != nil {
+		return nil, chonker.ToSyscallErrno(err, chonker.ToSyscallErrnoOptions{
+			Logger: d.logger,
+		})
+	}
+
 	// Return an inode with the chonkfs directory
 	return d.NewInode(ctx,
 		NewDirectory(backendChildDir, d.options...),
 		fs.StableAttr{Mode: syscall.S_IFDIR}), fs.OK
 }
 
+// setCreatedDirectoryAttributes sets the mode and the owner of a created
+// directory, and returns its attributes to the FUSE system.
+func (d *Directory) setCreatedDirectoryAttributes(
+	ctx context.Context,
+	dir chonker.Directory,
+	mode uint32,
+	out *fuse.EntryOut,
+) error {
+	attr, err := dir.GetAttributes(ctx)
+	if err != nil {
+		return err
+	}
+
+	createdAttributes(ctx, &attr.Attributes, syscall.S_IFDIR, mode)
+	if err := dir.SetAttributes(ctx, attr); err != nil {
+		return err
+	}
+
+	fillAttr(&out.Attr, attr.Attributes, dirMode)
+	out.Blksize = uint32(d.chunkSize)
+	return nil
+}
+
 // Readdir returns the children of the directory for the FUSE system.
 func (d *Directory) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
 	d.PreHook()
@@ -325,20 +402,55 @@ func (d *Directory) Unlink(ctx context.Context, name string) syscall.Errno {
 	d.PreHook()
 	defer d.PostHook()
 	d.logger.Printf("Directory.Unlink(name=%q, ...)\n", name)
-	return chonker.ToSyscallErrno(
-		d.backend.RemoveFile(ctx, name),
-		chonker.ToSyscallErrnoOptions{
+
+	if err := d.backend.RemoveFile(ctx, name); err != nil {
+		return chonker.ToSyscallErrno(err, chonker.ToSyscallErrnoOptions{
 			Logger: d.logger,
-		},
-	)
+		})
+	}
+
+	// Stop the background work on the file, if it is still open
+	if child := d.GetChild(name); child != nil {
+		if f, ok := child.Operations().(*File); ok {
+			f.backend.Stop()
+		}
+	}
+
+	return fs.OK
 }
 
 // Setattr sets the attributes of the directory for the FUSE system.
-func (d *Directory) Setattr(_ context.Context, _ fs.FileHandle, _ *fuse.SetAttrIn, _ *fuse.AttrOut) syscall.Errno {
+func (d *Directory) Setattr(
+	ctx context.Context,
+	fh fs.FileHandle,
+	in *fuse.SetAttrIn,
+	out *fuse.AttrOut,
+) syscall.Errno {
 	d.PreHook()
 	defer d.PostHook()
-	d.logger.Printf("Directory.Setattr(...)\n")
-	return fs.OK
+	d.logger.Printf("Directory.Setattr(in=%+v)\n", *in)
+
+	// Set the attributes if needed
+	if in.Valid&attributesMask != 0 {
+		attr, err := d.backend.GetAttributes(ctx)
+		if err != nil {
+			return chonker.ToSyscallErrno(err, chonker.ToSyscallErrnoOptions{
+				Logger: d.logger,
+			})
+		}
+
+		if errno := updateAttributes(ctx, &attr.Attributes, in, syscall.S_IFDIR, dirMode); errno != fs.OK {
+			return errno
+		}
+		if err := d.backend.SetAttributes(ctx, attr); err != nil {
+			return chonker.ToSyscallErrno(err, chonker.ToSyscallErrnoOptions{
+				Logger: d.logger,
+			})
+		}
+	}
+
+	// Return the new attributes
+	return d.Getattr(ctx, fh, out)
 }
 
 // Rename renames a child directory of the directory for the FUSE system.
@@ -4,11 +4,13 @@ import (
 	"context"
 	"io"
 	"log"
+	"sync"
 	"syscall"
 
 	"github.com/hanwen/go-fuse/v2/fs"
 	"github.com/hanwen/go-fuse/v2/fuse"
 	"github.com/lerenn/chonkfs/pkg/chonker"
+	"golang.org/x/sys/unix"
 )
 
 type fileOption func(fl *File)
@@ -42,15 +44,17 @@ func WithFileName(name string) fileOption {
 
 // Capabilities that the file struct should implements.
 var (
-	_ fs.FileFlusher   = (*File)(nil)
-	_ fs.FileGetattrer = (*File)(nil)
-	_ fs.FileReader    = (*File)(nil)
-	_ fs.FileWriter    = (*File)(nil)
-	_ fs.FileFsyncer   = (*File)(nil)
-	_ fs.FileStatxer   = (*File)(nil)
+	_ fs.FileFlusher  = (*File)(nil)
+	_ fs.FileReader   = (*File)(nil)
+	_ fs.FileWriter   = (*File)(nil)
+	_ fs.FileFsyncer  = (*File)(nil)
+	_ fs.FileStatxer  = (*File)(nil)
+	_ fs.FileLseeker  = (*File)(nil)
+	_ fs.FileReleaser = (*File)(nil)
 
 	_ fs.InodeEmbedder = (*File)(nil)
 
+	_ fs.NodeGetattrer = (*File)(nil)
 	_ fs.NodeOpener    = (*File)(nil)
 	_ fs.NodeSetattrer = (*File)(nil)
 )
@@ -65,6 +69,11 @@ type File struct {
 	backend      chonker.File
 	sessionFlags uint32
 
+	// handles is the count of the open handles of the file, that share its
+	// backend
+	handlesMutex sync.Mutex
+	handles      int
+
 	// Optional
 
 	options   []fileOption
@@ -98,8 +107,9 @@ func NewFile(backend chonker.File, options ...fileOption) *File {
 	return f
 }
 
-// Getattr returns the attributes of the file to the FUSE system.
-func (f *File) Getattr(ctx context.Context, out *fuse.AttrOut) (errno syscall.Errno) {
+// Getattr returns the attributes of the file to the FUSE system, whether it is
+// open or not.
+func (f *File) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) (errno syscall.Errno) {
 	f.PreHook()
 	defer f.PostHook()
 	f.logger.Printf("File[%s].Getattr(...)\n", f.name)
@@ -114,9 +124,9 @@ func (f *File) Getattr(ctx context.Context, out *fuse.AttrOut) (errno syscall.Er
 	}
 
 	// Set attributes
-	out.Mode = fileMode
+	fillAttr(&out.Attr, attr.Attributes, fileMode)
 	out.Size = uint64(attr.Size)
-	out.Blocks = uint64((attr.Size-1)/f.chunkSize + 1)
+	out.Blocks = blocksCount(attr.AllocatedSize)
 	out.Blksize = uint32(f.chunkSize)
 
 	return fs.OK
@@ -138,9 +148,9 @@ func (f *File) Statx(ctx context.Context, _ uint32, _ uint32, out *fuse.StatxOut
 	}
 
 	// Set attributes
-	out.Mode = fileMode
+	fillStatx(&out.Statx, attr.Attributes, fileMode)
 	out.Size = uint64(attr.Size)
-	out.Blocks = uint64((attr.Size-1)/f.chunkSize + 1)
+	out.Blocks = blocksCount(attr.AllocatedSize)
 	out.Blksize = uint32(f.chunkSize)
 
 	return fs.OK
@@ -176,6 +186,7 @@ func (f *File) Open(_ context.Context, flags uint32) (fh fs.FileHandle, fuseFlag
 	// Check if file exists if O_EXCL
 	// TODO
 
+	f.openHandle()
 	return f, fuse.FOPEN_DIRECT_IO, fs.OK
 }
 
@@ -216,19 +227,31 @@ func (f *File) Setattr(ctx context.Context, _ fs.FileHandle, in *fuse.SetAttrIn,
 	defer f.PostHook()
 	f.logger.Printf("File[%s].Setattr(in=%+v, out=%+v)\n", f.name, *in, *out)
 
-	// Get actual size
-	info, err := f.backend.GetAttributes(ctx)
-	if err != nil {
-		return chonker.ToSyscallErrno(err,
-			chonker.ToSyscallErrnoOptions{
-				Logger: f.logger,
-			})
+	// Truncate the file if needed
+	if size, ok := in.GetSize(); ok {
+		if err := f.backend.Truncate(ctx, int(size)); err != nil {
+			return chonker.ToSyscallErrno(err,
+				chonker.ToSyscallErrnoOptions{
+					Logger: f.logger,
+				})
+		}
 	}
-	actualSize := info.Size
 
-	// Truncate the file if needed
-	if in.Size < uint64(actualSize) {
-		if err := f.backend.Truncate(ctx, int(in.Size)); err != nil {
+	// Set the other attributes if needed, once the file is truncated to keep
+	// the times it changed
+	if in.Valid&attributesMask != 0 {
+		attr, err := f.backend.GetAttributes(ctx)
+		if err != nil {
+			return chonker.ToSyscallErrno(err,
+				chonker.ToSyscallErrnoOptions{
+					Logger: f.logger,
+				})
+		}
+
+		if errno := updateAttributes(ctx, &attr.Attributes, in, syscall.S_IFREG, fileMode); errno != fs.OK {
+			return errno
+		}
+		if err := f.backend.SetAttributes(ctx, attr); err != nil {
 			return chonker.ToSyscallErrno(err,
 				chonker.ToSyscallErrnoOptions{
 					Logger: f.logger,
@@ -236,8 +259,29 @@ func (f *File) Setattr(ctx context.Context, _ fs.FileHandle, in *fuse.SetAttrIn,
 		}
 	}
 
-	return chonker.ToSyscallErrno(
-		f.backend.SetAttributes(ctx, chonker.FileAttributes{}),
+	// Return the new attributes
+	return f.Getattr(ctx, nil, out)
+}
+
+// Lseek looks for the next data or hole in the file for the FUSE system.
+func (f *File) Lseek(ctx context.Context, off uint64, whence uint32) (uint64, syscall.Errno) {
+	f.PreHook()
+	defer f.PostHook()
+	f.logger.Printf("File[%s].Lseek(off=%d, whence=%d)\n", f.name, off, whence)
+
+	// Look for the next data or hole
+	var newOff int
+	var err error
+	switch whence {
+	case unix.SEEK_DATA:
+		newOff, err = f.backend.SeekData(ctx, int(off))
+	case unix.SEEK_HOLE:
+		newOff, err = f.backend.SeekHole(ctx, int(off))
+	default:
+		return 0, syscall.EINVAL
+	}
+
+	return uint64(newOff), chonker.ToSyscallErrno(err,
 		chonker.ToSyscallErrnoOptions{
 			Logger: f.logger,
 		})
@@ -256,3 +300,31 @@ func (f *File) Flush(ctx context.Context) syscall.Errno {
 			Logger: f.logger,
 		})
 }
+
+// Release releases the file for the FUSE system.
+func (f *File) Release(_ context.Context) syscall.Errno {
+	f.PreHook()
+	defer f.PostHook()
+	f.logger.Printf("File[%s].Release(...)\n", f.name)
+
+	// Stop the background work on the file, once no handle uses it anymore
+	f.handlesMutex.Lock()
+	defer f.handlesMutex.Unlock()
+	f.handles--
+	if f.handles == 0 {
+		f.backend.Stop()
+	}
+	return fs.OK
+}
+
+// openHandle records a handle opened on the file.
+func (f *File) openHandle() {
+	f.handlesMutex.Lock()
+	defer f.handlesMutex.Unlock()
+	f.handles++
+}
+
+// blocksCount returns the number of blocks used by the allocated data.
+func blocksCount(allocatedSize int) uint64 {
+	return uint64((allocatedSize + blockSize - 1) / blockSize)
+}
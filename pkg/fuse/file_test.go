@@ -0,0 +1,46 @@
+package fuse
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lerenn/chonkfs/pkg/chonker"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestFileSuite(t *testing.T) {
+	suite.Run(t, new(FileSuite))
+}
+
+type FileSuite struct {
+	suite.Suite
+}
+
+func (suite *FileSuite) TestReleaseStopsOnLastHandle() {
+	backend := &stopCountingFile{}
+	f := NewFile(backend)
+
+	// Open two handles on the file
+	_, _, errno := f.Open(context.Background(), 0)
+	suite.Require().Zero(errno)
+	_, _, errno = f.Open(context.Background(), 0)
+	suite.Require().Zero(errno)
+
+	// Check the file is not stopped while a handle is still open
+	suite.Require().Zero(f.Release(context.Background()))
+	suite.Require().Equal(0, backend.stops)
+
+	// Check it is stopped once the last one is released
+	suite.Require().Zero(f.Release(context.Background()))
+	suite.Require().Equal(1, backend.stops)
+}
+
+// stopCountingFile is a file that counts the times it is stopped.
+type stopCountingFile struct {
+	chonker.File
+	stops int
+}
+
+func (f *stopCountingFile) Stop() {
+	f.stops++
+}
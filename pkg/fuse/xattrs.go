@@ -0,0 +1,173 @@
+package fuse
+
+import (
+	"context"
+	"log"
+	"strings"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/lerenn/chonkfs/pkg/chonker"
+	"golang.org/x/sys/unix"
+)
+
+// Capabilities that the file and dir structs should implements for the
+// extended attributes.
+var (
+	_ fs.NodeGetxattrer    = (*File)(nil)
+	_ fs.NodeListxattrer   = (*File)(nil)
+	_ fs.NodeRemovexattrer = (*File)(nil)
+	_ fs.NodeSetxattrer    = (*File)(nil)
+
+	_ fs.NodeGetxattrer    = (*Directory)(nil)
+	_ fs.NodeListxattrer   = (*Directory)(nil)
+	_ fs.NodeRemovexattrer = (*Directory)(nil)
+	_ fs.NodeSetxattrer    = (*Directory)(nil)
+)
+
+// xattrBackend is a chonker file or directory with extended attributes.
+type xattrBackend interface {
+	GetXattr(ctx context.Context, name string) ([]byte, error)
+	ListXattrs(ctx context.Context) ([]string, error)
+	SetXattr(ctx context.Context, name string, value []byte, opts chonker.XattrOptions) error
+	RemoveXattr(ctx context.Context, name string) error
+}
+
+// copyXattr copies the data in the destination, or returns its size with
+// ERANGE if it doesn't fit, so the FUSE system can ask for the size with an
+// empty destination.
+func copyXattr(dest, data []byte) (uint32, syscall.Errno) {
+	if len(data) > len(dest) {
+		return uint32(len(data)), syscall.ERANGE
+	}
+	return uint32(copy(dest, data)), fs.OK
+}
+
+func getXattr(
+	ctx context.Context,
+	b xattrBackend,
+	logger *log.Logger,
+	name string,
+	dest []byte,
+) (uint32, syscall.Errno) {
+	value, err := b.GetXattr(ctx, name)
+	if err != nil {
+		return 0, chonker.ToSyscallErrno(err, chonker.ToSyscallErrnoOptions{
+			Logger: logger,
+		})
+	}
+
+	return copyXattr(dest, value)
+}
+
+func listXattrs(ctx context.Context, b xattrBackend, logger *log.Logger, dest []byte) (uint32, syscall.Errno) {
+	names, err := b.ListXattrs(ctx)
+	if err != nil {
+		return 0, chonker.ToSyscallErrno(err, chonker.ToSyscallErrnoOptions{
+			Logger: logger,
+		})
+	}
+
+	// Each name is terminated by a null byte
+	var list strings.Builder
+	for _, name := range names {
+		list.WriteString(name)
+		list.WriteByte(0)
+	}
+
+	return copyXattr(dest, []byte(list.String()))
+}
+
+func setXattr(
+	ctx context.Context,
+	b xattrBackend,
+	logger *log.Logger,
+	name string,
+	data []byte,
+	flags uint32,
+) syscall.Errno {
+	return chonker.ToSyscallErrno(b.SetXattr(ctx, name, data, chonker.XattrOptions{
+		Create:  flags&unix.XATTR_CREATE != 0,
+		Replace: flags&unix.XATTR_REPLACE != 0,
+	}), chonker.ToSyscallErrnoOptions{
+		Logger: logger,
+	})
+}
+
+func removeXattr(ctx context.Context, b xattrBackend, logger *log.Logger, name string) syscall.Errno {
+	return chonker.ToSyscallErrno(b.RemoveXattr(ctx, name), chonker.ToSyscallErrnoOptions{
+		Logger: logger,
+	})
+}
+
+// Getxattr returns the value of an extended attribute of the file.
+func (f *File) Getxattr(ctx context.Context, attr string, dest []byte) (uint32, syscall.Errno) {
+	f.PreHook()
+	defer f.PostHook()
+	f.logger.Printf("File[%s].Getxattr(attr=%q, ...)\n", f.name, attr)
+
+	return getXattr(ctx, f.backend, f.logger, attr, dest)
+}
+
+// Listxattr returns the names of the extended attributes of the file.
+func (f *File) Listxattr(ctx context.Context, dest []byte) (uint32, syscall.Errno) {
+	f.PreHook()
+	defer f.PostHook()
+	f.logger.Printf("File[%s].Listxattr(...)\n", f.name)
+
+	return listXattrs(ctx, f.backend, f.logger, dest)
+}
+
+// Setxattr sets an extended attribute of the file.
+func (f *File) Setxattr(ctx context.Context, attr string, data []byte, flags uint32) syscall.Errno {
+	f.PreHook()
+	defer f.PostHook()
+	f.logger.Printf("File[%s].Setxattr(attr=%q, flags=%d, ...)\n", f.name, attr, flags)
+
+	return setXattr(ctx, f.backend, f.logger, attr, data, flags)
+}
+
+// Removexattr removes an extended attribute of the file.
+func (f *File) Removexattr(ctx context.Context, attr string) syscall.Errno {
+	f.PreHook()
+	defer f.PostHook()
+	f.logger.Printf("File[%s].Removexattr(attr=%q)\n", f.name, attr)
+
+	return removeXattr(ctx, f.backend, f.logger, attr)
+}
+
+// Getxattr returns the value of an extended attribute of the directory.
+func (d *Directory) Getxattr(ctx context.Context, attr string, dest []byte) (uint32, syscall.Errno) {
+	d.PreHook()
+	defer d.PostHook()
+	d.logger.Printf("Directory.Getxattr(attr=%q, ...)\n", attr)
+
+	return getXattr(ctx, d.backend, d.logger, attr, dest)
+}
+
+// Listxattr returns the names of the extended attributes of the directory.
+func (d *Directory) Listxattr(ctx context.Context, dest []byte) (uint32, syscall.Errno) {
+	d.PreHook()
+	defer d.PostHook()
+	d.logger.Printf("Directory.Listxattr(...)\n")
+
+	return listXattrs(ctx, d.backend, d.logger, dest)
+}
+
+// Setxattr sets an extended attribute of the directory.
+func (d *Directory) Setxattr(ctx context.Context, attr string, data []byte, flags uint32) syscall.Errno {
+	d.PreHook()
+	defer d.PostHook()
+	d.logger.Printf("Directory.Setxattr(attr=%q, flags=%d, ...)\n", attr, flags)
+
+	return setXattr(ctx, d.backend, d.logger, attr, data, flags)
+}
+
+// Removexattr removes an extended attribute of the directory.
+func (d *Directory) Removexattr(ctx context.Context, attr string) syscall.Errno {
+	d.PreHook()
+	defer d.PostHook()
+	d.logger.Printf("Directory.Removexattr(attr=%q)\n", attr)
+
+	return removeXattr(ctx, d.backend, d.logger, attr)
+}
@@ -1,12 +1,40 @@
 package info
 
+import "time"
+
+// Attributes represents the POSIX attributes of a file or a directory.
+type Attributes struct {
+	// Mode is the mode of the entry with its type bits, so that an entry
+	// without permission is not mistaken for one which mode was never set
+	// (zero).
+	Mode uint32
+	// UID is the user ID of the owner.
+	UID uint32
+	// GID is the group ID of the owner.
+	GID uint32
+
+	// Atime is the last access time.
+	Atime time.Time
+	// Mtime is the last modification time of the content.
+	Mtime time.Time
+	// Ctime is the last change time of the content or the attributes.
+	Ctime time.Time
+}
+
 // Directory represents a directory information.
 type Directory struct {
+	Attributes
 }
 
 // File represents a file information.
 type File struct {
-	Size          int
+	Attributes
+
+	// Size is the logical size of the file.
+	Size int
+	// AllocatedSize is the size of the data actually stored, holes excluded.
+	AllocatedSize int
+
 	ChunkSize     int
 	ChunksCount   int
 	LastChunkSize int
@@ -0,0 +1,162 @@
+package checksum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+// ErrNotChecksum happens when a checksum operation is called on another
+// storage.
+var ErrNotChecksum = errors.New("not a checksum storage")
+
+var _ storage.Directory = (*directory)(nil)
+
+type directory struct {
+	directory storage.Directory
+}
+
+// NewDirectory creates a new directory representation, storing a SHA-256
+// checksum at the end of each chunk in the wrapped directory. The checksum is
+// verified on each read, a mismatch failing with storage.ErrChunkCorrupted.
+func NewDirectory(d storage.Directory) storage.Directory {
+	return &directory{
+		directory: d,
+	}
+}
+
+func (d *directory) newChildDirectory(child storage.Directory) *directory {
+	return &directory{
+		directory: child,
+	}
+}
+
+// CreateDirectory creates a directory.
+func (d *directory) CreateDirectory(ctx context.Context, name string) (storage.Directory, error) {
+	child, err := d.directory.CreateDirectory(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.newChildDirectory(child), nil
+}
+
+// GetDirectory returns a directory.
+func (d *directory) GetDirectory(ctx context.Context, name string) (storage.Directory, error) {
+	child, err := d.directory.GetDirectory(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.newChildDirectory(child), nil
+}
+
+// GetInfo returns the directory info.
+func (d *directory) GetInfo(ctx context.Context) (info.Directory, error) {
+	return d.directory.GetInfo(ctx)
+}
+
+// SetAttributes sets the directory attributes.
+func (d *directory) SetAttributes(ctx context.Context, attr info.Attributes) error {
+	return d.directory.SetAttributes(ctx, attr)
+}
+
+// CreateFile creates a file, with chunks big enough to hold the checksum.
+func (d *directory) CreateFile(ctx context.Context, name string, fileInfo info.File) (storage.File, error) {
+	// Check chunk size
+	if fileInfo.ChunkSize <= 0 {
+		return nil, fmt.Errorf("%w: %d", storage.ErrInvalidChunkSize, fileInfo.ChunkSize)
+	}
+
+	f, err := d.directory.CreateFile(ctx, name, storedInfo(fileInfo))
+	if err != nil {
+		return nil, err
+	}
+
+	return newFile(f), nil
+}
+
+// GetFile returns a file.
+func (d *directory) GetFile(ctx context.Context, name string) (storage.File, error) {
+	f, err := d.directory.GetFile(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFile(f), nil
+}
+
+// ListFiles returns a map of files.
+func (d *directory) ListFiles(ctx context.Context) (map[string]storage.File, error) {
+	children, err := d.directory.ListFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]storage.File, len(children))
+	for name, f := range children {
+		files[name] = newFile(f)
+	}
+
+	return files, nil
+}
+
+// RemoveDirectory removes a directory.
+func (d *directory) RemoveDirectory(ctx context.Context, name string) error {
+	return d.directory.RemoveDirectory(ctx, name)
+}
+
+// ListDirectories returns a map of directories.
+func (d *directory) ListDirectories(ctx context.Context) (map[string]storage.Directory, error) {
+	children, err := d.directory.ListDirectories(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := make(map[string]storage.Directory, len(children))
+	for name, child := range children {
+		dirs[name] = d.newChildDirectory(child)
+	}
+
+	return dirs, nil
+}
+
+// RemoveFile removes a file.
+func (d *directory) RemoveFile(ctx context.Context, name string) error {
+	return d.directory.RemoveFile(ctx, name)
+}
+
+// RenameFile renames a file.
+func (d *directory) RenameFile(
+	ctx context.Context,
+	name string,
+	newParent storage.Directory,
+	newName string,
+	noReplace bool,
+) error {
+	np, ok := newParent.(*directory)
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrNotChecksum, newParent)
+	}
+
+	return d.directory.RenameFile(ctx, name, np.directory, newName, noReplace)
+}
+
+// RenameDirectory renames a directory.
+func (d *directory) RenameDirectory(
+	ctx context.Context,
+	name string,
+	newParent storage.Directory,
+	newName string,
+	noReplace bool,
+) error {
+	np, ok := newParent.(*directory)
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrNotChecksum, newParent)
+	}
+
+	return d.directory.RenameDirectory(ctx, name, np.directory, newName, noReplace)
+}
@@ -0,0 +1,275 @@
+package checksum
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+// overhead is the size of the checksum stored at the end of each chunk.
+const overhead = sha256.Size
+
+var (
+	_ storage.SparseFile = (*file)(nil)
+	_ storage.Syncer     = (*file)(nil)
+)
+
+type file struct {
+	file storage.File
+}
+
+func newFile(f storage.File) *file {
+	return &file{
+		file: f,
+	}
+}
+
+// storedInfo returns the info of the wrapped file, with the checksum on each
+// chunk.
+func storedInfo(fileInfo info.File) info.File {
+	stored := info.File{
+		Attributes:  fileInfo.Attributes,
+		ChunkSize:   fileInfo.ChunkSize + overhead,
+		ChunksCount: fileInfo.ChunksCount,
+	}
+	if fileInfo.ChunksCount > 0 {
+		stored.LastChunkSize = fileInfo.LastChunkSize + overhead
+	}
+
+	return stored
+}
+
+// seal returns the chunk followed by its checksum.
+func seal(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return append(append(make([]byte, 0, len(data)+overhead), data...), sum[:]...)
+}
+
+// open returns the chunk after verifying its checksum.
+func open(index int, payload []byte) ([]byte, error) {
+	if len(payload) < overhead {
+		return nil, fmt.Errorf("%w: %d", storage.ErrChunkCorrupted, index)
+	}
+
+	data, sum := payload[:len(payload)-overhead], payload[len(payload)-overhead:]
+	if expected := sha256.Sum256(data); !bytes.Equal(sum, expected[:]) {
+		return nil, fmt.Errorf("%w: %d", storage.ErrChunkCorrupted, index)
+	}
+	return data, nil
+}
+
+// GetInfo returns the file info, without the checksums.
+func (f *file) GetInfo(ctx context.Context) (info.File, error) {
+	stored, err := f.file.GetInfo(ctx)
+	if err != nil {
+		return info.File{}, err
+	}
+
+	fileInfo := info.File{
+		Attributes:  stored.Attributes,
+		ChunkSize:   stored.ChunkSize - overhead,
+		ChunksCount: stored.ChunksCount,
+	}
+	if stored.ChunksCount > 0 {
+		fileInfo.LastChunkSize = stored.LastChunkSize - overhead
+		fileInfo.Size = (fileInfo.ChunksCount-1)*fileInfo.ChunkSize + fileInfo.LastChunkSize
+	}
+
+	// Every allocated chunk is full but the last one, that has at least the
+	// checksum: this gives the number of allocated chunks
+	allocatedChunks := (stored.AllocatedSize + stored.ChunkSize - 1) / stored.ChunkSize
+	fileInfo.AllocatedSize = stored.AllocatedSize - allocatedChunks*overhead
+
+	return fileInfo, nil
+}
+
+// SetAttributes sets the file attributes.
+func (f *file) SetAttributes(ctx context.Context, attr info.Attributes) error {
+	return f.file.SetAttributes(ctx, attr)
+}
+
+// readChunk returns the whole chunk verified, or zeros if the wrapped file
+// tells this is a hole. Any other payload must match its checksum.
+func (f *file) readChunk(ctx context.Context, index int) ([]byte, error) {
+	stored, err := f.file.GetInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	hasData, err := f.HasChunkData(ctx, index)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := make([]byte, stored.ChunkSize)
+	n, err := f.file.ReadChunk(ctx, index, payload, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hasData {
+		if n < overhead {
+			return nil, fmt.Errorf("%w: %d", storage.ErrChunkCorrupted, index)
+		}
+		return make([]byte, n-overhead), nil
+	}
+	return open(index, payload[:n])
+}
+
+// writeChunk writes the whole chunk with its checksum.
+func (f *file) writeChunk(ctx context.Context, index int, data []byte) error {
+	_, err := f.file.WriteChunk(ctx, index, seal(data), 0)
+	return err
+}
+
+// ReadChunk reads _ from a chunk, failing if it doesn't match its checksum.
+func (f *file) ReadChunk(ctx context.Context, index int, data []byte, offset int) (int, error) {
+	chunk, err := f.readChunk(ctx, index)
+	if err != nil {
+		return 0, err
+	}
+
+	// Check if offset is correct
+	if offset < 0 || offset >= len(chunk) {
+		return 0, fmt.Errorf("%w: %d", storage.ErrInvalidOffset, offset)
+	}
+
+	return copy(data, chunk[offset:]), nil
+}
+
+// HasChunkData returns true if the chunk holds data, false if this is a hole.
+// Any chunk is considered holding data if the wrapped file cannot tell.
+func (f *file) HasChunkData(ctx context.Context, index int) (bool, error) {
+	sf, ok := f.file.(storage.SparseFile)
+	if !ok {
+		return true, nil
+	}
+
+	return sf.HasChunkData(ctx, index)
+}
+
+// WriteChunk writes _ to a chunk, updating its checksum. A corrupted chunk
+// can only be overwritten as a whole.
+func (f *file) WriteChunk(ctx context.Context, index int, data []byte, offset int) (int, error) {
+	chunk, err := f.readChunk(ctx, index)
+	if errors.Is(err, storage.ErrChunkCorrupted) && offset == 0 {
+		if chunk, err = f.replacedChunk(ctx, index, len(data), err); err != nil {
+			return 0, err
+		}
+	} else if err != nil {
+		return 0, err
+	}
+
+	// Check if offset is correct
+	if offset < 0 || offset >= len(chunk) {
+		return 0, fmt.Errorf("%w: %d", storage.ErrInvalidOffset, offset)
+	}
+
+	written := copy(chunk[offset:], data)
+	return written, f.writeChunk(ctx, index, chunk)
+}
+
+// replacedChunk returns an empty chunk to replace a corrupted one, if the
+// written data covers it entirely, or the corruption error.
+func (f *file) replacedChunk(ctx context.Context, index, size int, errCorrupted error) ([]byte, error) {
+	fileInfo, err := f.GetInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	chunkSize := fileInfo.ChunkSize
+	if index == fileInfo.ChunksCount-1 {
+		chunkSize = fileInfo.LastChunkSize
+	}
+	if size < chunkSize {
+		return nil, errCorrupted
+	}
+
+	return make([]byte, chunkSize), nil
+}
+
+// ImportChunk imports a chunk with its checksum.
+func (f *file) ImportChunk(ctx context.Context, index int, data []byte) error {
+	return f.file.ImportChunk(ctx, index, seal(data))
+}
+
+// ResizeChunksNb resizes the number of chunks. If the wrapped file cannot
+// tell the holes, the added chunks are written as zeros with their checksum,
+// so every chunk read is verified.
+func (f *file) ResizeChunksNb(ctx context.Context, size int) error {
+	stored, err := f.file.GetInfo(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := f.file.ResizeChunksNb(ctx, size); err != nil {
+		return err
+	} else if _, ok := f.file.(storage.SparseFile); ok {
+		return nil
+	}
+
+	for index := stored.ChunksCount; index < size; index++ {
+		if err := f.writeChunk(ctx, index, make([]byte, stored.ChunkSize-overhead)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResizeLastChunk resizes the last chunk, updating its checksum if this is not
+// a hole.
+func (f *file) ResizeLastChunk(ctx context.Context, size int) (int, error) {
+	stored, err := f.file.GetInfo(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Check size is correct
+	if size < 0 || size > stored.ChunkSize-overhead {
+		return 0, fmt.Errorf("%w: %d", storage.ErrInvalidChunkSize, size)
+	}
+
+	// Check if there is a last chunk
+	last := stored.ChunksCount - 1
+	if last < 0 {
+		return 0, fmt.Errorf("%w", storage.ErrNoChunk)
+	}
+
+	// Get the last chunk, if this is not a hole
+	hasData, err := f.HasChunkData(ctx, last)
+	if err != nil {
+		return 0, err
+	}
+	var chunk []byte
+	if hasData {
+		if chunk, err = f.readChunk(ctx, last); err != nil {
+			return 0, err
+		}
+	}
+
+	// Resize it
+	changed, err := f.file.ResizeLastChunk(ctx, size+overhead)
+	if err != nil || !hasData {
+		return changed, err
+	}
+
+	// Update its checksum
+	if size > len(chunk) {
+		chunk = append(chunk, make([]byte, size-len(chunk))...)
+	}
+	return changed, f.writeChunk(ctx, last, chunk[:size])
+}
+
+// Sync syncs the wrapped file, if it keeps data before writing it.
+func (f *file) Sync(ctx context.Context) error {
+	if s, ok := f.file.(storage.Syncer); ok {
+		return s.Sync(ctx)
+	}
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package checksum
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+	"github.com/lerenn/chonkfs/pkg/storage/mem"
+	"github.com/lerenn/chonkfs/pkg/storage/test"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestFileSuite(t *testing.T) {
+	suite.Run(t, new(FileSuite))
+}
+
+type FileSuite struct {
+	Wrapped storage.Directory
+	test.FileSuite
+}
+
+func (suite *FileSuite) SetupTest() {
+	suite.Wrapped = mem.NewDirectory()
+	suite.Directory = NewDirectory(suite.Wrapped)
+}
+
+// corruptChunk changes the first byte of a chunk in the wrapped directory.
+func (suite *FileSuite) corruptChunk(index int) {
+	wf, err := suite.Wrapped.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+	_, err = wf.WriteChunk(context.Background(), index, []byte("X"), 0)
+	suite.Require().NoError(err)
+}
+
+func (suite *FileSuite) TestReadCorruptedChunk() {
+	f, err := suite.Directory.CreateFile(context.Background(), "file", info.File{
+		ChunkSize:     8,
+		ChunksCount:   2,
+		LastChunkSize: 4,
+	})
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.ImportChunk(context.Background(), 0, []byte("ABCDEFGH")))
+	suite.Require().NoError(f.ImportChunk(context.Background(), 1, []byte("IJKL")))
+
+	// Check the info has the sizes without checksums
+	fileInfo, err := f.GetInfo(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal(12, fileInfo.Size)
+	suite.Require().Equal(12, fileInfo.AllocatedSize)
+
+	// Corrupt a chunk and check it fails on read, the other one still being
+	// readable
+	suite.corruptChunk(0)
+	data := make([]byte, 8)
+	_, err = f.ReadChunk(context.Background(), 0, data, 2)
+	suite.Require().ErrorIs(err, storage.ErrChunkCorrupted)
+	_, err = f.ReadChunk(context.Background(), 1, data, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal("IJKL", string(data[:4]))
+
+	// Check a partial write is rejected
+	_, err = f.WriteChunk(context.Background(), 0, []byte("xy"), 0)
+	suite.Require().ErrorIs(err, storage.ErrChunkCorrupted)
+}
+
+func (suite *FileSuite) TestOverwriteCorruptedChunk() {
+	f, err := suite.Directory.CreateFile(context.Background(), "file", info.File{
+		ChunkSize:     8,
+		ChunksCount:   1,
+		LastChunkSize: 8,
+	})
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.ImportChunk(context.Background(), 0, []byte("ABCDEFGH")))
+	suite.corruptChunk(0)
+
+	// Overwrite the whole chunk
+	_, err = f.WriteChunk(context.Background(), 0, []byte("abcdefgh"), 0)
+	suite.Require().NoError(err)
+
+	// Check it reads back
+	data := make([]byte, 8)
+	_, err = f.ReadChunk(context.Background(), 0, data, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal("abcdefgh", string(data))
+}
+
+func (suite *FileSuite) TestReadHole() {
+	f, err := suite.Directory.CreateFile(context.Background(), "file", info.File{ChunkSize: 8})
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.ResizeChunksNb(context.Background(), 1))
+
+	// Check the hole reads as zeros
+	data := []byte("ABCDEFGH")
+	_, err = f.ReadChunk(context.Background(), 0, data, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal(make([]byte, 8), data)
+}
+
+func (suite *FileSuite) TestZeroedChunk() {
+	f, err := suite.Directory.CreateFile(context.Background(), "file", info.File{
+		ChunkSize:     8,
+		ChunksCount:   1,
+		LastChunkSize: 8,
+	})
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.ImportChunk(context.Background(), 0, []byte("AAAAAAAA")))
+
+	// Zero the chunk in the wrapped file
+	wf, err := suite.Wrapped.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+	_, err = wf.WriteChunk(context.Background(), 0, make([]byte, 8+overhead), 0)
+	suite.Require().NoError(err)
+
+	_, err = f.ReadChunk(context.Background(), 0, make([]byte, 8), 0)
+	suite.Require().ErrorIs(err, storage.ErrChunkCorrupted)
+}
+
+func (suite *FileSuite) TestZeroedChunkOnNotSparseFile() {
+	suite.Wrapped = notSparseDirectory{Directory: mem.NewDirectory()}
+	suite.Directory = NewDirectory(suite.Wrapped)
+	f, err := suite.Directory.CreateFile(context.Background(), "file", info.File{ChunkSize: 8})
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.ResizeChunksNb(context.Background(), 1))
+
+	// Check the added chunk reads as zeros
+	data := []byte("ABCDEFGH")
+	_, err = f.ReadChunk(context.Background(), 0, data, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal(make([]byte, 8), data)
+
+	// Zero the chunk in the wrapped file and check it is detected
+	wf, err := suite.Wrapped.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+	_, err = wf.WriteChunk(context.Background(), 0, make([]byte, 8+overhead), 0)
+	suite.Require().NoError(err)
+	_, err = f.ReadChunk(context.Background(), 0, data, 0)
+	suite.Require().ErrorIs(err, storage.ErrChunkCorrupted)
+}
+
+// notSparseDirectory is a directory whose files cannot tell their holes.
+type notSparseDirectory struct {
+	storage.Directory
+}
+
+func (d notSparseDirectory) CreateFile(ctx context.Context, name string, fileInfo info.File) (storage.File, error) {
+	f, err := d.Directory.CreateFile(ctx, name, fileInfo)
+	return struct{ storage.File }{f}, err
+}
+
+func (d notSparseDirectory) GetFile(ctx context.Context, name string) (storage.File, error) {
+	f, err := d.Directory.GetFile(ctx, name)
+	return struct{ storage.File }{f}, err
+}
@@ -0,0 +1,43 @@
+package checksum
+
+import "context"
+
+// GetXattr returns the value of an extended attribute, on the wrapped file.
+func (f *file) GetXattr(ctx context.Context, name string) ([]byte, error) {
+	return f.file.GetXattr(ctx, name)
+}
+
+// ListXattrs returns the names of the extended attributes, on the wrapped file.
+func (f *file) ListXattrs(ctx context.Context) ([]string, error) {
+	return f.file.ListXattrs(ctx)
+}
+
+// SetXattr creates or replaces an extended attribute, on the wrapped file.
+func (f *file) SetXattr(ctx context.Context, name string, value []byte) error {
+	return f.file.SetXattr(ctx, name, value)
+}
+
+// RemoveXattr removes an extended attribute, on the wrapped file.
+func (f *file) RemoveXattr(ctx context.Context, name string) error {
+	return f.file.RemoveXattr(ctx, name)
+}
+
+// GetXattr returns the value of an extended attribute, on the wrapped directory.
+func (d *directory) GetXattr(ctx context.Context, name string) ([]byte, error) {
+	return d.directory.GetXattr(ctx, name)
+}
+
+// ListXattrs returns the names of the extended attributes, on the wrapped directory.
+func (d *directory) ListXattrs(ctx context.Context) ([]string, error) {
+	return d.directory.ListXattrs(ctx)
+}
+
+// SetXattr creates or replaces an extended attribute, on the wrapped directory.
+func (d *directory) SetXattr(ctx context.Context, name string, value []byte) error {
+	return d.directory.SetXattr(ctx, name, value)
+}
+
+// RemoveXattr removes an extended attribute, on the wrapped directory.
+func (d *directory) RemoveXattr(ctx context.Context, name string) error {
+	return d.directory.RemoveXattr(ctx, name)
+}
@@ -0,0 +1,84 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+// Algorithm is a compression algorithm.
+type Algorithm byte
+
+const (
+	// raw is the flag of the chunks stored without compression.
+	raw Algorithm = iota
+	// Zstd compresses the chunks with Zstandard.
+	Zstd
+	// Gzip compresses the chunks with gzip.
+	Gzip
+)
+
+// ErrUnknownAlgorithm happens when a chunk is flagged with an unknown
+// compression algorithm.
+var ErrUnknownAlgorithm = fmt.Errorf("%w: unknown compression algorithm", storage.ErrStorage)
+
+var (
+	// The encoder and decoder can be used concurrently, and can only fail on
+	// invalid options.
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// encode returns the payload of the chunk: its data compressed with the
+// algorithm, or kept raw if it does not get smaller, after a flag byte.
+func encode(algorithm Algorithm, data []byte) ([]byte, error) {
+	var compressed []byte
+	switch algorithm {
+	case Zstd:
+		compressed = zstdEncoder.EncodeAll(data, []byte{byte(Zstd)})
+	case Gzip:
+		buf := bytes.NewBuffer([]byte{byte(Gzip)})
+		w := gzip.NewWriter(buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		} else if err := w.Close(); err != nil {
+			return nil, err
+		}
+		compressed = buf.Bytes()
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrUnknownAlgorithm, algorithm)
+	}
+
+	// Keep the chunk raw if it is incompressible
+	if len(compressed) > len(data) {
+		return append([]byte{byte(raw)}, data...), nil
+	}
+
+	return compressed, nil
+}
+
+// decode returns the data of the chunk from its payload.
+func decode(payload []byte) ([]byte, error) {
+	if len(payload) == 0 {
+		return nil, fmt.Errorf("%w: empty payload", ErrUnknownAlgorithm)
+	}
+
+	switch Algorithm(payload[0]) {
+	case raw:
+		return payload[1:], nil
+	case Zstd:
+		return zstdDecoder.DecodeAll(payload[1:], nil)
+	case Gzip:
+		r, err := gzip.NewReader(bytes.NewReader(payload[1:]))
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(r)
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrUnknownAlgorithm, payload[0])
+	}
+}
@@ -0,0 +1,306 @@
+package compress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+// ErrNotCompress happens when a compress operation is called on another
+// storage.
+var ErrNotCompress = errors.New("not a compress storage")
+
+var _ storage.Directory = (*directory)(nil)
+
+type directoryOption func(dir *directory)
+
+// WithAlgorithm is an option to set the algorithm used to compress the
+// chunks written. By default, it is Zstd. The chunks already stored are read
+// whatever their algorithm.
+//
+//nolint:revive
+func WithAlgorithm(algorithm Algorithm) directoryOption {
+	return func(dir *directory) {
+		dir.algorithm = algorithm
+	}
+}
+
+type directory struct {
+	directory storage.Directory
+	opts      []directoryOption
+	algorithm Algorithm
+}
+
+// NewDirectory creates a new directory representation, compressing the chunks
+// before storing them in the wrapped directory. As the compressed chunks have
+// variable sizes, each file is stored as a directory, with a metadata file and
+// a file per chunk.
+func NewDirectory(d storage.Directory, opts ...directoryOption) storage.Directory {
+	return newDirectory(d, opts...)
+}
+
+func newDirectory(d storage.Directory, opts ...directoryOption) *directory {
+	// Create a default directory
+	dir := &directory{
+		directory: d,
+		opts:      opts,
+		algorithm: Zstd,
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(dir)
+	}
+
+	return dir
+}
+
+// isFile returns true if the wrapped directory stores a file.
+func isFile(ctx context.Context, d storage.Directory) (bool, error) {
+	_, err := d.GetFile(ctx, metadataFileName)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, storage.ErrFileNotFound), errors.Is(err, storage.ErrIsDirectory):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// checkNewName returns an error if the name is already used.
+func (d *directory) checkNewName(ctx context.Context, name string) error {
+	wd, err := d.directory.GetDirectory(ctx, name)
+	if errors.Is(err, storage.ErrDirectoryNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if ok, err := isFile(ctx, wd); err != nil {
+		return err
+	} else if ok {
+		return fmt.Errorf("%w: %q", storage.ErrFileAlreadyExists, name)
+	}
+	return fmt.Errorf("%w: %q", storage.ErrDirectoryAlreadyExists, name)
+}
+
+// getDirectory returns the wrapped directory of a directory.
+func (d *directory) getDirectory(ctx context.Context, name string, errIsFile error) (storage.Directory, error) {
+	wd, err := d.directory.GetDirectory(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok, err := isFile(ctx, wd); err != nil {
+		return nil, err
+	} else if ok {
+		return nil, fmt.Errorf("%w: %q", errIsFile, name)
+	}
+	return wd, nil
+}
+
+// getFile returns the wrapped directory of a file.
+func (d *directory) getFile(ctx context.Context, name string) (*file, error) {
+	wd, err := d.directory.GetDirectory(ctx, name)
+	if errors.Is(err, storage.ErrDirectoryNotFound) {
+		return nil, fmt.Errorf("%w: %q", storage.ErrFileNotFound, name)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if ok, err := isFile(ctx, wd); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, fmt.Errorf("%w: %q", storage.ErrIsDirectory, name)
+	}
+	return newFile(wd, d.algorithm), nil
+}
+
+// CreateDirectory creates a directory.
+func (d *directory) CreateDirectory(ctx context.Context, name string) (storage.Directory, error) {
+	if err := d.checkNewName(ctx, name); err != nil {
+		return nil, err
+	}
+
+	wd, err := d.directory.CreateDirectory(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return newDirectory(wd, d.opts...), nil
+}
+
+// GetDirectory returns a directory.
+func (d *directory) GetDirectory(ctx context.Context, name string) (storage.Directory, error) {
+	wd, err := d.getDirectory(ctx, name, storage.ErrIsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return newDirectory(wd, d.opts...), nil
+}
+
+// GetInfo returns the directory info.
+func (d *directory) GetInfo(ctx context.Context) (info.Directory, error) {
+	return d.directory.GetInfo(ctx)
+}
+
+// SetAttributes sets the directory attributes.
+func (d *directory) SetAttributes(ctx context.Context, attr info.Attributes) error {
+	return d.directory.SetAttributes(ctx, attr)
+}
+
+// CreateFile creates a file.
+func (d *directory) CreateFile(ctx context.Context, name string, info info.File) (storage.File, error) {
+	// Check chunk size
+	if info.ChunkSize <= 0 {
+		return nil, fmt.Errorf("%w: %d", storage.ErrInvalidChunkSize, info.ChunkSize)
+	}
+
+	if err := d.checkNewName(ctx, name); err != nil {
+		return nil, err
+	}
+
+	// Create the file directory and its metadata
+	wd, err := d.directory.CreateDirectory(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	f := newFile(wd, d.algorithm)
+	info.AllocatedSize = 0
+	if err := f.writeMetadata(ctx, info); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// GetFile returns a file.
+func (d *directory) GetFile(ctx context.Context, name string) (storage.File, error) {
+	return d.getFile(ctx, name)
+}
+
+// list returns the wrapped directories, split between files and directories.
+func (d *directory) list(ctx context.Context) (files, dirs map[string]storage.Directory, err error) {
+	children, err := d.directory.ListDirectories(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files = make(map[string]storage.Directory)
+	dirs = make(map[string]storage.Directory)
+	for name, wd := range children {
+		ok, err := isFile(ctx, wd)
+		if err != nil {
+			return nil, nil, err
+		} else if ok {
+			files[name] = wd
+		} else {
+			dirs[name] = wd
+		}
+	}
+
+	return files, dirs, nil
+}
+
+// ListFiles returns a map of files.
+func (d *directory) ListFiles(ctx context.Context) (map[string]storage.File, error) {
+	children, _, err := d.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]storage.File, len(children))
+	for name, wd := range children {
+		files[name] = newFile(wd, d.algorithm)
+	}
+
+	return files, nil
+}
+
+// RemoveDirectory removes a directory.
+func (d *directory) RemoveDirectory(ctx context.Context, name string) error {
+	if _, err := d.getDirectory(ctx, name, storage.ErrIsFile); err != nil {
+		return err
+	}
+
+	return d.directory.RemoveDirectory(ctx, name)
+}
+
+// ListDirectories returns a map of directories.
+func (d *directory) ListDirectories(ctx context.Context) (map[string]storage.Directory, error) {
+	_, children, err := d.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := make(map[string]storage.Directory, len(children))
+	for name, wd := range children {
+		dirs[name] = newDirectory(wd, d.opts...)
+	}
+
+	return dirs, nil
+}
+
+// RemoveFile removes a file.
+func (d *directory) RemoveFile(ctx context.Context, name string) error {
+	if _, err := d.getFile(ctx, name); err != nil {
+		return err
+	}
+
+	return d.directory.RemoveDirectory(ctx, name)
+}
+
+// RenameFile renames a file.
+func (d *directory) RenameFile(
+	ctx context.Context,
+	name string,
+	newParent storage.Directory,
+	newName string,
+	noReplace bool,
+) error {
+	np, ok := newParent.(*directory)
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrNotCompress, newParent)
+	}
+
+	if _, err := d.getFile(ctx, name); err != nil {
+		return err
+	}
+	if noReplace {
+		if err := np.checkNewName(ctx, newName); err != nil {
+			return err
+		}
+	}
+
+	return d.directory.RenameDirectory(ctx, name, np.directory, newName, noReplace)
+}
+
+// RenameDirectory renames a directory.
+func (d *directory) RenameDirectory(
+	ctx context.Context,
+	name string,
+	newParent storage.Directory,
+	newName string,
+	noReplace bool,
+) error {
+	np, ok := newParent.(*directory)
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrNotCompress, newParent)
+	}
+
+	if _, err := d.getDirectory(ctx, name, storage.ErrDirectoryNotFound); err != nil {
+		return err
+	}
+	if noReplace {
+		if err := np.checkNewName(ctx, newName); err != nil {
+			return err
+		}
+	}
+
+	return d.directory.RenameDirectory(ctx, name, np.directory, newName, noReplace)
+}
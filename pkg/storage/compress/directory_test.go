@@ -0,0 +1,21 @@
+package compress
+
+import (
+	"testing"
+
+	"github.com/lerenn/chonkfs/pkg/storage/mem"
+	"github.com/lerenn/chonkfs/pkg/storage/test"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestDirectorySuite(t *testing.T) {
+	suite.Run(t, new(DirectorySuite))
+}
+
+type DirectorySuite struct {
+	test.DirectorySuite
+}
+
+func (suite *DirectorySuite) SetupTest() {
+	suite.Directory = NewDirectory(mem.NewDirectory())
+}
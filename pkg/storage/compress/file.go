@@ -0,0 +1,413 @@
+package compress
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+const (
+	metadataFileName = ".metadata"
+	tmpFileSuffix    = ".tmp"
+)
+
+var _ storage.SparseFile = (*file)(nil)
+
+// file is a file stored as a directory, with a metadata file holding its
+// info and a file per chunk named after its index. A chunk file without chunk
+// is a hole, and a missing chunk file is a chunk not present.
+type file struct {
+	directory storage.Directory
+	algorithm Algorithm
+}
+
+func newFile(d storage.Directory, algorithm Algorithm) *file {
+	return &file{
+		directory: d,
+		algorithm: algorithm,
+	}
+}
+
+func chunkFileName(index int) string {
+	return strconv.Itoa(index)
+}
+
+// getChunkSize returns the size of a chunk.
+func getChunkSize(fileInfo info.File, index int) int {
+	if index == fileInfo.ChunksCount-1 {
+		return fileInfo.LastChunkSize
+	}
+	return fileInfo.ChunkSize
+}
+
+// writeBlob stores the data as a file with a single chunk, replacing the
+// previous one only once the new one is complete.
+func (f *file) writeBlob(ctx context.Context, name string, data []byte) error {
+	tmpName := name + tmpFileSuffix
+	blobInfo := info.File{
+		ChunkSize:     len(data),
+		ChunksCount:   1,
+		LastChunkSize: len(data),
+	}
+
+	// Create a temporary file, removing the one left by a previous failure
+	blob, err := f.directory.CreateFile(ctx, tmpName, blobInfo)
+	if errors.Is(err, storage.ErrFileAlreadyExists) {
+		if err := f.directory.RemoveFile(ctx, tmpName); err != nil {
+			return err
+		}
+		blob, err = f.directory.CreateFile(ctx, tmpName, blobInfo)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Write the data and replace the previous file
+	if err := blob.ImportChunk(ctx, 0, data); err != nil {
+		return err
+	}
+	return f.directory.RenameFile(ctx, tmpName, f.directory, name, false)
+}
+
+// readBlob returns the data of a file with a single chunk.
+func readBlob(ctx context.Context, blob storage.File) ([]byte, error) {
+	blobInfo, err := blob.GetInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, blobInfo.Size)
+	_, err = blob.ReadChunk(ctx, 0, data, 0)
+	return data, err
+}
+
+func (f *file) writeMetadata(ctx context.Context, fileInfo info.File) error {
+	fileInfo.Size = 0
+	if fileInfo.ChunksCount > 0 {
+		fileInfo.Size = (fileInfo.ChunksCount-1)*fileInfo.ChunkSize + fileInfo.LastChunkSize
+	}
+
+	data, err := json.Marshal(fileInfo)
+	if err != nil {
+		return err
+	}
+
+	return f.writeBlob(ctx, metadataFileName, data)
+}
+
+func (f *file) readMetadata(ctx context.Context) (info.File, error) {
+	blob, err := f.directory.GetFile(ctx, metadataFileName)
+	if err != nil {
+		return info.File{}, err
+	}
+
+	data, err := readBlob(ctx, blob)
+	if err != nil {
+		return info.File{}, err
+	}
+
+	var fileInfo info.File
+	if err := json.Unmarshal(data, &fileInfo); err != nil {
+		return info.File{}, err
+	}
+	return fileInfo, nil
+}
+
+// readChunk returns the chunk data, or nil if this is a hole.
+func (f *file) readChunk(ctx context.Context, index int) ([]byte, error) {
+	cf, err := f.directory.GetFile(ctx, chunkFileName(index))
+	if errors.Is(err, storage.ErrFileNotFound) {
+		return nil, fmt.Errorf("%w: %d", storage.ErrChunkNotFound, index)
+	} else if err != nil {
+		return nil, err
+	}
+
+	// A chunk file without chunk is a hole
+	cfInfo, err := cf.GetInfo(ctx)
+	if err != nil {
+		return nil, err
+	} else if cfInfo.ChunksCount == 0 {
+		return nil, nil
+	}
+
+	payload, err := readBlob(ctx, cf)
+	if err != nil {
+		return nil, err
+	}
+	return decode(payload)
+}
+
+// writeChunk stores the chunk data, compressed.
+func (f *file) writeChunk(ctx context.Context, index int, data []byte) error {
+	payload, err := encode(f.algorithm, data)
+	if err != nil {
+		return err
+	}
+
+	return f.writeBlob(ctx, chunkFileName(index), payload)
+}
+
+// GetInfo returns the file info, with the logical sizes.
+func (f *file) GetInfo(ctx context.Context) (info.File, error) {
+	return f.readMetadata(ctx)
+}
+
+// SetAttributes sets the file attributes.
+func (f *file) SetAttributes(ctx context.Context, attr info.Attributes) error {
+	fileInfo, err := f.readMetadata(ctx)
+	if err != nil {
+		return err
+	}
+
+	fileInfo.Attributes = attr
+	return f.writeMetadata(ctx, fileInfo)
+}
+
+func checkReadWriteChunkParams(fileInfo info.File, index int, offset int) error {
+	// Check if chunk index is correct
+	if index < 0 || index >= fileInfo.ChunksCount {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkNb, index)
+	}
+
+	// Check if offset is correct
+	if offset < 0 || offset >= getChunkSize(fileInfo, index) {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidOffset, offset)
+	}
+
+	return nil
+}
+
+// ReadChunk reads _ from a chunk.
+func (f *file) ReadChunk(ctx context.Context, index int, data []byte, offset int) (int, error) {
+	fileInfo, err := f.readMetadata(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Check params
+	if err := checkReadWriteChunkParams(fileInfo, index, offset); err != nil {
+		return 0, err
+	}
+
+	// Get chunk
+	chunk, err := f.readChunk(ctx, index)
+	if err != nil {
+		return 0, err
+	}
+
+	// Read zeros if this is a hole
+	if chunk == nil {
+		size := getChunkSize(fileInfo, index)
+		if len(data) > size-offset {
+			data = data[:size-offset]
+		}
+		clear(data)
+		return len(data), nil
+	}
+
+	return copy(data, chunk[offset:]), nil
+}
+
+// HasChunkData returns true if the chunk holds data, false if this is a hole.
+func (f *file) HasChunkData(ctx context.Context, index int) (bool, error) {
+	fileInfo, err := f.readMetadata(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	// Check if chunk index is correct
+	if index < 0 || index >= fileInfo.ChunksCount {
+		return false, fmt.Errorf("%w: %d", storage.ErrInvalidChunkNb, index)
+	}
+
+	// Check the chunk file
+	cf, err := f.directory.GetFile(ctx, chunkFileName(index))
+	if errors.Is(err, storage.ErrFileNotFound) {
+		return false, fmt.Errorf("%w: %d", storage.ErrChunkNotFound, index)
+	} else if err != nil {
+		return false, err
+	}
+	cfInfo, err := cf.GetInfo(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return cfInfo.ChunksCount > 0, nil
+}
+
+// WriteChunk writes _ to a chunk, decompressing it to write the data and
+// compressing it again.
+func (f *file) WriteChunk(ctx context.Context, index int, data []byte, offset int) (int, error) {
+	fileInfo, err := f.readMetadata(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Check params
+	if err := checkReadWriteChunkParams(fileInfo, index, offset); err != nil {
+		return 0, err
+	}
+
+	// Get chunk, allocating it if this is a hole
+	chunk, err := f.readChunk(ctx, index)
+	if err != nil {
+		return 0, err
+	}
+	wasHole := chunk == nil
+	if wasHole {
+		chunk = make([]byte, getChunkSize(fileInfo, index))
+	}
+
+	// Write data
+	written := copy(chunk[offset:], data)
+	if err := f.writeChunk(ctx, index, chunk); err != nil {
+		return 0, err
+	}
+
+	// Update allocated size
+	if wasHole {
+		fileInfo.AllocatedSize += len(chunk)
+		if err := f.writeMetadata(ctx, fileInfo); err != nil {
+			return 0, err
+		}
+	}
+
+	return written, nil
+}
+
+// ImportChunk imports a chunk.
+func (f *file) ImportChunk(ctx context.Context, index int, data []byte) error {
+	fileInfo, err := f.readMetadata(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Check if chunk index is correct
+	if index < 0 || index >= fileInfo.ChunksCount {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkNb, index)
+	}
+
+	// Check if the chunk is empty
+	if _, err := f.directory.GetFile(ctx, chunkFileName(index)); err == nil {
+		return fmt.Errorf("%w: %d", storage.ErrChunkAlreadyExists, index)
+	} else if !errors.Is(err, storage.ErrFileNotFound) {
+		return err
+	}
+
+	// Check if length of data is correct
+	last := index == fileInfo.ChunksCount-1
+	if (len(data) != fileInfo.ChunkSize && !last) || len(data) > fileInfo.ChunkSize {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkSize, len(data))
+	}
+
+	// Import data
+	if err := f.writeChunk(ctx, index, data); err != nil {
+		return err
+	}
+
+	// Update metadata
+	if last {
+		fileInfo.LastChunkSize = len(data)
+	}
+	fileInfo.AllocatedSize += len(data)
+	return f.writeMetadata(ctx, fileInfo)
+}
+
+// ResizeChunksNb resizes the number of chunks, the added ones being holes.
+func (f *file) ResizeChunksNb(ctx context.Context, size int) error {
+	fileInfo, err := f.readMetadata(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Check size is correct
+	if size < 0 {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkNb, size)
+	}
+
+	// Check the last chunk size is full
+	if fileInfo.ChunksCount > 0 && fileInfo.LastChunkSize != fileInfo.ChunkSize {
+		return fmt.Errorf("%w", storage.ErrLastChunkNotFull)
+	}
+
+	// Add chunks as holes
+	for i := fileInfo.ChunksCount; i < size; i++ {
+		if _, err := f.directory.CreateFile(ctx, chunkFileName(i), info.File{ChunkSize: 1}); err != nil {
+			return err
+		}
+	}
+	if size > fileInfo.ChunksCount {
+		fileInfo.LastChunkSize = fileInfo.ChunkSize
+	}
+
+	// Remove chunks
+	for i := size; i < fileInfo.ChunksCount; i++ {
+		hasData, err := f.HasChunkData(ctx, i)
+		if errors.Is(err, storage.ErrChunkNotFound) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if err := f.directory.RemoveFile(ctx, chunkFileName(i)); err != nil {
+			return err
+		}
+		if hasData {
+			fileInfo.AllocatedSize -= getChunkSize(fileInfo, i)
+		}
+	}
+
+	fileInfo.ChunksCount = size
+	return f.writeMetadata(ctx, fileInfo)
+}
+
+// ResizeLastChunk resizes the last chunk.
+func (f *file) ResizeLastChunk(ctx context.Context, size int) (changed int, err error) {
+	fileInfo, err := f.readMetadata(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Check size is correct
+	if size < 0 || size > fileInfo.ChunkSize {
+		return 0, fmt.Errorf("%w: %d", storage.ErrInvalidChunkSize, size)
+	}
+
+	// Check if there is a last chunk
+	if fileInfo.ChunksCount == 0 {
+		return 0, fmt.Errorf("%w", storage.ErrNoChunk)
+	}
+
+	// Resize last chunk data, if this is not a hole
+	last := fileInfo.ChunksCount - 1
+	chunk, err := f.readChunk(ctx, last)
+	if err != nil {
+		return 0, err
+	}
+	oldSize := fileInfo.LastChunkSize
+	if chunk != nil {
+		if size > oldSize {
+			chunk = append(chunk, make([]byte, size-oldSize)...)
+		} else {
+			chunk = chunk[:size]
+		}
+
+		if err := f.writeChunk(ctx, last, chunk); err != nil {
+			return 0, err
+		}
+		fileInfo.AllocatedSize += size - oldSize
+	}
+
+	// Set size
+	fileInfo.LastChunkSize = size
+	if err := f.writeMetadata(ctx, fileInfo); err != nil {
+		return 0, err
+	}
+
+	return size - oldSize, nil
+}
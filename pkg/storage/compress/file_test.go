@@ -0,0 +1,114 @@
+package compress
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"testing"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+	"github.com/lerenn/chonkfs/pkg/storage/mem"
+	"github.com/lerenn/chonkfs/pkg/storage/test"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestFileSuite(t *testing.T) {
+	suite.Run(t, new(FileSuite))
+}
+
+type FileSuite struct {
+	Wrapped storage.Directory
+	test.FileSuite
+}
+
+func (suite *FileSuite) SetupTest() {
+	suite.Wrapped = mem.NewDirectory()
+	suite.Directory = NewDirectory(suite.Wrapped)
+}
+
+// storedChunk returns the payload of a chunk in the wrapped directory.
+func (suite *FileSuite) storedChunk(index int) []byte {
+	wd, err := suite.Wrapped.GetDirectory(context.Background(), "file")
+	suite.Require().NoError(err)
+	cf, err := wd.GetFile(context.Background(), chunkFileName(index))
+	suite.Require().NoError(err)
+	payload, err := readBlob(context.Background(), cf)
+	suite.Require().NoError(err)
+	return payload
+}
+
+func (suite *FileSuite) testCompressedChunk(algorithm Algorithm) {
+	d := NewDirectory(suite.Wrapped, WithAlgorithm(algorithm))
+	f, err := d.CreateFile(context.Background(), "file", info.File{
+		ChunkSize:     4096,
+		ChunksCount:   1,
+		LastChunkSize: 4096,
+	})
+	suite.Require().NoError(err)
+
+	// Import a compressible chunk
+	chunk := bytes.Repeat([]byte("chonk"), 4096/5+1)[:4096]
+	suite.Require().NoError(f.ImportChunk(context.Background(), 0, chunk))
+
+	// Check it is stored compressed
+	payload := suite.storedChunk(0)
+	suite.Require().Equal(byte(algorithm), payload[0])
+	suite.Require().Less(len(payload), len(chunk))
+
+	// Check the info has the logical sizes
+	fileInfo, err := f.GetInfo(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal(4096, fileInfo.Size)
+	suite.Require().Equal(4096, fileInfo.AllocatedSize)
+
+	// Check it reads back
+	data := make([]byte, 4096)
+	_, err = f.ReadChunk(context.Background(), 0, data, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal(chunk, data)
+}
+
+func (suite *FileSuite) TestZstdChunk() {
+	suite.testCompressedChunk(Zstd)
+}
+
+func (suite *FileSuite) TestGzipChunk() {
+	suite.testCompressedChunk(Gzip)
+}
+
+func (suite *FileSuite) TestIncompressibleChunk() {
+	f, err := suite.Directory.CreateFile(context.Background(), "file", info.File{
+		ChunkSize:     4096,
+		ChunksCount:   1,
+		LastChunkSize: 4096,
+	})
+	suite.Require().NoError(err)
+
+	// Import a random chunk
+	chunk := make([]byte, 4096)
+	_, err = rand.Read(chunk)
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.ImportChunk(context.Background(), 0, chunk))
+
+	// Check it is stored raw
+	payload := suite.storedChunk(0)
+	suite.Require().Equal(byte(raw), payload[0])
+	suite.Require().Equal(chunk, payload[1:])
+}
+
+func (suite *FileSuite) TestWriteChunkWithOffset() {
+	f, err := suite.Directory.CreateFile(context.Background(), "file", info.File{ChunkSize: 8})
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.ResizeChunksNb(context.Background(), 1))
+
+	_, err = f.WriteChunk(context.Background(), 0, []byte("ABCDEFGH"), 0)
+	suite.Require().NoError(err)
+	_, err = f.WriteChunk(context.Background(), 0, []byte("xy"), 3)
+	suite.Require().NoError(err)
+
+	data := make([]byte, 8)
+	_, err = f.ReadChunk(context.Background(), 0, data, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal("ABCxyFGH", string(data))
+}
@@ -0,0 +1,141 @@
+package crypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/lerenn/chonkfs/pkg/storage"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// MinKeySize is the minimum size of the key, in bytes.
+	MinKeySize = 32
+
+	nonceSize = 12
+	tagSize   = 16
+	// overhead is the size added to each chunk by the encryption.
+	overhead = nonceSize + tagSize
+)
+
+var (
+	// ErrInvalidKey happens when the key is too short.
+	ErrInvalidKey = errors.New("invalid key")
+	// ErrDecryption happens when encrypted data cannot be authenticated.
+	ErrDecryption = fmt.Errorf("%w: decryption failed", storage.ErrStorage)
+)
+
+// keys are the keys derived from the key given by the user.
+type keys struct {
+	chunks cipher.AEAD
+	names  cipher.AEAD
+	// nameNonce is the key used to derive the nonce of a name from the name.
+	nameNonce []byte
+}
+
+func newKeys(key []byte) (*keys, error) {
+	if len(key) < MinKeySize {
+		return nil, fmt.Errorf("%w: %d bytes instead of at least %d", ErrInvalidKey, len(key), MinKeySize)
+	}
+
+	chunks, err := newAEAD(key, "chunks")
+	if err != nil {
+		return nil, err
+	}
+	names, err := newAEAD(key, "names")
+	if err != nil {
+		return nil, err
+	}
+	nameNonce, err := deriveKey(key, "name-nonces")
+	if err != nil {
+		return nil, err
+	}
+
+	return &keys{
+		chunks:    chunks,
+		names:     names,
+		nameNonce: nameNonce,
+	}, nil
+}
+
+// deriveKey derives a key for a purpose from the key given by the user.
+func deriveKey(key []byte, purpose string) ([]byte, error) {
+	derived := make([]byte, 32)
+	_, err := io.ReadFull(hkdf.New(sha256.New, key, nil, []byte("chonkfs-crypt-"+purpose)), derived)
+	return derived, err
+}
+
+func newAEAD(key []byte, purpose string) (cipher.AEAD, error) {
+	derived, err := deriveKey(key, purpose)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(derived)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkAdditionalData binds the encrypted chunk to its index.
+func chunkAdditionalData(index int) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(index))
+}
+
+// encryptChunk returns the chunk encrypted with a random nonce, followed by
+// the nonce.
+func (k *keys) encryptChunk(index int, data []byte) ([]byte, error) {
+	nonce := make([]byte, nonceSize, nonceSize+len(data)+tagSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return k.chunks.Seal(nonce, nonce, data, chunkAdditionalData(index)), nil
+}
+
+// decryptChunk returns the chunk decrypted.
+func (k *keys) decryptChunk(index int, payload []byte) ([]byte, error) {
+	if len(payload) < overhead {
+		return nil, fmt.Errorf("%w: chunk %d too short", ErrDecryption, index)
+	}
+
+	data, err := k.chunks.Open(nil, payload[:nonceSize], payload[nonceSize:], chunkAdditionalData(index))
+	if err != nil {
+		return nil, fmt.Errorf("%w: chunk %d", ErrDecryption, index)
+	}
+	return data, nil
+}
+
+// encryptName returns the name encrypted, with a nonce derived from it so the
+// same name is always encrypted the same way.
+func (k *keys) encryptName(name string) string {
+	mac := hmac.New(sha256.New, k.nameNonce)
+	mac.Write([]byte(name))
+	nonce := mac.Sum(nil)[:nonceSize]
+
+	sealed := k.names.Seal(nonce, nonce, []byte(name), nil)
+	return base64.RawURLEncoding.EncodeToString(sealed)
+}
+
+// decryptName returns the name decrypted.
+func (k *keys) decryptName(encrypted string) (string, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(encrypted)
+	if err != nil || len(sealed) < overhead {
+		return "", fmt.Errorf("%w: name %q", ErrDecryption, encrypted)
+	}
+
+	name, err := k.names.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return "", fmt.Errorf("%w: name %q", ErrDecryption, encrypted)
+	}
+	return string(name), nil
+}
@@ -0,0 +1,219 @@
+package crypt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+// ErrNotCrypt happens when a crypt operation is called on another storage.
+var ErrNotCrypt = errors.New("not a crypt storage")
+
+var _ storage.Directory = (*directory)(nil)
+
+type directoryOption func(dir *directory)
+
+// WithEncryptedNames is an option to also encrypt the names of the files and
+// directories. The same name is always encrypted the same way, so names can
+// still be looked up.
+//
+//nolint:revive
+func WithEncryptedNames() directoryOption {
+	return func(dir *directory) {
+		dir.encryptNames = true
+	}
+}
+
+type directory struct {
+	directory    storage.Directory
+	keys         *keys
+	encryptNames bool
+}
+
+// NewDirectory creates a new directory representation, encrypting each chunk
+// with AES-GCM before storing it in the wrapped directory. Each write uses a
+// new random nonce and the chunk index is authenticated with the chunk, so
+// chunks cannot be swapped. The keys are derived from the given key, that
+// should be at least MinKeySize random bytes.
+func NewDirectory(d storage.Directory, key []byte, opts ...directoryOption) (storage.Directory, error) {
+	k, err := newKeys(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a default directory
+	dir := &directory{
+		directory: d,
+		keys:      k,
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(dir)
+	}
+
+	return dir, nil
+}
+
+func (d *directory) newChildDirectory(child storage.Directory) *directory {
+	return &directory{
+		directory:    child,
+		keys:         d.keys,
+		encryptNames: d.encryptNames,
+	}
+}
+
+// storedName returns the name in the wrapped directory.
+func (d *directory) storedName(name string) string {
+	if !d.encryptNames {
+		return name
+	}
+	return d.keys.encryptName(name)
+}
+
+// name returns the name from the wrapped directory.
+func (d *directory) name(storedName string) (string, error) {
+	if !d.encryptNames {
+		return storedName, nil
+	}
+	return d.keys.decryptName(storedName)
+}
+
+// CreateDirectory creates a directory.
+func (d *directory) CreateDirectory(ctx context.Context, name string) (storage.Directory, error) {
+	child, err := d.directory.CreateDirectory(ctx, d.storedName(name))
+	if err != nil {
+		return nil, err
+	}
+
+	return d.newChildDirectory(child), nil
+}
+
+// GetDirectory returns a directory.
+func (d *directory) GetDirectory(ctx context.Context, name string) (storage.Directory, error) {
+	child, err := d.directory.GetDirectory(ctx, d.storedName(name))
+	if err != nil {
+		return nil, err
+	}
+
+	return d.newChildDirectory(child), nil
+}
+
+// GetInfo returns the directory info.
+func (d *directory) GetInfo(ctx context.Context) (info.Directory, error) {
+	return d.directory.GetInfo(ctx)
+}
+
+// SetAttributes sets the directory attributes.
+func (d *directory) SetAttributes(ctx context.Context, attr info.Attributes) error {
+	return d.directory.SetAttributes(ctx, attr)
+}
+
+// CreateFile creates a file, with chunks big enough to hold the encryption
+// overhead.
+func (d *directory) CreateFile(ctx context.Context, name string, fileInfo info.File) (storage.File, error) {
+	// Check chunk size
+	if fileInfo.ChunkSize <= 0 {
+		return nil, fmt.Errorf("%w: %d", storage.ErrInvalidChunkSize, fileInfo.ChunkSize)
+	}
+
+	f, err := d.directory.CreateFile(ctx, d.storedName(name), storedInfo(fileInfo))
+	if err != nil {
+		return nil, err
+	}
+
+	return newFile(f, d.keys), nil
+}
+
+// GetFile returns a file.
+func (d *directory) GetFile(ctx context.Context, name string) (storage.File, error) {
+	f, err := d.directory.GetFile(ctx, d.storedName(name))
+	if err != nil {
+		return nil, err
+	}
+
+	return newFile(f, d.keys), nil
+}
+
+// ListFiles returns a map of files.
+func (d *directory) ListFiles(ctx context.Context) (map[string]storage.File, error) {
+	children, err := d.directory.ListFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]storage.File, len(children))
+	for storedName, f := range children {
+		name, err := d.name(storedName)
+		if err != nil {
+			return nil, err
+		}
+		files[name] = newFile(f, d.keys)
+	}
+
+	return files, nil
+}
+
+// RemoveDirectory removes a directory.
+func (d *directory) RemoveDirectory(ctx context.Context, name string) error {
+	return d.directory.RemoveDirectory(ctx, d.storedName(name))
+}
+
+// ListDirectories returns a map of directories.
+func (d *directory) ListDirectories(ctx context.Context) (map[string]storage.Directory, error) {
+	children, err := d.directory.ListDirectories(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := make(map[string]storage.Directory, len(children))
+	for storedName, child := range children {
+		name, err := d.name(storedName)
+		if err != nil {
+			return nil, err
+		}
+		dirs[name] = d.newChildDirectory(child)
+	}
+
+	return dirs, nil
+}
+
+// RemoveFile removes a file.
+func (d *directory) RemoveFile(ctx context.Context, name string) error {
+	return d.directory.RemoveFile(ctx, d.storedName(name))
+}
+
+// RenameFile renames a file.
+func (d *directory) RenameFile(
+	ctx context.Context,
+	name string,
+	newParent storage.Directory,
+	newName string,
+	noReplace bool,
+) error {
+	np, ok := newParent.(*directory)
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrNotCrypt, newParent)
+	}
+
+	return d.directory.RenameFile(ctx, d.storedName(name), np.directory, np.storedName(newName), noReplace)
+}
+
+// RenameDirectory renames a directory.
+func (d *directory) RenameDirectory(
+	ctx context.Context,
+	name string,
+	newParent storage.Directory,
+	newName string,
+	noReplace bool,
+) error {
+	np, ok := newParent.(*directory)
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrNotCrypt, newParent)
+	}
+
+	return d.directory.RenameDirectory(ctx, d.storedName(name), np.directory, np.storedName(newName), noReplace)
+}
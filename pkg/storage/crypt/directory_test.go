@@ -0,0 +1,63 @@
+package crypt
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/lerenn/chonkfs/pkg/storage"
+	"github.com/lerenn/chonkfs/pkg/storage/mem"
+	"github.com/lerenn/chonkfs/pkg/storage/test"
+	"github.com/stretchr/testify/suite"
+)
+
+var testKey = bytes.Repeat([]byte("k"), MinKeySize)
+
+func TestDirectorySuite(t *testing.T) {
+	suite.Run(t, new(DirectorySuite))
+}
+
+type DirectorySuite struct {
+	test.DirectorySuite
+}
+
+func (suite *DirectorySuite) SetupTest() {
+	d, err := NewDirectory(mem.NewDirectory(), testKey)
+	suite.Require().NoError(err)
+	suite.Directory = d
+}
+
+func TestDirectoryWithEncryptedNamesSuite(t *testing.T) {
+	suite.Run(t, new(DirectoryWithEncryptedNamesSuite))
+}
+
+type DirectoryWithEncryptedNamesSuite struct {
+	Wrapped storage.Directory
+	test.DirectorySuite
+}
+
+func (suite *DirectoryWithEncryptedNamesSuite) SetupTest() {
+	suite.Wrapped = mem.NewDirectory()
+	d, err := NewDirectory(suite.Wrapped, testKey, WithEncryptedNames())
+	suite.Require().NoError(err)
+	suite.Directory = d
+}
+
+func (suite *DirectoryWithEncryptedNamesSuite) TestNamesAreEncrypted() {
+	_, err := suite.Directory.CreateDirectory(context.Background(), "secret")
+	suite.Require().NoError(err)
+
+	dirs, err := suite.Wrapped.ListDirectories(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Len(dirs, 1)
+	suite.Require().NotContains(dirs, "secret")
+
+	dirs, err = suite.Directory.ListDirectories(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Contains(dirs, "secret")
+}
+
+func (suite *DirectoryWithEncryptedNamesSuite) TestInvalidKey() {
+	_, err := NewDirectory(suite.Wrapped, testKey[:MinKeySize-1])
+	suite.Require().ErrorIs(err, ErrInvalidKey)
+}
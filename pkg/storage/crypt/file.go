@@ -0,0 +1,239 @@
+package crypt
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+var (
+	_ storage.SparseFile = (*file)(nil)
+	_ storage.Syncer     = (*file)(nil)
+)
+
+type file struct {
+	file storage.File
+	keys *keys
+}
+
+func newFile(f storage.File, k *keys) *file {
+	return &file{
+		file: f,
+		keys: k,
+	}
+}
+
+// storedInfo returns the info of the wrapped file, with the encryption
+// overhead on each chunk.
+func storedInfo(fileInfo info.File) info.File {
+	stored := info.File{
+		Attributes:  fileInfo.Attributes,
+		ChunkSize:   fileInfo.ChunkSize + overhead,
+		ChunksCount: fileInfo.ChunksCount,
+	}
+	if fileInfo.ChunksCount > 0 {
+		stored.LastChunkSize = fileInfo.LastChunkSize + overhead
+	}
+
+	return stored
+}
+
+// GetInfo returns the file info, without the encryption overhead.
+func (f *file) GetInfo(ctx context.Context) (info.File, error) {
+	stored, err := f.file.GetInfo(ctx)
+	if err != nil {
+		return info.File{}, err
+	}
+
+	fileInfo := info.File{
+		Attributes:  stored.Attributes,
+		ChunkSize:   stored.ChunkSize - overhead,
+		ChunksCount: stored.ChunksCount,
+	}
+	if stored.ChunksCount > 0 {
+		fileInfo.LastChunkSize = stored.LastChunkSize - overhead
+		fileInfo.Size = (fileInfo.ChunksCount-1)*fileInfo.ChunkSize + fileInfo.LastChunkSize
+	}
+
+	// Every allocated chunk is full but the last one, that has at least the
+	// overhead: this gives the number of allocated chunks
+	allocatedChunks := (stored.AllocatedSize + stored.ChunkSize - 1) / stored.ChunkSize
+	fileInfo.AllocatedSize = stored.AllocatedSize - allocatedChunks*overhead
+
+	return fileInfo, nil
+}
+
+// SetAttributes sets the file attributes.
+func (f *file) SetAttributes(ctx context.Context, attr info.Attributes) error {
+	return f.file.SetAttributes(ctx, attr)
+}
+
+// readChunk returns the whole chunk decrypted, or zeros if the wrapped file
+// tells this is a hole. Any other payload must be authenticated.
+func (f *file) readChunk(ctx context.Context, index int) ([]byte, error) {
+	stored, err := f.file.GetInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	hasData, err := f.HasChunkData(ctx, index)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := make([]byte, stored.ChunkSize)
+	n, err := f.file.ReadChunk(ctx, index, payload, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hasData {
+		if n < overhead {
+			return nil, fmt.Errorf("%w: chunk %d too short", ErrDecryption, index)
+		}
+		return make([]byte, n-overhead), nil
+	}
+	return f.keys.decryptChunk(index, payload[:n])
+}
+
+// writeChunk encrypts and writes the whole chunk.
+func (f *file) writeChunk(ctx context.Context, index int, data []byte) error {
+	payload, err := f.keys.encryptChunk(index, data)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.file.WriteChunk(ctx, index, payload, 0)
+	return err
+}
+
+// ReadChunk reads _ from a chunk.
+func (f *file) ReadChunk(ctx context.Context, index int, data []byte, offset int) (int, error) {
+	chunk, err := f.readChunk(ctx, index)
+	if err != nil {
+		return 0, err
+	}
+
+	// Check if offset is correct
+	if offset < 0 || offset >= len(chunk) {
+		return 0, fmt.Errorf("%w: %d", storage.ErrInvalidOffset, offset)
+	}
+
+	return copy(data, chunk[offset:]), nil
+}
+
+// HasChunkData returns true if the chunk holds data, false if this is a hole.
+// Any chunk is considered holding data if the wrapped file cannot tell.
+func (f *file) HasChunkData(ctx context.Context, index int) (bool, error) {
+	sf, ok := f.file.(storage.SparseFile)
+	if !ok {
+		return true, nil
+	}
+
+	return sf.HasChunkData(ctx, index)
+}
+
+// WriteChunk writes _ to a chunk, decrypting it to write the data and
+// encrypting it again with a new nonce.
+func (f *file) WriteChunk(ctx context.Context, index int, data []byte, offset int) (int, error) {
+	chunk, err := f.readChunk(ctx, index)
+	if err != nil {
+		return 0, err
+	}
+
+	// Check if offset is correct
+	if offset < 0 || offset >= len(chunk) {
+		return 0, fmt.Errorf("%w: %d", storage.ErrInvalidOffset, offset)
+	}
+
+	written := copy(chunk[offset:], data)
+	return written, f.writeChunk(ctx, index, chunk)
+}
+
+// ImportChunk imports a chunk.
+func (f *file) ImportChunk(ctx context.Context, index int, data []byte) error {
+	payload, err := f.keys.encryptChunk(index, data)
+	if err != nil {
+		return err
+	}
+
+	return f.file.ImportChunk(ctx, index, payload)
+}
+
+// ResizeChunksNb resizes the number of chunks. If the wrapped file cannot
+// tell the holes, the added chunks are written as encrypted zeros, so every
+// chunk read is authenticated.
+func (f *file) ResizeChunksNb(ctx context.Context, size int) error {
+	stored, err := f.file.GetInfo(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := f.file.ResizeChunksNb(ctx, size); err != nil {
+		return err
+	} else if _, ok := f.file.(storage.SparseFile); ok {
+		return nil
+	}
+
+	for index := stored.ChunksCount; index < size; index++ {
+		if err := f.writeChunk(ctx, index, make([]byte, stored.ChunkSize-overhead)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResizeLastChunk resizes the last chunk, encrypting it again if this is not
+// a hole.
+func (f *file) ResizeLastChunk(ctx context.Context, size int) (int, error) {
+	stored, err := f.file.GetInfo(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Check size is correct
+	if size < 0 || size > stored.ChunkSize-overhead {
+		return 0, fmt.Errorf("%w: %d", storage.ErrInvalidChunkSize, size)
+	}
+
+	// Check if there is a last chunk
+	last := stored.ChunksCount - 1
+	if last < 0 {
+		return 0, fmt.Errorf("%w", storage.ErrNoChunk)
+	}
+
+	// Get the last chunk, if this is not a hole
+	hasData, err := f.HasChunkData(ctx, last)
+	if err != nil {
+		return 0, err
+	}
+	var chunk []byte
+	if hasData {
+		if chunk, err = f.readChunk(ctx, last); err != nil {
+			return 0, err
+		}
+	}
+
+	// Resize it
+	changed, err := f.file.ResizeLastChunk(ctx, size+overhead)
+	if err != nil || !hasData {
+		return changed, err
+	}
+
+	// Encrypt it again
+	if size > len(chunk) {
+		chunk = append(chunk, make([]byte, size-len(chunk))...)
+	}
+	return changed, f.writeChunk(ctx, last, chunk[:size])
+}
+
+// Sync syncs the wrapped file, if it keeps data before writing it.
+func (f *file) Sync(ctx context.Context) error {
+	if s, ok := f.file.(storage.Syncer); ok {
+		return s.Sync(ctx)
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package crypt
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+	"github.com/lerenn/chonkfs/pkg/storage/mem"
+	"github.com/lerenn/chonkfs/pkg/storage/test"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestFileSuite(t *testing.T) {
+	suite.Run(t, new(FileSuite))
+}
+
+type FileSuite struct {
+	Wrapped storage.Directory
+	test.FileSuite
+}
+
+func (suite *FileSuite) SetupTest() {
+	suite.Wrapped = mem.NewDirectory()
+	d, err := NewDirectory(suite.Wrapped, testKey)
+	suite.Require().NoError(err)
+	suite.Directory = d
+}
+
+func (suite *FileSuite) storedChunk(index int) []byte {
+	wf, err := suite.Wrapped.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+	data := make([]byte, 64)
+	n, err := wf.ReadChunk(context.Background(), index, data, 0)
+	suite.Require().NoError(err)
+	return data[:n]
+}
+
+func (suite *FileSuite) TestChunksAreEncrypted() {
+	f, err := suite.Directory.CreateFile(context.Background(), "file", info.File{ChunkSize: 8})
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.ResizeChunksNb(context.Background(), 1))
+	_, err = f.WriteChunk(context.Background(), 0, []byte("ABCDEFGH"), 0)
+	suite.Require().NoError(err)
+
+	// Check the chunk is encrypted, with a new nonce on each write
+	stored := suite.storedChunk(0)
+	suite.Require().Len(stored, 8+overhead)
+	suite.Require().False(bytes.Contains(stored, []byte("ABCDEFGH")))
+	_, err = f.WriteChunk(context.Background(), 0, []byte("xy"), 3)
+	suite.Require().NoError(err)
+	suite.Require().NotEqual(stored[:nonceSize], suite.storedChunk(0)[:nonceSize])
+
+	// Check it reads back, with the sizes without overhead
+	data := make([]byte, 8)
+	_, err = f.ReadChunk(context.Background(), 0, data, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal("ABCxyFGH", string(data))
+	fileInfo, err := f.GetInfo(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal(info.File{
+		Size:          8,
+		AllocatedSize: 8,
+		ChunkSize:     8,
+		ChunksCount:   1,
+		LastChunkSize: 8,
+	}, fileInfo)
+}
+
+func (suite *FileSuite) TestSwappedChunks() {
+	f, err := suite.Directory.CreateFile(context.Background(), "file", info.File{
+		ChunkSize:     8,
+		ChunksCount:   2,
+		LastChunkSize: 8,
+	})
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.ImportChunk(context.Background(), 0, []byte("AAAAAAAA")))
+	suite.Require().NoError(f.ImportChunk(context.Background(), 1, []byte("BBBBBBBB")))
+
+	// Swap the chunks in the wrapped file
+	first, second := suite.storedChunk(0), suite.storedChunk(1)
+	wf, err := suite.Wrapped.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+	_, err = wf.WriteChunk(context.Background(), 0, second, 0)
+	suite.Require().NoError(err)
+	_, err = wf.WriteChunk(context.Background(), 1, first, 0)
+	suite.Require().NoError(err)
+
+	_, err = f.ReadChunk(context.Background(), 0, make([]byte, 8), 0)
+	suite.Require().ErrorIs(err, ErrDecryption)
+}
+
+func (suite *FileSuite) TestZeroedChunk() {
+	f, err := suite.Directory.CreateFile(context.Background(), "file", info.File{
+		ChunkSize:     8,
+		ChunksCount:   1,
+		LastChunkSize: 8,
+	})
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.ImportChunk(context.Background(), 0, []byte("AAAAAAAA")))
+
+	// Zero the chunk in the wrapped file
+	wf, err := suite.Wrapped.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+	_, err = wf.WriteChunk(context.Background(), 0, make([]byte, 8+overhead), 0)
+	suite.Require().NoError(err)
+
+	_, err = f.ReadChunk(context.Background(), 0, make([]byte, 8), 0)
+	suite.Require().ErrorIs(err, ErrDecryption)
+}
+
+func (suite *FileSuite) TestResizeLastChunkWithData() {
+	f, err := suite.Directory.CreateFile(context.Background(), "file", info.File{ChunkSize: 8})
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.ResizeChunksNb(context.Background(), 1))
+	_, err = f.WriteChunk(context.Background(), 0, []byte("ABCDEFGH"), 0)
+	suite.Require().NoError(err)
+
+	changed, err := f.ResizeLastChunk(context.Background(), 3)
+	suite.Require().NoError(err)
+	suite.Require().Equal(-5, changed)
+
+	data := make([]byte, 8)
+	n, err := f.ReadChunk(context.Background(), 0, data, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal("ABC", string(data[:n]))
+}
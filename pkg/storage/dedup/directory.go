@@ -0,0 +1,372 @@
+package dedup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+var (
+	// ErrNotDedup happens when a dedup operation is called on another storage.
+	ErrNotDedup = errors.New("not a dedup storage")
+	// ErrMissingPoolChunk happens when a chunk referenced by a file is not in
+	// the pool.
+	ErrMissingPoolChunk = fmt.Errorf("%w: missing chunk in pool", storage.ErrStorage)
+)
+
+var _ storage.Directory = (*directory)(nil)
+
+type directory struct {
+	directory storage.Directory
+	pool      *pool
+	path      string
+}
+
+// NewDirectory creates a new directory representation, storing the chunks
+// once per content in the pool directory, with a count of references, and
+// the files as directories in the tree directory, with a metadata file
+// referencing their chunks. The chunks not referenced anymore are removed
+// from the pool.
+func NewDirectory(tree, pool storage.Directory) storage.Directory {
+	return &directory{
+		directory: tree,
+		pool:      newPool(pool),
+		path:      "/",
+	}
+}
+
+func (d *directory) newChildDirectory(child storage.Directory, name string) *directory {
+	return &directory{
+		directory: child,
+		pool:      d.pool,
+		path:      path.Join(d.path, name),
+	}
+}
+
+func (d *directory) newChildFile(child storage.Directory, name string) *file {
+	return newFile(child, d.pool, path.Join(d.path, name))
+}
+
+// isFile returns true if the wrapped directory stores a file.
+func isFile(ctx context.Context, d storage.Directory) (bool, error) {
+	_, err := d.GetFile(ctx, metadataFileName)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, storage.ErrFileNotFound), errors.Is(err, storage.ErrIsDirectory):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// checkNewName returns an error if the name is already used.
+func (d *directory) checkNewName(ctx context.Context, name string) error {
+	wd, err := d.directory.GetDirectory(ctx, name)
+	if errors.Is(err, storage.ErrDirectoryNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if ok, err := isFile(ctx, wd); err != nil {
+		return err
+	} else if ok {
+		return fmt.Errorf("%w: %q", storage.ErrFileAlreadyExists, name)
+	}
+	return fmt.Errorf("%w: %q", storage.ErrDirectoryAlreadyExists, name)
+}
+
+// getDirectory returns the wrapped directory of a directory.
+func (d *directory) getDirectory(ctx context.Context, name string, errIsFile error) (storage.Directory, error) {
+	wd, err := d.directory.GetDirectory(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok, err := isFile(ctx, wd); err != nil {
+		return nil, err
+	} else if ok {
+		return nil, fmt.Errorf("%w: %q", errIsFile, name)
+	}
+	return wd, nil
+}
+
+// getFile returns the file, stored in a wrapped directory.
+func (d *directory) getFile(ctx context.Context, name string) (*file, error) {
+	wd, err := d.directory.GetDirectory(ctx, name)
+	if errors.Is(err, storage.ErrDirectoryNotFound) {
+		return nil, fmt.Errorf("%w: %q", storage.ErrFileNotFound, name)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if ok, err := isFile(ctx, wd); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, fmt.Errorf("%w: %q", storage.ErrIsDirectory, name)
+	}
+	return d.newChildFile(wd, name), nil
+}
+
+// referencedChunks returns the chunks referenced by the files of the wrapped
+// directory, and of its children.
+func (d *directory) referencedChunks(ctx context.Context, wd storage.Directory) ([]string, error) {
+	if ok, err := isFile(ctx, wd); err != nil {
+		return nil, err
+	} else if ok {
+		md, err := newFile(wd, d.pool, "").readMetadata(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return md.hashes(), nil
+	}
+
+	children, err := wd.ListDirectories(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var hashes []string
+	for _, child := range children {
+		childHashes, err := d.referencedChunks(ctx, child)
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, childHashes...)
+	}
+
+	return hashes, nil
+}
+
+// removeWrapped runs the removal of a wrapped directory, then releases the
+// chunks that were referenced inside.
+func (d *directory) removeWrapped(ctx context.Context, wd storage.Directory, remove func() error) error {
+	var hashes []string
+	if wd != nil {
+		var err error
+		if hashes, err = d.referencedChunks(ctx, wd); err != nil {
+			return err
+		}
+	}
+
+	if err := remove(); err != nil {
+		return err
+	}
+
+	return d.pool.release(ctx, hashes...)
+}
+
+// CreateDirectory creates a directory.
+func (d *directory) CreateDirectory(ctx context.Context, name string) (storage.Directory, error) {
+	if err := d.checkNewName(ctx, name); err != nil {
+		return nil, err
+	}
+
+	wd, err := d.directory.CreateDirectory(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.newChildDirectory(wd, name), nil
+}
+
+// GetDirectory returns a directory.
+func (d *directory) GetDirectory(ctx context.Context, name string) (storage.Directory, error) {
+	wd, err := d.getDirectory(ctx, name, storage.ErrIsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.newChildDirectory(wd, name), nil
+}
+
+// GetInfo returns the directory info.
+func (d *directory) GetInfo(ctx context.Context) (info.Directory, error) {
+	return d.directory.GetInfo(ctx)
+}
+
+// SetAttributes sets the directory attributes.
+func (d *directory) SetAttributes(ctx context.Context, attr info.Attributes) error {
+	return d.directory.SetAttributes(ctx, attr)
+}
+
+// CreateFile creates a file.
+func (d *directory) CreateFile(ctx context.Context, name string, info info.File) (storage.File, error) {
+	// Check chunk size
+	if info.ChunkSize <= 0 {
+		return nil, fmt.Errorf("%w: %d", storage.ErrInvalidChunkSize, info.ChunkSize)
+	}
+
+	if err := d.checkNewName(ctx, name); err != nil {
+		return nil, err
+	}
+
+	// Create the file directory and its metadata, without chunks present
+	wd, err := d.directory.CreateDirectory(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	f := d.newChildFile(wd, name)
+	info.AllocatedSize = 0
+	if err := f.writeMetadata(ctx, metadata{
+		File:   info,
+		Chunks: make([]string, info.ChunksCount),
+	}); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// GetFile returns a file.
+func (d *directory) GetFile(ctx context.Context, name string) (storage.File, error) {
+	return d.getFile(ctx, name)
+}
+
+// list returns the wrapped directories, split between files and directories.
+func (d *directory) list(ctx context.Context) (files, dirs map[string]storage.Directory, err error) {
+	children, err := d.directory.ListDirectories(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files = make(map[string]storage.Directory)
+	dirs = make(map[string]storage.Directory)
+	for name, wd := range children {
+		ok, err := isFile(ctx, wd)
+		if err != nil {
+			return nil, nil, err
+		} else if ok {
+			files[name] = wd
+		} else {
+			dirs[name] = wd
+		}
+	}
+
+	return files, dirs, nil
+}
+
+// ListFiles returns a map of files.
+func (d *directory) ListFiles(ctx context.Context) (map[string]storage.File, error) {
+	children, _, err := d.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]storage.File, len(children))
+	for name, wd := range children {
+		files[name] = d.newChildFile(wd, name)
+	}
+
+	return files, nil
+}
+
+// RemoveDirectory removes a directory, and releases the chunks of its files.
+func (d *directory) RemoveDirectory(ctx context.Context, name string) error {
+	wd, err := d.getDirectory(ctx, name, storage.ErrIsFile)
+	if err != nil {
+		return err
+	}
+
+	return d.removeWrapped(ctx, wd, func() error {
+		return d.directory.RemoveDirectory(ctx, name)
+	})
+}
+
+// ListDirectories returns a map of directories.
+func (d *directory) ListDirectories(ctx context.Context) (map[string]storage.Directory, error) {
+	_, children, err := d.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := make(map[string]storage.Directory, len(children))
+	for name, wd := range children {
+		dirs[name] = d.newChildDirectory(wd, name)
+	}
+
+	return dirs, nil
+}
+
+// RemoveFile removes a file, and releases its chunks.
+func (d *directory) RemoveFile(ctx context.Context, name string) error {
+	f, err := d.getFile(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	return d.removeWrapped(ctx, f.directory, func() error {
+		return d.directory.RemoveDirectory(ctx, name)
+	})
+}
+
+// replaced returns the wrapped directory that a rename would replace, if any.
+func (d *directory) replaced(ctx context.Context, name string, noReplace bool) (storage.Directory, error) {
+	if noReplace {
+		return nil, d.checkNewName(ctx, name)
+	}
+
+	wd, err := d.directory.GetDirectory(ctx, name)
+	if errors.Is(err, storage.ErrDirectoryNotFound) {
+		return nil, nil
+	}
+	return wd, err
+}
+
+// RenameFile renames a file, releasing the chunks of the replaced element.
+func (d *directory) RenameFile(
+	ctx context.Context,
+	name string,
+	newParent storage.Directory,
+	newName string,
+	noReplace bool,
+) error {
+	np, ok := newParent.(*directory)
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrNotDedup, newParent)
+	}
+
+	if _, err := d.getFile(ctx, name); err != nil {
+		return err
+	}
+	replaced, err := np.replaced(ctx, newName, noReplace)
+	if err != nil {
+		return err
+	}
+
+	return d.removeWrapped(ctx, replaced, func() error {
+		return d.directory.RenameDirectory(ctx, name, np.directory, newName, noReplace)
+	})
+}
+
+// RenameDirectory renames a directory, releasing the chunks of the replaced
+// element.
+func (d *directory) RenameDirectory(
+	ctx context.Context,
+	name string,
+	newParent storage.Directory,
+	newName string,
+	noReplace bool,
+) error {
+	np, ok := newParent.(*directory)
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrNotDedup, newParent)
+	}
+
+	if _, err := d.getDirectory(ctx, name, storage.ErrDirectoryNotFound); err != nil {
+		return err
+	}
+	replaced, err := np.replaced(ctx, newName, noReplace)
+	if err != nil {
+		return err
+	}
+
+	return d.removeWrapped(ctx, replaced, func() error {
+		return d.directory.RenameDirectory(ctx, name, np.directory, newName, noReplace)
+	})
+}
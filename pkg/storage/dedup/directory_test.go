@@ -0,0 +1,87 @@
+package dedup
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+	"github.com/lerenn/chonkfs/pkg/storage/mem"
+	"github.com/lerenn/chonkfs/pkg/storage/test"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestDirectorySuite(t *testing.T) {
+	suite.Run(t, new(DirectorySuite))
+}
+
+type DirectorySuite struct {
+	Pool storage.Directory
+	test.DirectorySuite
+}
+
+func (suite *DirectorySuite) SetupTest() {
+	suite.Pool = mem.NewDirectory()
+	suite.Directory = NewDirectory(mem.NewDirectory(), suite.Pool)
+}
+
+// createFileWithChunk creates a file with a single chunk.
+func (suite *DirectorySuite) createFileWithChunk(d storage.Directory, name string, chunk []byte) {
+	f, err := d.CreateFile(context.Background(), name, info.File{
+		ChunkSize:   len(chunk),
+		ChunksCount: 1,
+	})
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.ImportChunk(context.Background(), 0, chunk))
+}
+
+// poolFilesCount returns the number of files in the pool, references
+// included.
+func (suite *DirectorySuite) poolFilesCount() int {
+	files, err := suite.Pool.ListFiles(context.Background())
+	suite.Require().NoError(err)
+	return len(files)
+}
+
+func (suite *DirectorySuite) TestRemoveFileReleasesChunks() {
+	suite.createFileWithChunk(suite.Directory, "file1", []byte("chonk"))
+	suite.createFileWithChunk(suite.Directory, "file2", []byte("chonk"))
+	suite.Require().Equal(2, suite.poolFilesCount())
+
+	suite.Require().NoError(suite.Directory.RemoveFile(context.Background(), "file1"))
+	suite.Require().Equal(2, suite.poolFilesCount())
+
+	suite.Require().NoError(suite.Directory.RemoveFile(context.Background(), "file2"))
+	suite.Require().Equal(0, suite.poolFilesCount())
+}
+
+func (suite *DirectorySuite) TestRemoveDirectoryReleasesChunks() {
+	d, err := suite.Directory.CreateDirectory(context.Background(), "dir")
+	suite.Require().NoError(err)
+	sub, err := d.CreateDirectory(context.Background(), "sub")
+	suite.Require().NoError(err)
+	suite.createFileWithChunk(d, "file1", []byte("chonk"))
+	suite.createFileWithChunk(sub, "file2", []byte("other"))
+	suite.Require().Equal(4, suite.poolFilesCount())
+
+	suite.Require().NoError(suite.Directory.RemoveDirectory(context.Background(), "dir"))
+	suite.Require().Equal(0, suite.poolFilesCount())
+}
+
+func (suite *DirectorySuite) TestRenameFileReleasesReplacedChunks() {
+	suite.createFileWithChunk(suite.Directory, "file1", []byte("chonk"))
+	suite.createFileWithChunk(suite.Directory, "file2", []byte("other"))
+	suite.Require().Equal(4, suite.poolFilesCount())
+
+	err := suite.Directory.RenameFile(context.Background(), "file1", suite.Directory, "file2", false)
+	suite.Require().NoError(err)
+	suite.Require().Equal(2, suite.poolFilesCount())
+
+	// Check the renamed file is still readable
+	f, err := suite.Directory.GetFile(context.Background(), "file2")
+	suite.Require().NoError(err)
+	data := make([]byte, 5)
+	_, err = f.ReadChunk(context.Background(), 0, data, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal("chonk", string(data))
+}
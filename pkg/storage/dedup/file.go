@@ -0,0 +1,377 @@
+package dedup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+const (
+	metadataFileName = ".metadata"
+
+	// holeChunk is the reference of a chunk that is a hole.
+	holeChunk = "hole"
+)
+
+var _ storage.SparseFile = (*file)(nil)
+
+// metadata is the content of the metadata file of a file: its info and the
+// reference of each chunk, that is the hash of its content in the pool, the
+// hole reference or an empty one if the chunk is not present.
+type metadata struct {
+	info.File
+	Chunks []string
+}
+
+// hashes returns the chunks references that are in the pool.
+func (md metadata) hashes() []string {
+	hashes := make([]string, 0, len(md.Chunks))
+	for _, ref := range md.Chunks {
+		if ref != "" && ref != holeChunk {
+			hashes = append(hashes, ref)
+		}
+	}
+	return hashes
+}
+
+// file is a file stored as a directory, with a metadata file referencing its
+// chunks in the pool.
+type file struct {
+	directory storage.Directory
+	pool      *pool
+	path      string
+}
+
+func newFile(d storage.Directory, p *pool, path string) *file {
+	return &file{
+		directory: d,
+		pool:      p,
+		path:      path,
+	}
+}
+
+// getChunkSize returns the size of a chunk.
+func getChunkSize(fileInfo info.File, index int) int {
+	if index == fileInfo.ChunksCount-1 {
+		return fileInfo.LastChunkSize
+	}
+	return fileInfo.ChunkSize
+}
+
+func (f *file) writeMetadata(ctx context.Context, md metadata) error {
+	md.Size = 0
+	if md.ChunksCount > 0 {
+		md.Size = (md.ChunksCount-1)*md.ChunkSize + md.LastChunkSize
+	}
+
+	data, err := json.Marshal(md)
+	if err != nil {
+		return err
+	}
+
+	return writeBlob(ctx, f.directory, metadataFileName, data)
+}
+
+func (f *file) readMetadata(ctx context.Context) (metadata, error) {
+	data, err := readBlob(ctx, f.directory, metadataFileName)
+	if err != nil {
+		return metadata{}, err
+	}
+
+	var md metadata
+	if err := json.Unmarshal(data, &md); err != nil {
+		return metadata{}, err
+	}
+	return md, nil
+}
+
+// readChunk returns the chunk data, or nil if this is a hole.
+func (f *file) readChunk(ctx context.Context, md metadata, index int) ([]byte, error) {
+	switch ref := md.Chunks[index]; ref {
+	case "":
+		return nil, fmt.Errorf("%w: %d", storage.ErrChunkNotFound, index)
+	case holeChunk:
+		return nil, nil
+	default:
+		return f.pool.get(ctx, ref)
+	}
+}
+
+// setChunk references the new chunk data in the metadata, then releases the
+// previous one from the pool. The file must be locked since its metadata was
+// read.
+func (f *file) setChunk(ctx context.Context, md metadata, index int, data []byte) error {
+	ref := holeChunk
+	if len(data) > 0 {
+		var err error
+		if ref, err = f.pool.add(ctx, data); err != nil {
+			return err
+		}
+	}
+
+	previous := md.Chunks[index]
+	md.Chunks[index] = ref
+	if err := f.writeMetadata(ctx, md); err != nil {
+		// Release the new reference, as it is not used
+		if ref != holeChunk {
+			_ = f.pool.release(ctx, ref)
+		}
+		return err
+	}
+
+	return f.pool.release(ctx, metadata{Chunks: []string{previous}}.hashes()...)
+}
+
+// GetInfo returns the file info.
+func (f *file) GetInfo(ctx context.Context) (info.File, error) {
+	md, err := f.readMetadata(ctx)
+	if err != nil {
+		return info.File{}, err
+	}
+
+	return md.File, nil
+}
+
+// SetAttributes sets the file attributes.
+func (f *file) SetAttributes(ctx context.Context, attr info.Attributes) error {
+	unlock := f.pool.lockFile(f.path)
+	defer unlock()
+
+	md, err := f.readMetadata(ctx)
+	if err != nil {
+		return err
+	}
+
+	md.Attributes = attr
+	return f.writeMetadata(ctx, md)
+}
+
+func checkReadWriteChunkParams(fileInfo info.File, index int, offset int) error {
+	// Check if chunk index is correct
+	if index < 0 || index >= fileInfo.ChunksCount {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkNb, index)
+	}
+
+	// Check if offset is correct
+	if offset < 0 || offset >= getChunkSize(fileInfo, index) {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidOffset, offset)
+	}
+
+	return nil
+}
+
+// ReadChunk reads _ from a chunk.
+func (f *file) ReadChunk(ctx context.Context, index int, data []byte, offset int) (int, error) {
+	md, err := f.readMetadata(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Check params
+	if err := checkReadWriteChunkParams(md.File, index, offset); err != nil {
+		return 0, err
+	}
+
+	// Get chunk
+	chunk, err := f.readChunk(ctx, md, index)
+	if err != nil {
+		return 0, err
+	}
+
+	// Read zeros if this is a hole
+	if chunk == nil {
+		size := getChunkSize(md.File, index)
+		if len(data) > size-offset {
+			data = data[:size-offset]
+		}
+		clear(data)
+		return len(data), nil
+	}
+
+	return copy(data, chunk[offset:]), nil
+}
+
+// HasChunkData returns true if the chunk holds data, false if this is a hole.
+func (f *file) HasChunkData(ctx context.Context, index int) (bool, error) {
+	md, err := f.readMetadata(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	// Check if chunk index is correct
+	if index < 0 || index >= md.ChunksCount {
+		return false, fmt.Errorf("%w: %d", storage.ErrInvalidChunkNb, index)
+	}
+
+	switch md.Chunks[index] {
+	case "":
+		return false, fmt.Errorf("%w: %d", storage.ErrChunkNotFound, index)
+	case holeChunk:
+		return false, nil
+	default:
+		return true, nil
+	}
+}
+
+// WriteChunk writes _ to a chunk, storing the result in the pool as a new
+// chunk if its content is not known yet.
+func (f *file) WriteChunk(ctx context.Context, index int, data []byte, offset int) (int, error) {
+	unlock := f.pool.lockFile(f.path)
+	defer unlock()
+
+	md, err := f.readMetadata(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Check params
+	if err := checkReadWriteChunkParams(md.File, index, offset); err != nil {
+		return 0, err
+	}
+
+	// Get chunk, allocating it if this is a hole
+	chunk, err := f.readChunk(ctx, md, index)
+	if err != nil {
+		return 0, err
+	}
+	if chunk == nil {
+		chunk = make([]byte, getChunkSize(md.File, index))
+		md.AllocatedSize += len(chunk)
+	}
+
+	// Write data
+	written := copy(chunk[offset:], data)
+	return written, f.setChunk(ctx, md, index, chunk)
+}
+
+// ImportChunk imports a chunk, only adding a reference if its content is
+// already in the pool.
+func (f *file) ImportChunk(ctx context.Context, index int, data []byte) error {
+	unlock := f.pool.lockFile(f.path)
+	defer unlock()
+
+	md, err := f.readMetadata(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Check if chunk index is correct
+	if index < 0 || index >= md.ChunksCount {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkNb, index)
+	}
+
+	// Check if the chunk is empty
+	if md.Chunks[index] != "" {
+		return fmt.Errorf("%w: %d", storage.ErrChunkAlreadyExists, index)
+	}
+
+	// Check if length of data is correct
+	last := index == md.ChunksCount-1
+	if (len(data) != md.ChunkSize && !last) || len(data) > md.ChunkSize {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkSize, len(data))
+	}
+
+	// Import data
+	if last {
+		md.LastChunkSize = len(data)
+	}
+	md.AllocatedSize += len(data)
+	return f.setChunk(ctx, md, index, data)
+}
+
+// ResizeChunksNb resizes the number of chunks, the added ones being holes and
+// the removed ones being released from the pool.
+func (f *file) ResizeChunksNb(ctx context.Context, size int) error {
+	unlock := f.pool.lockFile(f.path)
+	defer unlock()
+
+	md, err := f.readMetadata(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Check size is correct
+	if size < 0 {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkNb, size)
+	}
+
+	// Check the last chunk size is full
+	if md.ChunksCount > 0 && md.LastChunkSize != md.ChunkSize {
+		return fmt.Errorf("%w", storage.ErrLastChunkNotFull)
+	}
+
+	// Add chunks as holes
+	for i := md.ChunksCount; i < size; i++ {
+		md.Chunks = append(md.Chunks, holeChunk)
+	}
+	if size > md.ChunksCount {
+		md.LastChunkSize = md.ChunkSize
+	}
+
+	// Remove chunks
+	var removed metadata
+	if size < md.ChunksCount {
+		removed.Chunks = md.Chunks[size:]
+		md.Chunks = md.Chunks[:size:size]
+		md.AllocatedSize -= len(removed.hashes()) * md.ChunkSize
+	}
+
+	// Update metadata before releasing the removed chunks, so they are never
+	// referenced once removed from the pool
+	md.ChunksCount = size
+	if err := f.writeMetadata(ctx, md); err != nil {
+		return err
+	}
+	return f.pool.release(ctx, removed.hashes()...)
+}
+
+// ResizeLastChunk resizes the last chunk.
+func (f *file) ResizeLastChunk(ctx context.Context, size int) (changed int, err error) {
+	unlock := f.pool.lockFile(f.path)
+	defer unlock()
+
+	md, err := f.readMetadata(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Check size is correct
+	if size < 0 || size > md.ChunkSize {
+		return 0, fmt.Errorf("%w: %d", storage.ErrInvalidChunkSize, size)
+	}
+
+	// Check if there is a last chunk
+	if md.ChunksCount == 0 {
+		return 0, fmt.Errorf("%w", storage.ErrNoChunk)
+	}
+
+	// Get last chunk data
+	last := md.ChunksCount - 1
+	chunk, err := f.readChunk(ctx, md, last)
+	if err != nil {
+		return 0, err
+	}
+	oldSize := md.LastChunkSize
+	md.LastChunkSize = size
+
+	// Only update the metadata if this is a hole
+	if chunk == nil {
+		return size - oldSize, f.writeMetadata(ctx, md)
+	}
+
+	// Resize the chunk, storing it as a new one
+	if size > oldSize {
+		chunk = append(chunk, make([]byte, size-oldSize)...)
+	} else {
+		chunk = chunk[:size]
+	}
+	md.AllocatedSize += size - oldSize
+	if err := f.setChunk(ctx, md, last, chunk); err != nil {
+		return 0, err
+	}
+
+	return size - oldSize, nil
+}
@@ -0,0 +1,140 @@
+package dedup
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+	"github.com/lerenn/chonkfs/pkg/storage/mem"
+	"github.com/lerenn/chonkfs/pkg/storage/test"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestFileSuite(t *testing.T) {
+	suite.Run(t, new(FileSuite))
+}
+
+type FileSuite struct {
+	Pool storage.Directory
+	test.FileSuite
+}
+
+func (suite *FileSuite) SetupTest() {
+	suite.Pool = mem.NewDirectory()
+	suite.Directory = NewDirectory(mem.NewDirectory(), suite.Pool)
+}
+
+// refs returns the number of references of the chunk in the pool.
+func (suite *FileSuite) refs(chunk []byte) int {
+	refs, err := newPool(suite.Pool).refs(context.Background(), hashChunk(chunk))
+	suite.Require().NoError(err)
+	return refs
+}
+
+func (suite *FileSuite) TestSameChunksStoredOnce() {
+	f, err := suite.Directory.CreateFile(context.Background(), "file", info.File{
+		ChunkSize:   5,
+		ChunksCount: 3,
+	})
+	suite.Require().NoError(err)
+
+	// Import the same content in all chunks
+	for i := 0; i < 3; i++ {
+		suite.Require().NoError(f.ImportChunk(context.Background(), i, []byte("chonk")))
+	}
+
+	// Check it is stored once, with a reference per chunk
+	files, err := suite.Pool.ListFiles(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Len(files, 2)
+	suite.Require().Equal(3, suite.refs([]byte("chonk")))
+
+	// Check the info has the logical sizes
+	fileInfo, err := f.GetInfo(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal(15, fileInfo.Size)
+	suite.Require().Equal(15, fileInfo.AllocatedSize)
+}
+
+func (suite *FileSuite) TestWriteChunkReleasesPreviousContent() {
+	f, err := suite.Directory.CreateFile(context.Background(), "file", info.File{
+		ChunkSize:   5,
+		ChunksCount: 2,
+	})
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.ImportChunk(context.Background(), 0, []byte("chonk")))
+	suite.Require().NoError(f.ImportChunk(context.Background(), 1, []byte("chonk")))
+
+	// Write on a chunk
+	_, err = f.WriteChunk(context.Background(), 1, []byte("ky"), 3)
+	suite.Require().NoError(err)
+	suite.Require().Equal(1, suite.refs([]byte("chonk")))
+	suite.Require().Equal(1, suite.refs([]byte("choky")))
+
+	// Write it back as the other chunk
+	_, err = f.WriteChunk(context.Background(), 1, []byte("nk"), 3)
+	suite.Require().NoError(err)
+	suite.Require().Equal(2, suite.refs([]byte("chonk")))
+	suite.Require().Equal(0, suite.refs([]byte("choky")))
+}
+
+func (suite *FileSuite) TestConcurrentImports() {
+	_, err := suite.Directory.CreateFile(context.Background(), "file", info.File{
+		ChunkSize:   5,
+		ChunksCount: 16,
+	})
+	suite.Require().NoError(err)
+
+	// Import each chunk from its own handle, concurrently, the handles being
+	// created before as the memory directories are not safe for concurrent use
+	handles := make([]storage.File, 16)
+	for i := range handles {
+		handles[i], err = suite.Directory.GetFile(context.Background(), "file")
+		suite.Require().NoError(err)
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, len(handles))
+	for i, f := range handles {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = f.ImportChunk(context.Background(), i, []byte(fmt.Sprintf("%05d", i)))
+		}()
+	}
+	wg.Wait()
+
+	// Check every chunk is referenced
+	f, err := suite.Directory.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+	for i, err := range errs {
+		suite.Require().NoError(err)
+
+		data := make([]byte, 5)
+		_, err = f.ReadChunk(context.Background(), i, data, 0)
+		suite.Require().NoError(err)
+		suite.Require().Equal(fmt.Sprintf("%05d", i), string(data))
+		suite.Require().Equal(1, suite.refs(data))
+	}
+}
+
+func (suite *FileSuite) TestResizeChunksNbReleasesChunks() {
+	f, err := suite.Directory.CreateFile(context.Background(), "file", info.File{
+		ChunkSize:   5,
+		ChunksCount: 2,
+	})
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.ImportChunk(context.Background(), 0, []byte("chonk")))
+	suite.Require().NoError(f.ImportChunk(context.Background(), 1, []byte("other")))
+
+	suite.Require().NoError(f.ResizeChunksNb(context.Background(), 1))
+	suite.Require().Equal(1, suite.refs([]byte("chonk")))
+	suite.Require().Equal(0, suite.refs([]byte("other")))
+
+	suite.Require().NoError(f.ResizeChunksNb(context.Background(), 0))
+	files, err := suite.Pool.ListFiles(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Empty(files)
+}
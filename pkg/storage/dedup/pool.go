@@ -0,0 +1,192 @@
+package dedup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+const (
+	refsFileSuffix = ".refs"
+	tmpFileSuffix  = ".tmp"
+)
+
+// pool stores the chunks by the hash of their content, each chunk being a
+// file named after its hash, with a file holding its number of references.
+type pool struct {
+	directory storage.Directory
+	mutex     sync.Mutex
+
+	// files are the locks of the files metadata, by path, shared by all the
+	// handles of a file.
+	filesMutex sync.Mutex
+	files      map[string]*fileLock
+}
+
+// fileLock is the lock of the metadata of a file, with its number of users.
+type fileLock struct {
+	mutex sync.Mutex
+	users int
+}
+
+func newPool(d storage.Directory) *pool {
+	return &pool{
+		directory: d,
+		files:     make(map[string]*fileLock),
+	}
+}
+
+// lockFile locks the metadata of the file at the path, until the returned
+// function is called.
+func (p *pool) lockFile(path string) (unlock func()) {
+	p.filesMutex.Lock()
+	l, ok := p.files[path]
+	if !ok {
+		l = &fileLock{}
+		p.files[path] = l
+	}
+	l.users++
+	p.filesMutex.Unlock()
+
+	l.mutex.Lock()
+	return func() {
+		l.mutex.Unlock()
+
+		p.filesMutex.Lock()
+		defer p.filesMutex.Unlock()
+		if l.users--; l.users == 0 {
+			delete(p.files, path)
+		}
+	}
+}
+
+func hashChunk(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// add adds a reference to the chunk, storing it if it is not known yet, and
+// returns its hash.
+func (p *pool) add(ctx context.Context, data []byte) (string, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	hash := hashChunk(data)
+	refs, err := p.refs(ctx, hash)
+	if err != nil {
+		return "", err
+	}
+
+	// Store the chunk on its first reference
+	if refs == 0 {
+		if err := writeBlob(ctx, p.directory, hash, data); err != nil {
+			return "", err
+		}
+	}
+
+	return hash, writeBlob(ctx, p.directory, hash+refsFileSuffix, []byte(strconv.Itoa(refs+1)))
+}
+
+// release removes a reference to each chunk, removing the chunks that are
+// not referenced anymore.
+func (p *pool) release(ctx context.Context, hashes ...string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var errs []error
+	for _, hash := range hashes {
+		refs, err := p.refs(ctx, hash)
+		switch {
+		case err != nil:
+			errs = append(errs, err)
+		case refs > 1:
+			errs = append(errs, writeBlob(ctx, p.directory, hash+refsFileSuffix, []byte(strconv.Itoa(refs-1))))
+		default:
+			errs = append(errs,
+				removeIfExists(ctx, p.directory, hash),
+				removeIfExists(ctx, p.directory, hash+refsFileSuffix))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// refs returns the number of references of the chunk.
+func (p *pool) refs(ctx context.Context, hash string) (int, error) {
+	data, err := readBlob(ctx, p.directory, hash+refsFileSuffix)
+	if errors.Is(err, storage.ErrFileNotFound) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(string(data))
+}
+
+// get returns the chunk content.
+func (p *pool) get(ctx context.Context, hash string) ([]byte, error) {
+	data, err := readBlob(ctx, p.directory, hash)
+	if errors.Is(err, storage.ErrFileNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrMissingPoolChunk, hash)
+	}
+	return data, err
+}
+
+// writeBlob stores the data in the directory as a file with a single chunk,
+// replacing the previous one only once the new one is complete.
+func writeBlob(ctx context.Context, d storage.Directory, name string, data []byte) error {
+	tmpName := name + tmpFileSuffix
+	blobInfo := info.File{
+		ChunkSize:     len(data),
+		ChunksCount:   1,
+		LastChunkSize: len(data),
+	}
+
+	// Create a temporary file, removing the one left by a previous failure
+	blob, err := d.CreateFile(ctx, tmpName, blobInfo)
+	if errors.Is(err, storage.ErrFileAlreadyExists) {
+		if err := d.RemoveFile(ctx, tmpName); err != nil {
+			return err
+		}
+		blob, err = d.CreateFile(ctx, tmpName, blobInfo)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Write the data and replace the previous file
+	if err := blob.ImportChunk(ctx, 0, data); err != nil {
+		return err
+	}
+	return d.RenameFile(ctx, tmpName, d, name, false)
+}
+
+// readBlob returns the data of a file with a single chunk.
+func readBlob(ctx context.Context, d storage.Directory, name string) ([]byte, error) {
+	blob, err := d.GetFile(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	blobInfo, err := blob.GetInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, blobInfo.Size)
+	_, err = blob.ReadChunk(ctx, 0, data, 0)
+	return data, err
+}
+
+func removeIfExists(ctx context.Context, d storage.Directory, name string) error {
+	if err := d.RemoveFile(ctx, name); err != nil && !errors.Is(err, storage.ErrFileNotFound) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package disk
+
+// bitmap is a set of bits, used to know which chunks are present.
+type bitmap []byte
+
+func newBitmap(size int) bitmap {
+	return make(bitmap, (size+7)/8)
+}
+
+// Get returns true if the bit is set.
+func (b bitmap) Get(i int) bool {
+	if i/8 >= len(b) {
+		return false
+	}
+	return b[i/8]&(1<<(i%8)) != 0
+}
+
+// Set sets the bit.
+func (b bitmap) Set(i int) {
+	b[i/8] |= 1 << (i % 8)
+}
+
+// Clear clears the bit.
+func (b bitmap) Clear(i int) {
+	if i/8 < len(b) {
+		b[i/8] &^= 1 << (i % 8)
+	}
+}
+
+// Resize resizes the bitmap to the given number of bits, clearing the bits
+// that are removed.
+func (b bitmap) Resize(size int) bitmap {
+	// Clear removed bits in the last byte, if any
+	if size%8 != 0 && size/8 < len(b) {
+		b[size/8] &= byte(1<<(size%8)) - 1
+	}
+
+	// Change the number of bytes
+	n := (size + 7) / 8
+	if n <= len(b) {
+		clear(b[n:])
+		return b[:n]
+	}
+	return append(b, make(bitmap, n-len(b))...)
+}
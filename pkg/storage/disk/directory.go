@@ -2,6 +2,7 @@ package disk
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -11,15 +12,57 @@ import (
 	"github.com/lerenn/chonkfs/pkg/storage"
 )
 
+// attributesFileName is the name of the file holding the attributes of a
+// directory, inside of it.
+const attributesFileName = ".attributes"
+
+// directoryMetadata is the content of the attributes file of a directory.
+type directoryMetadata struct {
+	info.Attributes
+
+	// Xattrs are the extended attributes of the directory.
+	Xattrs xattrs `json:",omitempty"`
+}
+
+type directoryOption func(dir *directory)
+
+// WithPackedChunks is an option to store all the chunks of the files created
+// in the directory (and its children) in one preallocated data file, instead
+// of one file per chunk.
+//
+//nolint:revive
+func WithPackedChunks() directoryOption {
+	return func(dir *directory) {
+		dir.packed = true
+	}
+}
+
 type directory struct {
-	path string
+	path   string
+	opts   []directoryOption
+	packed bool
+	locks  *fileLocks
 }
 
 // NewDirectory creates a new directory representation.
-func NewDirectory(path string) storage.Directory {
-	return &directory{
-		path: path,
+func NewDirectory(path string, opts ...directoryOption) storage.Directory {
+	return newDirectory(path, newFileLocks(), opts...)
+}
+
+func newDirectory(path string, locks *fileLocks, opts ...directoryOption) *directory {
+	// Create a default directory
+	dir := &directory{
+		path:  path,
+		opts:  opts,
+		locks: locks,
 	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(dir)
+	}
+
+	return dir
 }
 
 func (d *directory) getChildPath(name string) string {
@@ -50,12 +93,18 @@ func (d *directory) ensureChildDoesNotExists(name string) error {
 	return nil
 }
 
-func (d *directory) writeChildMetadata(name string, info info.File) error {
-	return writeMetadata(path.Join(d.path, name), info)
+func (d *directory) readChildMetadata(name string) (metadata, error) {
+	return readMetadata(path.Join(d.path, name))
 }
 
-func (d *directory) readChildMetadata(name string) (info.File, error) {
-	return readMetadata(path.Join(d.path, name))
+// newChildFile creates the representation of a child file, depending on the
+// way its chunks are stored.
+func (d *directory) newChildFile(name string, md metadata) (storage.File, error) {
+	if md.Packed {
+		return newPackedFile(d.getChildPath(name), md.File, d.locks)
+	}
+
+	return newFile(d.getChildPath(name), md.File, d.locks)
 }
 
 // CreateDirectory creates a directory.
@@ -67,7 +116,7 @@ func (d *directory) CreateDirectory(_ context.Context, name string) (storage.Dir
 
 	// Create directory and return representation
 	path := d.getChildPath(name)
-	return NewDirectory(path), os.Mkdir(path, 0755)
+	return newDirectory(path, d.locks, d.opts...), os.Mkdir(path, 0755)
 }
 
 // GetDirectory returns a child directory of the directory.
@@ -86,12 +135,51 @@ func (d *directory) GetDirectory(_ context.Context, name string) (storage.Direct
 	}
 
 	// Return representation
-	return NewDirectory(path), nil
+	return newDirectory(path, d.locks, d.opts...), nil
+}
+
+// readMetadata reads the attributes file of the directory, if it has been
+// written.
+func (d *directory) readMetadata() (directoryMetadata, error) {
+	data, err := os.ReadFile(path.Join(d.path, attributesFileName))
+	if os.IsNotExist(err) {
+		return directoryMetadata{}, nil
+	} else if err != nil {
+		return directoryMetadata{}, err
+	}
+
+	var md directoryMetadata
+	if err := json.Unmarshal(data, &md); err != nil {
+		return directoryMetadata{}, err
+	}
+
+	return md, nil
+}
+
+func (d *directory) writeMetadata(md directoryMetadata) error {
+	data, err := json.Marshal(md)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path.Join(d.path, attributesFileName), data, 0644)
 }
 
 // GetInfo returns the directory information.
 func (d *directory) GetInfo(_ context.Context) (info.Directory, error) {
-	return info.Directory{}, nil
+	md, err := d.readMetadata()
+	return info.Directory{Attributes: md.Attributes}, err
+}
+
+// SetAttributes sets the directory attributes.
+func (d *directory) SetAttributes(_ context.Context, attr info.Attributes) error {
+	md, err := d.readMetadata()
+	if err != nil {
+		return err
+	}
+
+	md.Attributes = attr
+	return d.writeMetadata(md)
 }
 
 // CreateFile creates a file.
@@ -103,8 +191,10 @@ func (d *directory) CreateFile(_ context.Context, name string, info info.File) (
 		return nil, err
 	}
 
-	// Create file representation
-	f, err := newFile(path, info)
+	// Create file representation, without any chunk allocated
+	md := metadata{File: info, Packed: d.packed}
+	md.AllocatedSize = 0
+	f, err := d.newChildFile(name, md)
 	if err != nil {
 		return nil, err
 	}
@@ -114,7 +204,15 @@ func (d *directory) CreateFile(_ context.Context, name string, info info.File) (
 		return nil, err
 	}
 
-	return f, d.writeChildMetadata(name, info)
+	// Create the data file if the chunks are packed
+	if md.Packed {
+		md.Chunks = newBitmap(info.ChunksCount)
+		if err := createDataFile(path, info); err != nil {
+			return nil, err
+		}
+	}
+
+	return f, writeMetadata(path, md)
 }
 
 // GetFile returns a child file.
@@ -131,14 +229,13 @@ func (d *directory) GetFile(_ context.Context, name string) (storage.File, error
 	}
 
 	// Read metadata
-	info, err := d.readChildMetadata(name)
+	md, err := d.readChildMetadata(name)
 	if err != nil {
 		return nil, err
 	}
 
 	// Create file representation
-	path := d.getChildPath(name)
-	return newFile(path, info)
+	return d.newChildFile(name, md)
 }
 
 // ListFiles returns a map of files.
@@ -155,7 +252,7 @@ func (d *directory) ListFiles(_ context.Context) (map[string]storage.File, error
 		}
 
 		// Read metadata
-		info, err := d.readChildMetadata(entry.Name())
+		md, err := d.readChildMetadata(entry.Name())
 		if err != nil && !os.IsNotExist(err) {
 			return nil, err
 		} else if os.IsNotExist(err) {
@@ -164,8 +261,7 @@ func (d *directory) ListFiles(_ context.Context) (map[string]storage.File, error
 		}
 
 		// Create file representation
-		path := path.Join(d.path, entry.Name())
-		f, err := newFile(path, info)
+		f, err := d.newChildFile(entry.Name(), md)
 		if err != nil {
 			return nil, err
 		}
@@ -189,8 +285,20 @@ func (d *directory) RemoveDirectory(_ context.Context, name string) error {
 		return err
 	}
 
+	// Remove the attributes, if this is the only thing in the directory
+	p := d.getChildPath(name)
+	entries, err := os.ReadDir(p)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 1 && entries[0].Name() == attributesFileName {
+		if err := os.Remove(path.Join(p, attributesFileName)); err != nil {
+			return err
+		}
+	}
+
 	// Remove directory
-	return os.Remove(d.getChildPath(name))
+	return os.Remove(p)
 }
 
 // ListDirectories returns a map of directories.
@@ -218,7 +326,7 @@ func (d *directory) ListDirectories(_ context.Context) (map[string]storage.Direc
 
 		// Create directory representation
 		path := path.Join(d.path, entry.Name())
-		directories[entry.Name()] = NewDirectory(path)
+		directories[entry.Name()] = newDirectory(path, d.locks, d.opts...)
 	}
 
 	return directories, nil
@@ -10,6 +10,7 @@ import (
 
 func TestDirectorySuite(t *testing.T) {
 	suite.Run(t, new(DirectorySuite))
+	suite.Run(t, new(PackedDirectorySuite))
 }
 
 type DirectorySuite struct {
@@ -28,3 +29,20 @@ func (suite *DirectorySuite) TearDownTest() {
 	err := os.RemoveAll(suite.Path)
 	suite.Require().NoError(err)
 }
+
+type PackedDirectorySuite struct {
+	test.DirectorySuite
+	Path string
+}
+
+func (suite *PackedDirectorySuite) SetupTest() {
+	path, err := os.MkdirTemp("", "chonkfs-test-*")
+	suite.Require().NoError(err)
+	suite.Path = path
+	suite.Directory = NewDirectory(path, WithPackedChunks())
+}
+
+func (suite *PackedDirectorySuite) TearDownTest() {
+	err := os.RemoveAll(suite.Path)
+	suite.Require().NoError(err)
+}
@@ -15,52 +15,100 @@ const (
 	metadataFileName = ".metadata"
 )
 
+// metadata is the content of the metadata file of a file.
+type metadata struct {
+	info.File
+
+	// Packed is set when the chunks are stored in a single data file.
+	Packed bool `json:",omitempty"`
+	// Chunks is the presence bitmap of the chunks, when they are packed.
+	Chunks bitmap `json:",omitempty"`
+	// Holes is the bitmap of the chunks created by a resize and never written,
+	// that read as zeros and have no data stored.
+	Holes bitmap `json:",omitempty"`
+	// Xattrs are the extended attributes of the file.
+	Xattrs xattrs `json:",omitempty"`
+}
+
+// info returns the file info, with the sizes computed from the chunks.
+func (md metadata) info() info.File {
+	fileInfo := md.File
+	fileInfo.Size = 0
+	if md.ChunksCount > 0 {
+		fileInfo.Size = (md.ChunksCount-1)*md.ChunkSize + md.LastChunkSize
+	}
+	return fileInfo
+}
+
+// getChunkSize returns the size of a chunk.
+func (md metadata) getChunkSize(index int) int {
+	if index == md.ChunksCount-1 {
+		return md.LastChunkSize
+	}
+	return md.ChunkSize
+}
+
+// resizeHoles changes the number of chunks in the holes bitmap, the new chunks
+// being holes.
+func (md *metadata) resizeHoles(size int) {
+	md.Holes = md.Holes.Resize(size)
+	for i := md.ChunksCount; i < size; i++ {
+		md.Holes.Set(i)
+	}
+}
+
+var _ storage.SparseFile = (*file)(nil)
+
 type file struct {
-	path string
+	path  string
+	locks *fileLocks
 }
 
-func newFile(path string, info info.File) (*file, error) {
+func newFile(path string, info info.File, locks *fileLocks) (*file, error) {
 	if info.ChunkSize <= 0 {
 		return nil, fmt.Errorf("%w: chunk size must be greater than 0", storage.ErrInvalidChunkSize)
 	}
 
 	return &file{
-		path: path,
+		path:  path,
+		locks: locks,
 	}, nil
 }
 
-func writeMetadata(p string, info info.File) error {
+func writeMetadata(p string, md metadata) error {
 	metadataPath := path.Join(p, metadataFileName)
 
-	// Encode info
-	data, err := json.Marshal(info)
+	// Encode metadata
+	data, err := json.Marshal(md)
 	if err != nil {
 		return err
 	}
 
-	// Create metadata file
-	if err := os.WriteFile(metadataPath, data, 0644); err != nil {
+	// Write it aside then replace the metadata file, so it is never read
+	// partially written
+	tmpPath := metadataPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
 		return err
 	}
 
-	return nil
+	return os.Rename(tmpPath, metadataPath)
 }
 
-func readMetadata(p string) (info.File, error) {
+func readMetadata(p string) (metadata, error) {
 	metadataPath := path.Join(p, metadataFileName)
 
 	// Read metadata
 	data, err := os.ReadFile(metadataPath)
 	if err != nil {
-		return info.File{}, err
+		return metadata{}, err
 	}
 
-	var info info.File
-	if err := json.Unmarshal(data, &info); err != nil {
-		return info, err
+	var md metadata
+	if err := json.Unmarshal(data, &md); err != nil {
+		return md, err
 	}
 
-	return info, nil
+	return md, nil
 }
 
 func getChunkName(nb int) string {
@@ -71,22 +119,36 @@ func (f *file) getChunckPath(nb int) string {
 	return path.Join(f.path, getChunkName(nb))
 }
 
-func (f *file) checkImportChunkParams(info info.File, index int, data []byte) error {
+// chunkExists returns true if the chunk is stored or is a hole.
+func (f *file) chunkExists(md metadata, index int) (bool, error) {
+	if md.Holes.Get(index) {
+		return true, nil
+	}
+
+	if _, err := os.Stat(f.getChunckPath(index)); err == nil {
+		return true, nil
+	} else if !os.IsNotExist(err) {
+		return false, err
+	}
+
+	return false, nil
+}
+
+func (f *file) checkImportChunkParams(md metadata, index int, data []byte) error {
 	// Check if chunk index is correct
-	if index < 0 || index >= info.ChunksCount {
+	if index < 0 || index >= md.ChunksCount {
 		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkNb, index)
 	}
 
 	// Check if the chunk is empty
-	chunkPath := f.getChunckPath(index)
-	if _, err := os.Stat(chunkPath); err == nil {
-		return fmt.Errorf("%w: %d", storage.ErrChunkAlreadyExists, index)
-	} else if !os.IsNotExist(err) {
+	if exists, err := f.chunkExists(md, index); err != nil {
 		return err
+	} else if exists {
+		return fmt.Errorf("%w: %d", storage.ErrChunkAlreadyExists, index)
 	}
 
 	// Check if length of data is correct
-	if (len(data) != info.ChunkSize && index != info.ChunksCount-1) || len(data) > info.ChunkSize {
+	if (len(data) != md.ChunkSize && index != md.ChunksCount-1) || len(data) > md.ChunkSize {
 		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkSize, len(data))
 	}
 
@@ -94,15 +156,17 @@ func (f *file) checkImportChunkParams(info info.File, index int, data []byte) er
 }
 
 // ImportChunk imports a chunk of data.
-func (f *file) ImportChunk(ctx context.Context, index int, data []byte) error {
-	// Get info
-	info, err := f.GetInfo(ctx)
+func (f *file) ImportChunk(_ context.Context, index int, data []byte) error {
+	defer f.locks.lock(f.path)()
+
+	// Get metadata
+	md, err := readMetadata(f.path)
 	if err != nil {
 		return err
 	}
 
 	// Check params
-	if err := f.checkImportChunkParams(info, index, data); err != nil {
+	if err := f.checkImportChunkParams(md, index, data); err != nil {
 		return err
 	}
 
@@ -112,67 +176,104 @@ func (f *file) ImportChunk(ctx context.Context, index int, data []byte) error {
 		return err
 	}
 
-	// If this is the last chunk, set the last chunk size
-	if index == info.ChunksCount-1 {
-		info.LastChunkSize = len(data)
-		if err := f.saveInfo(info); err != nil {
-			return err
-		}
+	// Count the allocated data and, if this is the last chunk, set the last
+	// chunk size
+	md.AllocatedSize += len(data)
+	if index == md.ChunksCount-1 {
+		md.LastChunkSize = len(data)
 	}
 
-	return nil
+	return f.saveMetadata(md)
 }
 
 // GetInfo returns the file info.
 func (f *file) GetInfo(_ context.Context) (info.File, error) {
-	return readMetadata(f.path)
+	md, err := readMetadata(f.path)
+	return md.info(), err
 }
 
-func (f *file) saveInfo(info info.File) error {
-	return writeMetadata(f.path, info)
+// SetAttributes sets the file attributes.
+func (f *file) SetAttributes(_ context.Context, attr info.Attributes) error {
+	defer f.locks.lock(f.path)()
+
+	md, err := readMetadata(f.path)
+	if err != nil {
+		return err
+	}
+
+	md.Attributes = attr
+	return f.saveMetadata(md)
 }
 
-func (f *file) checkReadWriteChunkParams(info info.File, index int, offset int) error {
+func (f *file) saveMetadata(md metadata) error {
+	return writeMetadata(f.path, md)
+}
+
+func (f *file) checkReadWriteChunkParams(md metadata, index int, offset int) error {
 	// Check if chunk index is correct
-	if index < 0 || index >= info.ChunksCount {
+	if index < 0 || index >= md.ChunksCount {
 		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkNb, index)
 	}
 
 	// Check if there is data to read
-	chunkPath := f.getChunckPath(index)
-	if _, err := os.Stat(chunkPath); err != nil {
-		if os.IsNotExist(err) {
-			return fmt.Errorf("%w", storage.ErrChunkNotFound)
-		}
+	if exists, err := f.chunkExists(md, index); err != nil {
 		return err
+	} else if !exists {
+		return fmt.Errorf("%w", storage.ErrChunkNotFound)
 	}
 
 	// Check if offset is correct
-	if offset < 0 || offset >= info.ChunkSize {
+	if offset < 0 || offset >= md.ChunkSize {
 		return fmt.Errorf("%w: %d", storage.ErrInvalidOffset, offset)
 	}
 
 	// Check if this is the last chunk, that the offset is correct$
-	if index == info.ChunksCount-1 && offset >= info.LastChunkSize {
+	if index == md.ChunksCount-1 && offset >= md.LastChunkSize {
 		return fmt.Errorf("%w: %d", storage.ErrInvalidOffset, offset)
 	}
 
 	return nil
 }
 
+// allocateHole creates the chunk file of a hole, filled with zeros.
+func (f *file) allocateHole(md *metadata, index int) error {
+	// Create an empty chunk file of the chunk size
+	size := md.getChunkSize(index)
+	if err := os.WriteFile(f.getChunckPath(index), nil, 0644); err != nil {
+		return err
+	}
+	if err := os.Truncate(f.getChunckPath(index), int64(size)); err != nil {
+		return err
+	}
+
+	// Update metadata
+	md.Holes.Clear(index)
+	md.AllocatedSize += size
+	return f.saveMetadata(*md)
+}
+
 // WriteChunk writes a chunk of data.
-func (f *file) WriteChunk(ctx context.Context, index int, data []byte, offset int) (int, error) {
-	// Get info
-	info, err := f.GetInfo(ctx)
+func (f *file) WriteChunk(_ context.Context, index int, data []byte, offset int) (int, error) {
+	defer f.locks.lock(f.path)()
+
+	// Get metadata
+	md, err := readMetadata(f.path)
 	if err != nil {
 		return 0, err
 	}
 
 	// Check params
-	if err := f.checkReadWriteChunkParams(info, index, offset); err != nil {
+	if err := f.checkReadWriteChunkParams(md, index, offset); err != nil {
 		return 0, err
 	}
 
+	// Allocate the chunk if this is a hole
+	if md.Holes.Get(index) {
+		if err := f.allocateHole(&md, index); err != nil {
+			return 0, err
+		}
+	}
+
 	// Open file
 	chunkPath := f.getChunckPath(index)
 	file, err := os.OpenFile(chunkPath, os.O_WRONLY, 0644)
@@ -181,8 +282,8 @@ func (f *file) WriteChunk(ctx context.Context, index int, data []byte, offset in
 	}
 
 	// Limit data to write if it is too long
-	if len(data) > info.ChunkSize-offset {
-		data = data[:info.ChunkSize-offset]
+	if len(data) > md.ChunkSize-offset {
+		data = data[:md.ChunkSize-offset]
 	}
 
 	// Write data
@@ -196,18 +297,27 @@ func (f *file) WriteChunk(ctx context.Context, index int, data []byte, offset in
 }
 
 // ReadChunk reads a chunk of data.
-func (f *file) ReadChunk(ctx context.Context, index int, data []byte, offset int) (int, error) {
-	// Get info
-	info, err := f.GetInfo(ctx)
+func (f *file) ReadChunk(_ context.Context, index int, data []byte, offset int) (int, error) {
+	// Get metadata
+	md, err := readMetadata(f.path)
 	if err != nil {
 		return 0, err
 	}
 
 	// Check params
-	if err := f.checkReadWriteChunkParams(info, index, offset); err != nil {
+	if err := f.checkReadWriteChunkParams(md, index, offset); err != nil {
 		return 0, err
 	}
 
+	// Read zeros if this is a hole
+	if md.Holes.Get(index) {
+		if size := md.getChunkSize(index); len(data) > size-offset {
+			data = data[:size-offset]
+		}
+		clear(data)
+		return len(data), nil
+	}
+
 	// Read data
 	chunkPath := f.getChunckPath(index)
 	chunkData, err := os.ReadFile(chunkPath)
@@ -218,103 +328,128 @@ func (f *file) ReadChunk(ctx context.Context, index int, data []byte, offset int
 	return copy(data, chunkData[offset:]), nil
 }
 
+// HasChunkData returns true if the chunk holds data, false if this is a hole.
+func (f *file) HasChunkData(_ context.Context, index int) (bool, error) {
+	// Get metadata
+	md, err := readMetadata(f.path)
+	if err != nil {
+		return false, err
+	}
+
+	// Check if chunk index is correct
+	if index < 0 || index >= md.ChunksCount {
+		return false, fmt.Errorf("%w: %d", storage.ErrInvalidChunkNb, index)
+	}
+
+	// Check if the chunk is present
+	if exists, err := f.chunkExists(md, index); err != nil {
+		return false, err
+	} else if !exists {
+		return false, fmt.Errorf("%w", storage.ErrChunkNotFound)
+	}
+
+	return !md.Holes.Get(index), nil
+}
+
 // ResizeChunksNb resizes the number of chunks.
-func (f *file) ResizeChunksNb(ctx context.Context, size int) error {
+func (f *file) ResizeChunksNb(_ context.Context, size int) error {
+	defer f.locks.lock(f.path)()
+
 	// Check size is correct
 	if size < 0 {
 		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkNb, size)
 	}
 
-	// Get actual info
-	info, err := f.GetInfo(ctx)
+	// Get actual metadata
+	md, err := readMetadata(f.path)
 	if err != nil {
 		return err
 	}
 
 	// Check the last chunk size is full
-	if info.ChunksCount > 0 && info.LastChunkSize != info.ChunkSize {
+	if md.ChunksCount > 0 && md.LastChunkSize != md.ChunkSize {
 		return fmt.Errorf("%w", storage.ErrLastChunkNotFull)
 	}
 
-	// Resize chunks
-	if size > info.ChunksCount {
-		// Add chunks
-		for i := info.ChunksCount; i < size; i++ {
-			path := f.getChunckPath(i)
-			if err := os.WriteFile(path, make([]byte, info.ChunkSize), 0644); err != nil {
-				return err
-			}
+	// Remove chunks that are stored, the others being holes or not imported
+	for i := size; i < md.ChunksCount; i++ {
+		if md.Holes.Get(i) {
+			continue
 		}
-	} else {
-		// Remove chunks
-		for i := size; i < info.ChunksCount; i++ {
-			path := f.getChunckPath(i)
-			if err := os.Remove(path); err != nil {
-				return err
-			}
+
+		if err := os.Remove(f.getChunckPath(i)); err == nil {
+			md.AllocatedSize -= md.ChunkSize
+		} else if !os.IsNotExist(err) {
+			return err
 		}
 	}
 
-	// Update info
-	info.ChunksCount = size
-	info.LastChunkSize = info.ChunkSize
-	return f.saveInfo(info)
+	// Add new chunks as holes
+	md.resizeHoles(size)
+
+	// Update metadata
+	md.ChunksCount = size
+	md.LastChunkSize = md.ChunkSize
+	return f.saveMetadata(md)
 }
 
-func (f *file) checkResizeLastChunkParams(info info.File, size int) error {
+func (f *file) checkResizeLastChunkParams(md metadata, size int) error {
 	// Check size is correct
-	if size < 0 || size > info.ChunkSize {
+	if size < 0 || size > md.ChunkSize {
 		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkSize, size)
 	}
 
 	// Check if there is a last chunk
-	if info.ChunksCount == 0 {
+	if md.ChunksCount == 0 {
 		return fmt.Errorf("%w", storage.ErrNoChunk)
 	}
 
 	// Check if the last chunk is present
-	lastChunkPath := f.getChunckPath(info.ChunksCount - 1)
-	if _, err := os.Stat(lastChunkPath); err != nil {
-		if os.IsNotExist(err) {
-			return fmt.Errorf("%w", storage.ErrChunkNotFound)
-		}
+	if exists, err := f.chunkExists(md, md.ChunksCount-1); err != nil {
 		return err
+	} else if !exists {
+		return fmt.Errorf("%w", storage.ErrChunkNotFound)
 	}
 
 	return nil
 }
 
 // ResizeLastChunk resizes the last chunk.
-func (f *file) ResizeLastChunk(ctx context.Context, size int) (changed int, err error) {
-	// Get actual info
-	info, err := f.GetInfo(ctx)
+func (f *file) ResizeLastChunk(_ context.Context, size int) (changed int, err error) {
+	defer f.locks.lock(f.path)()
+
+	// Get actual metadata
+	md, err := readMetadata(f.path)
 	if err != nil {
 		return 0, err
 	}
 
 	// Check params
-	if err := f.checkResizeLastChunkParams(info, size); err != nil {
+	if err := f.checkResizeLastChunkParams(md, size); err != nil {
 		return 0, err
 	}
 
-	// Resize last chunk
-	lastChunkPath := f.getChunckPath(info.ChunksCount - 1)
-	lastChunkSize := info.LastChunkSize
-	if size > lastChunkSize {
-		// Append data
-		if err := appendFile(lastChunkPath, make([]byte, size-lastChunkSize)); err != nil {
-			return 0, err
-		}
-	} else {
-		// Remove data
-		if err := os.Truncate(lastChunkPath, int64(size)); err != nil {
-			return 0, err
+	// Resize last chunk, if this is not a hole
+	lastChunkPath := f.getChunckPath(md.ChunksCount - 1)
+	lastChunkSize := md.LastChunkSize
+	if !md.Holes.Get(md.ChunksCount - 1) {
+		if size > lastChunkSize {
+			// Append data
+			if err := appendFile(lastChunkPath, make([]byte, size-lastChunkSize)); err != nil {
+				return 0, err
+			}
+		} else {
+			// Remove data
+			if err := os.Truncate(lastChunkPath, int64(size)); err != nil {
+				return 0, err
+			}
 		}
+		md.AllocatedSize += size - lastChunkSize
 	}
 
 	// Set size
-	info.LastChunkSize = size
-	if err := f.saveInfo(info); err != nil {
+	md.LastChunkSize = size
+	if err := f.saveMetadata(md); err != nil {
 		return 0, err
 	}
 
@@ -2,16 +2,21 @@ package disk
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"sync"
 	"testing"
 
 	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
 	"github.com/lerenn/chonkfs/pkg/storage/test"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
 func TestFileSuite(t *testing.T) {
 	suite.Run(t, new(FileSuite))
+	suite.Run(t, new(PackedFileSuite))
 }
 
 type FileSuite struct {
@@ -63,3 +68,189 @@ func (suite *FileSuite) TestWriteChunkData() {
 	suite.Require().NoError(err)
 	suite.Require().Equal(int64(8), stats.Size())
 }
+
+func (suite *FileSuite) TestConcurrentWrites() {
+	writeChunksConcurrently(suite.Require(), suite.Directory)
+}
+
+func (suite *FileSuite) TestResizeChunksNbCreatesHoles() {
+	f, err := suite.Directory.CreateFile(context.Background(), "File", info.File{
+		ChunkSize: 8,
+	})
+	suite.Require().NoError(err)
+
+	// Resize chunk count
+	err = f.ResizeChunksNb(context.Background(), 3)
+	suite.Require().NoError(err)
+
+	// Check there is no chunk file and nothing allocated
+	_, err = os.Stat(f.(*file).getChunckPath(1))
+	suite.Require().True(os.IsNotExist(err))
+	fInfo, err := f.GetInfo(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal(24, fInfo.Size)
+	suite.Require().Equal(0, fInfo.AllocatedSize)
+
+	// Write on a chunk and check it is allocated
+	_, err = f.WriteChunk(context.Background(), 1, []byte("Hello"), 0)
+	suite.Require().NoError(err)
+	_, err = os.Stat(f.(*file).getChunckPath(1))
+	suite.Require().NoError(err)
+	fInfo, err = f.GetInfo(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal(8, fInfo.AllocatedSize)
+
+	// Remove the allocated chunk and check it is not counted anymore
+	err = f.ResizeChunksNb(context.Background(), 1)
+	suite.Require().NoError(err)
+	fInfo, err = f.GetInfo(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal(8, fInfo.Size)
+	suite.Require().Equal(0, fInfo.AllocatedSize)
+}
+
+type PackedFileSuite struct {
+	test.FileSuite
+	Path string
+}
+
+func (suite *PackedFileSuite) SetupTest() {
+	path, err := os.MkdirTemp("", "chonkfs-test-*")
+	suite.Require().NoError(err)
+	suite.Path = path
+	suite.Directory = NewDirectory(path, WithPackedChunks())
+}
+
+func (suite *PackedFileSuite) TearDownTest() {
+	err := os.RemoveAll(suite.Path)
+	suite.Require().NoError(err)
+}
+
+func (suite *PackedFileSuite) TestChunksAreInOneDataFile() {
+	f, err := suite.Directory.CreateFile(context.Background(), "File", info.File{
+		ChunkSize: 8,
+	})
+	suite.Require().NoError(err)
+
+	// Resize chunk count
+	err = f.ResizeChunksNb(context.Background(), 3)
+	suite.Require().NoError(err)
+
+	// Write on the last chunk
+	written, err := f.WriteChunk(context.Background(), 2, []byte("Hello"), 1)
+	suite.Require().NoError(err)
+	suite.Require().Equal(5, written)
+
+	// Check there is only the data file and the metadata
+	entries, err := os.ReadDir(f.(*packedFile).path)
+	suite.Require().NoError(err)
+	suite.Require().Len(entries, 2)
+
+	// Check the data file is allocated for every chunk, with data at the right place
+	data, err := os.ReadFile(f.(*packedFile).getDataPath())
+	suite.Require().NoError(err)
+	suite.Require().Len(data, 24)
+	suite.Require().Equal("Hello", string(data[17:22]))
+}
+
+func (suite *PackedFileSuite) TestReadChunkWhenNotImported() {
+	f, err := suite.Directory.CreateFile(context.Background(), "File", info.File{
+		ChunkSize:     8,
+		ChunksCount:   2,
+		LastChunkSize: 4,
+	})
+	suite.Require().NoError(err)
+
+	// Read a chunk that is allocated but not imported
+	_, err = f.ReadChunk(context.Background(), 0, make([]byte, 8), 0)
+	suite.Require().ErrorIs(err, storage.ErrChunkNotFound)
+
+	// Import it and read it
+	suite.Require().NoError(f.ImportChunk(context.Background(), 0, []byte("ChonkFS!")))
+	data := make([]byte, 8)
+	read, err := f.ReadChunk(context.Background(), 0, data, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal(8, read)
+	suite.Require().Equal("ChonkFS!", string(data))
+
+	// Check the other chunk is still not present
+	_, err = f.ReadChunk(context.Background(), 1, make([]byte, 8), 0)
+	suite.Require().ErrorIs(err, storage.ErrChunkNotFound)
+}
+
+func (suite *PackedFileSuite) TestResizeChunksNbCreatesHoles() {
+	f, err := suite.Directory.CreateFile(context.Background(), "File", info.File{
+		ChunkSize: 8,
+	})
+	suite.Require().NoError(err)
+
+	// Resize chunk count
+	err = f.ResizeChunksNb(context.Background(), 3)
+	suite.Require().NoError(err)
+
+	// Check nothing is allocated
+	fInfo, err := f.GetInfo(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal(24, fInfo.Size)
+	suite.Require().Equal(0, fInfo.AllocatedSize)
+
+	// Write on the last chunk and check it is allocated
+	_, err = f.ResizeLastChunk(context.Background(), 4)
+	suite.Require().NoError(err)
+	_, err = f.WriteChunk(context.Background(), 2, []byte("Hi"), 0)
+	suite.Require().NoError(err)
+	fInfo, err = f.GetInfo(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal(20, fInfo.Size)
+	suite.Require().Equal(4, fInfo.AllocatedSize)
+}
+
+func (suite *PackedFileSuite) TestConcurrentWrites() {
+	writeChunksConcurrently(suite.Require(), suite.Directory)
+}
+
+// writeChunksConcurrently writes every hole of a file at once, from several
+// handles, then checks every chunk has been kept.
+func writeChunksConcurrently(r *require.Assertions, d storage.Directory) {
+	const chunks = 32
+
+	f, err := d.CreateFile(context.Background(), "file", info.File{ChunkSize: 4})
+	r.NoError(err)
+	r.NoError(f.ResizeChunksNb(context.Background(), chunks))
+
+	// Write the chunks
+	var wg sync.WaitGroup
+	errs := make(chan error, chunks)
+	for i := range chunks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			h, err := d.GetFile(context.Background(), "file")
+			if err == nil {
+				_, err = h.WriteChunk(context.Background(), i, []byte(fmt.Sprintf("%04d", i)), 0)
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		r.NoError(err)
+	}
+
+	// Check they are all kept and allocated
+	fileInfo, err := f.GetInfo(context.Background())
+	r.NoError(err)
+	r.Equal(chunks*4, fileInfo.AllocatedSize)
+	for i := range chunks {
+		hasData, err := f.(storage.SparseFile).HasChunkData(context.Background(), i)
+		r.NoError(err)
+		r.True(hasData, "chunk %d", i)
+
+		data := make([]byte, 4)
+		_, err = f.ReadChunk(context.Background(), i, data, 0)
+		r.NoError(err)
+		r.Equal(fmt.Sprintf("%04d", i), string(data))
+	}
+}
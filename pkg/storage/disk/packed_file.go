@@ -0,0 +1,367 @@
+package disk
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+const (
+	dataFileName = "chunks.dat"
+)
+
+var _ storage.SparseFile = (*packedFile)(nil)
+
+// packedFile is a file whose chunks are all stored in one data file, each
+// chunk being at the offset index*ChunkSize. As the data file is allocated
+// for every chunk, the presence of the chunks and the holes are kept in the
+// metadata.
+type packedFile struct {
+	path  string
+	locks *fileLocks
+}
+
+func newPackedFile(path string, info info.File, locks *fileLocks) (*packedFile, error) {
+	if info.ChunkSize <= 0 {
+		return nil, fmt.Errorf("%w: chunk size must be greater than 0", storage.ErrInvalidChunkSize)
+	}
+
+	return &packedFile{
+		path:  path,
+		locks: locks,
+	}, nil
+}
+
+func createDataFile(p string, info info.File) error {
+	// Create data file
+	f, err := os.Create(path.Join(p, dataFileName))
+	if err != nil {
+		return err
+	}
+
+	// Allocate chunks
+	if err := f.Truncate(int64(info.ChunksCount * info.ChunkSize)); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func (f *packedFile) getDataPath() string {
+	return path.Join(f.path, dataFileName)
+}
+
+// GetInfo returns the file info.
+func (f *packedFile) GetInfo(_ context.Context) (info.File, error) {
+	md, err := readMetadata(f.path)
+	return md.info(), err
+}
+
+// SetAttributes sets the file attributes.
+func (f *packedFile) SetAttributes(_ context.Context, attr info.Attributes) error {
+	defer f.locks.lock(f.path)()
+
+	md, err := readMetadata(f.path)
+	if err != nil {
+		return err
+	}
+
+	md.Attributes = attr
+	return f.saveMetadata(md)
+}
+
+func (f *packedFile) saveMetadata(md metadata) error {
+	return writeMetadata(f.path, md)
+}
+
+func (f *packedFile) checkImportChunkParams(md metadata, index int, data []byte) error {
+	// Check if chunk index is correct
+	if index < 0 || index >= md.ChunksCount {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkNb, index)
+	}
+
+	// Check if the chunk is empty
+	if md.Chunks.Get(index) || md.Holes.Get(index) {
+		return fmt.Errorf("%w: %d", storage.ErrChunkAlreadyExists, index)
+	}
+
+	// Check if length of data is correct
+	if (len(data) != md.ChunkSize && index != md.ChunksCount-1) || len(data) > md.ChunkSize {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkSize, len(data))
+	}
+
+	return nil
+}
+
+// ImportChunk imports a chunk of data.
+func (f *packedFile) ImportChunk(_ context.Context, index int, data []byte) error {
+	defer f.locks.lock(f.path)()
+
+	// Get metadata
+	md, err := readMetadata(f.path)
+	if err != nil {
+		return err
+	}
+
+	// Check params
+	if err := f.checkImportChunkParams(md, index, data); err != nil {
+		return err
+	}
+
+	// Import data
+	if err := writeAt(f.getDataPath(), data, int64(index*md.ChunkSize)); err != nil {
+		return err
+	}
+
+	// Mark the chunk as present and, if this is the last chunk, set the last
+	// chunk size
+	md.Chunks.Set(index)
+	md.AllocatedSize += len(data)
+	if index == md.ChunksCount-1 {
+		md.LastChunkSize = len(data)
+	}
+
+	return f.saveMetadata(md)
+}
+
+func (f *packedFile) checkReadWriteChunkParams(md metadata, index int, offset int) error {
+	// Check if chunk index is correct
+	if index < 0 || index >= md.ChunksCount {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkNb, index)
+	}
+
+	// Check if there is data to read
+	if !md.Chunks.Get(index) && !md.Holes.Get(index) {
+		return fmt.Errorf("%w", storage.ErrChunkNotFound)
+	}
+
+	// Check if offset is correct
+	if offset < 0 || offset >= md.ChunkSize {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidOffset, offset)
+	}
+
+	// Check if this is the last chunk, that the offset is correct
+	if index == md.ChunksCount-1 && offset >= md.LastChunkSize {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidOffset, offset)
+	}
+
+	return nil
+}
+
+// WriteChunk writes a chunk of data.
+func (f *packedFile) WriteChunk(_ context.Context, index int, data []byte, offset int) (int, error) {
+	defer f.locks.lock(f.path)()
+
+	// Get metadata
+	md, err := readMetadata(f.path)
+	if err != nil {
+		return 0, err
+	}
+
+	// Check params
+	if err := f.checkReadWriteChunkParams(md, index, offset); err != nil {
+		return 0, err
+	}
+
+	// Limit data to write if it is too long
+	if len(data) > md.ChunkSize-offset {
+		data = data[:md.ChunkSize-offset]
+	}
+
+	// Write data
+	if err := writeAt(f.getDataPath(), data, int64(index*md.ChunkSize+offset)); err != nil {
+		return 0, err
+	}
+
+	// Allocate the chunk if this is a hole, as its space in the data file
+	// is already filled with zeros
+	if md.Holes.Get(index) {
+		md.Holes.Clear(index)
+		md.Chunks.Set(index)
+		md.AllocatedSize += md.getChunkSize(index)
+		if err := f.saveMetadata(md); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(data), nil
+}
+
+// ReadChunk reads a chunk of data.
+func (f *packedFile) ReadChunk(_ context.Context, index int, data []byte, offset int) (int, error) {
+	// Get metadata
+	md, err := readMetadata(f.path)
+	if err != nil {
+		return 0, err
+	}
+
+	// Check params
+	if err := f.checkReadWriteChunkParams(md, index, offset); err != nil {
+		return 0, err
+	}
+
+	// Limit data to read to the chunk
+	chunkSize := md.ChunkSize
+	if index == md.ChunksCount-1 {
+		chunkSize = md.LastChunkSize
+	}
+	if len(data) > chunkSize-offset {
+		data = data[:chunkSize-offset]
+	}
+
+	// Open file
+	file, err := os.Open(f.getDataPath())
+	if err != nil {
+		return 0, err
+	}
+
+	// Read data
+	n, err := file.ReadAt(data, int64(index*md.ChunkSize+offset))
+	if err != nil {
+		_ = file.Close()
+		return 0, err
+	}
+
+	// Close file
+	return n, file.Close()
+}
+
+// HasChunkData returns true if the chunk holds data, false if this is a hole.
+func (f *packedFile) HasChunkData(_ context.Context, index int) (bool, error) {
+	// Get metadata
+	md, err := readMetadata(f.path)
+	if err != nil {
+		return false, err
+	}
+
+	// Check if chunk index is correct
+	if index < 0 || index >= md.ChunksCount {
+		return false, fmt.Errorf("%w: %d", storage.ErrInvalidChunkNb, index)
+	}
+
+	// Check if the chunk is present
+	if !md.Chunks.Get(index) && !md.Holes.Get(index) {
+		return false, fmt.Errorf("%w", storage.ErrChunkNotFound)
+	}
+
+	return md.Chunks.Get(index), nil
+}
+
+// ResizeChunksNb resizes the number of chunks.
+func (f *packedFile) ResizeChunksNb(_ context.Context, size int) error {
+	defer f.locks.lock(f.path)()
+
+	// Check size is correct
+	if size < 0 {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkNb, size)
+	}
+
+	// Get actual metadata
+	md, err := readMetadata(f.path)
+	if err != nil {
+		return err
+	}
+
+	// Check the last chunk size is full
+	if md.ChunksCount > 0 && md.LastChunkSize != md.ChunkSize {
+		return fmt.Errorf("%w", storage.ErrLastChunkNotFull)
+	}
+
+	// Resize the data file, new chunks being filled with zeros
+	if err := os.Truncate(f.getDataPath(), int64(size*md.ChunkSize)); err != nil {
+		return err
+	}
+
+	// Remove the allocated size of the removed chunks
+	for i := size; i < md.ChunksCount; i++ {
+		if md.Chunks.Get(i) {
+			md.AllocatedSize -= md.ChunkSize
+		}
+	}
+
+	// Update chunks presence, new chunks being holes
+	md.Chunks = md.Chunks.Resize(size)
+	md.resizeHoles(size)
+
+	// Update info
+	md.ChunksCount = size
+	md.LastChunkSize = md.ChunkSize
+	return f.saveMetadata(md)
+}
+
+func (f *packedFile) checkResizeLastChunkParams(md metadata, size int) error {
+	// Check size is correct
+	if size < 0 || size > md.ChunkSize {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkSize, size)
+	}
+
+	// Check if there is a last chunk
+	if md.ChunksCount == 0 {
+		return fmt.Errorf("%w", storage.ErrNoChunk)
+	}
+
+	// Check if the last chunk is present
+	if !md.Chunks.Get(md.ChunksCount-1) && !md.Holes.Get(md.ChunksCount-1) {
+		return fmt.Errorf("%w", storage.ErrChunkNotFound)
+	}
+
+	return nil
+}
+
+// ResizeLastChunk resizes the last chunk.
+func (f *packedFile) ResizeLastChunk(_ context.Context, size int) (changed int, err error) {
+	defer f.locks.lock(f.path)()
+
+	// Get actual metadata
+	md, err := readMetadata(f.path)
+	if err != nil {
+		return 0, err
+	}
+
+	// Check params
+	if err := f.checkResizeLastChunkParams(md, size); err != nil {
+		return 0, err
+	}
+
+	// Erase the removed data, so it reads as zeros if the chunk grows again
+	lastChunkSize := md.LastChunkSize
+	if size < lastChunkSize && md.Chunks.Get(md.ChunksCount-1) {
+		start := (md.ChunksCount-1)*md.ChunkSize + size
+		if err := writeAt(f.getDataPath(), make([]byte, lastChunkSize-size), int64(start)); err != nil {
+			return 0, err
+		}
+	}
+
+	// Set size
+	if md.Chunks.Get(md.ChunksCount - 1) {
+		md.AllocatedSize += size - lastChunkSize
+	}
+	md.LastChunkSize = size
+	if err := f.saveMetadata(md); err != nil {
+		return 0, err
+	}
+
+	return size - lastChunkSize, nil
+}
+
+func writeAt(path string, data []byte, offset int64) error {
+	// Open file
+	f, err := os.OpenFile(path, os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	// Write data
+	if _, err := f.WriteAt(data, offset); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	// Close file
+	return f.Close()
+}
@@ -0,0 +1,165 @@
+package disk
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+// xattrs are the extended attributes of a file or a directory, kept in its
+// metadata.
+type xattrs map[string][]byte
+
+func (x xattrs) get(name string) ([]byte, error) {
+	value, ok := x[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", storage.ErrXattrNotFound, name)
+	}
+	return value, nil
+}
+
+func (x xattrs) list() []string {
+	return slices.Sorted(maps.Keys(x))
+}
+
+func (x *xattrs) set(name string, value []byte) {
+	if *x == nil {
+		*x = make(xattrs)
+	}
+	(*x)[name] = value
+}
+
+func (x xattrs) remove(name string) error {
+	if _, ok := x[name]; !ok {
+		return fmt.Errorf("%w: %q", storage.ErrXattrNotFound, name)
+	}
+	delete(x, name)
+	return nil
+}
+
+// GetXattr returns the value of an extended attribute.
+func (f *file) GetXattr(_ context.Context, name string) ([]byte, error) {
+	return getFileXattr(f.path, name)
+}
+
+// ListXattrs returns the names of the extended attributes.
+func (f *file) ListXattrs(_ context.Context) ([]string, error) {
+	return listFileXattrs(f.path)
+}
+
+// SetXattr creates or replaces an extended attribute.
+func (f *file) SetXattr(_ context.Context, name string, value []byte) error {
+	defer f.locks.lock(f.path)()
+	return setFileXattr(f.path, name, value)
+}
+
+// RemoveXattr removes an extended attribute.
+func (f *file) RemoveXattr(_ context.Context, name string) error {
+	defer f.locks.lock(f.path)()
+	return removeFileXattr(f.path, name)
+}
+
+// GetXattr returns the value of an extended attribute.
+func (f *packedFile) GetXattr(_ context.Context, name string) ([]byte, error) {
+	return getFileXattr(f.path, name)
+}
+
+// ListXattrs returns the names of the extended attributes.
+func (f *packedFile) ListXattrs(_ context.Context) ([]string, error) {
+	return listFileXattrs(f.path)
+}
+
+// SetXattr creates or replaces an extended attribute.
+func (f *packedFile) SetXattr(_ context.Context, name string, value []byte) error {
+	defer f.locks.lock(f.path)()
+	return setFileXattr(f.path, name, value)
+}
+
+// RemoveXattr removes an extended attribute.
+func (f *packedFile) RemoveXattr(_ context.Context, name string) error {
+	defer f.locks.lock(f.path)()
+	return removeFileXattr(f.path, name)
+}
+
+func getFileXattr(p, name string) ([]byte, error) {
+	md, err := readMetadata(p)
+	if err != nil {
+		return nil, err
+	}
+	return md.Xattrs.get(name)
+}
+
+func listFileXattrs(p string) ([]string, error) {
+	md, err := readMetadata(p)
+	if err != nil {
+		return nil, err
+	}
+	return md.Xattrs.list(), nil
+}
+
+func setFileXattr(p, name string, value []byte) error {
+	md, err := readMetadata(p)
+	if err != nil {
+		return err
+	}
+
+	md.Xattrs.set(name, value)
+	return writeMetadata(p, md)
+}
+
+func removeFileXattr(p, name string) error {
+	md, err := readMetadata(p)
+	if err != nil {
+		return err
+	}
+
+	if err := md.Xattrs.remove(name); err != nil {
+		return err
+	}
+	return writeMetadata(p, md)
+}
+
+// GetXattr returns the value of an extended attribute.
+func (d *directory) GetXattr(_ context.Context, name string) ([]byte, error) {
+	md, err := d.readMetadata()
+	if err != nil {
+		return nil, err
+	}
+	return md.Xattrs.get(name)
+}
+
+// ListXattrs returns the names of the extended attributes.
+func (d *directory) ListXattrs(_ context.Context) ([]string, error) {
+	md, err := d.readMetadata()
+	if err != nil {
+		return nil, err
+	}
+	return md.Xattrs.list(), nil
+}
+
+// SetXattr creates or replaces an extended attribute.
+func (d *directory) SetXattr(_ context.Context, name string, value []byte) error {
+	md, err := d.readMetadata()
+	if err != nil {
+		return err
+	}
+
+	md.Xattrs.set(name, value)
+	return d.writeMetadata(md)
+}
+
+// RemoveXattr removes an extended attribute.
+func (d *directory) RemoveXattr(_ context.Context, name string) error {
+	md, err := d.readMetadata()
+	if err != nil {
+		return err
+	}
+
+	if err := md.Xattrs.remove(name); err != nil {
+		return err
+	}
+	return d.writeMetadata(md)
+}
@@ -0,0 +1,277 @@
+package erasure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+
+	"github.com/klauspost/reedsolomon"
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+var (
+	// ErrInvalidShardsNb happens when there is not at least one data shard and
+	// one parity shard.
+	ErrInvalidShardsNb = errors.New("invalid shards number")
+	// ErrNotErasure happens when an erasure operation is called on another
+	// storage.
+	ErrNotErasure = errors.New("not an erasure storage")
+)
+
+var _ storage.Directory = (*directory)(nil)
+
+type directoryOption func(dir *directory)
+
+// WithVerifyOnRead is an option to check every chunk read against the other
+// shards of its group, rebuilding it if it is corrupted. It reads the whole
+// group on each read.
+//
+//nolint:revive
+func WithVerifyOnRead() directoryOption {
+	return func(dir *directory) {
+		dir.verify = true
+	}
+}
+
+type directory struct {
+	shards []storage.Directory
+	data   int
+	path   string
+	locks  *fileLocks
+
+	encoder reedsolomon.Encoder
+	verify  bool
+}
+
+// NewDirectory creates a new directory representation, keeping the same tree
+// and files metadata on every shard. The last shards are parity ones: the
+// chunks of a file are grouped by the number of data shards, the chunk at
+// index i being stored on the data shard i mod k, and each group gets its
+// Reed-Solomon parity on the parity shards, at the index of its first chunk.
+// Up to the number of parity shards can be lost.
+func NewDirectory(shards []storage.Directory, parity int, opts ...directoryOption) (storage.Directory, error) {
+	data := len(shards) - parity
+	if data < 1 || parity < 1 {
+		return nil, fmt.Errorf("%w: %d data and %d parity shards", ErrInvalidShardsNb, data, parity)
+	}
+
+	enc, err := reedsolomon.New(data, parity)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the directory
+	d := &directory{
+		shards:  shards,
+		data:    data,
+		path:    "/",
+		locks:   newFileLocks(),
+		encoder: enc,
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d, nil
+}
+
+func (d *directory) newChildDirectory(shards []storage.Directory, name string) *directory {
+	return &directory{
+		shards:  shards,
+		data:    d.data,
+		path:    path.Join(d.path, name),
+		locks:   d.locks,
+		encoder: d.encoder,
+		verify:  d.verify,
+	}
+}
+
+func (d *directory) newChildFile(shards []storage.File, name string) *file {
+	return newFile(shards, d.data, d.encoder, d.verify, path.Join(d.path, name), d.locks)
+}
+
+// CreateDirectory creates a directory.
+func (d *directory) CreateDirectory(ctx context.Context, name string) (storage.Directory, error) {
+	children, err := onPresent(d.shards, func(s storage.Directory) (storage.Directory, error) {
+		return s.CreateDirectory(ctx, name)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return d.newChildDirectory(children, name), nil
+}
+
+// GetDirectory returns a directory.
+func (d *directory) GetDirectory(ctx context.Context, name string) (storage.Directory, error) {
+	children, err := fromEnough(d.shards, d.data, func(s storage.Directory) (storage.Directory, error) {
+		return s.GetDirectory(ctx, name)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return d.newChildDirectory(children, name), nil
+}
+
+// GetInfo returns the directory info.
+func (d *directory) GetInfo(ctx context.Context) (info.Directory, error) {
+	return firstHealthy(d.shards, func(s storage.Directory) (info.Directory, error) {
+		return s.GetInfo(ctx)
+	})
+}
+
+// SetAttributes sets the directory attributes, on the present shards.
+func (d *directory) SetAttributes(ctx context.Context, attr info.Attributes) error {
+	_, err := onPresent(d.shards, func(s storage.Directory) (struct{}, error) {
+		return struct{}{}, s.SetAttributes(ctx, attr)
+	})
+	return err
+}
+
+// CreateFile creates a file.
+func (d *directory) CreateFile(ctx context.Context, name string, info info.File) (storage.File, error) {
+	children, err := onPresent(d.shards, func(s storage.Directory) (storage.File, error) {
+		return s.CreateFile(ctx, name, info)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return d.newChildFile(children, name), nil
+}
+
+// GetFile returns a file.
+func (d *directory) GetFile(ctx context.Context, name string) (storage.File, error) {
+	children, err := fromEnough(d.shards, d.data, func(s storage.Directory) (storage.File, error) {
+		return s.GetFile(ctx, name)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return d.newChildFile(children, name), nil
+}
+
+// ListFiles returns a map of files, present on enough shards to be read.
+func (d *directory) ListFiles(ctx context.Context) (map[string]storage.File, error) {
+	lists, err := fromEnough(d.shards, d.data, func(s storage.Directory) (map[string]storage.File, error) {
+		return s.ListFiles(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]storage.File)
+	for _, l := range lists {
+		for name := range l {
+			if _, ok := files[name]; ok {
+				continue
+			}
+
+			if children, ok := enough(lists, name, d.data); ok {
+				files[name] = d.newChildFile(children, name)
+			}
+		}
+	}
+
+	return files, nil
+}
+
+// RemoveDirectory removes a directory.
+func (d *directory) RemoveDirectory(ctx context.Context, name string) error {
+	_, err := onPresent(d.shards, func(s storage.Directory) (struct{}, error) {
+		return struct{}{}, s.RemoveDirectory(ctx, name)
+	})
+	return err
+}
+
+// ListDirectories returns a map of directories, present on enough shards to
+// be read.
+func (d *directory) ListDirectories(ctx context.Context) (map[string]storage.Directory, error) {
+	lists, err := fromEnough(d.shards, d.data, func(s storage.Directory) (map[string]storage.Directory, error) {
+		return s.ListDirectories(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := make(map[string]storage.Directory)
+	for _, l := range lists {
+		for name := range l {
+			if _, ok := dirs[name]; ok {
+				continue
+			}
+
+			if children, ok := enough(lists, name, d.data); ok {
+				dirs[name] = d.newChildDirectory(children, name)
+			}
+		}
+	}
+
+	return dirs, nil
+}
+
+// RemoveFile removes a file.
+func (d *directory) RemoveFile(ctx context.Context, name string) error {
+	_, err := onPresent(d.shards, func(s storage.Directory) (struct{}, error) {
+		return struct{}{}, s.RemoveFile(ctx, name)
+	})
+	return err
+}
+
+// RenameFile renames a file.
+func (d *directory) RenameFile(
+	ctx context.Context,
+	name string,
+	newParent storage.Directory,
+	newName string,
+	noReplace bool,
+) error {
+	np, ok := newParent.(*directory)
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrNotErasure, newParent)
+	}
+
+	for i, s := range d.shards {
+		if s == nil || np.shards[i] == nil {
+			continue
+		}
+
+		if err := s.RenameFile(ctx, name, np.shards[i], newName, noReplace); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RenameDirectory renames a directory.
+func (d *directory) RenameDirectory(
+	ctx context.Context,
+	name string,
+	newParent storage.Directory,
+	newName string,
+	noReplace bool,
+) error {
+	np, ok := newParent.(*directory)
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrNotErasure, newParent)
+	}
+
+	for i, s := range d.shards {
+		if s == nil || np.shards[i] == nil {
+			continue
+		}
+
+		if err := s.RenameDirectory(ctx, name, np.shards[i], newName, noReplace); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
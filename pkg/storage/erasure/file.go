@@ -0,0 +1,457 @@
+package erasure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/klauspost/reedsolomon"
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+var (
+	_ storage.SparseFile = (*file)(nil)
+	_ storage.Syncer     = (*file)(nil)
+)
+
+type file struct {
+	shards []storage.File
+	data   int
+	path   string
+	locks  *fileLocks
+
+	encoder reedsolomon.Encoder
+	verify  bool
+}
+
+func newFile(
+	shards []storage.File,
+	data int,
+	encoder reedsolomon.Encoder,
+	verify bool,
+	path string,
+	locks *fileLocks,
+) *file {
+	return &file{
+		shards:  shards,
+		data:    data,
+		path:    path,
+		locks:   locks,
+		encoder: encoder,
+		verify:  verify,
+	}
+}
+
+// dataShard returns the data shard storing the chunk. Invalid indexes are
+// sent to the first shard, that will reject them.
+func (f *file) dataShard(index int) int {
+	if index < 0 {
+		return 0
+	}
+
+	return index % f.data
+}
+
+// chunkSize returns the size of the chunk at the index.
+func chunkSize(fileInfo info.File, index int) int {
+	if index == fileInfo.ChunksCount-1 {
+		return fileInfo.LastChunkSize
+	}
+	return fileInfo.ChunkSize
+}
+
+// GetInfo returns the file info, with the last chunk size from the data shard
+// that stores it and the allocated size of the data shards.
+func (f *file) GetInfo(ctx context.Context) (info.File, error) {
+	infos, err := fromEnough(f.shards, 1, func(s storage.File) (info.File, error) {
+		return s.GetInfo(ctx)
+	})
+	if err != nil {
+		return info.File{}, err
+	}
+
+	// Take the metadata from the first shard present
+	var fileInfo info.File
+	for i, s := range f.shards {
+		if s != nil {
+			fileInfo = infos[i]
+			break
+		}
+	}
+
+	if fileInfo.ChunksCount > 0 {
+		if last := f.dataShard(fileInfo.ChunksCount - 1); f.shards[last] != nil {
+			fileInfo.LastChunkSize = infos[last].LastChunkSize
+		}
+		fileInfo.Size = (fileInfo.ChunksCount-1)*fileInfo.ChunkSize + fileInfo.LastChunkSize
+	}
+
+	fileInfo.AllocatedSize = 0
+	for _, i := range infos[:f.data] {
+		fileInfo.AllocatedSize += i.AllocatedSize
+	}
+
+	return fileInfo, nil
+}
+
+// SetAttributes sets the file attributes, on the present shards.
+func (f *file) SetAttributes(ctx context.Context, attr info.Attributes) error {
+	_, err := onPresent(f.shards, func(s storage.File) (struct{}, error) {
+		return struct{}{}, s.SetAttributes(ctx, attr)
+	})
+	return err
+}
+
+// ReadChunk reads _ from a chunk, rebuilding it from the other shards of its
+// group if its data shard is missing or, when verifying, corrupted.
+func (f *file) ReadChunk(ctx context.Context, index int, data []byte, offset int) (int, error) {
+	s := f.shards[f.dataShard(index)]
+
+	// Read from the data shard
+	err := ErrNotEnoughShards
+	if s != nil && !f.verify {
+		var read int
+		read, err = s.ReadChunk(ctx, index, data, offset)
+		if err == nil || errors.Is(err, storage.ErrInvalidChunkNb) || errors.Is(err, storage.ErrInvalidOffset) {
+			return read, err
+		}
+	}
+
+	// Rebuild it from its group
+	chunk, rerr := f.rebuildChunk(ctx, index)
+	switch {
+	case rerr == nil:
+	case f.verify && s != nil && errors.Is(rerr, ErrNotEnoughShards):
+		// The group cannot be verified (yet)
+		return s.ReadChunk(ctx, index, data, offset)
+	case errors.Is(rerr, ErrNotEnoughShards):
+		return 0, err
+	default:
+		return 0, rerr
+	}
+
+	// Check if offset is correct
+	if offset < 0 || offset >= len(chunk) {
+		return 0, fmt.Errorf("%w: %d", storage.ErrInvalidOffset, offset)
+	}
+
+	return copy(data, chunk[offset:]), nil
+}
+
+// rebuildChunk returns the chunk data, from the shards of its group. The file
+// is locked so the group is not read while it is modified.
+func (f *file) rebuildChunk(ctx context.Context, index int) ([]byte, error) {
+	unlock := f.locks.lock(f.path)
+	defer unlock()
+
+	fileInfo, err := f.GetInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if chunk index is correct
+	if index < 0 || index >= fileInfo.ChunksCount {
+		return nil, fmt.Errorf("%w: %d", storage.ErrInvalidChunkNb, index)
+	}
+
+	// Read the group
+	group := index / f.data
+	shards, _ := f.readGroup(ctx, fileInfo, group)
+	if f.verify {
+		if shards, err = f.heal(ctx, fileInfo, group, shards); err != nil {
+			return nil, err
+		}
+	}
+
+	// Rebuild the missing data shards
+	if err := f.encoder.ReconstructData(shards); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNotEnoughShards, err)
+	}
+
+	return shards[f.dataShard(index)][:chunkSize(fileInfo, index)], nil
+}
+
+// readGroup reads the shards of the group, with nil for the ones that cannot
+// be read. All shards have the size of the group first chunk, the chunks
+// after the end of the file being zeros. It also returns true if a data shard
+// is not present on its medium, as opposed to being lost.
+func (f *file) readGroup(ctx context.Context, fileInfo info.File, group int) ([][]byte, bool) {
+	first := group * f.data
+	size := chunkSize(fileInfo, first)
+
+	absent := false
+	shards := make([][]byte, len(f.shards))
+	for i, s := range f.shards {
+		index := first
+		if i < f.data {
+			index += i
+		}
+
+		// Chunks after the end of the file are zeros
+		buf := make([]byte, size)
+		if i < f.data && index >= fileInfo.ChunksCount {
+			shards[i] = buf
+			continue
+		} else if s == nil {
+			continue
+		}
+
+		_, err := s.ReadChunk(ctx, index, buf, 0)
+		switch {
+		case err == nil:
+			shards[i] = buf
+		case i < f.data && errors.Is(err, storage.ErrChunkNotFound):
+			absent = true
+		}
+	}
+
+	return shards, absent
+}
+
+// heal checks the group shards consistency. If they are not consistent, it
+// looks for the corrupted shard by rebuilding each of them in turn, and
+// writes it back on its medium.
+func (f *file) heal(ctx context.Context, fileInfo info.File, group int, shards [][]byte) ([][]byte, error) {
+	present := 0
+	for _, s := range shards {
+		if s != nil {
+			present++
+		}
+	}
+
+	// Check the shards, if there is enough of them to tell
+	if present <= f.data {
+		return shards, nil
+	}
+	rebuilt := append([][]byte(nil), shards...)
+	if err := f.encoder.Reconstruct(rebuilt); err != nil {
+		return nil, err
+	} else if ok, err := f.encoder.Verify(rebuilt); err != nil {
+		return nil, err
+	} else if ok {
+		return rebuilt, nil
+	}
+
+	// Look for the corrupted shard
+	for i := range shards {
+		if shards[i] == nil || present-1 <= f.data {
+			continue
+		}
+
+		rebuilt := append([][]byte(nil), shards...)
+		rebuilt[i] = nil
+		if err := f.encoder.Reconstruct(rebuilt); err != nil {
+			return nil, err
+		} else if ok, err := f.encoder.Verify(rebuilt); err != nil {
+			return nil, err
+		} else if !ok {
+			continue
+		}
+
+		// Errors are retried on next read
+		_ = f.writeShard(ctx, fileInfo, group, i, rebuilt[i])
+		return rebuilt, nil
+	}
+
+	return nil, fmt.Errorf("%w: group %d", ErrCorruptedShards, group)
+}
+
+// writeShard writes the shard of the group on its medium, importing it if it
+// is not present yet.
+func (f *file) writeShard(ctx context.Context, fileInfo info.File, group, shard int, data []byte) error {
+	index := group * f.data
+	if shard < f.data {
+		index += shard
+		data = data[:chunkSize(fileInfo, index)]
+	}
+
+	_, err := f.shards[shard].WriteChunk(ctx, index, data, 0)
+	if errors.Is(err, storage.ErrChunkNotFound) {
+		return f.shards[shard].ImportChunk(ctx, index, data)
+	}
+	return err
+}
+
+// withParity runs the modification of the group and updates its parity. The
+// group is read before the modification, so the lost shards can still be
+// rebuilt afterwards. Groups whose chunks are not all present have no parity.
+// The file is locked for the whole sequence, so concurrent modifications of
+// the group do not compute the parity from each other's chunks.
+func (f *file) withParity(ctx context.Context, group int, modify func() error) error {
+	unlock := f.locks.lock(f.path)
+	defer unlock()
+
+	// Get the group before modification
+	fileInfo, err := f.GetInfo(ctx)
+	if err != nil {
+		return err
+	}
+	var before [][]byte
+	if group*f.data < fileInfo.ChunksCount && chunkSize(fileInfo, group*f.data) > 0 {
+		shards, absent := f.readGroup(ctx, fileInfo, group)
+		if err := f.encoder.ReconstructData(shards); err == nil {
+			before = shards
+		} else if !absent {
+			return fmt.Errorf("%w: %w", ErrNotEnoughShards, err)
+		}
+	}
+
+	// Modify it
+	if err := modify(); err != nil {
+		return err
+	}
+
+	// Get the group after modification
+	fileInfo, err = f.GetInfo(ctx)
+	if err != nil {
+		return err
+	}
+	first := group * f.data
+	size := chunkSize(fileInfo, first)
+	if first >= fileInfo.ChunksCount || size == 0 {
+		return nil
+	}
+	shards, _ := f.readGroup(ctx, fileInfo, group)
+	for i := range shards[:f.data] {
+		if shards[i] == nil && before != nil {
+			shards[i] = make([]byte, size)
+			copy(shards[i], before[i])
+		} else if shards[i] == nil {
+			return nil
+		}
+	}
+
+	// Compute and write the parity
+	for i := f.data; i < len(shards); i++ {
+		shards[i] = make([]byte, size)
+	}
+	if err := f.encoder.Encode(shards); err != nil {
+		return err
+	}
+
+	var errs []error
+	for i := f.data; i < len(shards); i++ {
+		if f.shards[i] != nil {
+			errs = append(errs, f.writeShard(ctx, fileInfo, group, i, shards[i]))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// HasChunkData returns true if the chunk holds data, false if this is a hole.
+// Any chunk is considered holding data if its data shard is missing or cannot
+// tell.
+func (f *file) HasChunkData(ctx context.Context, index int) (bool, error) {
+	sf, ok := f.shards[f.dataShard(index)].(storage.SparseFile)
+	if !ok {
+		return true, nil
+	}
+
+	return sf.HasChunkData(ctx, index)
+}
+
+// WriteChunk writes _ to a chunk.
+func (f *file) WriteChunk(ctx context.Context, index int, data []byte, offset int) (int, error) {
+	s := f.shards[f.dataShard(index)]
+	if s == nil {
+		return 0, fmt.Errorf("%w: data shard %d", ErrNotEnoughShards, f.dataShard(index))
+	}
+
+	var written int
+	err := f.withParity(ctx, index/f.data, func() (err error) {
+		written, err = s.WriteChunk(ctx, index, data, offset)
+		return err
+	})
+	return written, err
+}
+
+// ImportChunk imports a chunk.
+func (f *file) ImportChunk(ctx context.Context, index int, data []byte) error {
+	s := f.shards[f.dataShard(index)]
+	if s == nil {
+		return fmt.Errorf("%w: data shard %d", ErrNotEnoughShards, f.dataShard(index))
+	}
+
+	return f.withParity(ctx, index/f.data, func() error {
+		return s.ImportChunk(ctx, index, data)
+	})
+}
+
+// ResizeChunksNb resizes the number of chunks on every shard.
+func (f *file) ResizeChunksNb(ctx context.Context, size int) error {
+	fileInfo, err := f.GetInfo(ctx)
+	if err != nil {
+		return err
+	}
+
+	resize := func() error {
+		_, err := onPresent(f.shards, func(s storage.File) (struct{}, error) {
+			return struct{}{}, s.ResizeChunksNb(ctx, size)
+		})
+		return err
+	}
+
+	// Added chunks are holes, that do not change the parity: only the group
+	// of the last chunk kept needs an update
+	kept := min(size, fileInfo.ChunksCount)
+	if kept <= 0 {
+		return resize()
+	}
+	return f.withParity(ctx, (kept-1)/f.data, resize)
+}
+
+// ResizeLastChunk resizes the last chunk, on the data shard that stores it
+// first. The other shards follow to keep the same metadata, unless they never
+// had this chunk.
+func (f *file) ResizeLastChunk(ctx context.Context, size int) (int, error) {
+	fileInfo, err := f.GetInfo(ctx)
+	if err != nil {
+		return 0, err
+	}
+	last := fileInfo.ChunksCount - 1
+	owner := f.shards[f.dataShard(last)]
+	if owner == nil {
+		return 0, fmt.Errorf("%w: data shard %d", ErrNotEnoughShards, f.dataShard(last))
+	}
+
+	var changed int
+	resize := func() error {
+		if changed, err = owner.ResizeLastChunk(ctx, size); err != nil {
+			return err
+		}
+
+		for _, s := range f.shards {
+			if s == nil || s == owner {
+				continue
+			}
+
+			_, err := s.ResizeLastChunk(ctx, size)
+			if err != nil && !errors.Is(err, storage.ErrChunkNotFound) {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if last < 0 {
+		err = resize()
+	} else {
+		err = f.withParity(ctx, last/f.data, resize)
+	}
+	return changed, err
+}
+
+// Sync syncs the shards that keep data before writing it.
+func (f *file) Sync(ctx context.Context) error {
+	var errs []error
+	for _, s := range f.shards {
+		if syncer, ok := s.(storage.Syncer); ok {
+			errs = append(errs, syncer.Sync(ctx))
+		}
+	}
+
+	return errors.Join(errs...)
+}
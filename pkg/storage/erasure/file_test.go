@@ -0,0 +1,236 @@
+package erasure
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+	"github.com/lerenn/chonkfs/pkg/storage/mem"
+	"github.com/lerenn/chonkfs/pkg/storage/test"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestFileSuite(t *testing.T) {
+	suite.Run(t, new(FileSuite))
+}
+
+type FileSuite struct {
+	Shards []storage.Directory
+	test.FileSuite
+}
+
+func (suite *FileSuite) SetupTest() {
+	suite.Shards = []storage.Directory{
+		mem.NewDirectory(), mem.NewDirectory(), mem.NewDirectory(),
+		mem.NewDirectory(), mem.NewDirectory(),
+	}
+
+	d, err := NewDirectory(suite.Shards, 2)
+	suite.Require().NoError(err)
+	suite.Directory = d
+}
+
+// createFile creates a file with 5 chunks, the last one being partial.
+func (suite *FileSuite) createFile() storage.File {
+	f, err := suite.Directory.CreateFile(context.Background(), "file", info.File{ChunkSize: 4})
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.ResizeChunksNb(context.Background(), 5))
+	_, err = f.ResizeLastChunk(context.Background(), 2)
+	suite.Require().NoError(err)
+
+	for i, data := range []string{"AAAA", "BBBB", "CCCC", "DDDD", "EE"} {
+		_, err := f.WriteChunk(context.Background(), i, []byte(data), 0)
+		suite.Require().NoError(err)
+	}
+
+	return f
+}
+
+// checkFile checks the file content, through a new erasure directory.
+func (suite *FileSuite) checkFile(opts ...directoryOption) {
+	d, err := NewDirectory(suite.Shards, 2, opts...)
+	suite.Require().NoError(err)
+	f, err := d.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+
+	for i, expected := range []string{"AAAA", "BBBB", "CCCC", "DDDD", "EE"} {
+		data := make([]byte, 4)
+		n, err := f.ReadChunk(context.Background(), i, data, 0)
+		suite.Require().NoError(err)
+		suite.Require().Equal(expected, string(data[:n]))
+	}
+}
+
+func (suite *FileSuite) TestReadWithDeletedShards() {
+	suite.createFile()
+
+	// Delete a data shard and a parity shard
+	suite.Require().NoError(suite.Shards[1].RemoveFile(context.Background(), "file"))
+	suite.Require().NoError(suite.Shards[4].RemoveFile(context.Background(), "file"))
+
+	suite.checkFile()
+}
+
+func (suite *FileSuite) TestReadWithReplacedShards() {
+	suite.createFile()
+
+	// Replace two data shards by empty ones
+	suite.Shards[0] = mem.NewDirectory()
+	suite.Shards[2] = mem.NewDirectory()
+
+	suite.checkFile()
+}
+
+func (suite *FileSuite) TestReadWithTooManyDeletedShards() {
+	suite.createFile()
+
+	for i := 0; i < 3; i++ {
+		suite.Require().NoError(suite.Shards[i].RemoveFile(context.Background(), "file"))
+	}
+
+	_, err := suite.Directory.GetFile(context.Background(), "file")
+	suite.Require().ErrorIs(err, storage.ErrFileNotFound)
+}
+
+func (suite *FileSuite) TestWriteWithDeletedShard() {
+	f := suite.createFile()
+
+	// Delete a data shard, then write on another chunk of the same group
+	suite.Require().NoError(suite.Shards[1].RemoveFile(context.Background(), "file"))
+	d, err := NewDirectory(suite.Shards, 2)
+	suite.Require().NoError(err)
+	f, err = d.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+	_, err = f.WriteChunk(context.Background(), 0, []byte("ZZ"), 2)
+	suite.Require().NoError(err)
+
+	// Check the deleted chunk can still be rebuilt
+	data := make([]byte, 4)
+	_, err = f.ReadChunk(context.Background(), 1, data, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal("BBBB", string(data))
+	_, err = f.ReadChunk(context.Background(), 0, data, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal("AAZZ", string(data))
+}
+
+func (suite *FileSuite) TestReadCorruptedShard() {
+	suite.createFile()
+
+	// Corrupt a chunk on its data shard
+	sf, err := suite.Shards[0].GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+	_, err = sf.WriteChunk(context.Background(), 3, []byte("XXXX"), 0)
+	suite.Require().NoError(err)
+
+	suite.checkFile(WithVerifyOnRead())
+
+	// Check the chunk has been repaired on its data shard
+	data := make([]byte, 4)
+	_, err = sf.ReadChunk(context.Background(), 3, data, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal("DDDD", string(data))
+}
+
+func (suite *FileSuite) TestShrinkWithDeletedShard() {
+	f := suite.createFile()
+
+	// Shrink the file to 4 chunks, then delete a data shard
+	_, err := f.ResizeLastChunk(context.Background(), 4)
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.ResizeChunksNb(context.Background(), 4))
+	suite.Require().NoError(suite.Shards[0].RemoveFile(context.Background(), "file"))
+
+	// Check the remaining chunk of the last group can be rebuilt
+	d, err := NewDirectory(suite.Shards, 2)
+	suite.Require().NoError(err)
+	f, err = d.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+	data := make([]byte, 4)
+	_, err = f.ReadChunk(context.Background(), 3, data, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal("DDDD", string(data))
+}
+
+func (suite *FileSuite) TestConcurrentWritesInGroup() {
+	suite.createFile()
+
+	// Slow down the parity writes, to let the writes of the group interleave
+	shards := append([]storage.Directory(nil), suite.Shards...)
+	for i := 3; i < len(shards); i++ {
+		shards[i] = slowDirectory{shards[i]}
+	}
+	d, err := NewDirectory(shards, 2)
+	suite.Require().NoError(err)
+
+	// Open a handle per chunk of the first group
+	files := make([]storage.File, 3)
+	for i := range files {
+		f, err := d.GetFile(context.Background(), "file")
+		suite.Require().NoError(err)
+		files[i] = f
+	}
+
+	// Write the chunks concurrently, a few times each
+	const writes = 20
+	var wg sync.WaitGroup
+	errs := make([]error, len(files))
+	for i, f := range files {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < writes && errs[i] == nil; j++ {
+				_, errs[i] = f.WriteChunk(context.Background(), i, []byte(fmt.Sprintf("%d%03d", i, j)), 0)
+			}
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		suite.Require().NoError(err)
+	}
+
+	// Delete two data shards and check the chunks are rebuilt from the parity
+	suite.Require().NoError(suite.Shards[0].RemoveFile(context.Background(), "file"))
+	suite.Require().NoError(suite.Shards[1].RemoveFile(context.Background(), "file"))
+	d, err = NewDirectory(suite.Shards, 2)
+	suite.Require().NoError(err)
+	f, err := d.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+	for i := range files {
+		data := make([]byte, 4)
+		_, err := f.ReadChunk(context.Background(), i, data, 0)
+		suite.Require().NoError(err)
+		suite.Require().Equal(fmt.Sprintf("%d%03d", i, writes-1), string(data))
+	}
+}
+
+func (suite *FileSuite) TestInvalidShardsNb() {
+	_, err := NewDirectory(suite.Shards, 0)
+	suite.Require().ErrorIs(err, ErrInvalidShardsNb)
+	_, err = NewDirectory(suite.Shards, 5)
+	suite.Require().ErrorIs(err, ErrInvalidShardsNb)
+}
+
+// slowDirectory is a directory whose files are slow to write.
+type slowDirectory struct {
+	storage.Directory
+}
+
+func (d slowDirectory) GetFile(ctx context.Context, name string) (storage.File, error) {
+	f, err := d.Directory.GetFile(ctx, name)
+	return slowFile{f}, err
+}
+
+type slowFile struct {
+	storage.File
+}
+
+func (f slowFile) WriteChunk(ctx context.Context, index int, data []byte, offset int) (int, error) {
+	time.Sleep(time.Duration(rand.IntN(1000)) * time.Microsecond)
+	return f.File.WriteChunk(ctx, index, data, offset)
+}
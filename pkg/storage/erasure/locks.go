@@ -0,0 +1,45 @@
+package erasure
+
+import "sync"
+
+// fileLocks are the locks of the files, by path, shared by all the handles of
+// a file, so the parity of a group is computed from the chunks it protects.
+type fileLocks struct {
+	mutex sync.Mutex
+	files map[string]*fileLock
+}
+
+// fileLock is the lock of a file, with its number of users.
+type fileLock struct {
+	mutex sync.Mutex
+	users int
+}
+
+func newFileLocks() *fileLocks {
+	return &fileLocks{
+		files: make(map[string]*fileLock),
+	}
+}
+
+// lock locks the file at the path, until the returned function is called.
+func (l *fileLocks) lock(path string) (unlock func()) {
+	l.mutex.Lock()
+	fl, ok := l.files[path]
+	if !ok {
+		fl = &fileLock{}
+		l.files[path] = fl
+	}
+	fl.users++
+	l.mutex.Unlock()
+
+	fl.mutex.Lock()
+	return func() {
+		fl.mutex.Unlock()
+
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		if fl.users--; fl.users == 0 {
+			delete(l.files, path)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package erasure
+
+import (
+	"fmt"
+
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+var (
+	// ErrNotEnoughShards happens when there are not enough shards to access or
+	// rebuild an element.
+	ErrNotEnoughShards = fmt.Errorf("%w: not enough shards", storage.ErrStorage)
+	// ErrCorruptedShards happens when the shards of a group are inconsistent
+	// and the corrupted one cannot be found.
+	ErrCorruptedShards = fmt.Errorf("%w: corrupted shards", storage.ErrStorage)
+)
+
+// onPresent runs the function on every shard present, in order, a missing
+// shard being a nil one. It returns the results, with zero values for the
+// missing shards, and stops on the first error.
+func onPresent[S, T any](shards []S, fn func(s S) (T, error)) ([]T, error) {
+	results := make([]T, len(shards))
+	for i, s := range shards {
+		if isMissing(s) {
+			continue
+		}
+
+		res, err := fn(s)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = res
+	}
+
+	return results, nil
+}
+
+// fromEnough runs the function on every shard present, keeping the results of
+// the successful ones and zero values for the others. It fails if less than
+// the minimum succeeded, with the error of the first shard if any.
+func fromEnough[S, T any](shards []S, minimum int, fn func(s S) (T, error)) ([]T, error) {
+	results := make([]T, len(shards))
+
+	var firstErr error
+	found := 0
+	for i, s := range shards {
+		if isMissing(s) {
+			continue
+		}
+
+		res, err := fn(s)
+		if err == nil {
+			results[i] = res
+			found++
+		} else if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	switch {
+	case found >= minimum:
+		return results, nil
+	case firstErr != nil:
+		return nil, firstErr
+	default:
+		return nil, ErrNotEnoughShards
+	}
+}
+
+// firstHealthy runs the function on the shards present, in order, until it
+// succeeds. If all shards failed, the error is the one of the first shard.
+func firstHealthy[S, T any](shards []S, fn func(s S) (T, error)) (T, error) {
+	var firstErr error
+	for _, s := range shards {
+		if isMissing(s) {
+			continue
+		}
+
+		res, err := fn(s)
+		if err == nil {
+			return res, nil
+		} else if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	var zero T
+	if firstErr == nil {
+		return zero, ErrNotEnoughShards
+	}
+	return zero, firstErr
+}
+
+// enough returns the elements with the given name from the maps, with nil
+// for the maps that does not have it, and false if less than the minimum
+// have it.
+func enough[T any](maps []map[string]T, name string, minimum int) ([]T, bool) {
+	elems := make([]T, len(maps))
+	found := 0
+	for i, m := range maps {
+		if e, ok := m[name]; ok {
+			elems[i] = e
+			found++
+		}
+	}
+
+	return elems, found >= minimum
+}
+
+func isMissing[T any](v T) bool {
+	return any(v) == nil
+}
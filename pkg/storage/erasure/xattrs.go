@@ -0,0 +1,71 @@
+package erasure
+
+import (
+	"context"
+
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+// GetXattr returns the value of an extended attribute, from the first shard
+// that has it.
+func (f *file) GetXattr(ctx context.Context, name string) ([]byte, error) {
+	return firstHealthy(f.shards, func(s storage.File) ([]byte, error) {
+		return s.GetXattr(ctx, name)
+	})
+}
+
+// ListXattrs returns the names of the extended attributes, from the first
+// healthy shard.
+func (f *file) ListXattrs(ctx context.Context) ([]string, error) {
+	return firstHealthy(f.shards, func(s storage.File) ([]string, error) {
+		return s.ListXattrs(ctx)
+	})
+}
+
+// SetXattr creates or replaces an extended attribute, on the present shards.
+func (f *file) SetXattr(ctx context.Context, name string, value []byte) error {
+	_, err := onPresent(f.shards, func(s storage.File) (struct{}, error) {
+		return struct{}{}, s.SetXattr(ctx, name, value)
+	})
+	return err
+}
+
+// RemoveXattr removes an extended attribute, from the present shards.
+func (f *file) RemoveXattr(ctx context.Context, name string) error {
+	_, err := onPresent(f.shards, func(s storage.File) (struct{}, error) {
+		return struct{}{}, s.RemoveXattr(ctx, name)
+	})
+	return err
+}
+
+// GetXattr returns the value of an extended attribute, from the first shard
+// that has it.
+func (d *directory) GetXattr(ctx context.Context, name string) ([]byte, error) {
+	return firstHealthy(d.shards, func(s storage.Directory) ([]byte, error) {
+		return s.GetXattr(ctx, name)
+	})
+}
+
+// ListXattrs returns the names of the extended attributes, from the first
+// healthy shard.
+func (d *directory) ListXattrs(ctx context.Context) ([]string, error) {
+	return firstHealthy(d.shards, func(s storage.Directory) ([]string, error) {
+		return s.ListXattrs(ctx)
+	})
+}
+
+// SetXattr creates or replaces an extended attribute, on the present shards.
+func (d *directory) SetXattr(ctx context.Context, name string, value []byte) error {
+	_, err := onPresent(d.shards, func(s storage.Directory) (struct{}, error) {
+		return struct{}{}, s.SetXattr(ctx, name, value)
+	})
+	return err
+}
+
+// RemoveXattr removes an extended attribute, from the present shards.
+func (d *directory) RemoveXattr(ctx context.Context, name string) error {
+	_, err := onPresent(d.shards, func(s storage.Directory) (struct{}, error) {
+		return struct{}{}, s.RemoveXattr(ctx, name)
+	})
+	return err
+}
@@ -32,4 +32,16 @@ var (
 	ErrChunkNotFound = fmt.Errorf("%w: chunk not found", ErrStorage)
 	// ErrChunkAlreadyExists happens when the chunk already exists and cannot be imported.
 	ErrChunkAlreadyExists = fmt.Errorf("%w: chunk already exists", ErrStorage)
+	// ErrReadOnly happens when trying to modify a read-only storage.
+	ErrReadOnly = fmt.Errorf("%w: read-only storage", ErrStorage)
+	// ErrChunkFetchFailed happens when a missing chunk cannot be fetched.
+	ErrChunkFetchFailed = fmt.Errorf("%w: chunk fetch failed", ErrStorage)
+	// ErrChunkFetchTimeout happens when a missing chunk has not been imported in time.
+	ErrChunkFetchTimeout = fmt.Errorf("%w: chunk fetch timeout", ErrStorage)
+	// ErrChunkCorrupted happens when the chunk data doesn't match its checksum.
+	ErrChunkCorrupted = fmt.Errorf("%w: chunk corrupted", ErrStorage)
+	// ErrXattrNotFound happens when the requested extended attribute doesn't exist.
+	ErrXattrNotFound = fmt.Errorf("%w: extended attribute not found", ErrStorage)
+	// ErrXattrNotSupported happens when the extended attributes cannot be stored.
+	ErrXattrNotSupported = fmt.Errorf("%w: extended attributes not supported", ErrStorage)
 )
@@ -0,0 +1,164 @@
+package ftp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/textproto"
+	"path"
+	"sync"
+
+	"github.com/jlaffaye/ftp"
+)
+
+// client wraps a FTP connection that is shared between every directory and
+// file of the same storage. As FTP doesn't allow concurrent commands on the
+// same connection, every command is protected by a mutex.
+type client struct {
+	conn  *ftp.ServerConn
+	mutex sync.Mutex
+}
+
+func newClient(conn *ftp.ServerConn) *client {
+	return &client{
+		conn: conn,
+	}
+}
+
+// convertError turns FTP 'file unavailable' errors into fs.ErrNotExist, in
+// order to be checked the same way than local file system errors.
+func convertError(err error) error {
+	var tpErr *textproto.Error
+	if errors.As(err, &tpErr) && tpErr.Code == ftp.StatusFileUnavailable {
+		return fmt.Errorf("%w: %w", fs.ErrNotExist, err)
+	}
+
+	return err
+}
+
+func (c *client) readFile(p string) ([]byte, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// Retrieve file
+	r, err := c.conn.Retr(p)
+	if err != nil {
+		return nil, convertError(err)
+	}
+
+	// Read content
+	data, err := io.ReadAll(r)
+	if err != nil {
+		_ = r.Close()
+		return nil, err
+	}
+
+	return data, r.Close()
+}
+
+func (c *client) writeFile(p string, data []byte) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return convertError(c.conn.Stor(p, bytes.NewReader(data)))
+}
+
+func (c *client) fileSize(p string) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	size, err := c.conn.FileSize(p)
+	return int(size), convertError(err)
+}
+
+func (c *client) list(p string) ([]*ftp.Entry, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entries, err := c.conn.List(p)
+	if err != nil {
+		return nil, convertError(err)
+	}
+
+	// Remove current and parent directories
+	filtered := make([]*ftp.Entry, 0, len(entries))
+	for _, e := range entries {
+		if e.Name == "." || e.Name == ".." {
+			continue
+		}
+		filtered = append(filtered, e)
+	}
+
+	return filtered, nil
+}
+
+// stat returns the entry corresponding to the path, or an error wrapping
+// fs.ErrNotExist if it doesn't exist.
+func (c *client) stat(p string) (*ftp.Entry, error) {
+	entries, err := c.list(path.Dir(p))
+	if err != nil {
+		return nil, err
+	}
+
+	name := path.Base(p)
+	for _, e := range entries {
+		if e.Name == name {
+			return e, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %q", fs.ErrNotExist, p)
+}
+
+func (c *client) makeDir(p string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return convertError(c.conn.MakeDir(p))
+}
+
+func (c *client) removeDir(p string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return convertError(c.conn.RemoveDir(p))
+}
+
+func (c *client) removeFile(p string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return convertError(c.conn.Delete(p))
+}
+
+// removeAll removes a directory and all its content.
+func (c *client) removeAll(p string) error {
+	entries, err := c.list(p)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		childPath := path.Join(p, e.Name)
+		if e.Type == ftp.EntryTypeFolder {
+			err = c.removeAll(childPath)
+		} else {
+			err = c.removeFile(childPath)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.removeDir(p)
+}
+
+func (c *client) rename(from, to string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return convertError(c.conn.Rename(from, to))
+}
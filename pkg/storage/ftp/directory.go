@@ -0,0 +1,363 @@
+package ftp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+
+	"github.com/jlaffaye/ftp"
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+// attributesFileName is the name of the file holding the attributes of a
+// directory, inside of it.
+const attributesFileName = ".attributes"
+
+var _ storage.Directory = (*directory)(nil)
+
+type directory struct {
+	client *client
+	path   string
+}
+
+// NewDirectory creates a new directory representation on a FTP server.
+// The connection should already be logged in and will be shared by every
+// child directory and file.
+func NewDirectory(conn *ftp.ServerConn, path string) storage.Directory {
+	return newDirectory(newClient(conn), path)
+}
+
+func newDirectory(c *client, path string) *directory {
+	return &directory{
+		client: c,
+		path:   path,
+	}
+}
+
+func (d *directory) getChildPath(name string) string {
+	return path.Join(d.path, name)
+}
+
+func (d *directory) getChildMetadataPath(name string) string {
+	return path.Join(d.getChildPath(name), metadataFileName)
+}
+
+func (d *directory) ensureChildDoesNotExists(name string) error {
+	entry, err := d.client.stat(d.getChildPath(name))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	// Chunks and metadata are always in a folder
+	if entry.Type != ftp.EntryTypeFolder {
+		return fmt.Errorf("%w: %q is not a folder", storage.ErrStorage, name)
+	}
+
+	// Check if there is a metadata file
+	if _, err := d.client.fileSize(d.getChildMetadataPath(name)); err == nil {
+		return fmt.Errorf("%w: %q", storage.ErrFileAlreadyExists, name)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return fmt.Errorf("%w: %q", storage.ErrDirectoryAlreadyExists, name)
+}
+
+// CreateDirectory creates a directory.
+func (d *directory) CreateDirectory(_ context.Context, name string) (storage.Directory, error) {
+	// Check if a file or a directory exists
+	if err := d.ensureChildDoesNotExists(name); err != nil {
+		return nil, err
+	}
+
+	// Create directory and return representation
+	path := d.getChildPath(name)
+	return newDirectory(d.client, path), d.client.makeDir(path)
+}
+
+// GetDirectory returns a child directory of the directory.
+func (d *directory) GetDirectory(_ context.Context, name string) (storage.Directory, error) {
+	// Check if directory exists
+	err := d.ensureChildDoesNotExists(name)
+	switch {
+	case err == nil:
+		return nil, fmt.Errorf("%w: %q", storage.ErrDirectoryNotFound, name)
+	case errors.Is(err, storage.ErrFileAlreadyExists):
+		return nil, fmt.Errorf("%w: %q", storage.ErrIsFile, name)
+	case !errors.Is(err, storage.ErrDirectoryAlreadyExists):
+		return nil, err
+	}
+
+	// Return representation
+	return newDirectory(d.client, d.getChildPath(name)), nil
+}
+
+// GetInfo returns the directory information.
+func (d *directory) GetInfo(_ context.Context) (info.Directory, error) {
+	// Download attributes, if they have been set
+	data, err := d.client.readFile(path.Join(d.path, attributesFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return info.Directory{}, nil
+	} else if err != nil {
+		return info.Directory{}, err
+	}
+
+	var dirInfo info.Directory
+	if err := json.Unmarshal(data, &dirInfo.Attributes); err != nil {
+		return info.Directory{}, err
+	}
+
+	return dirInfo, nil
+}
+
+// SetAttributes sets the directory attributes.
+func (d *directory) SetAttributes(_ context.Context, attr info.Attributes) error {
+	data, err := json.Marshal(attr)
+	if err != nil {
+		return err
+	}
+
+	return d.client.writeFile(path.Join(d.path, attributesFileName), data)
+}
+
+// CreateFile creates a file.
+func (d *directory) CreateFile(_ context.Context, name string, info info.File) (storage.File, error) {
+	path := d.getChildPath(name)
+
+	// Check if there is a file with this name
+	if err := d.ensureChildDoesNotExists(name); err != nil {
+		return nil, err
+	}
+
+	// Create file representation
+	f, err := newFile(d.client, path, info)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create directory representing the file
+	if err := d.client.makeDir(path); err != nil {
+		return nil, err
+	}
+
+	return f, f.saveInfo(info)
+}
+
+// GetFile returns a child file.
+func (d *directory) GetFile(_ context.Context, name string) (storage.File, error) {
+	// Check if file exists
+	err := d.ensureChildDoesNotExists(name)
+	switch {
+	case err == nil:
+		return nil, fmt.Errorf("%w: %q", storage.ErrFileNotFound, name)
+	case errors.Is(err, storage.ErrDirectoryAlreadyExists):
+		return nil, fmt.Errorf("%w: %q", storage.ErrIsDirectory, name)
+	case !errors.Is(err, storage.ErrFileAlreadyExists):
+		return nil, err
+	}
+
+	// Read metadata
+	path := d.getChildPath(name)
+	info, err := readMetadata(d.client, path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create file representation
+	return newFile(d.client, path, info)
+}
+
+// listChildren returns the child folders of the directory, sorted between
+// the ones representing files and the ones representing directories.
+func (d *directory) listChildren() (files, directories []string, err error) {
+	entries, err := d.client.list(d.path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.Type != ftp.EntryTypeFolder {
+			continue
+		}
+
+		// Check if there is metadata
+		_, err := d.client.fileSize(d.getChildMetadataPath(entry.Name))
+		switch {
+		case err == nil:
+			files = append(files, entry.Name)
+		case errors.Is(err, fs.ErrNotExist):
+			directories = append(directories, entry.Name)
+		default:
+			return nil, nil, err
+		}
+	}
+
+	return files, directories, nil
+}
+
+// ListFiles returns a map of files.
+func (d *directory) ListFiles(_ context.Context) (map[string]storage.File, error) {
+	names, _, err := d.listChildren()
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]storage.File, len(names))
+	for _, name := range names {
+		// Read metadata
+		path := d.getChildPath(name)
+		info, err := readMetadata(d.client, path)
+		if err != nil {
+			return nil, err
+		}
+
+		// Create file representation
+		f, err := newFile(d.client, path, info)
+		if err != nil {
+			return nil, err
+		}
+
+		files[name] = f
+	}
+
+	return files, nil
+}
+
+// RemoveDirectory removes a directory.
+func (d *directory) RemoveDirectory(_ context.Context, name string) error {
+	// Check if directory exists
+	err := d.ensureChildDoesNotExists(name)
+	switch {
+	case err == nil:
+		return fmt.Errorf("%w: %q", storage.ErrDirectoryNotFound, name)
+	case errors.Is(err, storage.ErrFileAlreadyExists):
+		return fmt.Errorf("%w: %q", storage.ErrIsFile, name)
+	case !errors.Is(err, storage.ErrDirectoryAlreadyExists):
+		return err
+	}
+
+	// Remove the attributes, if this is the only thing in the directory
+	p := d.getChildPath(name)
+	entries, err := d.client.list(p)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 1 && entries[0].Name == attributesFileName {
+		if err := d.client.removeFile(path.Join(p, attributesFileName)); err != nil {
+			return err
+		}
+	}
+
+	// Remove directory
+	return d.client.removeDir(p)
+}
+
+// ListDirectories returns a map of directories.
+func (d *directory) ListDirectories(_ context.Context) (map[string]storage.Directory, error) {
+	_, names, err := d.listChildren()
+	if err != nil {
+		return nil, err
+	}
+
+	directories := make(map[string]storage.Directory, len(names))
+	for _, name := range names {
+		directories[name] = newDirectory(d.client, d.getChildPath(name))
+	}
+
+	return directories, nil
+}
+
+// RemoveFile removes a file.
+func (d *directory) RemoveFile(_ context.Context, name string) error {
+	// Check if file or directory exists
+	err := d.ensureChildDoesNotExists(name)
+	switch {
+	case err == nil:
+		return fmt.Errorf("%w: %q", storage.ErrFileNotFound, name)
+	case errors.Is(err, storage.ErrDirectoryAlreadyExists):
+		return fmt.Errorf("%w: %q", storage.ErrIsDirectory, name)
+	case !errors.Is(err, storage.ErrFileAlreadyExists):
+		return err
+	}
+
+	return d.client.removeAll(d.getChildPath(name))
+}
+
+func (d *directory) prepareRenameDestination(newParent storage.Directory, newName string, noReplace bool) error {
+	// Check if there is a file or a directory with the new name
+	newPath := newParent.(*directory).getChildPath(newName)
+	err := newParent.(*directory).ensureChildDoesNotExists(newName)
+	switch {
+	case err == nil:
+		// Nothing to do
+		return nil
+	case !noReplace && (errors.Is(err, storage.ErrDirectoryAlreadyExists) ||
+		errors.Is(err, storage.ErrFileAlreadyExists)):
+		return d.client.removeAll(newPath)
+	default:
+		return err
+	}
+}
+
+// RenameFile renames a file.
+func (d *directory) RenameFile(
+	_ context.Context,
+	name string,
+	newParent storage.Directory,
+	newName string,
+	noReplace bool,
+) error {
+	// Check if file exists
+	err := d.ensureChildDoesNotExists(name)
+	switch {
+	case err == nil:
+		return fmt.Errorf("%w: %q", storage.ErrFileNotFound, name)
+	case errors.Is(err, storage.ErrDirectoryAlreadyExists):
+		return fmt.Errorf("%w: %q", storage.ErrIsDirectory, name)
+	case !errors.Is(err, storage.ErrFileAlreadyExists):
+		return err
+	}
+
+	// Check the destination
+	if err := d.prepareRenameDestination(newParent, newName, noReplace); err != nil {
+		return err
+	}
+
+	// Move the file
+	return d.client.rename(d.getChildPath(name), newParent.(*directory).getChildPath(newName))
+}
+
+// RenameDirectory renames a directory.
+func (d *directory) RenameDirectory(
+	_ context.Context,
+	name string,
+	newParent storage.Directory,
+	newName string,
+	noReplace bool,
+) error {
+	// Check if directory exists
+	err := d.ensureChildDoesNotExists(name)
+	switch {
+	case err == nil:
+		return fmt.Errorf("%w: %q", storage.ErrDirectoryNotFound, name)
+	case errors.Is(err, storage.ErrFileAlreadyExists):
+		return fmt.Errorf("%w: %q", storage.ErrIsFile, name)
+	case !errors.Is(err, storage.ErrDirectoryAlreadyExists):
+		return err
+	}
+
+	// Check the destination
+	if err := d.prepareRenameDestination(newParent, newName, noReplace); err != nil {
+		return err
+	}
+
+	// Move the directory
+	return d.client.rename(d.getChildPath(name), newParent.(*directory).getChildPath(newName))
+}
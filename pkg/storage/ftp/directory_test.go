@@ -0,0 +1,52 @@
+package ftp
+
+import (
+	"testing"
+
+	"github.com/jlaffaye/ftp"
+	"github.com/lerenn/chonkfs/pkg/storage/layer"
+	"github.com/lerenn/chonkfs/pkg/storage/mem"
+	"github.com/lerenn/chonkfs/pkg/storage/test"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestDirectorySuite(t *testing.T) {
+	suite.Run(t, new(DirectorySuite))
+	suite.Run(t, new(DirectoryAsUnderlayerSuite))
+}
+
+type DirectorySuite struct {
+	test.DirectorySuite
+	Server *testServer
+	Conn   *ftp.ServerConn
+}
+
+func (suite *DirectorySuite) SetupTest() {
+	suite.Server = newTestServer(suite.T())
+	suite.Conn = suite.Server.Dial(suite.T())
+	suite.Directory = NewDirectory(suite.Conn, "/")
+}
+
+func (suite *DirectorySuite) TearDownTest() {
+	suite.Require().NoError(suite.Conn.Quit())
+	suite.Server.Close()
+}
+
+type DirectoryAsUnderlayerSuite struct {
+	layer.DirectorySuite
+	Server *testServer
+	Conn   *ftp.ServerConn
+}
+
+func (suite *DirectoryAsUnderlayerSuite) SetupTest() {
+	suite.Server = newTestServer(suite.T())
+	suite.Conn = suite.Server.Dial(suite.T())
+	suite.Upperlayer = mem.NewDirectory()
+	suite.Underlayer = NewDirectory(suite.Conn, "/")
+	suite.Directory, _ = layer.NewDirectory(suite.Upperlayer, suite.Underlayer)
+}
+
+func (suite *DirectoryAsUnderlayerSuite) TearDownTest() {
+	suite.Require().NoError(suite.Conn.Quit())
+	suite.Server.Close()
+}
@@ -0,0 +1,52 @@
+package ftp
+
+import (
+	"testing"
+
+	"github.com/jlaffaye/ftp"
+	"github.com/lerenn/chonkfs/pkg/storage/layer"
+	"github.com/lerenn/chonkfs/pkg/storage/mem"
+	"github.com/lerenn/chonkfs/pkg/storage/test"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestFileSuite(t *testing.T) {
+	suite.Run(t, new(FileSuite))
+	suite.Run(t, new(FileAsUnderlayerSuite))
+}
+
+type FileSuite struct {
+	test.FileSuite
+	Server *testServer
+	Conn   *ftp.ServerConn
+}
+
+func (suite *FileSuite) SetupTest() {
+	suite.Server = newTestServer(suite.T())
+	suite.Conn = suite.Server.Dial(suite.T())
+	suite.Directory = NewDirectory(suite.Conn, "/")
+}
+
+func (suite *FileSuite) TearDownTest() {
+	suite.Require().NoError(suite.Conn.Quit())
+	suite.Server.Close()
+}
+
+type FileAsUnderlayerSuite struct {
+	layer.FileSuite
+	Server *testServer
+	Conn   *ftp.ServerConn
+}
+
+func (suite *FileAsUnderlayerSuite) SetupTest() {
+	suite.Server = newTestServer(suite.T())
+	suite.Conn = suite.Server.Dial(suite.T())
+	suite.Upperlayer = mem.NewDirectory()
+	suite.Underlayer = NewDirectory(suite.Conn, "/")
+	suite.Directory, _ = layer.NewDirectory(suite.Upperlayer, suite.Underlayer)
+}
+
+func (suite *FileAsUnderlayerSuite) TearDownTest() {
+	suite.Require().NoError(suite.Conn.Quit())
+	suite.Server.Close()
+}
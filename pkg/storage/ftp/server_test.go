@@ -0,0 +1,246 @@
+package ftp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jlaffaye/ftp"
+)
+
+// testServer is a minimal in-process FTP server, backed by a local directory,
+// that implements just enough of the protocol for the storage tests.
+type testServer struct {
+	listener net.Listener
+	root     string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &testServer{
+		listener: l,
+		root:     t.TempDir(),
+	}
+	go s.serve()
+
+	return s
+}
+
+// Dial creates a new logged in connection to the server.
+func (s *testServer) Dial(t *testing.T) *ftp.ServerConn {
+	t.Helper()
+
+	conn, err := ftp.Dial(s.listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := conn.Login("user", "password"); err != nil {
+		t.Fatal(err)
+	}
+
+	return conn
+}
+
+func (s *testServer) Close() {
+	_ = s.listener.Close()
+}
+
+func (s *testServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go s.handle(conn)
+	}
+}
+
+type testSession struct {
+	server     *testServer
+	ctrl       net.Conn
+	data       net.Listener
+	renameFrom string
+}
+
+func (s *testServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	session := &testSession{server: s, ctrl: conn}
+	session.reply(220, "ready")
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		cmd, arg, _ := strings.Cut(scanner.Text(), " ")
+		if !session.execute(strings.ToUpper(cmd), arg) {
+			return
+		}
+	}
+}
+
+func (ss *testSession) reply(code int, msg string) {
+	fmt.Fprintf(ss.ctrl, "%d %s\r\n", code, msg)
+}
+
+func (ss *testSession) localPath(p string) string {
+	return filepath.Join(ss.server.root, filepath.FromSlash(filepath.Clean("/"+p)))
+}
+
+//nolint:cyclop
+func (ss *testSession) execute(cmd, arg string) bool {
+	switch cmd {
+	case "USER":
+		ss.reply(331, "password required")
+	case "PASS":
+		ss.reply(230, "logged in")
+	case "FEAT":
+		fmt.Fprint(ss.ctrl, "211-Features:\r\n MLST type*;size*;\r\n211 End\r\n")
+	case "TYPE":
+		ss.reply(200, "type set")
+	case "EPSV":
+		ss.passive()
+	case "MLSD":
+		ss.list(arg)
+	case "SIZE":
+		ss.size(arg)
+	case "RETR":
+		ss.retrieve(arg)
+	case "STOR":
+		ss.store(arg)
+	case "MKD":
+		ss.result(os.Mkdir(ss.localPath(arg), 0755), 257)
+	case "RMD", "DELE":
+		ss.result(os.Remove(ss.localPath(arg)), 250)
+	case "RNFR":
+		ss.renameFrom = arg
+		ss.reply(350, "ready for destination")
+	case "RNTO":
+		ss.result(os.Rename(ss.localPath(ss.renameFrom), ss.localPath(arg)), 250)
+	case "QUIT":
+		ss.reply(221, "bye")
+		return false
+	default:
+		ss.reply(502, "not implemented")
+	}
+
+	return true
+}
+
+func (ss *testSession) result(err error, code int) {
+	if err != nil {
+		ss.reply(550, err.Error())
+		return
+	}
+	ss.reply(code, "ok")
+}
+
+func (ss *testSession) passive() {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		ss.reply(425, err.Error())
+		return
+	}
+
+	ss.data = l
+	ss.reply(229, fmt.Sprintf("Entering Extended Passive Mode (|||%d|)", l.Addr().(*net.TCPAddr).Port))
+}
+
+// transfer accepts the data connection and executes the transfer on it.
+func (ss *testSession) transfer(fn func(conn net.Conn) error) {
+	if ss.data == nil {
+		ss.reply(425, "no data connection")
+		return
+	}
+
+	conn, err := ss.data.Accept()
+	_ = ss.data.Close()
+	ss.data = nil
+	if err != nil {
+		ss.reply(425, err.Error())
+		return
+	}
+
+	ss.reply(150, "opening data connection")
+	err = fn(conn)
+	_ = conn.Close()
+	ss.result(err, 226)
+}
+
+func (ss *testSession) abortTransfer(err error) {
+	if ss.data != nil {
+		_ = ss.data.Close()
+		ss.data = nil
+	}
+	ss.reply(550, err.Error())
+}
+
+func (ss *testSession) list(arg string) {
+	entries, err := os.ReadDir(ss.localPath(arg))
+	if err != nil {
+		ss.abortTransfer(err)
+		return
+	}
+
+	ss.transfer(func(conn net.Conn) error {
+		for _, e := range entries {
+			info, err := e.Info()
+			if err != nil {
+				return err
+			}
+
+			t := "file"
+			if e.IsDir() {
+				t = "dir"
+			}
+			fmt.Fprintf(conn, "type=%s;size=%d; %s\r\n", t, info.Size(), e.Name())
+		}
+		return nil
+	})
+}
+
+func (ss *testSession) size(arg string) {
+	info, err := os.Stat(ss.localPath(arg))
+	switch {
+	case err != nil:
+		ss.reply(550, err.Error())
+	case info.IsDir():
+		ss.reply(550, "is a directory")
+	default:
+		ss.reply(213, fmt.Sprintf("%d", info.Size()))
+	}
+}
+
+func (ss *testSession) retrieve(arg string) {
+	data, err := os.ReadFile(ss.localPath(arg))
+	if err != nil {
+		ss.abortTransfer(err)
+		return
+	}
+
+	ss.transfer(func(conn net.Conn) error {
+		_, err := conn.Write(data)
+		return err
+	})
+}
+
+func (ss *testSession) store(arg string) {
+	ss.transfer(func(conn net.Conn) error {
+		data, err := io.ReadAll(conn)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(ss.localPath(arg), data, 0644)
+	})
+}
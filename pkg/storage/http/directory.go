@@ -0,0 +1,164 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+var _ storage.Directory = (*directory)(nil)
+
+// directory is a read-only directory whose tree is known in advance, as
+// HTTP doesn't offer a way to list resources.
+type directory struct {
+	directories map[string]*directory
+	files       map[string]*file
+}
+
+// NewDirectory creates a read-only directory from a list of URLs, indexed by
+// the path of the file they represent (i.e. "dir/subdir/file"). Each file is
+// split in chunks of the given size, and each chunk is fetched with a range
+// request when read.
+func NewDirectory(c *http.Client, chunkSize int, urls map[string]string) (storage.Directory, error) {
+	if chunkSize <= 0 {
+		return nil, fmt.Errorf("%w: chunk size must be greater than 0", storage.ErrInvalidChunkSize)
+	}
+
+	root := newDirectory()
+	for p, url := range urls {
+		if err := root.addFile(c, chunkSize, strings.Split(strings.Trim(p, "/"), "/"), url); err != nil {
+			return nil, err
+		}
+	}
+
+	return root, nil
+}
+
+func newDirectory() *directory {
+	return &directory{
+		directories: make(map[string]*directory),
+		files:       make(map[string]*file),
+	}
+}
+
+func (d *directory) addFile(c *http.Client, chunkSize int, elems []string, url string) error {
+	name := elems[0]
+
+	// Add the file if this is the last element
+	if len(elems) == 1 {
+		if _, ok := d.directories[name]; ok {
+			return fmt.Errorf("%w: %q", storage.ErrDirectoryAlreadyExists, name)
+		} else if _, ok := d.files[name]; ok {
+			return fmt.Errorf("%w: %q", storage.ErrFileAlreadyExists, name)
+		}
+
+		d.files[name] = newFile(c, chunkSize, url)
+		return nil
+	}
+
+	// Otherwise, get or create the directory
+	if _, ok := d.files[name]; ok {
+		return fmt.Errorf("%w: %q", storage.ErrFileAlreadyExists, name)
+	}
+	child, ok := d.directories[name]
+	if !ok {
+		child = newDirectory()
+		d.directories[name] = child
+	}
+
+	return child.addFile(c, chunkSize, elems[1:], url)
+}
+
+// CreateDirectory creates a directory.
+func (d *directory) CreateDirectory(_ context.Context, _ string) (storage.Directory, error) {
+	return nil, storage.ErrReadOnly
+}
+
+// GetDirectory returns a child directory of the directory.
+func (d *directory) GetDirectory(_ context.Context, name string) (storage.Directory, error) {
+	// Check if there is a file with this name
+	if _, ok := d.files[name]; ok {
+		return nil, fmt.Errorf("%w: %q", storage.ErrIsFile, name)
+	}
+
+	// Check if there is a directory with this name
+	nd, ok := d.directories[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", storage.ErrDirectoryNotFound, name)
+	}
+
+	return nd, nil
+}
+
+// GetInfo returns the directory information.
+func (d *directory) GetInfo(_ context.Context) (info.Directory, error) {
+	return info.Directory{}, nil
+}
+
+// SetAttributes sets the directory attributes.
+func (d *directory) SetAttributes(_ context.Context, _ info.Attributes) error {
+	return storage.ErrReadOnly
+}
+
+// CreateFile creates a file.
+func (d *directory) CreateFile(_ context.Context, _ string, _ info.File) (storage.File, error) {
+	return nil, storage.ErrReadOnly
+}
+
+// GetFile returns a child file.
+func (d *directory) GetFile(_ context.Context, name string) (storage.File, error) {
+	// Check if there is a directory with this name
+	if _, ok := d.directories[name]; ok {
+		return nil, fmt.Errorf("%w: %q", storage.ErrIsDirectory, name)
+	}
+
+	// Check if there is a file with this name
+	f, ok := d.files[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", storage.ErrFileNotFound, name)
+	}
+
+	return f, nil
+}
+
+// ListFiles returns a map of files.
+func (d *directory) ListFiles(_ context.Context) (map[string]storage.File, error) {
+	files := make(map[string]storage.File, len(d.files))
+	for name, f := range d.files {
+		files[name] = f
+	}
+	return files, nil
+}
+
+// ListDirectories returns a map of directories.
+func (d *directory) ListDirectories(_ context.Context) (map[string]storage.Directory, error) {
+	directories := make(map[string]storage.Directory, len(d.directories))
+	for name, nd := range d.directories {
+		directories[name] = nd
+	}
+	return directories, nil
+}
+
+// RemoveDirectory removes a directory.
+func (d *directory) RemoveDirectory(_ context.Context, _ string) error {
+	return storage.ErrReadOnly
+}
+
+// RemoveFile removes a file.
+func (d *directory) RemoveFile(_ context.Context, _ string) error {
+	return storage.ErrReadOnly
+}
+
+// RenameFile renames a file.
+func (d *directory) RenameFile(_ context.Context, _ string, _ storage.Directory, _ string, _ bool) error {
+	return storage.ErrReadOnly
+}
+
+// RenameDirectory renames a directory.
+func (d *directory) RenameDirectory(_ context.Context, _ string, _ storage.Directory, _ string, _ bool) error {
+	return storage.ErrReadOnly
+}
@@ -0,0 +1,99 @@
+package http
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestDirectorySuite(t *testing.T) {
+	suite.Run(t, new(DirectorySuite))
+}
+
+type DirectorySuite struct {
+	suite.Suite
+	Server    *testServer
+	Directory storage.Directory
+}
+
+func (suite *DirectorySuite) SetupTest() {
+	suite.Server = newTestServer(suite.T(), map[string][]byte{
+		"/file":            []byte("Hello, World!"),
+		"/directory/child": []byte("Hello, Child!"),
+	})
+
+	var err error
+	suite.Directory, err = NewDirectory(suite.Server.Client(), 4, map[string]string{
+		"file":            suite.Server.URL + "/file",
+		"directory/child": suite.Server.URL + "/directory/child",
+	})
+	suite.Require().NoError(err)
+}
+
+func (suite *DirectorySuite) TestNewDirectoryWithConflictingPaths() {
+	_, err := NewDirectory(suite.Server.Client(), 4, map[string]string{
+		"file":       suite.Server.URL + "/file",
+		"file/child": suite.Server.URL + "/directory/child",
+	})
+	suite.Require().Error(err)
+}
+
+func (suite *DirectorySuite) TestGetFile() {
+	_, err := suite.Directory.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+}
+
+func (suite *DirectorySuite) TestGetFileWhenDoesNotExist() {
+	_, err := suite.Directory.GetFile(context.Background(), "unknown")
+	suite.Require().ErrorIs(err, storage.ErrFileNotFound)
+}
+
+func (suite *DirectorySuite) TestGetFileWhenIsDirectory() {
+	_, err := suite.Directory.GetFile(context.Background(), "directory")
+	suite.Require().ErrorIs(err, storage.ErrIsDirectory)
+}
+
+func (suite *DirectorySuite) TestGetDirectory() {
+	dir, err := suite.Directory.GetDirectory(context.Background(), "directory")
+	suite.Require().NoError(err)
+
+	_, err = dir.GetFile(context.Background(), "child")
+	suite.Require().NoError(err)
+}
+
+func (suite *DirectorySuite) TestGetDirectoryWhenIsFile() {
+	_, err := suite.Directory.GetDirectory(context.Background(), "file")
+	suite.Require().ErrorIs(err, storage.ErrIsFile)
+}
+
+func (suite *DirectorySuite) TestListFilesAndDirectories() {
+	files, err := suite.Directory.ListFiles(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Len(files, 1)
+	suite.Require().Contains(files, "file")
+
+	dirs, err := suite.Directory.ListDirectories(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Len(dirs, 1)
+	suite.Require().Contains(dirs, "directory")
+}
+
+func (suite *DirectorySuite) TestModificationsAreReadOnly() {
+	ctx := context.Background()
+
+	_, err := suite.Directory.CreateDirectory(ctx, "new")
+	suite.Require().ErrorIs(err, storage.ErrReadOnly)
+
+	_, err = suite.Directory.CreateFile(ctx, "new", info.File{ChunkSize: 4})
+	suite.Require().ErrorIs(err, storage.ErrReadOnly)
+
+	suite.Require().ErrorIs(suite.Directory.RemoveFile(ctx, "file"), storage.ErrReadOnly)
+	suite.Require().ErrorIs(suite.Directory.RemoveDirectory(ctx, "directory"), storage.ErrReadOnly)
+	suite.Require().ErrorIs(suite.Directory.RenameFile(ctx, "file", suite.Directory, "new", false), storage.ErrReadOnly)
+	suite.Require().ErrorIs(
+		suite.Directory.RenameDirectory(ctx, "directory", suite.Directory, "new", false),
+		storage.ErrReadOnly)
+}
@@ -0,0 +1,225 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+var (
+	// ErrUnexpectedStatus is returned when the server answers with an
+	// unexpected status code.
+	ErrUnexpectedStatus = fmt.Errorf("%w: unexpected status", storage.ErrStorage)
+	// ErrUnknownSize is returned when the server doesn't give the size of the
+	// file.
+	ErrUnknownSize = fmt.Errorf("%w: unknown size", storage.ErrStorage)
+	// ErrUnexpectedRange is returned when the server answers with a range that
+	// doesn't cover the requested one.
+	ErrUnexpectedRange = fmt.Errorf("%w: unexpected range", storage.ErrStorage)
+)
+
+var _ storage.File = (*file)(nil)
+
+type file struct {
+	client    *http.Client
+	url       string
+	chunkSize int
+
+	// info is retrieved only once, as the remote file isn't supposed to
+	// change during the lifetime of the storage.
+	info      *info.File
+	infoMutex sync.Mutex
+}
+
+func newFile(c *http.Client, chunkSize int, url string) *file {
+	return &file{
+		client:    c,
+		url:       url,
+		chunkSize: chunkSize,
+	}
+}
+
+func (f *file) do(req *http.Request, expected ...int) (*http.Response, error) {
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if slices.Contains(expected, resp.StatusCode) {
+		return resp, nil
+	}
+
+	_ = resp.Body.Close()
+	return nil, fmt.Errorf("%w: %s %q: %s", ErrUnexpectedStatus, req.Method, f.url, resp.Status)
+}
+
+func (f *file) fetchInfo(ctx context.Context) (info.File, error) {
+	// Get the size of the remote file
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, f.url, nil)
+	if err != nil {
+		return info.File{}, err
+	}
+	resp, err := f.do(req, http.StatusOK)
+	if err != nil {
+		return info.File{}, err
+	}
+	_ = resp.Body.Close()
+
+	// Check the size is known
+	size := int(resp.ContentLength)
+	if size < 0 {
+		return info.File{}, fmt.Errorf("%w: %q", ErrUnknownSize, f.url)
+	}
+
+	// Deduce the chunks from it
+	fileInfo := info.File{
+		Size:      size,
+		ChunkSize: f.chunkSize,
+	}
+	if size > 0 {
+		fileInfo.ChunksCount = (size-1)/f.chunkSize + 1
+		fileInfo.LastChunkSize = size - (fileInfo.ChunksCount-1)*f.chunkSize
+	}
+
+	return fileInfo, nil
+}
+
+// GetInfo returns the file info.
+func (f *file) GetInfo(ctx context.Context) (info.File, error) {
+	f.infoMutex.Lock()
+	defer f.infoMutex.Unlock()
+
+	if f.info != nil {
+		return *f.info, nil
+	}
+
+	fileInfo, err := f.fetchInfo(ctx)
+	if err != nil {
+		return info.File{}, err
+	}
+	f.info = &fileInfo
+
+	return fileInfo, nil
+}
+
+// SetAttributes sets the file attributes.
+func (f *file) SetAttributes(_ context.Context, _ info.Attributes) error {
+	return storage.ErrReadOnly
+}
+
+func (f *file) checkReadChunkParams(info info.File, index int, offset int) error {
+	// Check if chunk index is correct
+	if index < 0 || index >= info.ChunksCount {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkNb, index)
+	}
+
+	// Check if offset is correct
+	if offset < 0 || offset >= info.ChunkSize {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidOffset, offset)
+	}
+
+	// Check if this is the last chunk, that the offset is correct
+	if index == info.ChunksCount-1 && offset >= info.LastChunkSize {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidOffset, offset)
+	}
+
+	return nil
+}
+
+// ReadChunk reads a chunk of data.
+func (f *file) ReadChunk(ctx context.Context, index int, data []byte, offset int) (int, error) {
+	// Get info
+	info, err := f.GetInfo(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Check params
+	if err := f.checkReadChunkParams(info, index, offset); err != nil {
+		return 0, err
+	}
+
+	// Limit data to read to the chunk
+	chunkSize := info.ChunkSize
+	if index == info.ChunksCount-1 {
+		chunkSize = info.LastChunkSize
+	}
+	if len(data) > chunkSize-offset {
+		data = data[:chunkSize-offset]
+	}
+	if len(data) == 0 {
+		return 0, nil
+	}
+
+	// Request the range
+	start := index*info.ChunkSize + offset
+	end := start + len(data) - 1
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	resp, err := f.do(req, http.StatusPartialContent, http.StatusOK)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		// Check the server answered with the requested range
+		if err := f.checkContentRange(resp, start, end); err != nil {
+			return 0, err
+		}
+	case http.StatusOK:
+		// Skip the beginning if the server doesn't support ranges
+		if _, err := io.CopyN(io.Discard, resp.Body, int64(start)); err != nil {
+			return 0, err
+		}
+	}
+
+	// Read data
+	return io.ReadFull(resp.Body, data)
+}
+
+// checkContentRange checks that the range of a partial content starts at the
+// start and covers up to the end, included.
+func (f *file) checkContentRange(resp *http.Response, start, end int) error {
+	var first, last int
+	contentRange := resp.Header.Get("Content-Range")
+	if _, err := fmt.Sscanf(contentRange, "bytes %d-%d/", &first, &last); err != nil {
+		return fmt.Errorf("%w: %q for %q: %w", ErrUnexpectedRange, contentRange, f.url, err)
+	}
+
+	if first != start || last < end {
+		return fmt.Errorf("%w: %q for %q, expected bytes %d-%d", ErrUnexpectedRange, contentRange, f.url, start, end)
+	}
+
+	return nil
+}
+
+// WriteChunk writes a chunk of data.
+func (f *file) WriteChunk(_ context.Context, _ int, _ []byte, _ int) (int, error) {
+	return 0, storage.ErrReadOnly
+}
+
+// ImportChunk imports a chunk of data.
+func (f *file) ImportChunk(_ context.Context, _ int, _ []byte) error {
+	return storage.ErrReadOnly
+}
+
+// ResizeChunksNb resizes the number of chunks.
+func (f *file) ResizeChunksNb(_ context.Context, _ int) error {
+	return storage.ErrReadOnly
+}
+
+// ResizeLastChunk resizes the last chunk.
+func (f *file) ResizeLastChunk(_ context.Context, _ int) (int, error) {
+	return 0, storage.ErrReadOnly
+}
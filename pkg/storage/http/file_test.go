@@ -0,0 +1,171 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lerenn/chonkfs/pkg/storage"
+	"github.com/lerenn/chonkfs/pkg/storage/layer"
+	"github.com/lerenn/chonkfs/pkg/storage/mem"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestFileSuite(t *testing.T) {
+	suite.Run(t, new(FileSuite))
+}
+
+type FileSuite struct {
+	suite.Suite
+	Server    *testServer
+	Directory storage.Directory
+}
+
+func (suite *FileSuite) SetupTest() {
+	suite.Server = newTestServer(suite.T(), map[string][]byte{
+		"/file":  []byte("Hello, World!"),
+		"/empty": {},
+	})
+
+	var err error
+	suite.Directory, err = NewDirectory(suite.Server.Client(), 4, map[string]string{
+		"file":    suite.Server.URL + "/file",
+		"empty":   suite.Server.URL + "/empty",
+		"missing": suite.Server.URL + "/missing",
+	})
+	suite.Require().NoError(err)
+}
+
+func (suite *FileSuite) TestGetInfo() {
+	file, err := suite.Directory.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+
+	info, err := file.GetInfo(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal(13, info.Size)
+	suite.Require().Equal(4, info.ChunkSize)
+	suite.Require().Equal(4, info.ChunksCount)
+	suite.Require().Equal(1, info.LastChunkSize)
+}
+
+func (suite *FileSuite) TestGetInfoWhenEmpty() {
+	file, err := suite.Directory.GetFile(context.Background(), "empty")
+	suite.Require().NoError(err)
+
+	info, err := file.GetInfo(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal(0, info.Size)
+	suite.Require().Equal(0, info.ChunksCount)
+}
+
+func (suite *FileSuite) TestGetInfoWhenMissing() {
+	file, err := suite.Directory.GetFile(context.Background(), "missing")
+	suite.Require().NoError(err)
+
+	_, err = file.GetInfo(context.Background())
+	suite.Require().ErrorIs(err, ErrUnexpectedStatus)
+	suite.Require().ErrorIs(err, storage.ErrStorage)
+}
+
+func (suite *FileSuite) TestReadChunk() {
+	file, err := suite.Directory.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+
+	data := make([]byte, 4)
+	read, err := file.ReadChunk(context.Background(), 1, data, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal(4, read)
+	suite.Require().Equal("o, W", string(data))
+}
+
+func (suite *FileSuite) TestReadChunkWithOffset() {
+	file, err := suite.Directory.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+
+	data := make([]byte, 16)
+	read, err := file.ReadChunk(context.Background(), 2, data, 2)
+	suite.Require().NoError(err)
+	suite.Require().Equal(2, read)
+	suite.Require().Equal("ld", string(data[:read]))
+}
+
+func (suite *FileSuite) TestReadLastChunk() {
+	file, err := suite.Directory.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+
+	data := make([]byte, 4)
+	read, err := file.ReadChunk(context.Background(), 3, data, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal(1, read)
+	suite.Require().Equal("!", string(data[:read]))
+}
+
+func (suite *FileSuite) TestReadChunkWithInvalidParams() {
+	file, err := suite.Directory.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+
+	_, err = file.ReadChunk(context.Background(), 4, make([]byte, 4), 0)
+	suite.Require().ErrorIs(err, storage.ErrInvalidChunkNb)
+
+	_, err = file.ReadChunk(context.Background(), 3, make([]byte, 4), 1)
+	suite.Require().ErrorIs(err, storage.ErrInvalidOffset)
+}
+
+func (suite *FileSuite) TestReadChunkWithUnexpectedRange() {
+	// Create a server answering with the beginning of the file for any range
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "13")
+		if r.Method == http.MethodGet {
+			w.Header().Set("Content-Length", "4")
+			w.Header().Set("Content-Range", "bytes 0-3/13")
+			w.WriteHeader(http.StatusPartialContent)
+			_, _ = w.Write([]byte("Hell"))
+		}
+	}))
+	defer server.Close()
+	dir, err := NewDirectory(server.Client(), 4, map[string]string{"file": server.URL})
+	suite.Require().NoError(err)
+
+	// Check the chunk is not read from the wrong range
+	file, err := dir.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+	_, err = file.ReadChunk(context.Background(), 1, make([]byte, 4), 0)
+	suite.Require().ErrorIs(err, ErrUnexpectedRange)
+	suite.Require().ErrorIs(err, storage.ErrStorage)
+}
+
+func (suite *FileSuite) TestModificationsAreReadOnly() {
+	ctx := context.Background()
+	file, err := suite.Directory.GetFile(ctx, "file")
+	suite.Require().NoError(err)
+
+	_, err = file.WriteChunk(ctx, 0, []byte("data"), 0)
+	suite.Require().ErrorIs(err, storage.ErrReadOnly)
+
+	suite.Require().ErrorIs(file.ImportChunk(ctx, 0, []byte("data")), storage.ErrReadOnly)
+	suite.Require().ErrorIs(file.ResizeChunksNb(ctx, 1), storage.ErrReadOnly)
+
+	_, err = file.ResizeLastChunk(ctx, 1)
+	suite.Require().ErrorIs(err, storage.ErrReadOnly)
+}
+
+func (suite *FileSuite) TestReadChunkAsUnderlayer() {
+	dir, err := layer.NewDirectory(mem.NewDirectory(), suite.Directory)
+	suite.Require().NoError(err)
+
+	file, err := dir.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+
+	// Read twice the same chunk
+	for i := 0; i < 2; i++ {
+		data := make([]byte, 4)
+		read, err := file.ReadChunk(context.Background(), 1, data, 0)
+		suite.Require().NoError(err)
+		suite.Require().Equal(4, read)
+		suite.Require().Equal("o, W", string(data))
+	}
+
+	// Check that the chunk has been fetched only once
+	suite.Require().Equal(int32(1), suite.Server.gets.Load())
+}
@@ -0,0 +1,39 @@
+package http
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testServer is an in-process HTTP server that serves in-memory files and
+// supports range requests.
+type testServer struct {
+	*httptest.Server
+	files map[string][]byte
+	gets  atomic.Int32
+}
+
+func newTestServer(t *testing.T, files map[string][]byte) *testServer {
+	t.Helper()
+
+	s := &testServer{files: files}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := s.files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		if r.Method == http.MethodGet {
+			s.gets.Add(1)
+		}
+		http.ServeContent(w, r, r.URL.Path, time.Time{}, bytes.NewReader(data))
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
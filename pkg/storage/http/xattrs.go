@@ -0,0 +1,49 @@
+package http
+
+import (
+	"context"
+
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+// The extended attributes cannot be stored on a HTTP server.
+
+// GetXattr returns storage.ErrXattrNotSupported.
+func (f *file) GetXattr(_ context.Context, _ string) ([]byte, error) {
+	return nil, storage.ErrXattrNotSupported
+}
+
+// ListXattrs returns storage.ErrXattrNotSupported.
+func (f *file) ListXattrs(_ context.Context) ([]string, error) {
+	return nil, storage.ErrXattrNotSupported
+}
+
+// SetXattr returns storage.ErrXattrNotSupported.
+func (f *file) SetXattr(_ context.Context, _ string, _ []byte) error {
+	return storage.ErrXattrNotSupported
+}
+
+// RemoveXattr returns storage.ErrXattrNotSupported.
+func (f *file) RemoveXattr(_ context.Context, _ string) error {
+	return storage.ErrXattrNotSupported
+}
+
+// GetXattr returns storage.ErrXattrNotSupported.
+func (d *directory) GetXattr(_ context.Context, _ string) ([]byte, error) {
+	return nil, storage.ErrXattrNotSupported
+}
+
+// ListXattrs returns storage.ErrXattrNotSupported.
+func (d *directory) ListXattrs(_ context.Context) ([]string, error) {
+	return nil, storage.ErrXattrNotSupported
+}
+
+// SetXattr returns storage.ErrXattrNotSupported.
+func (d *directory) SetXattr(_ context.Context, _ string, _ []byte) error {
+	return storage.ErrXattrNotSupported
+}
+
+// RemoveXattr returns storage.ErrXattrNotSupported.
+func (d *directory) RemoveXattr(_ context.Context, _ string) error {
+	return storage.ErrXattrNotSupported
+}
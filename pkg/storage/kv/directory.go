@@ -0,0 +1,476 @@
+package kv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+	"go.etcd.io/bbolt"
+)
+
+var (
+	rootBucketName = []byte("root")
+	attributesKey  = []byte(".attributes")
+)
+
+var _ storage.Directory = (*directory)(nil)
+
+// directory is represented by a bucket in the database, containing a nested
+// bucket for each of its children.
+type directory struct {
+	db   *bbolt.DB
+	path []string
+}
+
+// NewDirectory creates a new directory representation on the database. Every
+// directory and file of the tree will be stored in this database.
+func NewDirectory(db *bbolt.DB) (storage.Directory, error) {
+	// Create root bucket
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(rootBucketName)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return newDirectory(db, nil), nil
+}
+
+func newDirectory(db *bbolt.DB, path []string) *directory {
+	return &directory{
+		db:   db,
+		path: path,
+	}
+}
+
+// getBucket returns the bucket corresponding to the path.
+func getBucket(tx *bbolt.Tx, path []string) *bbolt.Bucket {
+	b := tx.Bucket(rootBucketName)
+	for _, name := range path {
+		if b == nil {
+			return nil
+		}
+		b = b.Bucket([]byte(name))
+	}
+	return b
+}
+
+func (d *directory) getBucket(tx *bbolt.Tx) (*bbolt.Bucket, error) {
+	b := getBucket(tx, d.path)
+	if b == nil {
+		return nil, fmt.Errorf("%w: %q", storage.ErrDirectoryNotFound, d.path)
+	}
+	return b, nil
+}
+
+func (d *directory) getChildPath(name string) []string {
+	return append(slices.Clone(d.path), name)
+}
+
+func ensureChildDoesNotExists(b *bbolt.Bucket, name string) error {
+	child := b.Bucket([]byte(name))
+	if child == nil {
+		return nil
+	}
+
+	// Check if there is metadata
+	if child.Get(metadataKey) != nil {
+		return fmt.Errorf("%w: %q", storage.ErrFileAlreadyExists, name)
+	}
+
+	return fmt.Errorf("%w: %q", storage.ErrDirectoryAlreadyExists, name)
+}
+
+// CreateDirectory creates a directory.
+func (d *directory) CreateDirectory(_ context.Context, name string) (storage.Directory, error) {
+	err := d.db.Update(func(tx *bbolt.Tx) error {
+		b, err := d.getBucket(tx)
+		if err != nil {
+			return err
+		}
+
+		// Check if a file or a directory exists
+		if err := ensureChildDoesNotExists(b, name); err != nil {
+			return err
+		}
+
+		// Create directory
+		_, err = b.CreateBucket([]byte(name))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return newDirectory(d.db, d.getChildPath(name)), nil
+}
+
+// GetDirectory returns a child directory of the directory.
+func (d *directory) GetDirectory(_ context.Context, name string) (storage.Directory, error) {
+	err := d.db.View(func(tx *bbolt.Tx) error {
+		b, err := d.getBucket(tx)
+		if err != nil {
+			return err
+		}
+
+		// Check if directory exists
+		err = ensureChildDoesNotExists(b, name)
+		switch {
+		case err == nil:
+			return fmt.Errorf("%w: %q", storage.ErrDirectoryNotFound, name)
+		case errors.Is(err, storage.ErrFileAlreadyExists):
+			return fmt.Errorf("%w: %q", storage.ErrIsFile, name)
+		case !errors.Is(err, storage.ErrDirectoryAlreadyExists):
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return newDirectory(d.db, d.getChildPath(name)), nil
+}
+
+// GetInfo returns the directory information.
+func (d *directory) GetInfo(_ context.Context) (dirInfo info.Directory, err error) {
+	err = d.db.View(func(tx *bbolt.Tx) error {
+		b, err := d.getBucket(tx)
+		if err != nil {
+			return err
+		}
+
+		// Read attributes, if they have been set
+		data := b.Get(attributesKey)
+		if data == nil {
+			return nil
+		}
+		return json.Unmarshal(data, &dirInfo.Attributes)
+	})
+	return dirInfo, err
+}
+
+// SetAttributes sets the directory attributes.
+func (d *directory) SetAttributes(_ context.Context, attr info.Attributes) error {
+	data, err := json.Marshal(attr)
+	if err != nil {
+		return err
+	}
+
+	return d.db.Update(func(tx *bbolt.Tx) error {
+		b, err := d.getBucket(tx)
+		if err != nil {
+			return err
+		}
+
+		return b.Put(attributesKey, data)
+	})
+}
+
+// CreateFile creates a file.
+func (d *directory) CreateFile(_ context.Context, name string, info info.File) (storage.File, error) {
+	// Create file representation
+	f, err := newFile(d.db, d.getChildPath(name), info)
+	if err != nil {
+		return nil, err
+	}
+
+	err = d.db.Update(func(tx *bbolt.Tx) error {
+		b, err := d.getBucket(tx)
+		if err != nil {
+			return err
+		}
+
+		// Check if there is a file with this name
+		if err := ensureChildDoesNotExists(b, name); err != nil {
+			return err
+		}
+
+		// Create bucket representing the file
+		fb, err := b.CreateBucket([]byte(name))
+		if err != nil {
+			return err
+		}
+
+		return writeMetadata(fb, info)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// GetFile returns a child file.
+func (d *directory) GetFile(_ context.Context, name string) (storage.File, error) {
+	var fileInfo info.File
+	err := d.db.View(func(tx *bbolt.Tx) error {
+		b, err := d.getBucket(tx)
+		if err != nil {
+			return err
+		}
+
+		// Check if file exists
+		err = ensureChildDoesNotExists(b, name)
+		switch {
+		case err == nil:
+			return fmt.Errorf("%w: %q", storage.ErrFileNotFound, name)
+		case errors.Is(err, storage.ErrDirectoryAlreadyExists):
+			return fmt.Errorf("%w: %q", storage.ErrIsDirectory, name)
+		case !errors.Is(err, storage.ErrFileAlreadyExists):
+			return err
+		}
+
+		// Read metadata
+		fileInfo, err = readMetadata(b.Bucket([]byte(name)))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Create file representation
+	return newFile(d.db, d.getChildPath(name), fileInfo)
+}
+
+// listChildren returns the children of the directory, sorted between the
+// ones representing files (with their info) and the ones representing
+// directories.
+func (d *directory) listChildren() (files map[string]info.File, directories []string, err error) {
+	files = make(map[string]info.File)
+	err = d.db.View(func(tx *bbolt.Tx) error {
+		b, err := d.getBucket(tx)
+		if err != nil {
+			return err
+		}
+
+		return b.ForEachBucket(func(k []byte) error {
+			child := b.Bucket(k)
+
+			// Check if there is metadata
+			if child.Get(metadataKey) == nil {
+				directories = append(directories, string(k))
+				return nil
+			}
+
+			info, err := readMetadata(child)
+			if err != nil {
+				return err
+			}
+			files[string(k)] = info
+
+			return nil
+		})
+	})
+
+	return files, directories, err
+}
+
+// ListFiles returns a map of files.
+func (d *directory) ListFiles(_ context.Context) (map[string]storage.File, error) {
+	infos, _, err := d.listChildren()
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]storage.File, len(infos))
+	for name, info := range infos {
+		f, err := newFile(d.db, d.getChildPath(name), info)
+		if err != nil {
+			return nil, err
+		}
+
+		files[name] = f
+	}
+
+	return files, nil
+}
+
+// ListDirectories returns a map of directories.
+func (d *directory) ListDirectories(_ context.Context) (map[string]storage.Directory, error) {
+	_, names, err := d.listChildren()
+	if err != nil {
+		return nil, err
+	}
+
+	directories := make(map[string]storage.Directory, len(names))
+	for _, name := range names {
+		directories[name] = newDirectory(d.db, d.getChildPath(name))
+	}
+
+	return directories, nil
+}
+
+// RemoveDirectory removes a directory.
+func (d *directory) RemoveDirectory(_ context.Context, name string) error {
+	return d.db.Update(func(tx *bbolt.Tx) error {
+		b, err := d.getBucket(tx)
+		if err != nil {
+			return err
+		}
+
+		// Check if directory exists
+		err = ensureChildDoesNotExists(b, name)
+		switch {
+		case err == nil:
+			return fmt.Errorf("%w: %q", storage.ErrDirectoryNotFound, name)
+		case errors.Is(err, storage.ErrFileAlreadyExists):
+			return fmt.Errorf("%w: %q", storage.ErrIsFile, name)
+		case !errors.Is(err, storage.ErrDirectoryAlreadyExists):
+			return err
+		}
+
+		// Remove directory
+		return b.DeleteBucket([]byte(name))
+	})
+}
+
+// RemoveFile removes a file.
+func (d *directory) RemoveFile(_ context.Context, name string) error {
+	return d.db.Update(func(tx *bbolt.Tx) error {
+		b, err := d.getBucket(tx)
+		if err != nil {
+			return err
+		}
+
+		// Check if file exists
+		err = ensureChildDoesNotExists(b, name)
+		switch {
+		case err == nil:
+			return fmt.Errorf("%w: %q", storage.ErrFileNotFound, name)
+		case errors.Is(err, storage.ErrDirectoryAlreadyExists):
+			return fmt.Errorf("%w: %q", storage.ErrIsDirectory, name)
+		case !errors.Is(err, storage.ErrFileAlreadyExists):
+			return err
+		}
+
+		// Remove file
+		return b.DeleteBucket([]byte(name))
+	})
+}
+
+// move moves a child bucket to the new parent, in the same transaction than
+// the checks made on the source and destination.
+func (d *directory) move(
+	tx *bbolt.Tx,
+	name string,
+	newParent storage.Directory,
+	newName string,
+	noReplace bool,
+) error {
+	b, err := d.getBucket(tx)
+	if err != nil {
+		return err
+	}
+	nb, err := newParent.(*directory).getBucket(tx)
+	if err != nil {
+		return err
+	}
+
+	// Check if there is a file or a directory with the new name
+	err = ensureChildDoesNotExists(nb, newName)
+	switch {
+	case err == nil:
+		// Nothing to do
+	case !noReplace && (errors.Is(err, storage.ErrDirectoryAlreadyExists) ||
+		errors.Is(err, storage.ErrFileAlreadyExists)):
+		if err := nb.DeleteBucket([]byte(newName)); err != nil {
+			return err
+		}
+	default:
+		return err
+	}
+
+	// Copy the bucket to its new place, then remove the old one
+	dst, err := nb.CreateBucket([]byte(newName))
+	if err != nil {
+		return err
+	}
+	if err := copyBucket(b.Bucket([]byte(name)), dst); err != nil {
+		return err
+	}
+
+	return b.DeleteBucket([]byte(name))
+}
+
+// copyBucket recursively copies the content of a bucket into another.
+func copyBucket(src, dst *bbolt.Bucket) error {
+	return src.ForEach(func(k, v []byte) error {
+		// Copy value
+		if v != nil {
+			return dst.Put(k, v)
+		}
+
+		// Copy nested bucket
+		child, err := dst.CreateBucket(k)
+		if err != nil {
+			return err
+		}
+		return copyBucket(src.Bucket(k), child)
+	})
+}
+
+// RenameFile renames a file.
+func (d *directory) RenameFile(
+	_ context.Context,
+	name string,
+	newParent storage.Directory,
+	newName string,
+	noReplace bool,
+) error {
+	return d.db.Update(func(tx *bbolt.Tx) error {
+		b, err := d.getBucket(tx)
+		if err != nil {
+			return err
+		}
+
+		// Check if file exists
+		err = ensureChildDoesNotExists(b, name)
+		switch {
+		case err == nil:
+			return fmt.Errorf("%w: %q", storage.ErrFileNotFound, name)
+		case errors.Is(err, storage.ErrDirectoryAlreadyExists):
+			return fmt.Errorf("%w: %q", storage.ErrIsDirectory, name)
+		case !errors.Is(err, storage.ErrFileAlreadyExists):
+			return err
+		}
+
+		// Move the file
+		return d.move(tx, name, newParent, newName, noReplace)
+	})
+}
+
+// RenameDirectory renames a directory.
+func (d *directory) RenameDirectory(
+	_ context.Context,
+	name string,
+	newParent storage.Directory,
+	newName string,
+	noReplace bool,
+) error {
+	return d.db.Update(func(tx *bbolt.Tx) error {
+		b, err := d.getBucket(tx)
+		if err != nil {
+			return err
+		}
+
+		// Check if directory exists
+		err = ensureChildDoesNotExists(b, name)
+		switch {
+		case err == nil:
+			return fmt.Errorf("%w: %q", storage.ErrDirectoryNotFound, name)
+		case errors.Is(err, storage.ErrFileAlreadyExists):
+			return fmt.Errorf("%w: %q", storage.ErrIsFile, name)
+		case !errors.Is(err, storage.ErrDirectoryAlreadyExists):
+			return err
+		}
+
+		// Move the directory
+		return d.move(tx, name, newParent, newName, noReplace)
+	})
+}
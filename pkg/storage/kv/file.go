@@ -0,0 +1,304 @@
+package kv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+	"go.etcd.io/bbolt"
+)
+
+var (
+	metadataKey = []byte(".metadata")
+)
+
+var _ storage.File = (*file)(nil)
+
+// file is represented by a bucket in the database, containing its metadata
+// and its chunks.
+type file struct {
+	db   *bbolt.DB
+	path []string
+}
+
+func newFile(db *bbolt.DB, path []string, info info.File) (*file, error) {
+	if info.ChunkSize <= 0 {
+		return nil, fmt.Errorf("%w: chunk size must be greater than 0", storage.ErrInvalidChunkSize)
+	}
+
+	return &file{
+		db:   db,
+		path: path,
+	}, nil
+}
+
+func writeMetadata(b *bbolt.Bucket, info info.File) error {
+	// Encode info
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	// Store metadata
+	return b.Put(metadataKey, data)
+}
+
+func readMetadata(b *bbolt.Bucket) (info.File, error) {
+	var info info.File
+	if err := json.Unmarshal(b.Get(metadataKey), &info); err != nil {
+		return info, err
+	}
+
+	return info, nil
+}
+
+func getChunkKey(nb int) []byte {
+	return []byte(fmt.Sprintf("chunk-%d.dat", nb))
+}
+
+func (f *file) getBucket(tx *bbolt.Tx) (*bbolt.Bucket, error) {
+	b := getBucket(tx, f.path)
+	if b == nil || b.Get(metadataKey) == nil {
+		return nil, fmt.Errorf("%w: %q", storage.ErrFileNotFound, f.path)
+	}
+	return b, nil
+}
+
+// view executes the function on the file bucket, with the file info, in a
+// read-only transaction.
+func (f *file) view(fn func(b *bbolt.Bucket, info info.File) error) error {
+	return f.db.View(func(tx *bbolt.Tx) error {
+		b, err := f.getBucket(tx)
+		if err != nil {
+			return err
+		}
+
+		info, err := readMetadata(b)
+		if err != nil {
+			return err
+		}
+
+		return fn(b, info)
+	})
+}
+
+// update executes the function on the file bucket, with the file info, in a
+// read-write transaction.
+func (f *file) update(fn func(b *bbolt.Bucket, info info.File) error) error {
+	return f.db.Update(func(tx *bbolt.Tx) error {
+		b, err := f.getBucket(tx)
+		if err != nil {
+			return err
+		}
+
+		info, err := readMetadata(b)
+		if err != nil {
+			return err
+		}
+
+		return fn(b, info)
+	})
+}
+
+// GetInfo returns the file info.
+func (f *file) GetInfo(_ context.Context) (fileInfo info.File, err error) {
+	err = f.view(func(_ *bbolt.Bucket, info info.File) error {
+		fileInfo = info
+		return nil
+	})
+	return fileInfo, err
+}
+
+// SetAttributes sets the file attributes.
+func (f *file) SetAttributes(_ context.Context, attr info.Attributes) error {
+	return f.update(func(b *bbolt.Bucket, info info.File) error {
+		info.Attributes = attr
+		return writeMetadata(b, info)
+	})
+}
+
+func checkImportChunkParams(b *bbolt.Bucket, info info.File, index int, data []byte) error {
+	// Check if chunk index is correct
+	if index < 0 || index >= info.ChunksCount {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkNb, index)
+	}
+
+	// Check if the chunk is empty
+	if b.Get(getChunkKey(index)) != nil {
+		return fmt.Errorf("%w: %d", storage.ErrChunkAlreadyExists, index)
+	}
+
+	// Check if length of data is correct
+	if (len(data) != info.ChunkSize && index != info.ChunksCount-1) || len(data) > info.ChunkSize {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkSize, len(data))
+	}
+
+	return nil
+}
+
+// ImportChunk imports a chunk of data.
+func (f *file) ImportChunk(_ context.Context, index int, data []byte) error {
+	return f.update(func(b *bbolt.Bucket, info info.File) error {
+		// Check params
+		if err := checkImportChunkParams(b, info, index, data); err != nil {
+			return err
+		}
+
+		// Import data
+		if err := b.Put(getChunkKey(index), data); err != nil {
+			return err
+		}
+
+		// If this is the last chunk, set the last chunk size
+		if index == info.ChunksCount-1 {
+			info.LastChunkSize = len(data)
+			return writeMetadata(b, info)
+		}
+
+		return nil
+	})
+}
+
+func checkReadWriteChunkParams(b *bbolt.Bucket, info info.File, index int, offset int) error {
+	// Check if chunk index is correct
+	if index < 0 || index >= info.ChunksCount {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkNb, index)
+	}
+
+	// Check if there is data to read
+	if b.Get(getChunkKey(index)) == nil {
+		return fmt.Errorf("%w", storage.ErrChunkNotFound)
+	}
+
+	// Check if offset is correct
+	if offset < 0 || offset >= info.ChunkSize {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidOffset, offset)
+	}
+
+	// Check if this is the last chunk, that the offset is correct
+	if index == info.ChunksCount-1 && offset >= info.LastChunkSize {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidOffset, offset)
+	}
+
+	return nil
+}
+
+// WriteChunk writes a chunk of data.
+func (f *file) WriteChunk(_ context.Context, index int, data []byte, offset int) (n int, err error) {
+	err = f.update(func(b *bbolt.Bucket, info info.File) error {
+		// Check params
+		if err := checkReadWriteChunkParams(b, info, index, offset); err != nil {
+			return err
+		}
+
+		// Copy the chunk, as values from the database can't be modified
+		key := getChunkKey(index)
+		chunk := append([]byte(nil), b.Get(key)...)
+
+		// Modify it and store it back
+		n = copy(chunk[offset:], data)
+		return b.Put(key, chunk)
+	})
+	return n, err
+}
+
+// ReadChunk reads a chunk of data.
+func (f *file) ReadChunk(_ context.Context, index int, data []byte, offset int) (n int, err error) {
+	err = f.view(func(b *bbolt.Bucket, info info.File) error {
+		// Check params
+		if err := checkReadWriteChunkParams(b, info, index, offset); err != nil {
+			return err
+		}
+
+		// Read data
+		n = copy(data, b.Get(getChunkKey(index))[offset:])
+		return nil
+	})
+	return n, err
+}
+
+// ResizeChunksNb resizes the number of chunks.
+func (f *file) ResizeChunksNb(_ context.Context, size int) error {
+	// Check size is correct
+	if size < 0 {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkNb, size)
+	}
+
+	return f.update(func(b *bbolt.Bucket, info info.File) error {
+		// Check the last chunk size is full
+		if info.ChunksCount > 0 && info.LastChunkSize != info.ChunkSize {
+			return fmt.Errorf("%w", storage.ErrLastChunkNotFull)
+		}
+
+		// Resize chunks
+		if size > info.ChunksCount {
+			// Add chunks
+			for i := info.ChunksCount; i < size; i++ {
+				if err := b.Put(getChunkKey(i), make([]byte, info.ChunkSize)); err != nil {
+					return err
+				}
+			}
+		} else {
+			// Remove chunks
+			for i := size; i < info.ChunksCount; i++ {
+				if err := b.Delete(getChunkKey(i)); err != nil {
+					return err
+				}
+			}
+		}
+
+		// Update info
+		info.ChunksCount = size
+		info.LastChunkSize = info.ChunkSize
+		return writeMetadata(b, info)
+	})
+}
+
+func checkResizeLastChunkParams(b *bbolt.Bucket, info info.File, size int) error {
+	// Check size is correct
+	if size < 0 || size > info.ChunkSize {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkSize, size)
+	}
+
+	// Check if there is a last chunk
+	if info.ChunksCount == 0 {
+		return fmt.Errorf("%w", storage.ErrNoChunk)
+	}
+
+	// Check if the last chunk is present
+	if b.Get(getChunkKey(info.ChunksCount-1)) == nil {
+		return fmt.Errorf("%w", storage.ErrChunkNotFound)
+	}
+
+	return nil
+}
+
+// ResizeLastChunk resizes the last chunk.
+func (f *file) ResizeLastChunk(_ context.Context, size int) (changed int, err error) {
+	err = f.update(func(b *bbolt.Bucket, info info.File) error {
+		// Check params
+		if err := checkResizeLastChunkParams(b, info, size); err != nil {
+			return err
+		}
+
+		// Resize last chunk
+		key := getChunkKey(info.ChunksCount - 1)
+		data := append([]byte(nil), b.Get(key)...)
+		if size > len(data) {
+			data = append(data, make([]byte, size-len(data))...)
+		} else {
+			data = data[:size]
+		}
+		if err := b.Put(key, data); err != nil {
+			return err
+		}
+
+		// Set size
+		changed = size - info.LastChunkSize
+		info.LastChunkSize = size
+		return writeMetadata(b, info)
+	})
+	return changed, err
+}
@@ -0,0 +1,53 @@
+package kv
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/lerenn/chonkfs/pkg/storage/layer"
+	"github.com/lerenn/chonkfs/pkg/storage/mem"
+	"github.com/lerenn/chonkfs/pkg/storage/test"
+	"github.com/stretchr/testify/suite"
+	"go.etcd.io/bbolt"
+)
+
+func TestFileSuite(t *testing.T) {
+	suite.Run(t, new(FileSuite))
+	suite.Run(t, new(FileAsUnderlayerSuite))
+}
+
+type FileSuite struct {
+	test.FileSuite
+	DB *bbolt.DB
+}
+
+func (suite *FileSuite) SetupTest() {
+	var err error
+	suite.DB, err = bbolt.Open(filepath.Join(suite.T().TempDir(), "chonkfs.db"), 0600, nil)
+	suite.Require().NoError(err)
+	suite.Directory, err = NewDirectory(suite.DB)
+	suite.Require().NoError(err)
+}
+
+func (suite *FileSuite) TearDownTest() {
+	suite.Require().NoError(suite.DB.Close())
+}
+
+type FileAsUnderlayerSuite struct {
+	layer.FileSuite
+	DB *bbolt.DB
+}
+
+func (suite *FileAsUnderlayerSuite) SetupTest() {
+	var err error
+	suite.DB, err = bbolt.Open(filepath.Join(suite.T().TempDir(), "chonkfs.db"), 0600, nil)
+	suite.Require().NoError(err)
+	suite.Upperlayer = mem.NewDirectory()
+	suite.Underlayer, err = NewDirectory(suite.DB)
+	suite.Require().NoError(err)
+	suite.Directory, _ = layer.NewDirectory(suite.Upperlayer, suite.Underlayer)
+}
+
+func (suite *FileAsUnderlayerSuite) TearDownTest() {
+	suite.Require().NoError(suite.DB.Close())
+}
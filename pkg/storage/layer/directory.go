@@ -3,6 +3,8 @@ package layer
 import (
 	"context"
 	"errors"
+	"path"
+	"time"
 
 	"github.com/lerenn/chonkfs/pkg/info"
 	"github.com/lerenn/chonkfs/pkg/storage"
@@ -10,13 +12,75 @@ import (
 
 var _ storage.Directory = (*directory)(nil)
 
+type directoryOption func(dir *directory)
+
+// WithFetcher is an option to call a fetcher when a chunk is missing on both
+// layers, the read waiting for the chunk to be imported until the timeout
+// expires. A zero timeout waits until the read is cancelled.
+//
+//nolint:revive
+func WithFetcher(f Fetcher, timeout time.Duration) directoryOption {
+	// Create it once, so it is shared by all directories and files
+	fe := newFetcher(f, timeout)
+	return func(dir *directory) {
+		dir.fetcher = fe
+	}
+}
+
+// WithWriteBack is an option to write chunks on the upperlayer only, the
+// written chunks being flushed to the underlayer at the given interval and
+// when the file is synced, until the context is done. A zero interval keeps
+// writing on both layers.
+//
+//nolint:revive
+func WithWriteBack(ctx context.Context, interval time.Duration) directoryOption {
+	if interval <= 0 {
+		return func(_ *directory) {}
+	}
+
+	// Create it once, so it is shared by all directories and files
+	wb := newWriteBack(ctx, interval)
+	return func(dir *directory) {
+		dir.writeBack = wb
+	}
+}
+
+// WithoutPromotion is an option to read the chunks missing on the upperlayer
+// directly from the underlayer, instead of importing them on the upperlayer.
+//
+//nolint:revive
+func WithoutPromotion() directoryOption {
+	return func(dir *directory) {
+		dir.noPromotion = true
+	}
+}
+
 type directory struct {
 	upperlayer storage.Directory
 	underlayer storage.Directory
+	path       *nodePath
+
+	opts        []directoryOption
+	fetcher     *fetcher
+	writeBack   *writeBack
+	noPromotion bool
 }
 
 // NewDirectory creates a new directory representation.
-func NewDirectory(upperlayer storage.Directory, underlayer storage.Directory) (storage.Directory, error) {
+func NewDirectory(
+	upperlayer storage.Directory,
+	underlayer storage.Directory,
+	opts ...directoryOption,
+) (storage.Directory, error) {
+	return newDirectory(upperlayer, underlayer, newPaths().get("/"), opts...)
+}
+
+func newDirectory(
+	upperlayer storage.Directory,
+	underlayer storage.Directory,
+	path *nodePath,
+	opts ...directoryOption,
+) (*directory, error) {
 	if upperlayer == nil {
 		return nil, errors.New("upperlayer is required")
 	}
@@ -25,10 +89,32 @@ func NewDirectory(upperlayer storage.Directory, underlayer storage.Directory) (s
 		return nil, errors.New("underlayer is required")
 	}
 
-	return &directory{
+	// Create the directory
+	d := &directory{
 		upperlayer: upperlayer,
 		underlayer: underlayer,
-	}, nil
+		path:       path,
+		opts:       opts,
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d, nil
+}
+
+func (d *directory) newChildDirectory(
+	name string,
+	upperlayer storage.Directory,
+	underlayer storage.Directory,
+) (storage.Directory, error) {
+	return newDirectory(upperlayer, underlayer, d.path.child(name), d.opts...)
+}
+
+func (d *directory) newChildFile(name string, upperlayer storage.File, underlayer storage.File) *file {
+	return newFile(upperlayer, underlayer, d.path.child(name), d.fetcher, d.writeBack, d.noPromotion)
 }
 
 // CreateDirectory creates a directory.
@@ -48,12 +134,26 @@ func (d *directory) CreateDirectory(ctx context.Context, name string) (storage.D
 	}
 
 	// Return the new directory
-	return NewDirectory(upperlayerChild, underlayerChild)
+	return d.newChildDirectory(name, upperlayerChild, underlayerChild)
+}
+
+// GetInfo returns the directory info, from the underlayer if the upperlayer
+// has no attributes, like the root of a new upperlayer.
+func (d *directory) GetInfo(ctx context.Context) (info.Directory, error) {
+	dirInfo, err := d.upperlayer.GetInfo(ctx)
+	if err != nil || dirInfo.Attributes != (info.Attributes{}) {
+		return dirInfo, err
+	}
+
+	return d.underlayer.GetInfo(ctx)
 }
 
-// GetInfo returns the directory info.
-func (d *directory) GetInfo(_ context.Context) (info.Directory, error) {
-	return info.Directory{}, nil
+// SetAttributes sets the directory attributes on both layers.
+func (d *directory) SetAttributes(ctx context.Context, attr info.Attributes) error {
+	if err := d.underlayer.SetAttributes(ctx, attr); err != nil {
+		return err
+	}
+	return d.upperlayer.SetAttributes(ctx, attr)
 }
 
 // ListFiles returns a map of files.
@@ -115,16 +215,38 @@ func (d *directory) GetDirectory(ctx context.Context, name string) (storage.Dire
 		}
 	}
 
-	// If the directory is not found on the upperlayer, create it
+	// If the directory is not found on the upperlayer, create it with the
+	// attributes from the underlayer
 	if upperlayer == nil {
-		upperlayer, err = d.upperlayer.CreateDirectory(ctx, name)
-		if err != nil {
+		if upperlayer, err = d.createDirectoryFromUnderlayer(ctx, name, underlayer); err != nil {
 			return nil, err
 		}
 	}
 
 	// Return the directory
-	return NewDirectory(upperlayer, underlayer)
+	return d.newChildDirectory(name, upperlayer, underlayer)
+}
+
+func (d *directory) createDirectoryFromUnderlayer(
+	ctx context.Context,
+	name string,
+	underlayer storage.Directory,
+) (storage.Directory, error) {
+	// Get the info from the underlayer
+	info, err := underlayer.GetInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a new directory on the upperlayer
+	upperlayer, err := d.upperlayer.CreateDirectory(ctx, name)
+	if err != nil {
+		return nil, err
+	} else if err := upperlayer.SetAttributes(ctx, info.Attributes); err != nil {
+		return nil, err
+	}
+
+	return upperlayer, copyXattrs(ctx, upperlayer, underlayer)
 }
 
 func (d *directory) createFileFromUnderlayer(
@@ -139,7 +261,12 @@ func (d *directory) createFileFromUnderlayer(
 	}
 
 	// Create a new file on the upperlayer
-	return d.upperlayer.CreateFile(ctx, name, info)
+	upperlayer, err := d.upperlayer.CreateFile(ctx, name, info)
+	if err != nil {
+		return nil, err
+	}
+
+	return upperlayer, copyXattrs(ctx, upperlayer, underlayer)
 }
 
 // GetFile returns a child file.
@@ -167,7 +294,7 @@ func (d *directory) GetFile(ctx context.Context, name string) (storage.File, err
 	}
 
 	// Return the directory
-	return newFile(upperlayerFile, underlayer), nil
+	return d.newChildFile(name, upperlayerFile, underlayer), nil
 }
 
 // ListDirectories returns a map of directories.
@@ -227,15 +354,21 @@ func (d *directory) CreateFile(ctx context.Context, name string, info info.File)
 	}
 
 	// Return the new directory
-	return newFile(upperlayerFile, underlayerChild), nil
+	return d.newChildFile(name, upperlayerFile, underlayerChild), nil
 }
 
 // RemoveDirectory removes a child directory of the directory.
 func (d *directory) RemoveDirectory(ctx context.Context, name string) error {
+	// Forget the chunks not written on the underlayer
+	if d.writeBack != nil {
+		d.writeBack.discard(path.Join(d.path.String(), name))
+	}
+
 	// Remove the directory from the underlayer
 	if err := d.underlayer.RemoveDirectory(ctx, name); err != nil {
 		return err
 	}
+	d.path.paths.remove(path.Join(d.path.String(), name))
 
 	// Remove the directory from the upperlayer
 	err := d.upperlayer.RemoveDirectory(ctx, name)
@@ -248,10 +381,16 @@ func (d *directory) RemoveDirectory(ctx context.Context, name string) error {
 
 // RemoveFile removes a child file of the directory.
 func (d *directory) RemoveFile(ctx context.Context, name string) error {
+	// Forget the chunks not written on the underlayer
+	if d.writeBack != nil {
+		d.writeBack.discard(path.Join(d.path.String(), name))
+	}
+
 	// Remove the directory from the underlayer
 	if err := d.underlayer.RemoveFile(ctx, name); err != nil {
 		return err
 	}
+	d.path.paths.remove(path.Join(d.path.String(), name))
 
 	// Remove the directory from the upperlayer
 	err := d.upperlayer.RemoveFile(ctx, name)
@@ -270,11 +409,19 @@ func (d *directory) RenameFile(
 	newName string,
 	noReplace bool,
 ) error {
+	// Write the chunks on the underlayer before it is moved
+	if d.writeBack != nil {
+		if err := d.writeBack.flush(ctx, path.Join(d.path.String(), name)); err != nil {
+			return err
+		}
+	}
+
 	// Rename the file on the underlayer
 	newParentUnderlayer := newParent.(*directory).underlayer
 	if err := d.underlayer.RenameFile(ctx, name, newParentUnderlayer, newName, noReplace); err != nil {
 		return err
 	}
+	d.move(name, newParent.(*directory), newName)
 
 	// Rename the file on the upperlayer
 	newParentBackend := newParent.(*directory).upperlayer
@@ -294,11 +441,19 @@ func (d *directory) RenameDirectory(
 	newName string,
 	noReplace bool,
 ) error {
+	// Write the chunks on the underlayer before it is moved
+	if d.writeBack != nil {
+		if err := d.writeBack.flush(ctx, path.Join(d.path.String(), name)); err != nil {
+			return err
+		}
+	}
+
 	// Rename the directory on the underlayer
 	newParentUnderlayer := newParent.(*directory).underlayer
 	if err := d.underlayer.RenameDirectory(ctx, name, newParentUnderlayer, newName, noReplace); err != nil {
 		return err
 	}
+	d.move(name, newParent.(*directory), newName)
 
 	// Rename the directory on the upperlayer
 	newParentBackend := newParent.(*directory).upperlayer
@@ -309,3 +464,18 @@ func (d *directory) RenameDirectory(
 
 	return err
 }
+
+// move moves the path of a child, and its chunks not written on the
+// underlayer, so the handles opened before the rename follow it.
+func (d *directory) move(name string, newParent *directory, newName string) {
+	from := path.Join(d.path.String(), name)
+	to := path.Join(newParent.path.String(), newName)
+	if from == to {
+		return
+	}
+
+	d.path.paths.move(from, to)
+	if d.writeBack != nil {
+		d.writeBack.move(from, to)
+	}
+}
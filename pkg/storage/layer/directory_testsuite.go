@@ -2,6 +2,7 @@ package layer
 
 import (
 	"context"
+	"errors"
 
 	"github.com/lerenn/chonkfs/pkg/info"
 	"github.com/lerenn/chonkfs/pkg/storage"
@@ -32,6 +33,19 @@ func (suite *DirectorySuite) TestGetInfoWhenDirectoryExistsOnlyOnUnderlayer() {
 	suite.Require().Equal(info.Directory{}, dirInfo)
 }
 
+// TestGetInfoOfRootFromUnderlayer tests the retrieval of the root attributes
+// when they are only on the underlayer.
+func (suite *DirectorySuite) TestGetInfoOfRootFromUnderlayer() {
+	// Set the attributes of the root on the underlayer
+	attr := info.Attributes{Mode: 0o40750, UID: 1000, GID: 1000}
+	suite.Require().NoError(suite.Underlayer.SetAttributes(context.Background(), attr))
+
+	// Get info
+	dirInfo, err := suite.Directory.GetInfo(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal(attr, dirInfo.Attributes)
+}
+
 // TestRemoveDirectoryOnBackendAndUnderlayer tests the removal of a directory
 // when it exists on both the backend and the underlayer.
 func (suite *DirectorySuite) TestRemoveDirectoryOnBackendAndUnderlayer() {
@@ -113,6 +127,51 @@ func (suite *DirectorySuite) TestGetFileWhenOnlyOnUnderlayer() {
 	suite.Require().NotNil(file)
 }
 
+// TestGetFileXattrsWhenOnlyOnUnderlayer tests the extended attributes of a
+// file only on the underlayer are copied on the upperlayer.
+func (suite *DirectorySuite) TestGetFileXattrsWhenOnlyOnUnderlayer() {
+	// Create a file with an extended attribute on underlayer
+	f, err := suite.Underlayer.CreateFile(context.Background(), "File", info.File{
+		ChunkSize: 4096,
+	})
+	suite.Require().NoError(err)
+	err = f.SetXattr(context.Background(), "user.a", []byte("A"))
+	if errors.Is(err, storage.ErrXattrNotSupported) {
+		suite.T().Skip("extended attributes not supported")
+	}
+	suite.Require().NoError(err)
+
+	// Get the file
+	_, err = suite.Directory.GetFile(context.Background(), "File")
+	suite.Require().NoError(err)
+
+	// Check the extended attribute is on the upperlayer
+	f, err = suite.Upperlayer.GetFile(context.Background(), "File")
+	suite.Require().NoError(err)
+	value, err := f.GetXattr(context.Background(), "user.a")
+	suite.Require().NoError(err)
+	suite.Require().Equal([]byte("A"), value)
+}
+
+// TestGetRootXattrsWhenOnlyOnUnderlayer tests the extended attributes of the
+// root set before the upperlayer are read from the underlayer.
+func (suite *DirectorySuite) TestGetRootXattrsWhenOnlyOnUnderlayer() {
+	// Set an extended attribute on the underlayer root
+	err := suite.Underlayer.SetXattr(context.Background(), "user.a", []byte("A"))
+	if errors.Is(err, storage.ErrXattrNotSupported) {
+		suite.T().Skip("extended attributes not supported")
+	}
+	suite.Require().NoError(err)
+
+	// Check it is readable and listed from the layer
+	value, err := suite.Directory.GetXattr(context.Background(), "user.a")
+	suite.Require().NoError(err)
+	suite.Require().Equal([]byte("A"), value)
+	names, err := suite.Directory.ListXattrs(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal([]string{"user.a"}, names)
+}
+
 // TestGetDirectory tests the retrieval of a directory.
 func (suite *DirectorySuite) TestGetDirectory() {
 	// Create a directory
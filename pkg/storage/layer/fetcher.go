@@ -0,0 +1,146 @@
+package layer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+// Fetcher is a hook called when a chunk is missing on both layers, so it can
+// be retrieved by another component (a torrent client for example) that will
+// import it on the layer. It can be called several times for the same chunk.
+type Fetcher interface {
+	Fetch(ctx context.Context, path string, index int) error
+}
+
+// FetcherFunc is an adapter to use a function as a Fetcher.
+type FetcherFunc func(ctx context.Context, path string, index int) error
+
+// Fetch calls the function.
+func (f FetcherFunc) Fetch(ctx context.Context, path string, index int) error {
+	return f(ctx, path, index)
+}
+
+type chunkKey struct {
+	path  string
+	index int
+}
+
+// fetcher calls the Fetcher hook for missing chunks and waits for them to be
+// imported.
+type fetcher struct {
+	fetcher Fetcher
+	timeout time.Duration
+
+	waitersMutex sync.Mutex
+	waiters      map[chunkKey]*chunkWaiters
+}
+
+// chunkWaiters is the channel closed when a chunk is imported, with the count
+// of the reads waiting on it.
+type chunkWaiters struct {
+	imported chan struct{}
+	count    int
+}
+
+func newFetcher(f Fetcher, timeout time.Duration) *fetcher {
+	return &fetcher{
+		fetcher: f,
+		timeout: timeout,
+		waiters: make(map[chunkKey]*chunkWaiters),
+	}
+}
+
+// wait returns a channel that will be closed when the chunk is imported. The
+// read must call leave with it once it stops waiting.
+func (fe *fetcher) wait(key chunkKey) <-chan struct{} {
+	fe.waitersMutex.Lock()
+	defer fe.waitersMutex.Unlock()
+
+	w, ok := fe.waiters[key]
+	if !ok {
+		w = &chunkWaiters{imported: make(chan struct{})}
+		fe.waiters[key] = w
+	}
+	w.count++
+
+	return w.imported
+}
+
+// leave stops waiting on the channel returned by wait, forgetting the chunk
+// when no read waits for it anymore.
+func (fe *fetcher) leave(key chunkKey, imported <-chan struct{}) {
+	fe.waitersMutex.Lock()
+	defer fe.waitersMutex.Unlock()
+
+	// The chunk has been forgotten if it was imported since
+	w, ok := fe.waiters[key]
+	if !ok || w.imported != imported {
+		return
+	}
+
+	w.count--
+	if w.count == 0 {
+		delete(fe.waiters, key)
+	}
+}
+
+// notify wakes up the reads waiting for the chunk.
+func (fe *fetcher) notify(path string, index int) {
+	fe.waitersMutex.Lock()
+	defer fe.waitersMutex.Unlock()
+
+	key := chunkKey{path: path, index: index}
+	if w, ok := fe.waiters[key]; ok {
+		close(w.imported)
+		delete(fe.waiters, key)
+	}
+}
+
+// fetch asks for the chunk to be fetched and calls read each time the chunk is
+// imported, until it succeeds, the timeout expires or the context is done.
+func (fe *fetcher) fetch(
+	ctx context.Context,
+	path string,
+	index int,
+	read func() (int, error),
+) (int, error) {
+	key := chunkKey{path: path, index: index}
+
+	// Set the timeout
+	if fe.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, fe.timeout)
+		defer cancel()
+	}
+
+	// Ask for the chunk, waiting before so the import can't be missed
+	imported := fe.wait(key)
+	defer func() { fe.leave(key, imported) }()
+	if err := fe.fetcher.Fetch(ctx, path, index); err != nil {
+		return 0, fmt.Errorf("%w: %w", storage.ErrChunkFetchFailed, err)
+	}
+
+	for {
+		// Read the chunk, in case it has been imported
+		n, err := read()
+		if !errors.Is(err, storage.ErrChunkNotFound) {
+			return n, err
+		}
+
+		// Wait for the chunk to be imported
+		select {
+		case <-imported:
+			imported = fe.wait(key)
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return 0, fmt.Errorf("%w: %q, %d", storage.ErrChunkFetchTimeout, path, index)
+			}
+			return 0, fmt.Errorf("waiting for chunk %q, %d: %w", path, index, ctx.Err())
+		}
+	}
+}
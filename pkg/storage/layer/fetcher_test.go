@@ -0,0 +1,143 @@
+package layer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+	"github.com/lerenn/chonkfs/pkg/storage/mem"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestFetcherSuite(t *testing.T) {
+	suite.Run(t, new(FetcherSuite))
+}
+
+type FetcherSuite struct {
+	suite.Suite
+}
+
+func (suite *FetcherSuite) newDirectoryWithFile(f Fetcher, timeout time.Duration) storage.Directory {
+	d, err := NewDirectory(mem.NewDirectory(), mem.NewDirectory(), WithFetcher(f, timeout))
+	suite.Require().NoError(err)
+
+	// Create a file with a chunk that is not present
+	sd, err := d.CreateDirectory(context.Background(), "dir")
+	suite.Require().NoError(err)
+	_, err = sd.CreateFile(context.Background(), "file", info.File{
+		ChunkSize:     4,
+		ChunksCount:   2,
+		LastChunkSize: 4,
+	})
+	suite.Require().NoError(err)
+
+	return sd
+}
+
+func (suite *FetcherSuite) TestReadWaitsForImport() {
+	var d storage.Directory
+	fetched := make(chan string, 1)
+	d = suite.newDirectoryWithFile(FetcherFunc(func(_ context.Context, path string, index int) error {
+		fetched <- path
+
+		// Import the chunk from another file representation
+		go func() {
+			f, err := d.GetFile(context.Background(), "file")
+			if err == nil {
+				err = f.ImportChunk(context.Background(), index, []byte("ABCD"))
+			}
+			suite.NoError(err)
+		}()
+
+		return nil
+	}), 0)
+
+	// Read the chunk
+	f, err := d.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+	data := make([]byte, 4)
+	read, err := f.ReadChunk(context.Background(), 1, data, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal(4, read)
+	suite.Require().Equal("ABCD", string(data))
+	suite.Require().Equal("/dir/file", <-fetched)
+	suite.Require().Empty(d.(*directory).fetcher.waiters)
+}
+
+func (suite *FetcherSuite) TestReadTimeout() {
+	d := suite.newDirectoryWithFile(FetcherFunc(func(_ context.Context, _ string, _ int) error {
+		return nil
+	}), time.Millisecond)
+
+	f, err := d.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+	_, err = f.ReadChunk(context.Background(), 0, make([]byte, 4), 0)
+	suite.Require().ErrorIs(err, storage.ErrChunkFetchTimeout)
+	suite.Require().Empty(d.(*directory).fetcher.waiters)
+}
+
+func (suite *FetcherSuite) TestReadCancelled() {
+	d := suite.newDirectoryWithFile(FetcherFunc(func(_ context.Context, _ string, _ int) error {
+		return nil
+	}), 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	f, err := d.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+	_, err = f.ReadChunk(ctx, 0, make([]byte, 4), 0)
+	suite.Require().ErrorIs(err, context.Canceled)
+	suite.Require().Empty(d.(*directory).fetcher.waiters)
+}
+
+func (suite *FetcherSuite) TestReadCancelledWhileAnotherWaits() {
+	fetched := make(chan struct{}, 2)
+	d := suite.newDirectoryWithFile(FetcherFunc(func(_ context.Context, _ string, _ int) error {
+		fetched <- struct{}{}
+		return nil
+	}), 0)
+	f, err := d.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+
+	// Wait for the chunk on a read that will be cancelled, and on another
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error, 1)
+	go func() {
+		_, err := f.ReadChunk(ctx, 0, make([]byte, 4), 0)
+		cancelled <- err
+	}()
+	read := make(chan error, 1)
+	data := make([]byte, 4)
+	go func() {
+		_, err := f.ReadChunk(context.Background(), 0, data, 0)
+		read <- err
+	}()
+	<-fetched
+	<-fetched
+
+	// Cancel the first read
+	cancel()
+	suite.Require().ErrorIs(<-cancelled, context.Canceled)
+
+	// Check the other read still gets the chunk once imported
+	suite.Require().NoError(f.ImportChunk(context.Background(), 0, []byte("ABCD")))
+	suite.Require().NoError(<-read)
+	suite.Require().Equal("ABCD", string(data))
+	suite.Require().Empty(d.(*directory).fetcher.waiters)
+}
+
+func (suite *FetcherSuite) TestFetchFailed() {
+	d := suite.newDirectoryWithFile(FetcherFunc(func(_ context.Context, _ string, _ int) error {
+		return errors.New("no peer")
+	}), 0)
+
+	f, err := d.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+	_, err = f.ReadChunk(context.Background(), 0, make([]byte, 4), 0)
+	suite.Require().ErrorIs(err, storage.ErrChunkFetchFailed)
+	suite.Require().Empty(d.(*directory).fetcher.waiters)
+}
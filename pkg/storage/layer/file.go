@@ -9,17 +9,35 @@ import (
 	"github.com/lerenn/chonkfs/pkg/storage"
 )
 
-var _ storage.File = (*file)(nil)
+var (
+	_ storage.SparseFile = (*file)(nil)
+	_ storage.Syncer     = (*file)(nil)
+)
 
 type file struct {
-	upperlayer storage.File
-	underlayer storage.File
+	upperlayer  storage.File
+	underlayer  storage.File
+	path        *nodePath
+	fetcher     *fetcher
+	writeBack   *writeBack
+	noPromotion bool
 }
 
-func newFile(upperlayer storage.File, underlayer storage.File) *file {
+func newFile(
+	upperlayer storage.File,
+	underlayer storage.File,
+	path *nodePath,
+	fetcher *fetcher,
+	writeBack *writeBack,
+	noPromotion bool,
+) *file {
 	return &file{
-		upperlayer: upperlayer,
-		underlayer: underlayer,
+		upperlayer:  upperlayer,
+		underlayer:  underlayer,
+		path:        path,
+		fetcher:     fetcher,
+		writeBack:   writeBack,
+		noPromotion: noPromotion,
 	}
 }
 
@@ -34,28 +52,148 @@ func (f *file) GetInfo(ctx context.Context) (info.File, error) {
 	return f.underlayer.GetInfo(ctx)
 }
 
-// ReadChunk reads _ from a chunk.
+// SetAttributes sets the file attributes on both layers. With write-back, they
+// are set on the upperlayer only and written later on the underlayer.
+func (f *file) SetAttributes(ctx context.Context, attr info.Attributes) error {
+	if f.writeBack != nil {
+		if err := f.upperlayer.SetAttributes(ctx, attr); err != nil {
+			return err
+		}
+
+		// Mark them after the write, so a concurrent flush can't miss them
+		f.writeBack.markAttributesDirty(f)
+		return nil
+	}
+
+	if err := f.underlayer.SetAttributes(ctx, attr); err != nil {
+		return err
+	}
+	return f.upperlayer.SetAttributes(ctx, attr)
+}
+
+// ReadChunk reads _ from a chunk. If the chunk is missing on both layers and
+// there is a fetcher, it waits for the chunk to be imported.
 func (f *file) ReadChunk(ctx context.Context, index int, data []byte, offset int) (int, error) {
+	read, err := f.readChunk(ctx, index, data, offset)
+	if f.fetcher == nil || !errors.Is(err, storage.ErrChunkNotFound) {
+		return read, err
+	}
+
+	return f.fetcher.fetch(ctx, f.path.String(), index, func() (int, error) {
+		return f.readChunk(ctx, index, data, offset)
+	})
+}
+
+func (f *file) readChunk(ctx context.Context, index int, data []byte, offset int) (int, error) {
 	read, err := f.upperlayer.ReadChunk(ctx, index, data, offset)
-	if err == nil {
+	switch {
+	case err == nil:
 		return read, nil
-	} else if !errors.Is(err, storage.ErrChunkNotFound) {
+	case errors.Is(err, storage.ErrChunkCorrupted):
+		return f.readRepairedChunk(ctx, index, data, offset)
+	case !errors.Is(err, storage.ErrChunkNotFound):
 		return 0, fmt.Errorf("%w: %w", storage.ErrStorage, err)
 	}
 
-	return f.underlayer.ReadChunk(ctx, index, data, offset)
+	// Read it from the underlayer if it should not be promoted
+	if f.noPromotion {
+		return f.underlayer.ReadChunk(ctx, index, data, offset)
+	}
+
+	// Get info
+	info, err := f.GetInfo(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Import the chunk from underlayer, so the next reads don't need it
+	if err := f.importChunkFromUnderlayer(ctx, info, index); err != nil {
+		return 0, err
+	}
+
+	return f.upperlayer.ReadChunk(ctx, index, data, offset)
+}
+
+// readRepairedChunk reads _ from a chunk corrupted on the upperlayer, once
+// repaired from the underlayer.
+func (f *file) readRepairedChunk(ctx context.Context, index int, data []byte, offset int) (int, error) {
+	chunk, err := f.repairChunk(ctx, index)
+	if err != nil {
+		return 0, err
+	}
+
+	// Check if offset is correct
+	if offset < 0 || offset >= len(chunk) {
+		return 0, fmt.Errorf("%w: %d", storage.ErrInvalidOffset, offset)
+	}
+
+	return copy(data, chunk[offset:]), nil
+}
+
+// repairChunk overwrites a chunk corrupted on the upperlayer with the one from
+// the underlayer, and returns it.
+func (f *file) repairChunk(ctx context.Context, index int) ([]byte, error) {
+	info, err := f.GetInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the chunk from underlayer
+	chunk := make([]byte, info.ChunkSize)
+	read, err := f.underlayer.ReadChunk(ctx, index, chunk, 0)
+	if err != nil {
+		return nil, err
+	}
+	chunk = chunk[:read]
+
+	// Overwrite it on the upperlayer
+	if _, err := f.upperlayer.WriteChunk(ctx, index, chunk, 0); err != nil {
+		return nil, fmt.Errorf("%w: %w", storage.ErrStorage, err)
+	}
+
+	return chunk, nil
+}
+
+// HasChunkData returns true if the chunk holds data, false if this is a hole.
+// The underlayer is used when the chunk is not present on the upperlayer, and
+// any chunk is considered holding data if a layer cannot tell.
+func (f *file) HasChunkData(ctx context.Context, index int) (bool, error) {
+	// Check on upperlayer
+	if sf, ok := f.upperlayer.(storage.SparseFile); ok {
+		hasData, err := sf.HasChunkData(ctx, index)
+		if err == nil {
+			return hasData, nil
+		} else if !errors.Is(err, storage.ErrChunkNotFound) {
+			return false, fmt.Errorf("%w: %w", storage.ErrStorage, err)
+		}
+	}
+
+	// Check on underlayer
+	sf, ok := f.underlayer.(storage.SparseFile)
+	if !ok {
+		return true, nil
+	}
+	return sf.HasChunkData(ctx, index)
 }
 
 // WriteChunk writes _ to a chunk.
 func (f *file) WriteChunk(ctx context.Context, index int, data []byte, offset int) (int, error) {
+	if f.writeBack != nil {
+		return f.writeChunkBack(ctx, index, data, offset)
+	}
+
 	// Write to underlayer
 	rd, err := f.underlayer.WriteChunk(ctx, index, data, offset)
 	if err != nil {
 		return rd, fmt.Errorf("%w: %w", storage.ErrStorage, err)
 	}
 
-	// Try to write upperlayer
+	// Try to write upperlayer, repairing it from the underlayer if the chunk
+	// is corrupted
 	_, err = f.upperlayer.WriteChunk(ctx, index, data, offset)
+	if errors.Is(err, storage.ErrChunkCorrupted) {
+		_, err = f.repairChunk(ctx, index)
+	}
 	if err != nil && !errors.Is(err, storage.ErrChunkNotFound) {
 		return rd, fmt.Errorf("%w: %w", storage.ErrStorage, err)
 	}
@@ -63,8 +201,66 @@ func (f *file) WriteChunk(ctx context.Context, index int, data []byte, offset in
 	return rd, nil
 }
 
+// writeChunkBack writes _ to a chunk on the upperlayer only, marked as dirty
+// so it is written later on the underlayer.
+func (f *file) writeChunkBack(ctx context.Context, index int, data []byte, offset int) (int, error) {
+	f.path.writeBackMutex.Lock()
+	defer f.path.writeBackMutex.Unlock()
+
+	// Mark it as dirty, before writing so it can't be dropped from upperlayer
+	if err := f.writeBack.markDirty(ctx, f, index); err != nil {
+		return 0, err
+	}
+
+	// Write to upperlayer
+	written, err := f.upperlayer.WriteChunk(ctx, index, data, offset)
+	if errors.Is(err, storage.ErrChunkNotFound) {
+		// Import the chunk from the underlayer first
+		info, err := f.GetInfo(ctx)
+		if err != nil {
+			return 0, err
+		}
+		if err := f.importChunkFromUnderlayer(ctx, info, index); err != nil {
+			return 0, err
+		}
+
+		written, err = f.upperlayer.WriteChunk(ctx, index, data, offset)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %w", storage.ErrStorage, err)
+		}
+	} else if errors.Is(err, storage.ErrChunkCorrupted) {
+		// Repair the chunk from the underlayer first
+		if _, err := f.repairChunk(ctx, index); err != nil {
+			return 0, err
+		}
+
+		written, err = f.upperlayer.WriteChunk(ctx, index, data, offset)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %w", storage.ErrStorage, err)
+		}
+	} else if err != nil {
+		return 0, fmt.Errorf("%w: %w", storage.ErrStorage, err)
+	}
+
+	return written, nil
+}
+
+// Sync writes the dirty chunks of the file on the underlayer.
+func (f *file) Sync(ctx context.Context) error {
+	if f.writeBack == nil {
+		return nil
+	}
+
+	return f.writeBack.flush(ctx, f.path.String())
+}
+
 // ResizeChunksNb resizes the number of chunks.
 func (f *file) ResizeChunksNb(ctx context.Context, size int) error {
+	// Write the dirty chunks before resizing both layers
+	if err := f.Sync(ctx); err != nil {
+		return err
+	}
+
 	if err := f.underlayer.ResizeChunksNb(ctx, size); err != nil {
 		return err
 	}
@@ -74,6 +270,11 @@ func (f *file) ResizeChunksNb(ctx context.Context, size int) error {
 
 // ResizeLastChunk resizes the last chunk.
 func (f *file) ResizeLastChunk(ctx context.Context, size int) (int, error) {
+	// Write the dirty chunks before resizing both layers
+	if err := f.Sync(ctx); err != nil {
+		return 0, err
+	}
+
 	// Modify it on underlayer
 	changed, err := f.underlayer.ResizeLastChunk(ctx, size)
 	if err != nil {
@@ -106,13 +307,32 @@ func (f *file) importChunkFromUnderlayer(ctx context.Context, info info.File, in
 	}
 
 	// Write the chunk to the upperlayer
-	return f.upperlayer.ImportChunk(ctx, index, data[:read])
+	// NOTE: the chunk can already be there if a concurrent read (a prefetch
+	// for example) imported it in the meantime
+	err = f.upperlayer.ImportChunk(ctx, index, data[:read])
+	if err != nil && !errors.Is(err, storage.ErrChunkAlreadyExists) {
+		return err
+	}
+	return nil
 }
 
+// ImportChunk imports a chunk on both layers, waking up the reads waiting
+// for it.
 func (f *file) ImportChunk(ctx context.Context, index int, data []byte) error {
 	if err := f.underlayer.ImportChunk(ctx, index, data); err != nil {
 		return err
 	}
 
-	return f.upperlayer.ImportChunk(ctx, index, data)
+	// NOTE: the chunk can already be on the upperlayer if a waiting read
+	// imported it from the underlayer in the meantime
+	err := f.upperlayer.ImportChunk(ctx, index, data)
+	if err != nil && !errors.Is(err, storage.ErrChunkAlreadyExists) {
+		return err
+	}
+
+	if f.fetcher != nil {
+		f.fetcher.notify(f.path.String(), index)
+	}
+
+	return nil
 }
@@ -1,9 +1,13 @@
 package layer
 
 import (
+	"context"
 	"os"
 	"testing"
 
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+	"github.com/lerenn/chonkfs/pkg/storage/checksum"
 	"github.com/lerenn/chonkfs/pkg/storage/disk"
 	"github.com/lerenn/chonkfs/pkg/storage/mem"
 	"github.com/stretchr/testify/suite"
@@ -12,6 +16,9 @@ import (
 func TestFileSuite(t *testing.T) {
 	suite.Run(t, new(FileWithMemSuite))
 	suite.Run(t, new(FileWithDiskSuite))
+	suite.Run(t, new(FileWithMemCacheSuite))
+	suite.Run(t, new(FileWithoutPromotionSuite))
+	suite.Run(t, new(FileWithCorruptionSuite))
 }
 
 type FileWithMemSuite struct {
@@ -24,6 +31,39 @@ func (suite *FileWithMemSuite) SetupTest() {
 	suite.Directory, _ = NewDirectory(suite.Upperlayer, suite.Underlayer)
 }
 
+type FileWithMemCacheSuite struct {
+	FileSuite
+}
+
+func (suite *FileWithMemCacheSuite) SetupTest() {
+	suite.Upperlayer = mem.NewDirectory(mem.WithMaxSize(4096))
+	suite.Underlayer = mem.NewDirectory()
+	suite.Directory, _ = NewDirectory(suite.Upperlayer, suite.Underlayer)
+}
+
+func (suite *FileWithMemCacheSuite) TestReadEvictedChunk() {
+	f, err := suite.Directory.CreateFile(context.Background(), "FileA", info.File{
+		ChunkSize: 4096,
+	})
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.ResizeChunksNb(context.Background(), 2))
+
+	// Write two chunks, more than what the upperlayer can keep
+	_, err = f.WriteChunk(context.Background(), 0, []byte("First"), 0)
+	suite.Require().NoError(err)
+	_, err = f.WriteChunk(context.Background(), 1, []byte("Second"), 0)
+	suite.Require().NoError(err)
+
+	// Read them back
+	data := make([]byte, 4096)
+	_, err = f.ReadChunk(context.Background(), 0, data, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal("First", string(data[:5]))
+	_, err = f.ReadChunk(context.Background(), 1, data, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal("Second", string(data[:6]))
+}
+
 type FileWithDiskSuite struct {
 	UpperlayerPath string
 	UnderlayerPath string
@@ -50,3 +90,73 @@ func (suite *FileWithDiskSuite) TearDownTest() {
 	err = os.RemoveAll(suite.UnderlayerPath)
 	suite.Require().NoError(err)
 }
+
+type FileWithoutPromotionSuite struct {
+	suite.Suite
+}
+
+func (suite *FileWithoutPromotionSuite) TestReadChunkFromUnderlayer() {
+	upperlayer, underlayer := mem.NewDirectory(), mem.NewDirectory()
+	d, err := NewDirectory(upperlayer, underlayer, WithoutPromotion())
+	suite.Require().NoError(err)
+
+	// Create a file on the underlayer only
+	f, err := underlayer.CreateFile(context.Background(), "file", info.File{
+		ChunkSize:     4,
+		ChunksCount:   1,
+		LastChunkSize: 4,
+	})
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.ImportChunk(context.Background(), 0, []byte("ABCD")))
+
+	// Read the chunk through the layer
+	f, err = d.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+	data := make([]byte, 4)
+	_, err = f.ReadChunk(context.Background(), 0, data, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal("ABCD", string(data))
+
+	// Check it has not been imported on the upperlayer
+	uf, err := upperlayer.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+	_, err = uf.ReadChunk(context.Background(), 0, data, 0)
+	suite.Require().ErrorIs(err, storage.ErrChunkNotFound)
+}
+
+type FileWithCorruptionSuite struct {
+	suite.Suite
+}
+
+func (suite *FileWithCorruptionSuite) TestReadCorruptedChunkFromUnderlayer() {
+	stored := mem.NewDirectory()
+	d, err := NewDirectory(checksum.NewDirectory(stored), mem.NewDirectory())
+	suite.Require().NoError(err)
+
+	// Create a file on both layers
+	f, err := d.CreateFile(context.Background(), "file", info.File{
+		ChunkSize:     4,
+		ChunksCount:   1,
+		LastChunkSize: 4,
+	})
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.ImportChunk(context.Background(), 0, []byte("ABCD")))
+
+	// Corrupt the chunk on the upperlayer
+	sf, err := stored.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+	_, err = sf.WriteChunk(context.Background(), 0, []byte("X"), 0)
+	suite.Require().NoError(err)
+
+	// Read the chunk through the layer
+	data := make([]byte, 4)
+	_, err = f.ReadChunk(context.Background(), 0, data, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal("ABCD", string(data))
+
+	// Check it has been repaired on the upperlayer
+	payload := make([]byte, 1)
+	_, err = sf.ReadChunk(context.Background(), 0, payload, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal("A", string(payload))
+}
@@ -234,6 +234,38 @@ func (suite *FileSuite) TestReadChunkWhenUnderlayerOnly() {
 	suite.Require().Equal("Hello, World!", string(data[:13]))
 }
 
+// TestReadChunkImportsFromUnderlayer tests that the ReadChunk method imports the
+// chunk on the upperlayer when it exists only on the underlayer.
+func (suite *FileSuite) TestReadChunkImportsFromUnderlayer() {
+	// Create a file on underlayer
+	ufile, err := suite.Underlayer.CreateFile(context.Background(), "FileA", info.File{
+		ChunkSize: 4096,
+	})
+	suite.Require().NoError(err)
+
+	// Add a chunk
+	err = ufile.ResizeChunksNb(context.Background(), 1)
+	suite.Require().NoError(err)
+
+	// Write a chunk
+	_, err = ufile.WriteChunk(context.Background(), 0, []byte("Hello, World!"), 0)
+	suite.Require().NoError(err)
+
+	// Read the chunk from layer
+	file, err := suite.Directory.GetFile(context.Background(), "FileA")
+	suite.Require().NoError(err)
+	_, err = file.ReadChunk(context.Background(), 0, make([]byte, 4096), 0)
+	suite.Require().NoError(err)
+
+	// Check the chunk is now on the upperlayer
+	bfile, err := suite.Upperlayer.GetFile(context.Background(), "FileA")
+	suite.Require().NoError(err)
+	data := make([]byte, 4096)
+	_, err = bfile.ReadChunk(context.Background(), 0, data, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal("Hello, World!", string(data[:13]))
+}
+
 // TestWriteChunkWhenUnderlayerOnly tests the WriteChunk method when the file exists only on the underlayer.
 func (suite *FileSuite) TestWriteChunkWhenUnderlayerOnly() {
 	// Create a file on underlayer
@@ -262,3 +294,52 @@ func (suite *FileSuite) TestWriteChunkWhenUnderlayerOnly() {
 	suite.Require().Equal(4096, read)
 	suite.Require().Equal("Hello, World!", string(data[:13]))
 }
+
+// TestResizeChunksNbCreatesHolesOnUpperlayer tests that the chunks created by
+// the ResizeChunksNb method are holes on the upperlayer, until written.
+func (suite *FileSuite) TestResizeChunksNbCreatesHolesOnUpperlayer() {
+	// Create a file
+	file, err := suite.Directory.CreateFile(context.Background(), "FileA", info.File{
+		ChunkSize: 4096,
+	})
+	suite.Require().NoError(err)
+
+	// Resize the file
+	err = file.ResizeChunksNb(context.Background(), 3)
+	suite.Require().NoError(err)
+
+	// Write on one chunk
+	_, err = file.WriteChunk(context.Background(), 1, []byte("Hello, World!"), 0)
+	suite.Require().NoError(err)
+
+	// Check only the written chunk is allocated on the upperlayer
+	ufile, err := suite.Upperlayer.GetFile(context.Background(), "FileA")
+	suite.Require().NoError(err)
+	info, err := ufile.GetInfo(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal(3*4096, info.Size)
+	suite.Require().Equal(4096, info.AllocatedSize)
+}
+
+// TestHasChunkData tests the HasChunkData method.
+func (suite *FileSuite) TestHasChunkData() {
+	// Create a file
+	f, err := suite.Directory.CreateFile(context.Background(), "FileA", info.File{
+		ChunkSize: 4096,
+	})
+	suite.Require().NoError(err)
+
+	// Resize the file and write on the last chunk
+	err = f.ResizeChunksNb(context.Background(), 2)
+	suite.Require().NoError(err)
+	_, err = f.WriteChunk(context.Background(), 1, []byte("Hello, World!"), 0)
+	suite.Require().NoError(err)
+
+	// Check the chunks
+	hasData, err := f.(storage.SparseFile).HasChunkData(context.Background(), 0)
+	suite.Require().NoError(err)
+	suite.Require().False(hasData)
+	hasData, err = f.(storage.SparseFile).HasChunkData(context.Background(), 1)
+	suite.Require().NoError(err)
+	suite.Require().True(hasData)
+}
@@ -0,0 +1,90 @@
+package layer
+
+import (
+	"path"
+	"strings"
+	"sync"
+)
+
+// paths keeps the current path of the directories and files, shared by all
+// their handles, so the handles opened before a rename follow it.
+type paths struct {
+	mutex sync.Mutex
+	nodes map[string]*nodePath
+}
+
+// nodePath is the current path of a directory or file.
+type nodePath struct {
+	paths *paths
+	path  string
+
+	// writeBackMutex serializes the writes back of the chunks of the file
+	// with their flush, so a flush can't clean a chunk being written.
+	writeBackMutex sync.Mutex
+}
+
+func newPaths() *paths {
+	return &paths{
+		nodes: make(map[string]*nodePath),
+	}
+}
+
+// get returns the path of the directory or file, creating it if needed.
+func (p *paths) get(path string) *nodePath {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	np, ok := p.nodes[path]
+	if !ok {
+		np = &nodePath{paths: p, path: path}
+		p.nodes[path] = np
+	}
+	return np
+}
+
+// move changes the path of the directory or file, and of every element under
+// it, replacing the ones at the destination.
+func (p *paths) move(from, to string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	moved := make(map[string]*nodePath)
+	for k, np := range p.nodes {
+		if isInPath(k, from) {
+			moved[to+strings.TrimPrefix(k, from)] = np
+			delete(p.nodes, k)
+		} else if isInPath(k, to) {
+			delete(p.nodes, k)
+		}
+	}
+
+	for k, np := range moved {
+		np.path = k
+		p.nodes[k] = np
+	}
+}
+
+// remove forgets the directory or file, and every element under it.
+func (p *paths) remove(path string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for k := range p.nodes {
+		if isInPath(k, path) {
+			delete(p.nodes, k)
+		}
+	}
+}
+
+// String returns the current path.
+func (np *nodePath) String() string {
+	np.paths.mutex.Lock()
+	defer np.paths.mutex.Unlock()
+
+	return np.path
+}
+
+// child returns the path of a child of the directory.
+func (np *nodePath) child(name string) *nodePath {
+	return np.paths.get(path.Join(np.String(), name))
+}
@@ -0,0 +1,237 @@
+package layer
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+// dirtyFile is a file with chunks or attributes written on the upperlayer
+// only.
+type dirtyFile struct {
+	file       *file
+	chunks     map[int]struct{}
+	attributes bool
+}
+
+// writeBack keeps track of the dirty chunks and flushes them to the
+// underlayer in the background.
+type writeBack struct {
+	// flushMutex serializes the flushes, so a sync waits for the flush
+	// of the same chunks in the background.
+	flushMutex sync.Mutex
+
+	filesMutex sync.Mutex
+	files      map[string]*dirtyFile
+}
+
+func newWriteBack(ctx context.Context, interval time.Duration) *writeBack {
+	wb := &writeBack{
+		files: make(map[string]*dirtyFile),
+	}
+
+	go wb.run(ctx, interval)
+
+	return wb
+}
+
+// run flushes the dirty chunks periodically, until the context is done.
+func (wb *writeBack) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// Errors are kept as dirty chunks, and returned on next sync
+			_ = wb.flush(ctx, "/")
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// markDirty marks a chunk as written on the upperlayer only, pinning it on
+// the upperlayer so it can't be dropped before being flushed.
+func (wb *writeBack) markDirty(ctx context.Context, f *file, index int) error {
+	wb.filesMutex.Lock()
+	defer wb.filesMutex.Unlock()
+
+	if p, ok := f.upperlayer.(storage.ChunkPinner); ok {
+		if err := p.PinChunk(ctx, index); err != nil {
+			return err
+		}
+	}
+
+	wb.getDirtyFile(f).chunks[index] = struct{}{}
+
+	return nil
+}
+
+// markAttributesDirty marks the attributes of the file as written on the
+// upperlayer only.
+func (wb *writeBack) markAttributesDirty(f *file) {
+	wb.filesMutex.Lock()
+	defer wb.filesMutex.Unlock()
+
+	wb.getDirtyFile(f).attributes = true
+}
+
+// getDirtyFile returns the dirty file, creating it if needed. The files must
+// be locked.
+func (wb *writeBack) getDirtyFile(f *file) *dirtyFile {
+	path := f.path.String()
+	df, ok := wb.files[path]
+	if !ok {
+		df = &dirtyFile{
+			file:   f,
+			chunks: make(map[int]struct{}),
+		}
+		wb.files[path] = df
+	}
+	return df
+}
+
+// markClean unpins a flushed chunk on the upperlayer, if it has not been
+// marked dirty again in the meantime.
+func (wb *writeBack) markClean(ctx context.Context, f *file, index int) error {
+	wb.filesMutex.Lock()
+	defer wb.filesMutex.Unlock()
+
+	if df, ok := wb.files[f.path.String()]; ok {
+		if _, ok := df.chunks[index]; ok {
+			return nil
+		}
+	}
+
+	if p, ok := f.upperlayer.(storage.ChunkPinner); ok {
+		return p.UnpinChunk(ctx, index)
+	}
+	return nil
+}
+
+// take removes the dirty files matching the path, which is either a file or
+// a directory, and returns them.
+func (wb *writeBack) take(path string) map[string]*dirtyFile {
+	wb.filesMutex.Lock()
+	defer wb.filesMutex.Unlock()
+
+	files := make(map[string]*dirtyFile)
+	for p, df := range wb.files {
+		if isInPath(p, path) {
+			files[p] = df
+			delete(wb.files, p)
+		}
+	}
+
+	return files
+}
+
+// move moves the dirty files matching the path, which is either a file or a
+// directory, to another path, forgetting the ones already there.
+func (wb *writeBack) move(from, to string) {
+	wb.filesMutex.Lock()
+	defer wb.filesMutex.Unlock()
+
+	moved := make(map[string]*dirtyFile)
+	for p, df := range wb.files {
+		if isInPath(p, from) {
+			moved[to+strings.TrimPrefix(p, from)] = df
+			delete(wb.files, p)
+		} else if isInPath(p, to) {
+			delete(wb.files, p)
+		}
+	}
+
+	for p, df := range moved {
+		wb.files[p] = df
+	}
+}
+
+// flush writes the dirty chunks of the files matching the path to the
+// underlayer.
+func (wb *writeBack) flush(ctx context.Context, path string) error {
+	wb.flushMutex.Lock()
+	defer wb.flushMutex.Unlock()
+
+	var errs []error
+	for _, df := range wb.take(path) {
+		indexes := make([]int, 0, len(df.chunks))
+		for index := range df.chunks {
+			indexes = append(indexes, index)
+		}
+		slices.Sort(indexes)
+
+		for _, index := range indexes {
+			errs = append(errs, wb.flushChunk(ctx, df.file, index))
+		}
+
+		if df.attributes {
+			if err := df.file.flushAttributes(ctx); err != nil {
+				// Keep the attributes dirty
+				errs = append(errs, err)
+				wb.markAttributesDirty(df.file)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// flushChunk writes a dirty chunk to the underlayer then marks it clean, while
+// no write back can happen on the file.
+func (wb *writeBack) flushChunk(ctx context.Context, f *file, index int) error {
+	f.path.writeBackMutex.Lock()
+	defer f.path.writeBackMutex.Unlock()
+
+	if err := f.flushChunk(ctx, index); err != nil {
+		// Keep the chunk dirty
+		return errors.Join(err, wb.markDirty(ctx, f, index))
+	}
+	return wb.markClean(ctx, f, index)
+}
+
+// discard forgets the dirty chunks of the files matching the path.
+func (wb *writeBack) discard(path string) {
+	wb.take(path)
+}
+
+func isInPath(p, path string) bool {
+	return p == path || strings.HasPrefix(p, strings.TrimSuffix(path, "/")+"/")
+}
+
+// flushChunk writes a chunk from the upperlayer to the underlayer.
+func (f *file) flushChunk(ctx context.Context, index int) error {
+	// Get the chunk from the upperlayer
+	info, err := f.upperlayer.GetInfo(ctx)
+	if err != nil {
+		return err
+	}
+	data := make([]byte, info.ChunkSize)
+	read, err := f.upperlayer.ReadChunk(ctx, index, data, 0)
+	if err != nil {
+		return err
+	}
+
+	// Write it on the underlayer, or import it if it's not there
+	_, err = f.underlayer.WriteChunk(ctx, index, data[:read], 0)
+	if errors.Is(err, storage.ErrChunkNotFound) {
+		return f.underlayer.ImportChunk(ctx, index, data[:read])
+	}
+	return err
+}
+
+// flushAttributes writes the attributes from the upperlayer to the underlayer.
+func (f *file) flushAttributes(ctx context.Context) error {
+	info, err := f.upperlayer.GetInfo(ctx)
+	if err != nil {
+		return err
+	}
+
+	return f.underlayer.SetAttributes(ctx, info.Attributes)
+}
@@ -0,0 +1,266 @@
+package layer
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+	"github.com/lerenn/chonkfs/pkg/storage/mem"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestWriteBackSuite(t *testing.T) {
+	suite.Run(t, new(WriteBackSuite))
+}
+
+type WriteBackSuite struct {
+	Upperlayer storage.Directory
+	Underlayer storage.Directory
+	Cancel     context.CancelFunc
+	suite.Suite
+}
+
+func (suite *WriteBackSuite) SetupTest() {
+	suite.Upperlayer = mem.NewDirectory()
+	suite.Underlayer = mem.NewDirectory()
+}
+
+func (suite *WriteBackSuite) TearDownTest() {
+	if suite.Cancel != nil {
+		suite.Cancel()
+	}
+}
+
+func (suite *WriteBackSuite) newFile(interval time.Duration) (storage.Directory, storage.File) {
+	ctx, cancel := context.WithCancel(context.Background())
+	suite.Cancel = cancel
+
+	d, err := NewDirectory(suite.Upperlayer, suite.Underlayer, WithWriteBack(ctx, interval))
+	suite.Require().NoError(err)
+
+	f, err := d.CreateFile(context.Background(), "file", info.File{
+		ChunkSize: 4,
+	})
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.ResizeChunksNb(context.Background(), 2))
+
+	return d, f
+}
+
+func (suite *WriteBackSuite) readUnderlayer(name string, index int) string {
+	f, err := suite.Underlayer.GetFile(context.Background(), name)
+	suite.Require().NoError(err)
+
+	data := make([]byte, 4)
+	_, err = f.ReadChunk(context.Background(), index, data, 0)
+	suite.Require().NoError(err)
+	return string(data)
+}
+
+func (suite *WriteBackSuite) TestWriteChunkOnSync() {
+	_, f := suite.newFile(time.Hour)
+
+	// Write a chunk
+	_, err := f.WriteChunk(context.Background(), 1, []byte("ABCD"), 0)
+	suite.Require().NoError(err)
+
+	// Check it is not on the underlayer yet, but readable from the layer
+	suite.Require().Equal("\x00\x00\x00\x00", suite.readUnderlayer("file", 1))
+	data := make([]byte, 4)
+	_, err = f.ReadChunk(context.Background(), 1, data, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal("ABCD", string(data))
+
+	// Sync and check it is on the underlayer
+	suite.Require().NoError(f.(storage.Syncer).Sync(context.Background()))
+	suite.Require().Equal("ABCD", suite.readUnderlayer("file", 1))
+}
+
+func (suite *WriteBackSuite) TestSetAttributesOnSync() {
+	_, f := suite.newFile(time.Hour)
+
+	// Set the attributes
+	attr := info.Attributes{Mode: 0o600, UID: 1000}
+	suite.Require().NoError(f.SetAttributes(context.Background(), attr))
+
+	// Check they are not on the underlayer yet
+	uf, err := suite.Underlayer.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+	ufInfo, err := uf.GetInfo(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal(info.Attributes{}, ufInfo.Attributes)
+
+	// Sync and check they are on the underlayer
+	suite.Require().NoError(f.(storage.Syncer).Sync(context.Background()))
+	ufInfo, err = uf.GetInfo(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal(attr, ufInfo.Attributes)
+}
+
+func (suite *WriteBackSuite) TestWriteChunkInBackground() {
+	_, f := suite.newFile(time.Millisecond)
+
+	// Write a chunk
+	_, err := f.WriteChunk(context.Background(), 0, []byte("ABCD"), 0)
+	suite.Require().NoError(err)
+
+	// Check it is written on the underlayer
+	suite.Require().Eventually(func() bool {
+		return suite.readUnderlayer("file", 0) == "ABCD"
+	}, time.Second, time.Millisecond)
+}
+
+func (suite *WriteBackSuite) TestWriteChunkBeforeRename() {
+	d, f := suite.newFile(time.Hour)
+
+	// Write a chunk
+	_, err := f.WriteChunk(context.Background(), 0, []byte("ABCD"), 0)
+	suite.Require().NoError(err)
+
+	// Rename the file and check the chunk is on the underlayer
+	suite.Require().NoError(d.RenameFile(context.Background(), "file", d, "renamed", false))
+	suite.Require().Equal("ABCD", suite.readUnderlayer("renamed", 0))
+}
+
+func (suite *WriteBackSuite) TestWriteChunkAfterRename() {
+	d, f := suite.newFile(time.Hour)
+
+	// Rename the file while it is open, then write a chunk on it
+	suite.Require().NoError(d.RenameFile(context.Background(), "file", d, "renamed", false))
+	_, err := f.WriteChunk(context.Background(), 1, []byte("ABCD"), 0)
+	suite.Require().NoError(err)
+
+	// Sync the renamed file from a new handle and check it is on the underlayer
+	rf, err := d.GetFile(context.Background(), "renamed")
+	suite.Require().NoError(err)
+	suite.Require().NoError(rf.(storage.Syncer).Sync(context.Background()))
+	suite.Require().Equal("ABCD", suite.readUnderlayer("renamed", 1))
+
+	// Create a new file with the old name and write a chunk on it
+	nf, err := d.CreateFile(context.Background(), "file", info.File{ChunkSize: 4})
+	suite.Require().NoError(err)
+	suite.Require().NoError(nf.ResizeChunksNb(context.Background(), 1))
+	_, err = nf.WriteChunk(context.Background(), 0, []byte("EFGH"), 0)
+	suite.Require().NoError(err)
+
+	// Sync the new file and check its chunk did not go to the renamed one
+	suite.Require().NoError(nf.(storage.Syncer).Sync(context.Background()))
+	suite.Require().Equal("EFGH", suite.readUnderlayer("file", 0))
+	suite.Require().Equal("\x00\x00\x00\x00", suite.readUnderlayer("renamed", 0))
+}
+
+func (suite *WriteBackSuite) TestWriteChunkAfterDirectoryRename() {
+	ctx, cancel := context.WithCancel(context.Background())
+	suite.Cancel = cancel
+	d, err := NewDirectory(suite.Upperlayer, suite.Underlayer, WithWriteBack(ctx, time.Hour))
+	suite.Require().NoError(err)
+
+	// Create a file in a directory, and rename the directory while it is open
+	dir, err := d.CreateDirectory(context.Background(), "dir")
+	suite.Require().NoError(err)
+	suite.Require().NoError(d.RenameDirectory(context.Background(), "dir", d, "renamed", false))
+
+	// Create a file in it and write a chunk
+	f, err := dir.CreateFile(context.Background(), "file", info.File{ChunkSize: 4})
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.ResizeChunksNb(context.Background(), 1))
+	_, err = f.WriteChunk(context.Background(), 0, []byte("ABCD"), 0)
+	suite.Require().NoError(err)
+
+	// Sync the directory content and check the chunk is on the underlayer
+	rdir, err := d.GetDirectory(context.Background(), "renamed")
+	suite.Require().NoError(err)
+	rf, err := rdir.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+	suite.Require().NoError(rf.(storage.Syncer).Sync(context.Background()))
+	udir, err := suite.Underlayer.GetDirectory(context.Background(), "renamed")
+	suite.Require().NoError(err)
+	uf, err := udir.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+	data := make([]byte, 4)
+	_, err = uf.ReadChunk(context.Background(), 0, data, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal("ABCD", string(data))
+}
+
+func (suite *WriteBackSuite) TestDirtyChunksAreKeptOnUpperlayerCache() {
+	suite.Upperlayer = mem.NewDirectory(mem.WithMaxSize(4))
+	_, f := suite.newFile(time.Hour)
+
+	// Write more chunks than what the upperlayer can keep
+	_, err := f.WriteChunk(context.Background(), 0, []byte("ABCD"), 0)
+	suite.Require().NoError(err)
+	_, err = f.WriteChunk(context.Background(), 1, []byte("EFGH"), 0)
+	suite.Require().NoError(err)
+
+	// Sync and check they are on the underlayer
+	suite.Require().NoError(f.(storage.Syncer).Sync(context.Background()))
+	suite.Require().Equal("ABCD", suite.readUnderlayer("file", 0))
+	suite.Require().Equal("EFGH", suite.readUnderlayer("file", 1))
+}
+
+func (suite *WriteBackSuite) TestWriteChunkDuringFlush() {
+	upperlayer := &slowDirectory{Directory: mem.NewDirectory(), started: make(chan struct{})}
+	suite.Upperlayer = upperlayer
+	_, f := suite.newFile(time.Hour)
+
+	// Write a chunk and flush it
+	_, err := f.WriteChunk(context.Background(), 0, []byte("AAAA"), 0)
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.(storage.Syncer).Sync(context.Background()))
+
+	// Write it again, while it is flushed
+	upperlayer.slow.Store(true)
+	written := make(chan error)
+	go func() {
+		_, err := f.WriteChunk(context.Background(), 0, []byte("BBBB"), 0)
+		written <- err
+	}()
+	<-upperlayer.started
+	suite.Require().NoError(f.(storage.Syncer).Sync(context.Background()))
+	suite.Require().NoError(<-written)
+
+	// Check the new data is not lost
+	suite.Require().NoError(f.(storage.Syncer).Sync(context.Background()))
+	suite.Require().Equal("BBBB", suite.readUnderlayer("file", 0))
+}
+
+// slowDirectory is a directory whose files wait before writing a chunk once
+// slow is set, after signaling it has started.
+type slowDirectory struct {
+	storage.Directory
+	slow    atomic.Bool
+	started chan struct{}
+}
+
+func (d *slowDirectory) CreateFile(ctx context.Context, name string, fileInfo info.File) (storage.File, error) {
+	f, err := d.Directory.CreateFile(ctx, name, fileInfo)
+	if err != nil {
+		return nil, err
+	}
+	return &slowFile{File: f, directory: d}, nil
+}
+
+func (d *slowDirectory) GetFile(ctx context.Context, name string) (storage.File, error) {
+	f, err := d.Directory.GetFile(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return &slowFile{File: f, directory: d}, nil
+}
+
+type slowFile struct {
+	storage.File
+	directory *slowDirectory
+}
+
+func (f *slowFile) WriteChunk(ctx context.Context, index int, data []byte, offset int) (int, error) {
+	if f.directory.slow.CompareAndSwap(true, false) {
+		close(f.directory.started)
+		time.Sleep(100 * time.Millisecond)
+	}
+	return f.File.WriteChunk(ctx, index, data, offset)
+}
@@ -0,0 +1,119 @@
+package layer
+
+import (
+	"context"
+	"errors"
+
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+// The extended attributes are stored on the underlayer, and copied on the
+// upperlayer if it can store them, to be read from it. The entries that were
+// already on the upperlayer, like the root, can miss the attributes set
+// before: they are read from the underlayer then.
+
+func getXattr(ctx context.Context, upperlayer, underlayer storage.Xattrer, name string) ([]byte, error) {
+	value, err := upperlayer.GetXattr(ctx, name)
+	if errors.Is(err, storage.ErrXattrNotFound) || errors.Is(err, storage.ErrXattrNotSupported) {
+		return underlayer.GetXattr(ctx, name)
+	}
+	return value, err
+}
+
+func listXattrs(ctx context.Context, underlayer storage.Xattrer) ([]string, error) {
+	// The upperlayer can miss some of them, so they are listed from the
+	// underlayer
+	return underlayer.ListXattrs(ctx)
+}
+
+func setXattr(ctx context.Context, upperlayer, underlayer storage.Xattrer, name string, value []byte) error {
+	if err := underlayer.SetXattr(ctx, name, value); err != nil {
+		return err
+	}
+
+	err := upperlayer.SetXattr(ctx, name, value)
+	if err != nil && !errors.Is(err, storage.ErrXattrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+func removeXattr(ctx context.Context, upperlayer, underlayer storage.Xattrer, name string) error {
+	if err := underlayer.RemoveXattr(ctx, name); err != nil {
+		return err
+	}
+
+	// The upperlayer can miss the attribute, if it was not copied on it
+	err := upperlayer.RemoveXattr(ctx, name)
+	if err != nil && !errors.Is(err, storage.ErrXattrNotFound) && !errors.Is(err, storage.ErrXattrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// copyXattrs copies the extended attributes from the underlayer to the
+// upperlayer, when an entry is created on the upperlayer.
+func copyXattrs(ctx context.Context, upperlayer, underlayer storage.Xattrer) error {
+	names, err := underlayer.ListXattrs(ctx)
+	if errors.Is(err, storage.ErrXattrNotSupported) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		value, err := underlayer.GetXattr(ctx, name)
+		if err != nil {
+			return err
+		}
+
+		err = upperlayer.SetXattr(ctx, name, value)
+		if errors.Is(err, storage.ErrXattrNotSupported) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetXattr returns the value of an extended attribute.
+func (f *file) GetXattr(ctx context.Context, name string) ([]byte, error) {
+	return getXattr(ctx, f.upperlayer, f.underlayer, name)
+}
+
+// ListXattrs returns the names of the extended attributes.
+func (f *file) ListXattrs(ctx context.Context) ([]string, error) {
+	return listXattrs(ctx, f.underlayer)
+}
+
+// SetXattr creates or replaces an extended attribute on both layers, even
+// with write-back.
+func (f *file) SetXattr(ctx context.Context, name string, value []byte) error {
+	return setXattr(ctx, f.upperlayer, f.underlayer, name, value)
+}
+
+// RemoveXattr removes an extended attribute from both layers.
+func (f *file) RemoveXattr(ctx context.Context, name string) error {
+	return removeXattr(ctx, f.upperlayer, f.underlayer, name)
+}
+
+// GetXattr returns the value of an extended attribute.
+func (d *directory) GetXattr(ctx context.Context, name string) ([]byte, error) {
+	return getXattr(ctx, d.upperlayer, d.underlayer, name)
+}
+
+// ListXattrs returns the names of the extended attributes.
+func (d *directory) ListXattrs(ctx context.Context) ([]string, error) {
+	return listXattrs(ctx, d.underlayer)
+}
+
+// SetXattr creates or replaces an extended attribute on both layers.
+func (d *directory) SetXattr(ctx context.Context, name string, value []byte) error {
+	return setXattr(ctx, d.upperlayer, d.underlayer, name, value)
+}
+
+// RemoveXattr removes an extended attribute from both layers.
+func (d *directory) RemoveXattr(ctx context.Context, name string) error {
+	return removeXattr(ctx, d.upperlayer, d.underlayer, name)
+}
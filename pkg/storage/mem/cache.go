@@ -0,0 +1,89 @@
+package mem
+
+import (
+	"container/list"
+	"sync"
+)
+
+type cacheKey struct {
+	file  *file
+	index int
+}
+
+type cacheEntry struct {
+	key  cacheKey
+	size int
+}
+
+// cache keeps track of the chunks data held in memory, in order to evict the
+// least recently used ones when the size is over the maximum size.
+type cache struct {
+	maxSize int
+
+	mutex   sync.Mutex
+	size    int
+	lru     *list.List
+	entries map[cacheKey]*list.Element
+}
+
+func newCache(maxSize int) *cache {
+	return &cache{
+		maxSize: maxSize,
+		lru:     list.New(),
+		entries: make(map[cacheKey]*list.Element),
+	}
+}
+
+// use marks a chunk as the most recently used, with its size, and returns the
+// chunks that should be evicted.
+func (c *cache) use(f *file, index int, size int) []cacheKey {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// Add or update the chunk
+	key := cacheKey{file: f, index: index}
+	if e, ok := c.entries[key]; ok {
+		c.size += size - e.Value.(*cacheEntry).size
+		e.Value.(*cacheEntry).size = size
+		c.lru.MoveToFront(e)
+	} else {
+		c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, size: size})
+		c.size += size
+	}
+
+	// Get the chunks to evict, starting from the least recently used
+	var victims []cacheKey
+	for c.size > c.maxSize && c.lru.Len() > 1 {
+		e := c.lru.Back()
+		victims = append(victims, e.Value.(*cacheEntry).key)
+		c.removeElement(e)
+	}
+
+	return victims
+}
+
+// remove removes a chunk from the cache.
+func (c *cache) remove(f *file, index int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if e, ok := c.entries[cacheKey{file: f, index: index}]; ok {
+		c.removeElement(e)
+	}
+}
+
+// contains returns true if the chunk is in the cache.
+func (c *cache) contains(f *file, index int) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	_, ok := c.entries[cacheKey{file: f, index: index}]
+	return ok
+}
+
+func (c *cache) removeElement(e *list.Element) {
+	entry := e.Value.(*cacheEntry)
+	c.lru.Remove(e)
+	delete(c.entries, entry.key)
+	c.size -= entry.size
+}
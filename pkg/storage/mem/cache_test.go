@@ -0,0 +1,76 @@
+package mem
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestCacheSuite(t *testing.T) {
+	suite.Run(t, new(CacheSuite))
+}
+
+type CacheSuite struct {
+	File storage.File
+	suite.Suite
+}
+
+func (suite *CacheSuite) SetupTest() {
+	d := NewDirectory(WithMaxSize(8))
+
+	f, err := d.CreateFile(context.Background(), "file", info.File{
+		ChunkSize:     4,
+		ChunksCount:   3,
+		LastChunkSize: 4,
+	})
+	suite.Require().NoError(err)
+	suite.File = f
+}
+
+func (suite *CacheSuite) TestEvictLeastRecentlyUsed() {
+	// Import two chunks and use the first one
+	suite.Require().NoError(suite.File.ImportChunk(context.Background(), 0, []byte("AAAA")))
+	suite.Require().NoError(suite.File.ImportChunk(context.Background(), 1, []byte("BBBB")))
+	_, err := suite.File.ReadChunk(context.Background(), 0, make([]byte, 4), 0)
+	suite.Require().NoError(err)
+
+	// Import a third chunk
+	suite.Require().NoError(suite.File.ImportChunk(context.Background(), 2, []byte("CCCC")))
+
+	// Check the second chunk has been evicted
+	_, err = suite.File.ReadChunk(context.Background(), 1, make([]byte, 4), 0)
+	suite.Require().ErrorIs(err, storage.ErrChunkNotFound)
+	_, err = suite.File.ReadChunk(context.Background(), 0, make([]byte, 4), 0)
+	suite.Require().NoError(err)
+	_, err = suite.File.ReadChunk(context.Background(), 2, make([]byte, 4), 0)
+	suite.Require().NoError(err)
+
+	// Check the metadata is kept
+	fInfo, err := suite.File.GetInfo(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal(3, fInfo.ChunksCount)
+	suite.Require().Equal(12, fInfo.Size)
+}
+
+func (suite *CacheSuite) TestPinnedChunkIsNotEvicted() {
+	// Pin the first chunk and import all chunks
+	suite.Require().NoError(suite.File.(storage.ChunkPinner).PinChunk(context.Background(), 0))
+	suite.Require().NoError(suite.File.ImportChunk(context.Background(), 0, []byte("AAAA")))
+	suite.Require().NoError(suite.File.ImportChunk(context.Background(), 1, []byte("BBBB")))
+	suite.Require().NoError(suite.File.ImportChunk(context.Background(), 2, []byte("CCCC")))
+
+	// Check the pinned chunk is still there
+	_, err := suite.File.ReadChunk(context.Background(), 0, make([]byte, 4), 0)
+	suite.Require().NoError(err)
+
+	// Unpin it and import again the evicted chunk
+	suite.Require().NoError(suite.File.(storage.ChunkPinner).UnpinChunk(context.Background(), 0))
+	suite.Require().NoError(suite.File.ImportChunk(context.Background(), 1, []byte("BBBB")))
+
+	// Check the unpinned chunk is now evicted
+	_, err = suite.File.ReadChunk(context.Background(), 2, make([]byte, 4), 0)
+	suite.Require().ErrorIs(err, storage.ErrChunkNotFound)
+}
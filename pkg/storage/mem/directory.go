@@ -9,16 +9,60 @@ import (
 	"github.com/lerenn/chonkfs/pkg/storage"
 )
 
+type directoryOption func(dir *directory)
+
+// WithMaxSize is an option to limit the size of the chunks data kept in
+// memory, evicting the least recently used chunks when it is exceeded. The
+// evicted chunks are then not present, so this is meant for an upperlayer
+// caching an underlayer. A zero size keeps everything in memory.
+//
+//nolint:revive
+func WithMaxSize(size int) directoryOption {
+	if size <= 0 {
+		return func(_ *directory) {}
+	}
+
+	// Create it once, so it is shared by all directories and files
+	c := newCache(size)
+	return func(dir *directory) {
+		dir.cache = c
+	}
+}
+
 type directory struct {
 	directories map[string]storage.Directory
 	files       map[string]storage.File
+	attributes  info.Attributes
+	xattrs      xattrs
+
+	opts  []directoryOption
+	cache *cache
 }
 
 // NewDirectory creates a new directory.
-func NewDirectory() storage.Directory {
-	return &directory{
+func NewDirectory(opts ...directoryOption) storage.Directory {
+	d := &directory{
 		directories: make(map[string]storage.Directory),
 		files:       make(map[string]storage.File),
+		xattrs:      make(xattrs),
+		opts:        opts,
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
+}
+
+// uncache removes the chunks of the directory files from the cache.
+func (d *directory) uncache() {
+	for _, f := range d.files {
+		f.(*file).uncache()
+	}
+	for _, sd := range d.directories {
+		sd.(*directory).uncache()
 	}
 }
 
@@ -35,7 +79,7 @@ func (d *directory) CreateDirectory(_ context.Context, name string) (storage.Dir
 	}
 
 	// Create directory and store it
-	nd := NewDirectory()
+	nd := NewDirectory(d.opts...)
 	d.directories[name] = nd
 
 	return nd, nil
@@ -59,7 +103,34 @@ func (d *directory) GetDirectory(_ context.Context, name string) (storage.Direct
 
 // GetInfo returns the directory info.
 func (d *directory) GetInfo(_ context.Context) (info.Directory, error) {
-	return info.Directory{}, nil
+	return info.Directory{Attributes: d.attributes}, nil
+}
+
+// SetAttributes sets the directory attributes.
+func (d *directory) SetAttributes(_ context.Context, attr info.Attributes) error {
+	d.attributes = attr
+	return nil
+}
+
+// GetXattr returns the value of an extended attribute.
+func (d *directory) GetXattr(_ context.Context, name string) ([]byte, error) {
+	return d.xattrs.get(name)
+}
+
+// ListXattrs returns the names of the extended attributes.
+func (d *directory) ListXattrs(_ context.Context) ([]string, error) {
+	return d.xattrs.list(), nil
+}
+
+// SetXattr creates or replaces an extended attribute.
+func (d *directory) SetXattr(_ context.Context, name string, value []byte) error {
+	d.xattrs.set(name, value)
+	return nil
+}
+
+// RemoveXattr removes an extended attribute.
+func (d *directory) RemoveXattr(_ context.Context, name string) error {
+	return d.xattrs.remove(name)
 }
 
 // CreateFile creates a file.
@@ -75,7 +146,7 @@ func (d *directory) CreateFile(_ context.Context, name string, info info.File) (
 	}
 
 	// Create the file
-	f, err := newFile(info)
+	f, err := newFile(info, d.cache)
 	if err != nil {
 		return nil, err
 	}
@@ -120,6 +191,7 @@ func (d *directory) RemoveDirectory(_ context.Context, name string) error {
 	}
 
 	// Remove the directory
+	d.directories[name].(*directory).uncache()
 	delete(d.directories, name)
 
 	return nil
@@ -143,6 +215,7 @@ func (d *directory) RemoveFile(_ context.Context, name string) error {
 	}
 
 	// Remove the file
+	d.files[name].(*file).uncache()
 	delete(d.files, name)
 
 	return nil
@@ -169,6 +242,7 @@ func (d *directory) RenameFile(
 		}
 
 		// Delete directory
+		newParent.(*directory).directories[newName].(*directory).uncache()
 		delete(newParent.(*directory).directories, newName)
 	}
 
@@ -180,6 +254,7 @@ func (d *directory) RenameFile(
 		}
 
 		// Delete file
+		newParent.(*directory).files[newName].(*file).uncache()
 		delete(newParent.(*directory).files, newName)
 	}
 
@@ -211,6 +286,7 @@ func (d *directory) RenameDirectory(
 		}
 
 		// Delete directory
+		newParent.(*directory).directories[newName].(*directory).uncache()
 		delete(newParent.(*directory).directories, newName)
 	}
 
@@ -222,6 +298,7 @@ func (d *directory) RenameDirectory(
 		}
 
 		// Delete file
+		newParent.(*directory).files[newName].(*file).uncache()
 		delete(newParent.(*directory).files, newName)
 	}
 
@@ -3,23 +3,42 @@ package mem
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	"github.com/lerenn/chonkfs/pkg/info"
 	"github.com/lerenn/chonkfs/pkg/storage"
 )
 
+// chunk is a chunk of data. A chunk without data is a hole, that reads as
+// zeros and is allocated on its first write.
 type chunk struct {
 	Data []byte
 	Size int
 }
 
+func (c *chunk) isHole() bool {
+	return c.Data == nil
+}
+
+var (
+	_ storage.SparseFile  = (*file)(nil)
+	_ storage.ChunkPinner = (*file)(nil)
+)
+
 type file struct {
 	chunks        []*chunk
 	chunkSize     int
 	lastChunkSize int
+	attributes    info.Attributes
+	xattrs        xattrs
+
+	cache  *cache
+	pinned map[int]struct{}
+
+	mutex sync.Mutex
 }
 
-func newFile(info info.File) (*file, error) {
+func newFile(info info.File, cache *cache) (*file, error) {
 	// Check chunk size
 	if info.ChunkSize <= 0 {
 		return nil, fmt.Errorf("%w: %d", storage.ErrInvalidChunkSize, info.ChunkSize)
@@ -30,26 +49,105 @@ func newFile(info info.File) (*file, error) {
 		chunkSize:     info.ChunkSize,
 		chunks:        make([]*chunk, info.ChunksCount),
 		lastChunkSize: info.LastChunkSize,
+		attributes:    info.Attributes,
+		xattrs:        make(xattrs),
+		cache:         cache,
+		pinned:        make(map[int]struct{}),
 	}
 
 	return f, nil
 }
 
+// use marks the chunk as used in the cache, if there is one, and returns the
+// chunks to evict. The file must be locked.
+func (f *file) use(index int) []cacheKey {
+	if f.cache == nil {
+		return nil
+	}
+
+	// Pinned chunks and holes are not in the cache
+	c := f.chunks[index]
+	if _, ok := f.pinned[index]; ok || c == nil || c.isHole() {
+		return nil
+	}
+
+	return f.cache.use(f, index, len(c.Data))
+}
+
+// evictChunks removes the chunks data from their file, keeping the metadata.
+func evictChunks(victims []cacheKey) {
+	for _, v := range victims {
+		v.file.evict(v.index)
+	}
+}
+
+func (f *file) evict(index int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	// Check the chunk can still be evicted
+	if index >= len(f.chunks) || f.chunks[index] == nil || f.chunks[index].isHole() {
+		return
+	} else if _, ok := f.pinned[index]; ok {
+		return
+	} else if f.cache.contains(f, index) {
+		// It has been used since
+		return
+	}
+
+	f.chunks[index] = nil
+}
+
+// uncache removes the file chunks from the cache.
+func (f *file) uncache() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.cache == nil {
+		return
+	}
+
+	for i := range f.chunks {
+		f.cache.remove(f, i)
+	}
+}
+
 func (f *file) GetInfo(_ context.Context) (info.File, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
 	size := 0
 	chunksCount := len(f.chunks)
 	if chunksCount > 0 {
 		size = (chunksCount-1)*f.chunkSize + f.lastChunkSize
 	}
 
+	allocatedSize := 0
+	for _, c := range f.chunks {
+		if c != nil && !c.isHole() {
+			allocatedSize += c.Size
+		}
+	}
+
 	return info.File{
+		Attributes:    f.attributes,
 		Size:          size,
+		AllocatedSize: allocatedSize,
 		ChunkSize:     f.chunkSize,
 		ChunksCount:   chunksCount,
 		LastChunkSize: f.lastChunkSize,
 	}, nil
 }
 
+// SetAttributes sets the file attributes.
+func (f *file) SetAttributes(_ context.Context, attr info.Attributes) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.attributes = attr
+	return nil
+}
+
 func (f *file) checkReadWriteChunkParams(index int, offset int) error {
 	// Check if chunk index is correct
 	if index < 0 || index >= len(f.chunks) {
@@ -75,26 +173,77 @@ func (f *file) checkReadWriteChunkParams(index int, offset int) error {
 }
 
 func (f *file) WriteChunk(_ context.Context, index int, data []byte, offset int) (int, error) {
+	var victims []cacheKey
+	defer func() { evictChunks(victims) }()
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
 	// Check params
 	if err := f.checkReadWriteChunkParams(index, offset); err != nil {
 		return 0, err
 	}
 
+	// Allocate the chunk if this is a hole
+	c := f.chunks[index]
+	if c.isHole() {
+		c.Data = make([]byte, c.Size)
+	}
+
 	// Write data
-	return copy(f.chunks[index].Data[offset:], data), nil
+	written := copy(c.Data[offset:], data)
+	victims = f.use(index)
+	return written, nil
 }
 
 func (f *file) ReadChunk(_ context.Context, index int, data []byte, offset int) (int, error) {
+	var victims []cacheKey
+	defer func() { evictChunks(victims) }()
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
 	// Check params
 	if err := f.checkReadWriteChunkParams(index, offset); err != nil {
 		return 0, err
 	}
 
+	// Read zeros if this is a hole
+	c := f.chunks[index]
+	if c.isHole() {
+		if len(data) > c.Size-offset {
+			data = data[:c.Size-offset]
+		}
+		clear(data)
+		return len(data), nil
+	}
+
 	// Read data
-	return copy(data, f.chunks[index].Data[offset:]), nil
+	victims = f.use(index)
+	return copy(data, c.Data[offset:]), nil
+}
+
+func (f *file) HasChunkData(_ context.Context, index int) (bool, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	// Check if chunk index is correct
+	if index < 0 || index >= len(f.chunks) {
+		return false, fmt.Errorf("%w: %d", storage.ErrInvalidChunkNb, index)
+	}
+
+	// Check if the chunk is present
+	if f.chunks[index] == nil {
+		return false, fmt.Errorf("%w: %d", storage.ErrChunkNotFound, index)
+	}
+
+	return !f.chunks[index].isHole(), nil
 }
 
 func (f *file) ResizeChunksNb(_ context.Context, size int) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
 	// Check size is correct
 	if size < 0 {
 		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkNb, size)
@@ -107,10 +256,9 @@ func (f *file) ResizeChunksNb(_ context.Context, size int) error {
 
 	// Resize chunks
 	if size > len(f.chunks) {
-		// Add chunks
+		// Add chunks as holes
 		for i := len(f.chunks); i < size; i++ {
 			f.chunks = append(f.chunks, &chunk{
-				Data: make([]byte, f.chunkSize),
 				Size: f.chunkSize,
 			})
 		}
@@ -119,6 +267,12 @@ func (f *file) ResizeChunksNb(_ context.Context, size int) error {
 		f.lastChunkSize = f.chunkSize
 	} else {
 		// Remove chunks
+		for i := size; i < len(f.chunks); i++ {
+			delete(f.pinned, i)
+			if f.cache != nil {
+				f.cache.remove(f, i)
+			}
+		}
 		f.chunks = f.chunks[:size]
 	}
 
@@ -126,6 +280,12 @@ func (f *file) ResizeChunksNb(_ context.Context, size int) error {
 }
 
 func (f *file) ResizeLastChunk(_ context.Context, size int) (changed int, err error) {
+	var victims []cacheKey
+	defer func() { evictChunks(victims) }()
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
 	// Check size is correct
 	if size < 0 || size > f.chunkSize {
 		return 0, fmt.Errorf("%w: %d", storage.ErrInvalidChunkSize, size)
@@ -142,24 +302,33 @@ func (f *file) ResizeLastChunk(_ context.Context, size int) (changed int, err er
 		return 0, fmt.Errorf("%w", storage.ErrChunkNotFound)
 	}
 
-	// Resize last chunk
+	// Resize last chunk data, if this is not a hole
 	oldSize := lastChunk.Size
-	if size > oldSize {
-		// Add data
-		lastChunk.Data = append(lastChunk.Data, make([]byte, size-oldSize)...)
-	} else {
-		// Remove data
-		lastChunk.Data = lastChunk.Data[:size]
+	if !lastChunk.isHole() {
+		if size > oldSize {
+			// Add data
+			lastChunk.Data = append(lastChunk.Data, make([]byte, size-oldSize)...)
+		} else {
+			// Remove data
+			lastChunk.Data = lastChunk.Data[:size]
+		}
 	}
 
 	// Set size
 	lastChunk.Size = size
 	f.lastChunkSize = size
+	victims = f.use(len(f.chunks) - 1)
 
 	return size - oldSize, nil
 }
 
 func (f *file) ImportChunk(_ context.Context, index int, data []byte) error {
+	var victims []cacheKey
+	defer func() { evictChunks(victims) }()
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
 	// Check if chunk index is correct
 	if index < 0 || index >= len(f.chunks) {
 		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkNb, index)
@@ -186,5 +355,78 @@ func (f *file) ImportChunk(_ context.Context, index int, data []byte) error {
 		f.lastChunkSize = len(data)
 	}
 
+	victims = f.use(index)
 	return nil
 }
+
+// PinChunk prevents the chunk from being evicted from the cache.
+func (f *file) PinChunk(_ context.Context, index int) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	// Check if chunk index is correct
+	if index < 0 || index >= len(f.chunks) {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkNb, index)
+	}
+
+	// Pin it and remove it from the cache
+	f.pinned[index] = struct{}{}
+	if f.cache != nil {
+		f.cache.remove(f, index)
+	}
+
+	return nil
+}
+
+// UnpinChunk allows the chunk to be evicted from the cache again.
+func (f *file) UnpinChunk(_ context.Context, index int) error {
+	var victims []cacheKey
+	defer func() { evictChunks(victims) }()
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	// Check if chunk index is correct
+	if index < 0 || index >= len(f.chunks) {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkNb, index)
+	}
+
+	// Unpin it and add it back to the cache
+	delete(f.pinned, index)
+	victims = f.use(index)
+
+	return nil
+}
+
+// GetXattr returns the value of an extended attribute.
+func (f *file) GetXattr(_ context.Context, name string) ([]byte, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.xattrs.get(name)
+}
+
+// ListXattrs returns the names of the extended attributes.
+func (f *file) ListXattrs(_ context.Context) ([]string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.xattrs.list(), nil
+}
+
+// SetXattr creates or replaces an extended attribute.
+func (f *file) SetXattr(_ context.Context, name string, value []byte) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.xattrs.set(name, value)
+	return nil
+}
+
+// RemoveXattr removes an extended attribute.
+func (f *file) RemoveXattr(_ context.Context, name string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.xattrs.remove(name)
+}
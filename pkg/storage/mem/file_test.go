@@ -1,8 +1,11 @@
 package mem
 
 import (
+	"context"
 	"testing"
 
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
 	"github.com/lerenn/chonkfs/pkg/storage/test"
 	"github.com/stretchr/testify/suite"
 )
@@ -18,3 +21,52 @@ type FileSuite struct {
 func (suite *FileSuite) SetupTest() {
 	suite.Directory = NewDirectory()
 }
+
+func (suite *FileSuite) TestResizeChunksNbCreatesHoles() {
+	f, err := suite.Directory.CreateFile(context.Background(), "file", info.File{
+		ChunkSize: 4096,
+	})
+	suite.Require().NoError(err)
+
+	// Resize to have chunks
+	err = f.ResizeChunksNb(context.Background(), 3)
+	suite.Require().NoError(err)
+
+	// Check nothing is allocated
+	fInfo, err := f.GetInfo(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal(3*4096, fInfo.Size)
+	suite.Require().Equal(0, fInfo.AllocatedSize)
+
+	// Write on a chunk and check it is allocated
+	_, err = f.WriteChunk(context.Background(), 1, []byte("Hello"), 0)
+	suite.Require().NoError(err)
+	fInfo, err = f.GetInfo(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal(4096, fInfo.AllocatedSize)
+}
+
+func (suite *FileSuite) TestHasChunkData() {
+	f, err := suite.Directory.CreateFile(context.Background(), "file", info.File{
+		ChunkSize:     4096,
+		ChunksCount:   1,
+		LastChunkSize: 4096,
+	})
+	suite.Require().NoError(err)
+
+	// Check a chunk that is not present
+	_, err = f.(storage.SparseFile).HasChunkData(context.Background(), 0)
+	suite.Require().ErrorIs(err, storage.ErrChunkNotFound)
+
+	// Import a chunk and add a hole
+	suite.Require().NoError(f.ImportChunk(context.Background(), 0, make([]byte, 4096)))
+	suite.Require().NoError(f.ResizeChunksNb(context.Background(), 2))
+
+	// Check the chunks
+	hasData, err := f.(storage.SparseFile).HasChunkData(context.Background(), 0)
+	suite.Require().NoError(err)
+	suite.Require().True(hasData)
+	hasData, err = f.(storage.SparseFile).HasChunkData(context.Background(), 1)
+	suite.Require().NoError(err)
+	suite.Require().False(hasData)
+}
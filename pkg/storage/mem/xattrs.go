@@ -0,0 +1,36 @@
+package mem
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+// xattrs are the extended attributes of a file or a directory.
+type xattrs map[string][]byte
+
+func (x xattrs) get(name string) ([]byte, error) {
+	value, ok := x[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", storage.ErrXattrNotFound, name)
+	}
+	return slices.Clone(value), nil
+}
+
+func (x xattrs) list() []string {
+	return slices.Sorted(maps.Keys(x))
+}
+
+func (x xattrs) set(name string, value []byte) {
+	x[name] = slices.Clone(value)
+}
+
+func (x xattrs) remove(name string) error {
+	if _, ok := x[name]; !ok {
+		return fmt.Errorf("%w: %q", storage.ErrXattrNotFound, name)
+	}
+	delete(x, name)
+	return nil
+}
@@ -0,0 +1,305 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+var (
+	// ErrInvalidQuorum happens when the write quorum is not between one and
+	// the number of replicas.
+	ErrInvalidQuorum = errors.New("invalid write quorum")
+	// ErrNotMirror happens when a mirror operation is called on another
+	// storage.
+	ErrNotMirror = errors.New("not a mirror storage")
+)
+
+var _ storage.Directory = (*directory)(nil)
+
+type directoryOption func(dir *directory)
+
+// WithWriteQuorum is an option to consider a modification successful when it
+// succeeded on at least this number of replicas. By default, it should
+// succeed on all replicas.
+//
+//nolint:revive
+func WithWriteQuorum(quorum int) directoryOption {
+	return func(dir *directory) {
+		dir.quorum = quorum
+	}
+}
+
+// WithRepair is an option to repair the replicas at the given interval,
+// until the context is done. A zero interval disables it.
+//
+//nolint:revive
+func WithRepair(ctx context.Context, interval time.Duration) directoryOption {
+	return func(dir *directory) {
+		dir.repairCtx = ctx
+		dir.repairInterval = interval
+	}
+}
+
+type directory struct {
+	replicas []storage.Directory
+	quorum   int
+	path     string
+	stale    *staleChunks
+
+	opts           []directoryOption
+	repairCtx      context.Context //nolint:containedctx
+	repairInterval time.Duration
+}
+
+// NewDirectory creates a new directory representation, mirroring every
+// modification on the replicas and reading from the first one available.
+func NewDirectory(replicas []storage.Directory, opts ...directoryOption) (storage.Directory, error) {
+	if len(replicas) == 0 {
+		return nil, ErrNoReplica
+	}
+
+	// Create the directory
+	d := newDirectory(replicas, opts...)
+	if d.quorum < 1 || d.quorum > len(replicas) {
+		return nil, fmt.Errorf("%w: %d for %d replicas", ErrInvalidQuorum, d.quorum, len(replicas))
+	}
+
+	// Repair the replicas in the background if needed
+	if d.repairInterval > 0 {
+		go d.runRepair(d.repairCtx, d.repairInterval)
+	}
+
+	return d, nil
+}
+
+func newDirectory(replicas []storage.Directory, opts ...directoryOption) *directory {
+	// Create a default directory
+	d := &directory{
+		replicas: replicas,
+		quorum:   len(replicas),
+		path:     "/",
+		stale:    newStaleChunks(),
+		opts:     opts,
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
+}
+
+func (d *directory) newChildDirectory(replicas []storage.Directory, name string) *directory {
+	child := newDirectory(replicas, d.opts...)
+	child.path = path.Join(d.path, name)
+	child.stale = d.stale
+	return child
+}
+
+func (d *directory) newChildFile(replicas []storage.File, name string) *file {
+	return newFile(replicas, d.quorum, path.Join(d.path, name), d.stale)
+}
+
+// CreateDirectory creates a directory.
+func (d *directory) CreateDirectory(ctx context.Context, name string) (storage.Directory, error) {
+	children, _, err := fanOut(d.replicas, d.quorum, func(_ int, r storage.Directory) (storage.Directory, error) {
+		return r.CreateDirectory(ctx, name)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return d.newChildDirectory(children, name), nil
+}
+
+// GetDirectory returns a directory.
+func (d *directory) GetDirectory(ctx context.Context, name string) (storage.Directory, error) {
+	children, err := fromAll(d.replicas, func(r storage.Directory) (storage.Directory, error) {
+		return r.GetDirectory(ctx, name)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return d.newChildDirectory(children, name), nil
+}
+
+// GetInfo returns the directory info.
+func (d *directory) GetInfo(ctx context.Context) (info.Directory, error) {
+	return firstHealthy(d.replicas, func(r storage.Directory) (info.Directory, error) {
+		return r.GetInfo(ctx)
+	})
+}
+
+// SetAttributes sets the directory attributes, on the replicas.
+func (d *directory) SetAttributes(ctx context.Context, attr info.Attributes) error {
+	_, _, err := fanOut(d.replicas, d.quorum, func(_ int, r storage.Directory) (struct{}, error) {
+		return struct{}{}, r.SetAttributes(ctx, attr)
+	})
+	return err
+}
+
+// CreateFile creates a file.
+func (d *directory) CreateFile(ctx context.Context, name string, info info.File) (storage.File, error) {
+	children, _, err := fanOut(d.replicas, d.quorum, func(_ int, r storage.Directory) (storage.File, error) {
+		return r.CreateFile(ctx, name, info)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return d.newChildFile(children, name), nil
+}
+
+// GetFile returns a file.
+func (d *directory) GetFile(ctx context.Context, name string) (storage.File, error) {
+	children, err := fromAll(d.replicas, func(r storage.Directory) (storage.File, error) {
+		return r.GetFile(ctx, name)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return d.newChildFile(children, name), nil
+}
+
+// ListFiles returns a map of files, present on any replica.
+func (d *directory) ListFiles(ctx context.Context) (map[string]storage.File, error) {
+	lists, err := fromAll(d.replicas, func(r storage.Directory) (map[string]storage.File, error) {
+		return r.ListFiles(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]storage.File)
+	for name := range union(lists) {
+		children := make([]storage.File, len(lists))
+		for i, l := range lists {
+			children[i] = l[name]
+		}
+		files[name] = d.newChildFile(children, name)
+	}
+
+	return files, nil
+}
+
+// RemoveDirectory removes a directory.
+func (d *directory) RemoveDirectory(ctx context.Context, name string) error {
+	_, _, err := fanOut(d.replicas, d.quorum, func(_ int, r storage.Directory) (struct{}, error) {
+		return struct{}{}, r.RemoveDirectory(ctx, name)
+	})
+	if err != nil {
+		return err
+	}
+
+	d.stale.forget(path.Join(d.path, name))
+	return nil
+}
+
+// ListDirectories returns a map of directories, present on any replica.
+func (d *directory) ListDirectories(ctx context.Context) (map[string]storage.Directory, error) {
+	lists, err := fromAll(d.replicas, func(r storage.Directory) (map[string]storage.Directory, error) {
+		return r.ListDirectories(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := make(map[string]storage.Directory)
+	for name := range union(lists) {
+		children := make([]storage.Directory, len(lists))
+		for i, l := range lists {
+			children[i] = l[name]
+		}
+		dirs[name] = d.newChildDirectory(children, name)
+	}
+
+	return dirs, nil
+}
+
+// RemoveFile removes a file.
+func (d *directory) RemoveFile(ctx context.Context, name string) error {
+	_, _, err := fanOut(d.replicas, d.quorum, func(_ int, r storage.Directory) (struct{}, error) {
+		return struct{}{}, r.RemoveFile(ctx, name)
+	})
+	if err != nil {
+		return err
+	}
+
+	d.stale.forget(path.Join(d.path, name))
+	return nil
+}
+
+// RenameFile renames a file.
+func (d *directory) RenameFile(
+	ctx context.Context,
+	name string,
+	newParent storage.Directory,
+	newName string,
+	noReplace bool,
+) error {
+	np, ok := newParent.(*directory)
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrNotMirror, newParent)
+	}
+
+	_, _, err := fanOut(d.replicas, d.quorum, func(i int, r storage.Directory) (struct{}, error) {
+		if np.replicas[i] == nil {
+			return struct{}{}, ErrNoReplica
+		}
+		return struct{}{}, r.RenameFile(ctx, name, np.replicas[i], newName, noReplace)
+	})
+	if err != nil {
+		return err
+	}
+
+	d.stale.move(path.Join(d.path, name), path.Join(np.path, newName))
+	return nil
+}
+
+// RenameDirectory renames a directory.
+func (d *directory) RenameDirectory(
+	ctx context.Context,
+	name string,
+	newParent storage.Directory,
+	newName string,
+	noReplace bool,
+) error {
+	np, ok := newParent.(*directory)
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrNotMirror, newParent)
+	}
+
+	_, _, err := fanOut(d.replicas, d.quorum, func(i int, r storage.Directory) (struct{}, error) {
+		if np.replicas[i] == nil {
+			return struct{}{}, ErrNoReplica
+		}
+		return struct{}{}, r.RenameDirectory(ctx, name, np.replicas[i], newName, noReplace)
+	})
+	if err != nil {
+		return err
+	}
+
+	d.stale.move(path.Join(d.path, name), path.Join(np.path, newName))
+	return nil
+}
+
+// union returns the names present in any of the maps.
+func union[T any](maps []map[string]T) map[string]struct{} {
+	names := make(map[string]struct{})
+	for _, m := range maps {
+		for name := range m {
+			names[name] = struct{}{}
+		}
+	}
+
+	return names
+}
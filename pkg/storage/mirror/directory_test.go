@@ -0,0 +1,24 @@
+package mirror
+
+import (
+	"testing"
+
+	"github.com/lerenn/chonkfs/pkg/storage"
+	"github.com/lerenn/chonkfs/pkg/storage/mem"
+	"github.com/lerenn/chonkfs/pkg/storage/test"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestDirectorySuite(t *testing.T) {
+	suite.Run(t, new(DirectorySuite))
+}
+
+type DirectorySuite struct {
+	test.DirectorySuite
+}
+
+func (suite *DirectorySuite) SetupTest() {
+	d, err := NewDirectory([]storage.Directory{mem.NewDirectory(), mem.NewDirectory()})
+	suite.Require().NoError(err)
+	suite.Directory = d
+}
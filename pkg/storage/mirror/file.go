@@ -0,0 +1,162 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+var (
+	_ storage.SparseFile = (*file)(nil)
+	_ storage.Syncer     = (*file)(nil)
+)
+
+type file struct {
+	replicas []storage.File
+	quorum   int
+	path     string
+	stale    *staleChunks
+}
+
+func newFile(replicas []storage.File, quorum int, path string, stale *staleChunks) *file {
+	return &file{
+		replicas: replicas,
+		quorum:   quorum,
+		path:     path,
+		stale:    stale,
+	}
+}
+
+// upToDate returns the replicas, without the ones that are stale for the
+// chunk or for the metadata.
+func (f *file) upToDate(index int) []storage.File {
+	replicas := make([]storage.File, len(f.replicas))
+	for i, r := range f.replicas {
+		if !f.stale.isStale(f.path, index, i) && !f.stale.isStale(f.path, metadataIndex, i) {
+			replicas[i] = r
+		}
+	}
+
+	return replicas
+}
+
+// fanOutChunk runs the function on the replicas like fanOut, recording the
+// replicas that failed as stale for the chunk, or for the metadata with
+// metadataIndex, when the write quorum is met.
+func fanOutChunk[T any](f *file, index int, fn func(r storage.File) (T, error)) ([]T, int, error) {
+	errs := make([]error, len(f.replicas))
+	results, first, err := fanOut(f.replicas, f.quorum, func(i int, r storage.File) (T, error) {
+		res, err := fn(r)
+		errs[i] = err
+		return res, err
+	})
+	if err != nil {
+		return nil, -1, err
+	}
+
+	for i, err := range errs {
+		if err != nil {
+			f.stale.add(f.path, index, i)
+		}
+	}
+
+	return results, first, nil
+}
+
+// GetInfo returns the file info, from the first replica whose metadata is up
+// to date.
+func (f *file) GetInfo(ctx context.Context) (info.File, error) {
+	return firstHealthy(f.upToDate(metadataIndex), func(r storage.File) (info.File, error) {
+		return r.GetInfo(ctx)
+	})
+}
+
+// SetAttributes sets the file attributes, on the replicas. The replicas that
+// failed while the write quorum is met are not read from until they are
+// repaired.
+func (f *file) SetAttributes(ctx context.Context, attr info.Attributes) error {
+	_, _, err := fanOutChunk(f, metadataIndex, func(r storage.File) (struct{}, error) {
+		return struct{}{}, r.SetAttributes(ctx, attr)
+	})
+	return err
+}
+
+// ReadChunk reads _ from a chunk, on the first up to date replica that has it.
+func (f *file) ReadChunk(ctx context.Context, index int, data []byte, offset int) (int, error) {
+	return firstHealthy(f.upToDate(index), func(r storage.File) (int, error) {
+		return r.ReadChunk(ctx, index, data, offset)
+	})
+}
+
+// HasChunkData returns true if the chunk holds data, false if this is a hole,
+// on the first up to date replica that has it. Any chunk is considered holding
+// data if a replica cannot tell.
+func (f *file) HasChunkData(ctx context.Context, index int) (bool, error) {
+	return firstHealthy(f.upToDate(index), func(r storage.File) (bool, error) {
+		sf, ok := r.(storage.SparseFile)
+		if !ok {
+			return true, nil
+		}
+		return sf.HasChunkData(ctx, index)
+	})
+}
+
+// WriteChunk writes _ to a chunk. The replicas that failed while the write
+// quorum is met are not read from for this chunk until it is repaired.
+func (f *file) WriteChunk(ctx context.Context, index int, data []byte, offset int) (int, error) {
+	written, i, err := fanOutChunk(f, index, func(r storage.File) (int, error) {
+		return r.WriteChunk(ctx, index, data, offset)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return written[i], nil
+}
+
+// ImportChunk imports a chunk, like WriteChunk does.
+func (f *file) ImportChunk(ctx context.Context, index int, data []byte) error {
+	_, _, err := fanOutChunk(f, index, func(r storage.File) (struct{}, error) {
+		return struct{}{}, r.ImportChunk(ctx, index, data)
+	})
+	return err
+}
+
+// ResizeChunksNb resizes the number of chunks, like SetAttributes does.
+func (f *file) ResizeChunksNb(ctx context.Context, size int) error {
+	_, _, err := fanOutChunk(f, metadataIndex, func(r storage.File) (struct{}, error) {
+		return struct{}{}, r.ResizeChunksNb(ctx, size)
+	})
+	if err != nil {
+		return err
+	}
+
+	f.stale.truncate(f.path, size)
+	return nil
+}
+
+// ResizeLastChunk resizes the last chunk, like SetAttributes does.
+func (f *file) ResizeLastChunk(ctx context.Context, size int) (int, error) {
+	changed, i, err := fanOutChunk(f, metadataIndex, func(r storage.File) (int, error) {
+		return r.ResizeLastChunk(ctx, size)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return changed[i], nil
+}
+
+// Sync syncs the replicas that keep data before writing it.
+func (f *file) Sync(ctx context.Context) error {
+	var errs []error
+	for _, r := range f.replicas {
+		if s, ok := r.(storage.Syncer); ok {
+			errs = append(errs, s.Sync(ctx))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,102 @@
+package mirror
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+	"github.com/lerenn/chonkfs/pkg/storage/mem"
+	"github.com/lerenn/chonkfs/pkg/storage/readonly"
+	"github.com/lerenn/chonkfs/pkg/storage/test"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestFileSuite(t *testing.T) {
+	suite.Run(t, new(FileSuite))
+}
+
+type FileSuite struct {
+	Replicas []storage.Directory
+	test.FileSuite
+}
+
+func (suite *FileSuite) SetupTest() {
+	suite.Replicas = []storage.Directory{mem.NewDirectory(), mem.NewDirectory()}
+
+	d, err := NewDirectory(suite.Replicas)
+	suite.Require().NoError(err)
+	suite.Directory = d
+}
+
+func (suite *FileSuite) readReplica(replica, index int) (string, error) {
+	f, err := suite.Replicas[replica].GetFile(context.Background(), "file")
+	if err != nil {
+		return "", err
+	}
+
+	data := make([]byte, 4)
+	_, err = f.ReadChunk(context.Background(), index, data, 0)
+	return string(data), err
+}
+
+func (suite *FileSuite) TestWriteOnAllReplicas() {
+	f, err := suite.Directory.CreateFile(context.Background(), "file", info.File{ChunkSize: 4})
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.ResizeChunksNb(context.Background(), 1))
+	_, err = f.WriteChunk(context.Background(), 0, []byte("ABCD"), 0)
+	suite.Require().NoError(err)
+
+	for i := range suite.Replicas {
+		data, err := suite.readReplica(i, 0)
+		suite.Require().NoError(err)
+		suite.Require().Equal("ABCD", data)
+	}
+}
+
+func (suite *FileSuite) TestReadFromHealthyReplica() {
+	// Import a chunk on the second replica only
+	f, err := suite.Directory.CreateFile(context.Background(), "file", info.File{
+		ChunkSize:     4,
+		ChunksCount:   1,
+		LastChunkSize: 4,
+	})
+	suite.Require().NoError(err)
+	rf, err := suite.Replicas[1].GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+	suite.Require().NoError(rf.ImportChunk(context.Background(), 0, []byte("ABCD")))
+
+	// Read it through the mirror
+	data := make([]byte, 4)
+	_, err = f.ReadChunk(context.Background(), 0, data, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal("ABCD", string(data))
+}
+
+func (suite *FileSuite) TestWriteQuorum() {
+	// Create a file on both replicas, then make the second one read-only
+	_, err := suite.Directory.CreateFile(context.Background(), "file", info.File{ChunkSize: 4})
+	suite.Require().NoError(err)
+	replicas := []storage.Directory{suite.Replicas[0], readonly.NewDirectory(suite.Replicas[1])}
+
+	// Check the write fails without quorum
+	d, err := NewDirectory(replicas)
+	suite.Require().NoError(err)
+	f, err := d.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+	suite.Require().ErrorIs(f.ResizeChunksNb(context.Background(), 1), ErrQuorumNotReached)
+
+	// Check it succeeds with a quorum of one
+	d, err = NewDirectory(replicas, WithWriteQuorum(1))
+	suite.Require().NoError(err)
+	f, err = d.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.ResizeChunksNb(context.Background(), 2))
+}
+
+func (suite *FileSuite) TestInvalidQuorum() {
+	_, err := NewDirectory(suite.Replicas, WithWriteQuorum(3))
+	suite.Require().ErrorIs(err, ErrInvalidQuorum)
+	_, err = NewDirectory(nil)
+	suite.Require().ErrorIs(err, ErrNoReplica)
+}
@@ -0,0 +1,236 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+// Repair creates on every replica of the mirror the directories, files and
+// chunks that are present on another replica, importing the missing chunks
+// from the first replica that has them. The chunks that a replica failed to
+// write are imported again from an up to date replica, as well as the metadata
+// of the files that a replica failed to update. Other chunks present on every
+// replica are not compared.
+func Repair(ctx context.Context, d storage.Directory) error {
+	md, ok := d.(*directory)
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrNotMirror, d)
+	}
+
+	return md.repair(ctx)
+}
+
+// runRepair repairs the replicas periodically, until the context is done.
+func (d *directory) runRepair(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// Errors are retried on next repair
+			_ = d.repair(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (d *directory) repair(ctx context.Context) error {
+	return errors.Join(
+		d.repairDirectories(ctx),
+		d.repairFiles(ctx),
+	)
+}
+
+func (d *directory) repairDirectories(ctx context.Context) error {
+	lists, err := fromAll(d.replicas, func(r storage.Directory) (map[string]storage.Directory, error) {
+		return r.ListDirectories(ctx)
+	})
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for name := range union(lists) {
+		// Create the directory on the replicas missing it
+		for i, r := range d.replicas {
+			if r == nil || lists[i] == nil {
+				continue
+			} else if _, ok := lists[i][name]; ok {
+				continue
+			}
+
+			if _, err := r.CreateDirectory(ctx, name); err != nil {
+				errs = append(errs, fmt.Errorf("creating directory %q on replica %d: %w", name, i, err))
+			}
+		}
+
+		// Repair its content
+		child, err := d.GetDirectory(ctx, name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		errs = append(errs, child.(*directory).repair(ctx))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (d *directory) repairFiles(ctx context.Context) error {
+	lists, err := fromAll(d.replicas, func(r storage.Directory) (map[string]storage.File, error) {
+		return r.ListFiles(ctx)
+	})
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for name := range union(lists) {
+		// Get the file info from a replica that has it
+		f, err := d.GetFile(ctx, name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		fileInfo, err := f.GetInfo(ctx)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		// Create the file on the replicas missing it
+		for i, r := range d.replicas {
+			if r == nil || lists[i] == nil {
+				continue
+			} else if _, ok := lists[i][name]; ok {
+				continue
+			}
+
+			if _, err := r.CreateFile(ctx, name, fileInfo); err != nil {
+				errs = append(errs, fmt.Errorf("creating file %q on replica %d: %w", name, i, err))
+			}
+		}
+
+		// Repair its chunks
+		f, err = d.GetFile(ctx, name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		errs = append(errs, f.(*file).repair(ctx))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (f *file) repair(ctx context.Context) error {
+	fileInfo, err := f.GetInfo(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i, r := range f.replicas {
+		if r == nil || !f.stale.isStale(f.path, metadataIndex, i) {
+			continue
+		}
+		if err := f.repairMetadata(ctx, i, fileInfo); err != nil {
+			errs = append(errs, fmt.Errorf("repairing metadata on replica %d: %w", i, err))
+		}
+	}
+
+	data := make([]byte, fileInfo.ChunkSize)
+	for index := 0; index < fileInfo.ChunksCount; index++ {
+		// Check which replicas miss the chunk or are stale for it
+		var missing, stale []int
+		var source storage.File
+		for i, r := range f.replicas {
+			if r == nil {
+				continue
+			}
+
+			present, err := hasChunk(ctx, r, index, data)
+			switch {
+			case err != nil:
+				errs = append(errs, err)
+			case !present:
+				missing = append(missing, i)
+			case f.stale.isStale(f.path, index, i):
+				stale = append(stale, i)
+			case source == nil:
+				source = r
+			}
+		}
+
+		// Copy it from the first up to date replica that has it
+		if len(missing)+len(stale) == 0 || source == nil {
+			continue
+		}
+		read, err := source.ReadChunk(ctx, index, data, 0)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, i := range missing {
+			if err := f.replicas[i].ImportChunk(ctx, index, data[:read]); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			f.stale.remove(f.path, index, i)
+		}
+		for _, i := range stale {
+			if _, err := f.replicas[i].WriteChunk(ctx, index, data[:read], 0); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			f.stale.remove(f.path, index, i)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// repairMetadata updates the metadata of the replica from the file info. Its
+// last chunk is then recorded as stale, as its size could have failed to
+// change, so it is copied again from an up to date replica.
+func (f *file) repairMetadata(ctx context.Context, replica int, fileInfo info.File) error {
+	r := f.replicas[replica]
+	if err := r.ResizeChunksNb(ctx, fileInfo.ChunksCount); err != nil {
+		return err
+	}
+	if fileInfo.ChunksCount > 0 {
+		if _, err := r.ResizeLastChunk(ctx, fileInfo.LastChunkSize); err != nil {
+			return err
+		}
+		f.stale.add(f.path, fileInfo.ChunksCount-1, replica)
+	}
+	if err := r.SetAttributes(ctx, fileInfo.Attributes); err != nil {
+		return err
+	}
+
+	f.stale.remove(f.path, metadataIndex, replica)
+	return nil
+}
+
+// hasChunk returns true if the chunk is present on the file, using the buffer
+// to read it if the file cannot tell.
+func hasChunk(ctx context.Context, f storage.File, index int, buf []byte) (bool, error) {
+	var err error
+	if sf, ok := f.(storage.SparseFile); ok {
+		_, err = sf.HasChunkData(ctx, index)
+	} else {
+		_, err = f.ReadChunk(ctx, index, buf, 0)
+	}
+
+	if errors.Is(err, storage.ErrChunkNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,212 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+	"github.com/lerenn/chonkfs/pkg/storage/mem"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestRepairSuite(t *testing.T) {
+	suite.Run(t, new(RepairSuite))
+}
+
+type RepairSuite struct {
+	Replicas  []storage.Directory
+	Directory storage.Directory
+	suite.Suite
+}
+
+func (suite *RepairSuite) SetupTest() {
+	suite.Replicas = []storage.Directory{mem.NewDirectory(), mem.NewDirectory()}
+
+	d, err := NewDirectory(suite.Replicas)
+	suite.Require().NoError(err)
+	suite.Directory = d
+}
+
+func (suite *RepairSuite) TestRepairEmptyReplica() {
+	// Create a tree on the first replica only
+	dir, err := suite.Replicas[0].CreateDirectory(context.Background(), "dir")
+	suite.Require().NoError(err)
+	f, err := dir.CreateFile(context.Background(), "file", info.File{ChunkSize: 4})
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.ResizeChunksNb(context.Background(), 2))
+	_, err = f.WriteChunk(context.Background(), 1, []byte("ABCD"), 0)
+	suite.Require().NoError(err)
+
+	// Repair
+	suite.Require().NoError(Repair(context.Background(), suite.Directory))
+
+	// Check the tree is on the second replica
+	dir, err = suite.Replicas[1].GetDirectory(context.Background(), "dir")
+	suite.Require().NoError(err)
+	f, err = dir.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+	data := make([]byte, 4)
+	_, err = f.ReadChunk(context.Background(), 1, data, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal("ABCD", string(data))
+}
+
+func (suite *RepairSuite) TestRepairMissingChunk() {
+	// Create a file on both replicas, with a chunk on the second one only
+	f, err := suite.Directory.CreateFile(context.Background(), "file", info.File{
+		ChunkSize:     4,
+		ChunksCount:   1,
+		LastChunkSize: 4,
+	})
+	suite.Require().NoError(err)
+	rf, err := suite.Replicas[1].GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+	suite.Require().NoError(rf.ImportChunk(context.Background(), 0, []byte("ABCD")))
+
+	// Repair
+	suite.Require().NoError(Repair(context.Background(), suite.Directory))
+
+	// Check the chunk is on the first replica
+	rf, err = suite.Replicas[0].GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+	data := make([]byte, 4)
+	_, err = rf.ReadChunk(context.Background(), 0, data, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal("ABCD", string(data))
+	_, err = f.ReadChunk(context.Background(), 0, data, 0)
+	suite.Require().NoError(err)
+}
+
+func (suite *RepairSuite) TestRepairStaleReplica() {
+	// Create a mirror whose first replica can fail to write
+	broken := &atomic.Bool{}
+	replicas := []storage.Directory{flakyDirectory{suite.Replicas[0], broken}, suite.Replicas[1]}
+	d, err := NewDirectory(replicas, WithWriteQuorum(1))
+	suite.Require().NoError(err)
+	f, err := d.CreateFile(context.Background(), "file", info.File{ChunkSize: 4})
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.ResizeChunksNb(context.Background(), 1))
+	_, err = f.WriteChunk(context.Background(), 0, []byte("ABCD"), 0)
+	suite.Require().NoError(err)
+
+	// Fail one write on the first replica
+	broken.Store(true)
+	_, err = f.WriteChunk(context.Background(), 0, []byte("EFGH"), 0)
+	suite.Require().NoError(err)
+	broken.Store(false)
+
+	// Check the chunk is read from the second replica
+	data := make([]byte, 4)
+	_, err = f.ReadChunk(context.Background(), 0, data, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal("EFGH", string(data))
+
+	// Repair
+	suite.Require().NoError(Repair(context.Background(), d))
+
+	// Check the chunk is up to date on the first replica
+	rf, err := suite.Replicas[0].GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+	_, err = rf.ReadChunk(context.Background(), 0, data, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal("EFGH", string(data))
+}
+
+func (suite *RepairSuite) TestRepairStaleMetadata() {
+	// Create a mirror whose first replica can fail to update the metadata
+	broken := &atomic.Bool{}
+	replicas := []storage.Directory{flakyDirectory{suite.Replicas[0], broken}, suite.Replicas[1]}
+	d, err := NewDirectory(replicas, WithWriteQuorum(1))
+	suite.Require().NoError(err)
+	f, err := d.CreateFile(context.Background(), "file", info.File{ChunkSize: 4})
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.ResizeChunksNb(context.Background(), 1))
+	_, err = f.WriteChunk(context.Background(), 0, []byte("ABCD"), 0)
+	suite.Require().NoError(err)
+
+	// Fail the metadata updates on the first replica
+	broken.Store(true)
+	suite.Require().NoError(f.ResizeChunksNb(context.Background(), 2))
+	_, err = f.ResizeLastChunk(context.Background(), 2)
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.SetAttributes(context.Background(), info.Attributes{Mode: 0o600}))
+	broken.Store(false)
+
+	// Check the info is read from the second replica
+	fileInfo, err := f.GetInfo(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal(2, fileInfo.ChunksCount)
+	suite.Require().Equal(2, fileInfo.LastChunkSize)
+	suite.Require().Equal(uint32(0o600), fileInfo.Mode)
+
+	// Repair
+	suite.Require().NoError(Repair(context.Background(), d))
+
+	// Check the metadata is up to date on the first replica
+	rf, err := suite.Replicas[0].GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+	fileInfo, err = rf.GetInfo(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal(2, fileInfo.ChunksCount)
+	suite.Require().Equal(2, fileInfo.LastChunkSize)
+	suite.Require().Equal(uint32(0o600), fileInfo.Mode)
+}
+
+func (suite *RepairSuite) TestRepairOtherStorage() {
+	suite.Require().ErrorIs(Repair(context.Background(), mem.NewDirectory()), ErrNotMirror)
+}
+
+var errBroken = errors.New("broken")
+
+// flakyDirectory is a directory whose files fail to write or to update their
+// metadata while it is broken.
+type flakyDirectory struct {
+	storage.Directory
+	broken *atomic.Bool
+}
+
+func (d flakyDirectory) CreateFile(ctx context.Context, name string, info info.File) (storage.File, error) {
+	f, err := d.Directory.CreateFile(ctx, name, info)
+	return flakyFile{f, d.broken}, err
+}
+
+func (d flakyDirectory) GetFile(ctx context.Context, name string) (storage.File, error) {
+	f, err := d.Directory.GetFile(ctx, name)
+	return flakyFile{f, d.broken}, err
+}
+
+type flakyFile struct {
+	storage.File
+	broken *atomic.Bool
+}
+
+func (f flakyFile) WriteChunk(ctx context.Context, index int, data []byte, offset int) (int, error) {
+	if f.broken.Load() {
+		return 0, errBroken
+	}
+	return f.File.WriteChunk(ctx, index, data, offset)
+}
+
+func (f flakyFile) SetAttributes(ctx context.Context, attr info.Attributes) error {
+	if f.broken.Load() {
+		return errBroken
+	}
+	return f.File.SetAttributes(ctx, attr)
+}
+
+func (f flakyFile) ResizeChunksNb(ctx context.Context, size int) error {
+	if f.broken.Load() {
+		return errBroken
+	}
+	return f.File.ResizeChunksNb(ctx, size)
+}
+
+func (f flakyFile) ResizeLastChunk(ctx context.Context, size int) (int, error) {
+	if f.broken.Load() {
+		return 0, errBroken
+	}
+	return f.File.ResizeLastChunk(ctx, size)
+}
@@ -0,0 +1,128 @@
+package mirror
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+var (
+	// ErrQuorumNotReached happens when a modification succeeded on less
+	// replicas than the write quorum.
+	ErrQuorumNotReached = fmt.Errorf("%w: write quorum not reached", storage.ErrStorage)
+	// ErrNoReplica happens when an element is not present on any replica.
+	ErrNoReplica = fmt.Errorf("%w: no replica", storage.ErrStorage)
+)
+
+// fanOut runs the function concurrently on every replica present, a missing
+// replica being a nil one. It returns the results, with zero values for the
+// failed replicas, and the index of the first successful replica. It fails if
+// less replicas than the quorum succeeded, with the error of the first replica
+// if all of them failed.
+func fanOut[R, T any](replicas []R, quorum int, fn func(index int, r R) (T, error)) ([]T, int, error) {
+	results := make([]T, len(replicas))
+	errs := make([]error, len(replicas))
+
+	var wg sync.WaitGroup
+	for i, r := range replicas {
+		if isMissing(r) {
+			errs[i] = ErrNoReplica
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = fn(i, r)
+		}()
+	}
+	wg.Wait()
+
+	// Count the successes
+	successes, firstSuccess := 0, -1
+	var failures []error
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			successes++
+			if firstSuccess < 0 {
+				firstSuccess = i
+			}
+		case !errors.Is(err, ErrNoReplica):
+			failures = append(failures, err)
+		}
+	}
+
+	switch {
+	case successes >= quorum:
+		return results, firstSuccess, nil
+	case successes == 0 && len(failures) > 0:
+		return nil, -1, failures[0]
+	case successes == 0:
+		return nil, -1, ErrNoReplica
+	default:
+		return nil, -1, fmt.Errorf("%w (%d/%d): %w",
+			ErrQuorumNotReached, successes, quorum, errors.Join(failures...))
+	}
+}
+
+// firstHealthy runs the function on the replicas, in order, until it
+// succeeds. If all replicas failed, the error is the one of the first replica.
+func firstHealthy[R, T any](replicas []R, fn func(r R) (T, error)) (T, error) {
+	var firstErr error
+	for _, r := range replicas {
+		if isMissing(r) {
+			continue
+		}
+
+		res, err := fn(r)
+		if err == nil {
+			return res, nil
+		} else if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	var zero T
+	if firstErr == nil {
+		return zero, ErrNoReplica
+	}
+	return zero, firstErr
+}
+
+// fromAll runs the function on every replica present, keeping the results of
+// the successful ones and nil for the others. If all replicas failed, the
+// error is the one of the first replica.
+func fromAll[R, T any](replicas []R, fn func(r R) (T, error)) ([]T, error) {
+	results := make([]T, len(replicas))
+
+	var firstErr error
+	found := false
+	for i, r := range replicas {
+		if isMissing(r) {
+			continue
+		}
+
+		res, err := fn(r)
+		if err == nil {
+			results[i], found = res, true
+		} else if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	switch {
+	case found:
+		return results, nil
+	case firstErr != nil:
+		return nil, firstErr
+	default:
+		return nil, ErrNoReplica
+	}
+}
+
+func isMissing[T any](v T) bool {
+	return any(v) == nil
+}
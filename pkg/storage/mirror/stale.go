@@ -0,0 +1,124 @@
+package mirror
+
+import (
+	"strings"
+	"sync"
+)
+
+// metadataIndex is the index under which the replicas that failed to update the
+// metadata of a file are recorded, as no chunk has it.
+const metadataIndex = -1
+
+// staleChunks records the replicas that failed to write a chunk while the
+// write quorum was met, by file path then chunk index. These replicas keep an
+// old version of the chunk until it is repaired, so it is not read from them.
+// The replicas that failed to update the metadata of the file are recorded
+// under metadataIndex, and nothing of the file is read from them.
+type staleChunks struct {
+	mutex sync.Mutex
+	files map[string]map[int]map[int]struct{}
+}
+
+func newStaleChunks() *staleChunks {
+	return &staleChunks{
+		files: make(map[string]map[int]map[int]struct{}),
+	}
+}
+
+// add records the replica as stale for the chunk of the file.
+func (s *staleChunks) add(path string, index, replica int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	chunks, ok := s.files[path]
+	if !ok {
+		chunks = make(map[int]map[int]struct{})
+		s.files[path] = chunks
+	}
+	replicas, ok := chunks[index]
+	if !ok {
+		replicas = make(map[int]struct{})
+		chunks[index] = replicas
+	}
+	replicas[replica] = struct{}{}
+}
+
+// isStale returns true if the replica is stale for the chunk of the file.
+func (s *staleChunks) isStale(path string, index, replica int) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, ok := s.files[path][index][replica]
+	return ok
+}
+
+// remove records the replica as up to date for the chunk of the file.
+func (s *staleChunks) remove(path string, index, replica int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	chunks := s.files[path]
+	delete(chunks[index], replica)
+	if len(chunks[index]) == 0 {
+		delete(chunks, index)
+	}
+	if len(chunks) == 0 {
+		delete(s.files, path)
+	}
+}
+
+// truncate forgets the chunks of the file from the index, keeping the
+// metadata.
+func (s *staleChunks) truncate(path string, index int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	chunks := s.files[path]
+	for i := range chunks {
+		if i >= index {
+			delete(chunks, i)
+		}
+	}
+	if len(chunks) == 0 {
+		delete(s.files, path)
+	}
+}
+
+// forget forgets the file, or every file under the directory, at the path.
+func (s *staleChunks) forget(path string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for p := range s.under(path) {
+		delete(s.files, p)
+	}
+}
+
+// move moves the records of the file, or of every file under the directory,
+// from a path to another, replacing the ones of the destination.
+func (s *staleChunks) move(from, to string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	moved := s.under(from)
+	for p := range s.under(to) {
+		delete(s.files, p)
+	}
+	for p, rel := range moved {
+		s.files[to+rel] = s.files[p]
+		delete(s.files, p)
+	}
+}
+
+// under returns the recorded paths of the file, or of every file under the
+// directory, at the path, with their path relative to it.
+func (s *staleChunks) under(path string) map[string]string {
+	paths := make(map[string]string)
+	for p := range s.files {
+		if p == path || strings.HasPrefix(p, path+"/") {
+			paths[p] = strings.TrimPrefix(p, path)
+		}
+	}
+
+	return paths
+}
@@ -0,0 +1,71 @@
+package mirror
+
+import (
+	"context"
+
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+// GetXattr returns the value of an extended attribute, from the first replica
+// that has it.
+func (f *file) GetXattr(ctx context.Context, name string) ([]byte, error) {
+	return firstHealthy(f.replicas, func(r storage.File) ([]byte, error) {
+		return r.GetXattr(ctx, name)
+	})
+}
+
+// ListXattrs returns the names of the extended attributes, from the first
+// healthy replica.
+func (f *file) ListXattrs(ctx context.Context) ([]string, error) {
+	return firstHealthy(f.replicas, func(r storage.File) ([]string, error) {
+		return r.ListXattrs(ctx)
+	})
+}
+
+// SetXattr creates or replaces an extended attribute, on the replicas.
+func (f *file) SetXattr(ctx context.Context, name string, value []byte) error {
+	_, _, err := fanOut(f.replicas, f.quorum, func(_ int, r storage.File) (struct{}, error) {
+		return struct{}{}, r.SetXattr(ctx, name, value)
+	})
+	return err
+}
+
+// RemoveXattr removes an extended attribute, from the replicas.
+func (f *file) RemoveXattr(ctx context.Context, name string) error {
+	_, _, err := fanOut(f.replicas, f.quorum, func(_ int, r storage.File) (struct{}, error) {
+		return struct{}{}, r.RemoveXattr(ctx, name)
+	})
+	return err
+}
+
+// GetXattr returns the value of an extended attribute, from the first replica
+// that has it.
+func (d *directory) GetXattr(ctx context.Context, name string) ([]byte, error) {
+	return firstHealthy(d.replicas, func(r storage.Directory) ([]byte, error) {
+		return r.GetXattr(ctx, name)
+	})
+}
+
+// ListXattrs returns the names of the extended attributes, from the first
+// healthy replica.
+func (d *directory) ListXattrs(ctx context.Context) ([]string, error) {
+	return firstHealthy(d.replicas, func(r storage.Directory) ([]string, error) {
+		return r.ListXattrs(ctx)
+	})
+}
+
+// SetXattr creates or replaces an extended attribute, on the replicas.
+func (d *directory) SetXattr(ctx context.Context, name string, value []byte) error {
+	_, _, err := fanOut(d.replicas, d.quorum, func(_ int, r storage.Directory) (struct{}, error) {
+		return struct{}{}, r.SetXattr(ctx, name, value)
+	})
+	return err
+}
+
+// RemoveXattr removes an extended attribute, from the replicas.
+func (d *directory) RemoveXattr(ctx context.Context, name string) error {
+	_, _, err := fanOut(d.replicas, d.quorum, func(_ int, r storage.Directory) (struct{}, error) {
+		return struct{}{}, r.RemoveXattr(ctx, name)
+	})
+	return err
+}
@@ -0,0 +1,122 @@
+package readonly
+
+import (
+	"context"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+var _ storage.Directory = (*directory)(nil)
+
+type directory struct {
+	directory storage.Directory
+}
+
+// NewDirectory creates a new directory representation, giving access to the
+// directory without allowing any modification.
+func NewDirectory(d storage.Directory) storage.Directory {
+	return &directory{
+		directory: d,
+	}
+}
+
+// CreateDirectory creates a directory.
+func (d *directory) CreateDirectory(_ context.Context, _ string) (storage.Directory, error) {
+	return nil, storage.ErrReadOnly
+}
+
+// GetDirectory returns a directory.
+func (d *directory) GetDirectory(ctx context.Context, name string) (storage.Directory, error) {
+	child, err := d.directory.GetDirectory(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewDirectory(child), nil
+}
+
+// GetInfo returns the directory info.
+func (d *directory) GetInfo(ctx context.Context) (info.Directory, error) {
+	return d.directory.GetInfo(ctx)
+}
+
+// SetAttributes sets the directory attributes.
+func (d *directory) SetAttributes(_ context.Context, _ info.Attributes) error {
+	return storage.ErrReadOnly
+}
+
+// CreateFile creates a file.
+func (d *directory) CreateFile(_ context.Context, _ string, _ info.File) (storage.File, error) {
+	return nil, storage.ErrReadOnly
+}
+
+// GetFile returns a file.
+func (d *directory) GetFile(ctx context.Context, name string) (storage.File, error) {
+	f, err := d.directory.GetFile(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFile(f), nil
+}
+
+// ListFiles returns a map of files.
+func (d *directory) ListFiles(ctx context.Context) (map[string]storage.File, error) {
+	files, err := d.directory.ListFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, f := range files {
+		files[name] = newFile(f)
+	}
+
+	return files, nil
+}
+
+// RemoveDirectory removes a directory.
+func (d *directory) RemoveDirectory(_ context.Context, _ string) error {
+	return storage.ErrReadOnly
+}
+
+// ListDirectories returns a map of directories.
+func (d *directory) ListDirectories(ctx context.Context) (map[string]storage.Directory, error) {
+	dirs, err := d.directory.ListDirectories(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, child := range dirs {
+		dirs[name] = NewDirectory(child)
+	}
+
+	return dirs, nil
+}
+
+// RemoveFile removes a file.
+func (d *directory) RemoveFile(_ context.Context, _ string) error {
+	return storage.ErrReadOnly
+}
+
+// RenameFile renames a file.
+func (d *directory) RenameFile(
+	_ context.Context,
+	_ string,
+	_ storage.Directory,
+	_ string,
+	_ bool,
+) error {
+	return storage.ErrReadOnly
+}
+
+// RenameDirectory renames a directory.
+func (d *directory) RenameDirectory(
+	_ context.Context,
+	_ string,
+	_ storage.Directory,
+	_ string,
+	_ bool,
+) error {
+	return storage.ErrReadOnly
+}
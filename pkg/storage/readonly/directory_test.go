@@ -0,0 +1,67 @@
+package readonly
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+	"github.com/lerenn/chonkfs/pkg/storage/mem"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestDirectorySuite(t *testing.T) {
+	suite.Run(t, new(DirectorySuite))
+}
+
+type DirectorySuite struct {
+	Directory storage.Directory
+	suite.Suite
+}
+
+func (suite *DirectorySuite) SetupTest() {
+	d := mem.NewDirectory()
+	_, err := d.CreateDirectory(context.Background(), "dir")
+	suite.Require().NoError(err)
+
+	f, err := d.CreateFile(context.Background(), "file", info.File{ChunkSize: 4})
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.ResizeChunksNb(context.Background(), 1))
+	_, err = f.WriteChunk(context.Background(), 0, []byte("ABCD"), 0)
+	suite.Require().NoError(err)
+
+	suite.Directory = NewDirectory(d)
+}
+
+func (suite *DirectorySuite) TestRead() {
+	// Read the file
+	f, err := suite.Directory.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+	data := make([]byte, 4)
+	_, err = f.ReadChunk(context.Background(), 0, data, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal("ABCD", string(data))
+
+	// List the directories
+	dirs, err := suite.Directory.ListDirectories(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Len(dirs, 1)
+}
+
+func (suite *DirectorySuite) TestModificationsAreRejected() {
+	// Check on directories
+	_, err := suite.Directory.CreateFile(context.Background(), "new", info.File{ChunkSize: 4})
+	suite.Require().ErrorIs(err, storage.ErrReadOnly)
+	suite.Require().ErrorIs(suite.Directory.RemoveFile(context.Background(), "file"), storage.ErrReadOnly)
+	dir, err := suite.Directory.GetDirectory(context.Background(), "dir")
+	suite.Require().NoError(err)
+	_, err = dir.CreateDirectory(context.Background(), "new")
+	suite.Require().ErrorIs(err, storage.ErrReadOnly)
+
+	// Check on files
+	f, err := suite.Directory.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+	_, err = f.WriteChunk(context.Background(), 0, []byte("EFGH"), 0)
+	suite.Require().ErrorIs(err, storage.ErrReadOnly)
+	suite.Require().ErrorIs(f.ResizeChunksNb(context.Background(), 2), storage.ErrReadOnly)
+}
@@ -0,0 +1,66 @@
+package readonly
+
+import (
+	"context"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+var _ storage.SparseFile = (*file)(nil)
+
+type file struct {
+	file storage.File
+}
+
+func newFile(f storage.File) *file {
+	return &file{
+		file: f,
+	}
+}
+
+// GetInfo returns the file info.
+func (f *file) GetInfo(ctx context.Context) (info.File, error) {
+	return f.file.GetInfo(ctx)
+}
+
+// SetAttributes sets the file attributes.
+func (f *file) SetAttributes(_ context.Context, _ info.Attributes) error {
+	return storage.ErrReadOnly
+}
+
+// ReadChunk reads _ from a chunk.
+func (f *file) ReadChunk(ctx context.Context, index int, data []byte, offset int) (int, error) {
+	return f.file.ReadChunk(ctx, index, data, offset)
+}
+
+// HasChunkData returns true if the chunk holds data, false if this is a hole.
+// Any chunk is considered holding data if the file cannot tell.
+func (f *file) HasChunkData(ctx context.Context, index int) (bool, error) {
+	sf, ok := f.file.(storage.SparseFile)
+	if !ok {
+		return true, nil
+	}
+
+	return sf.HasChunkData(ctx, index)
+}
+
+// WriteChunk writes _ to a chunk.
+func (f *file) WriteChunk(_ context.Context, _ int, _ []byte, _ int) (int, error) {
+	return 0, storage.ErrReadOnly
+}
+
+// ImportChunk imports a chunk.
+func (f *file) ImportChunk(_ context.Context, _ int, _ []byte) error {
+	return storage.ErrReadOnly
+}
+
+// ResizeChunksNb resizes the number of chunks.
+func (f *file) ResizeChunksNb(_ context.Context, _ int) error {
+	return storage.ErrReadOnly
+}
+
+// ResizeLastChunk resizes the last chunk.
+func (f *file) ResizeLastChunk(_ context.Context, _ int) (int, error) {
+	return 0, storage.ErrReadOnly
+}
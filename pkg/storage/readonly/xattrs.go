@@ -0,0 +1,47 @@
+package readonly
+
+import (
+	"context"
+
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+// GetXattr returns the value of an extended attribute.
+func (f *file) GetXattr(ctx context.Context, name string) ([]byte, error) {
+	return f.file.GetXattr(ctx, name)
+}
+
+// ListXattrs returns the names of the extended attributes.
+func (f *file) ListXattrs(ctx context.Context) ([]string, error) {
+	return f.file.ListXattrs(ctx)
+}
+
+// SetXattr creates or replaces an extended attribute.
+func (f *file) SetXattr(_ context.Context, _ string, _ []byte) error {
+	return storage.ErrReadOnly
+}
+
+// RemoveXattr removes an extended attribute.
+func (f *file) RemoveXattr(_ context.Context, _ string) error {
+	return storage.ErrReadOnly
+}
+
+// GetXattr returns the value of an extended attribute.
+func (d *directory) GetXattr(ctx context.Context, name string) ([]byte, error) {
+	return d.directory.GetXattr(ctx, name)
+}
+
+// ListXattrs returns the names of the extended attributes.
+func (d *directory) ListXattrs(ctx context.Context) ([]string, error) {
+	return d.directory.ListXattrs(ctx)
+}
+
+// SetXattr creates or replaces an extended attribute.
+func (d *directory) SetXattr(_ context.Context, _ string, _ []byte) error {
+	return storage.ErrReadOnly
+}
+
+// RemoveXattr removes an extended attribute.
+func (d *directory) RemoveXattr(_ context.Context, _ string) error {
+	return storage.ErrReadOnly
+}
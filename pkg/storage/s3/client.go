@@ -0,0 +1,159 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// client wraps the S3 client with the bucket used to store the data.
+type client struct {
+	s3     *minio.Client
+	bucket string
+}
+
+func newClient(s3 *minio.Client, bucket string) *client {
+	return &client{
+		s3:     s3,
+		bucket: bucket,
+	}
+}
+
+// convertError turns S3 'no such key' errors into fs.ErrNotExist, in
+// order to be checked the same way than local file system errors.
+func convertError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	switch minio.ToErrorResponse(err).Code {
+	case "NoSuchKey", "NotFound":
+		return fmt.Errorf("%w: %w", fs.ErrNotExist, err)
+	default:
+		return err
+	}
+}
+
+func (c *client) getObject(ctx context.Context, key string) ([]byte, error) {
+	obj, err := c.s3.GetObject(ctx, c.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, convertError(err)
+	}
+	defer obj.Close()
+
+	data, err := io.ReadAll(obj)
+	return data, convertError(err)
+}
+
+func (c *client) putObject(ctx context.Context, key string, data []byte) error {
+	_, err := c.s3.PutObject(ctx, c.bucket, key, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType: "application/octet-stream",
+	})
+	return err
+}
+
+func (c *client) statObject(ctx context.Context, key string) (bool, error) {
+	_, err := c.s3.StatObject(ctx, c.bucket, key, minio.StatObjectOptions{})
+	if err == nil {
+		return true, nil
+	}
+
+	switch minio.ToErrorResponse(err).Code {
+	case "NoSuchKey", "NotFound":
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+func (c *client) removeObject(ctx context.Context, key string) error {
+	return c.s3.RemoveObject(ctx, c.bucket, key, minio.RemoveObjectOptions{})
+}
+
+// listPrefixes returns the direct children prefixes of the given prefix,
+// without the parent prefix and the trailing delimiter.
+func (c *client) listPrefixes(ctx context.Context, prefix string) ([]string, error) {
+	names := make([]string, 0)
+	for obj := range c.s3.ListObjects(ctx, c.bucket, minio.ListObjectsOptions{
+		Prefix: prefix,
+	}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+
+		// Only keep common prefixes
+		if !strings.HasSuffix(obj.Key, delimiter) || obj.Key == prefix {
+			continue
+		}
+
+		names = append(names, strings.TrimSuffix(strings.TrimPrefix(obj.Key, prefix), delimiter))
+	}
+
+	return names, nil
+}
+
+// listKeys returns every key starting with the prefix, recursively.
+func (c *client) listKeys(ctx context.Context, prefix string) ([]string, error) {
+	keys := make([]string, 0)
+	for obj := range c.s3.ListObjects(ctx, c.bucket, minio.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: true,
+	}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		keys = append(keys, obj.Key)
+	}
+
+	return keys, nil
+}
+
+// removePrefix removes every object starting with the prefix.
+func (c *client) removePrefix(ctx context.Context, prefix string) error {
+	keys, err := c.listKeys(ctx, prefix)
+	if err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		if err := c.removeObject(ctx, k); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// movePrefix moves every object starting with the prefix to the new prefix,
+// as S3 doesn't support renaming.
+func (c *client) movePrefix(ctx context.Context, oldPrefix, newPrefix string) error {
+	keys, err := c.listKeys(ctx, oldPrefix)
+	if err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		dst := minio.CopyDestOptions{
+			Bucket: c.bucket,
+			Object: newPrefix + strings.TrimPrefix(k, oldPrefix),
+		}
+		src := minio.CopySrcOptions{
+			Bucket: c.bucket,
+			Object: k,
+		}
+		if _, err := c.s3.CopyObject(ctx, dst, src); err != nil {
+			return err
+		}
+
+		if err := c.removeObject(ctx, k); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
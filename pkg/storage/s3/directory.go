@@ -0,0 +1,344 @@
+package s3
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+	"github.com/minio/minio-go/v7"
+)
+
+const (
+	delimiter           = "/"
+	directoryMarkerName = ".directory"
+)
+
+var _ storage.Directory = (*directory)(nil)
+
+// directory is represented on S3 by a marker object placed under the
+// directory prefix, in order to keep empty directories. The marker holds the
+// attributes of the directory, once they have been set.
+type directory struct {
+	client *client
+	prefix string
+}
+
+// NewDirectory creates a new directory representation on a S3 bucket.
+// The prefix is the location of the directory in the bucket: use an empty
+// prefix to use the root of the bucket.
+func NewDirectory(s3 *minio.Client, bucket, prefix string) storage.Directory {
+	if prefix != "" && !strings.HasSuffix(prefix, delimiter) {
+		prefix += delimiter
+	}
+
+	return newDirectory(newClient(s3, bucket), prefix)
+}
+
+func newDirectory(c *client, prefix string) *directory {
+	return &directory{
+		client: c,
+		prefix: prefix,
+	}
+}
+
+func (d *directory) getChildPrefix(name string) string {
+	return d.prefix + name + delimiter
+}
+
+func (d *directory) ensureChildDoesNotExists(ctx context.Context, name string) error {
+	// Check if there is a metadata object
+	if ok, err := d.client.statObject(ctx, d.getChildPrefix(name)+metadataObjectName); err != nil {
+		return err
+	} else if ok {
+		return fmt.Errorf("%w: %q", storage.ErrFileAlreadyExists, name)
+	}
+
+	// Check if there is a directory marker
+	if ok, err := d.client.statObject(ctx, d.getChildPrefix(name)+directoryMarkerName); err != nil {
+		return err
+	} else if ok {
+		return fmt.Errorf("%w: %q", storage.ErrDirectoryAlreadyExists, name)
+	}
+
+	return nil
+}
+
+// CreateDirectory creates a directory.
+func (d *directory) CreateDirectory(ctx context.Context, name string) (storage.Directory, error) {
+	// Check if a file or a directory exists
+	if err := d.ensureChildDoesNotExists(ctx, name); err != nil {
+		return nil, err
+	}
+
+	// Create directory marker and return representation
+	prefix := d.getChildPrefix(name)
+	return newDirectory(d.client, prefix), d.client.putObject(ctx, prefix+directoryMarkerName, nil)
+}
+
+// GetDirectory returns a child directory of the directory.
+func (d *directory) GetDirectory(ctx context.Context, name string) (storage.Directory, error) {
+	// Check if directory exists
+	err := d.ensureChildDoesNotExists(ctx, name)
+	switch {
+	case err == nil:
+		return nil, fmt.Errorf("%w: %q", storage.ErrDirectoryNotFound, name)
+	case errors.Is(err, storage.ErrFileAlreadyExists):
+		return nil, fmt.Errorf("%w: %q", storage.ErrIsFile, name)
+	case !errors.Is(err, storage.ErrDirectoryAlreadyExists):
+		return nil, err
+	}
+
+	// Return representation
+	return newDirectory(d.client, d.getChildPrefix(name)), nil
+}
+
+// GetInfo returns the directory information.
+func (d *directory) GetInfo(ctx context.Context) (info.Directory, error) {
+	// Download the marker, that is empty if the attributes are not set
+	data, err := d.client.getObject(ctx, d.prefix+directoryMarkerName)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return info.Directory{}, err
+	} else if len(data) == 0 {
+		return info.Directory{}, nil
+	}
+
+	var dirInfo info.Directory
+	if err := json.Unmarshal(data, &dirInfo.Attributes); err != nil {
+		return info.Directory{}, err
+	}
+
+	return dirInfo, nil
+}
+
+// SetAttributes sets the directory attributes.
+func (d *directory) SetAttributes(ctx context.Context, attr info.Attributes) error {
+	data, err := json.Marshal(attr)
+	if err != nil {
+		return err
+	}
+
+	return d.client.putObject(ctx, d.prefix+directoryMarkerName, data)
+}
+
+// CreateFile creates a file.
+func (d *directory) CreateFile(ctx context.Context, name string, info info.File) (storage.File, error) {
+	// Check if there is a file with this name
+	if err := d.ensureChildDoesNotExists(ctx, name); err != nil {
+		return nil, err
+	}
+
+	// Create file representation
+	f, err := newFile(d.client, d.getChildPrefix(name), info)
+	if err != nil {
+		return nil, err
+	}
+
+	return f, f.saveInfo(ctx, info)
+}
+
+// GetFile returns a child file.
+func (d *directory) GetFile(ctx context.Context, name string) (storage.File, error) {
+	// Check if file exists
+	err := d.ensureChildDoesNotExists(ctx, name)
+	switch {
+	case err == nil:
+		return nil, fmt.Errorf("%w: %q", storage.ErrFileNotFound, name)
+	case errors.Is(err, storage.ErrDirectoryAlreadyExists):
+		return nil, fmt.Errorf("%w: %q", storage.ErrIsDirectory, name)
+	case !errors.Is(err, storage.ErrFileAlreadyExists):
+		return nil, err
+	}
+
+	// Read metadata
+	prefix := d.getChildPrefix(name)
+	info, err := readMetadata(ctx, d.client, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create file representation
+	return newFile(d.client, prefix, info)
+}
+
+// listChildren returns the children of the directory, sorted between the
+// ones representing files and the ones representing directories.
+func (d *directory) listChildren(ctx context.Context) (files, directories []string, err error) {
+	names, err := d.client.listPrefixes(ctx, d.prefix)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, name := range names {
+		// Check if there is metadata
+		ok, err := d.client.statObject(ctx, d.getChildPrefix(name)+metadataObjectName)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if ok {
+			files = append(files, name)
+		} else {
+			directories = append(directories, name)
+		}
+	}
+
+	return files, directories, nil
+}
+
+// ListFiles returns a map of files.
+func (d *directory) ListFiles(ctx context.Context) (map[string]storage.File, error) {
+	names, _, err := d.listChildren(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]storage.File, len(names))
+	for _, name := range names {
+		// Read metadata
+		prefix := d.getChildPrefix(name)
+		info, err := readMetadata(ctx, d.client, prefix)
+		if err != nil {
+			return nil, err
+		}
+
+		// Create file representation
+		f, err := newFile(d.client, prefix, info)
+		if err != nil {
+			return nil, err
+		}
+
+		files[name] = f
+	}
+
+	return files, nil
+}
+
+// RemoveDirectory removes a directory.
+func (d *directory) RemoveDirectory(ctx context.Context, name string) error {
+	// Check if directory exists
+	err := d.ensureChildDoesNotExists(ctx, name)
+	switch {
+	case err == nil:
+		return fmt.Errorf("%w: %q", storage.ErrDirectoryNotFound, name)
+	case errors.Is(err, storage.ErrFileAlreadyExists):
+		return fmt.Errorf("%w: %q", storage.ErrIsFile, name)
+	case !errors.Is(err, storage.ErrDirectoryAlreadyExists):
+		return err
+	}
+
+	// Remove directory
+	return d.client.removePrefix(ctx, d.getChildPrefix(name))
+}
+
+// ListDirectories returns a map of directories.
+func (d *directory) ListDirectories(ctx context.Context) (map[string]storage.Directory, error) {
+	_, names, err := d.listChildren(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	directories := make(map[string]storage.Directory, len(names))
+	for _, name := range names {
+		directories[name] = newDirectory(d.client, d.getChildPrefix(name))
+	}
+
+	return directories, nil
+}
+
+// RemoveFile removes a file.
+func (d *directory) RemoveFile(ctx context.Context, name string) error {
+	// Check if file or directory exists
+	err := d.ensureChildDoesNotExists(ctx, name)
+	switch {
+	case err == nil:
+		return fmt.Errorf("%w: %q", storage.ErrFileNotFound, name)
+	case errors.Is(err, storage.ErrDirectoryAlreadyExists):
+		return fmt.Errorf("%w: %q", storage.ErrIsDirectory, name)
+	case !errors.Is(err, storage.ErrFileAlreadyExists):
+		return err
+	}
+
+	return d.client.removePrefix(ctx, d.getChildPrefix(name))
+}
+
+func (d *directory) prepareRenameDestination(
+	ctx context.Context,
+	newParent storage.Directory,
+	newName string,
+	noReplace bool,
+) error {
+	// Check if there is a file or a directory with the new name
+	newPrefix := newParent.(*directory).getChildPrefix(newName)
+	err := newParent.(*directory).ensureChildDoesNotExists(ctx, newName)
+	switch {
+	case err == nil:
+		// Nothing to do
+		return nil
+	case !noReplace && (errors.Is(err, storage.ErrDirectoryAlreadyExists) ||
+		errors.Is(err, storage.ErrFileAlreadyExists)):
+		return d.client.removePrefix(ctx, newPrefix)
+	default:
+		return err
+	}
+}
+
+// RenameFile renames a file.
+func (d *directory) RenameFile(
+	ctx context.Context,
+	name string,
+	newParent storage.Directory,
+	newName string,
+	noReplace bool,
+) error {
+	// Check if file exists
+	err := d.ensureChildDoesNotExists(ctx, name)
+	switch {
+	case err == nil:
+		return fmt.Errorf("%w: %q", storage.ErrFileNotFound, name)
+	case errors.Is(err, storage.ErrDirectoryAlreadyExists):
+		return fmt.Errorf("%w: %q", storage.ErrIsDirectory, name)
+	case !errors.Is(err, storage.ErrFileAlreadyExists):
+		return err
+	}
+
+	// Check the destination
+	if err := d.prepareRenameDestination(ctx, newParent, newName, noReplace); err != nil {
+		return err
+	}
+
+	// Move the file
+	return d.client.movePrefix(ctx, d.getChildPrefix(name), newParent.(*directory).getChildPrefix(newName))
+}
+
+// RenameDirectory renames a directory.
+func (d *directory) RenameDirectory(
+	ctx context.Context,
+	name string,
+	newParent storage.Directory,
+	newName string,
+	noReplace bool,
+) error {
+	// Check if directory exists
+	err := d.ensureChildDoesNotExists(ctx, name)
+	switch {
+	case err == nil:
+		return fmt.Errorf("%w: %q", storage.ErrDirectoryNotFound, name)
+	case errors.Is(err, storage.ErrFileAlreadyExists):
+		return fmt.Errorf("%w: %q", storage.ErrIsFile, name)
+	case !errors.Is(err, storage.ErrDirectoryAlreadyExists):
+		return err
+	}
+
+	// Check the destination
+	if err := d.prepareRenameDestination(ctx, newParent, newName, noReplace); err != nil {
+		return err
+	}
+
+	// Move the directory
+	return d.client.movePrefix(ctx, d.getChildPrefix(name), newParent.(*directory).getChildPrefix(newName))
+}
@@ -0,0 +1,64 @@
+package s3
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lerenn/chonkfs/pkg/storage/layer"
+	"github.com/lerenn/chonkfs/pkg/storage/mem"
+	"github.com/lerenn/chonkfs/pkg/storage/test"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestDirectorySuite(t *testing.T) {
+	suite.Run(t, new(DirectorySuite))
+	suite.Run(t, new(DirectoryWithPrefixSuite))
+	suite.Run(t, new(DirectoryAsUnderlayerSuite))
+}
+
+type DirectorySuite struct {
+	test.DirectorySuite
+	Server *httptest.Server
+}
+
+func (suite *DirectorySuite) SetupTest() {
+	srv, client := newTestServer(suite.T())
+	suite.Server = srv
+	suite.Directory = NewDirectory(client, testBucket, "")
+}
+
+func (suite *DirectorySuite) TearDownTest() {
+	suite.Server.Close()
+}
+
+type DirectoryWithPrefixSuite struct {
+	test.DirectorySuite
+	Server *httptest.Server
+}
+
+func (suite *DirectoryWithPrefixSuite) SetupTest() {
+	srv, client := newTestServer(suite.T())
+	suite.Server = srv
+	suite.Directory = NewDirectory(client, testBucket, "some/prefix")
+}
+
+func (suite *DirectoryWithPrefixSuite) TearDownTest() {
+	suite.Server.Close()
+}
+
+type DirectoryAsUnderlayerSuite struct {
+	layer.DirectorySuite
+	Server *httptest.Server
+}
+
+func (suite *DirectoryAsUnderlayerSuite) SetupTest() {
+	srv, client := newTestServer(suite.T())
+	suite.Server = srv
+	suite.Upperlayer = mem.NewDirectory()
+	suite.Underlayer = NewDirectory(client, testBucket, "")
+	suite.Directory, _ = layer.NewDirectory(suite.Upperlayer, suite.Underlayer)
+}
+
+func (suite *DirectoryAsUnderlayerSuite) TearDownTest() {
+	suite.Server.Close()
+}
@@ -0,0 +1,64 @@
+package s3
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lerenn/chonkfs/pkg/storage/layer"
+	"github.com/lerenn/chonkfs/pkg/storage/mem"
+	"github.com/lerenn/chonkfs/pkg/storage/test"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestFileSuite(t *testing.T) {
+	suite.Run(t, new(FileSuite))
+	suite.Run(t, new(FileWithPrefixSuite))
+	suite.Run(t, new(FileAsUnderlayerSuite))
+}
+
+type FileSuite struct {
+	test.FileSuite
+	Server *httptest.Server
+}
+
+func (suite *FileSuite) SetupTest() {
+	srv, client := newTestServer(suite.T())
+	suite.Server = srv
+	suite.Directory = NewDirectory(client, testBucket, "")
+}
+
+func (suite *FileSuite) TearDownTest() {
+	suite.Server.Close()
+}
+
+type FileWithPrefixSuite struct {
+	test.FileSuite
+	Server *httptest.Server
+}
+
+func (suite *FileWithPrefixSuite) SetupTest() {
+	srv, client := newTestServer(suite.T())
+	suite.Server = srv
+	suite.Directory = NewDirectory(client, testBucket, "some/prefix")
+}
+
+func (suite *FileWithPrefixSuite) TearDownTest() {
+	suite.Server.Close()
+}
+
+type FileAsUnderlayerSuite struct {
+	layer.FileSuite
+	Server *httptest.Server
+}
+
+func (suite *FileAsUnderlayerSuite) SetupTest() {
+	srv, client := newTestServer(suite.T())
+	suite.Server = srv
+	suite.Upperlayer = mem.NewDirectory()
+	suite.Underlayer = NewDirectory(client, testBucket, "")
+	suite.Directory, _ = layer.NewDirectory(suite.Upperlayer, suite.Underlayer)
+}
+
+func (suite *FileAsUnderlayerSuite) TearDownTest() {
+	suite.Server.Close()
+}
@@ -0,0 +1,52 @@
+package s3
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+const (
+	testBucket = "chonkfs"
+)
+
+// newTestServer starts an in-process S3 server with an empty bucket, and
+// returns a client connected to it.
+func newTestServer(t *testing.T) (*httptest.Server, *minio.Client) {
+	t.Helper()
+
+	// NOTE: TLS is used as the client would otherwise use streaming signatures
+	// that are not supported by the fake server.
+	faker := gofakes3.New(s3mem.New()).Server()
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The fake server considers an empty delimiter as a set delimiter,
+		// which breaks recursive listings
+		if q := r.URL.Query(); q.Has("delimiter") && q.Get("delimiter") == "" {
+			q.Del("delimiter")
+			r.URL.RawQuery = q.Encode()
+		}
+		faker.ServeHTTP(w, r)
+	}))
+
+	client, err := minio.New(strings.TrimPrefix(srv.URL, "https://"), &minio.Options{
+		Creds:     credentials.NewStaticV4("access-key", "secret-key", ""),
+		Secure:    true,
+		Transport: srv.Client().Transport,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.MakeBucket(context.Background(), testBucket, minio.MakeBucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	return srv, client
+}
@@ -0,0 +1,119 @@
+package sftp
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	// ErrMissingPrivateKey is returned when there is no private key in the
+	// configuration.
+	ErrMissingPrivateKey = errors.New("missing private key")
+	// ErrMissingHostKey is returned when there is no way to check the host
+	// key of the server in the configuration.
+	ErrMissingHostKey = errors.New("missing host key")
+)
+
+// Config is the configuration used to connect to the SSH server.
+type Config struct {
+	// User is the user used to log in on the SSH server.
+	User string
+	// PrivateKey is the PEM encoded private key used to authenticate.
+	PrivateKey []byte
+	// Passphrase is the passphrase of the private key, if it is encrypted.
+	Passphrase []byte
+	// HostKey is the expected public key of the server.
+	HostKey ssh.PublicKey
+	// HostKeyCallback checks the public key of the server, like the one
+	// returned by knownhosts.New. It is used instead of HostKey if set.
+	HostKeyCallback ssh.HostKeyCallback
+	// InsecureIgnoreHostKey accepts any public key from the server, when
+	// neither HostKey nor HostKeyCallback are set. It should only be used for
+	// testing.
+	InsecureIgnoreHostKey bool
+}
+
+func (c Config) signer() (ssh.Signer, error) {
+	if len(c.PrivateKey) == 0 {
+		return nil, ErrMissingPrivateKey
+	}
+
+	if len(c.Passphrase) > 0 {
+		return ssh.ParsePrivateKeyWithPassphrase(c.PrivateKey, c.Passphrase)
+	}
+
+	return ssh.ParsePrivateKey(c.PrivateKey)
+}
+
+func (c Config) hostKeyCallback() (ssh.HostKeyCallback, error) {
+	switch {
+	case c.HostKeyCallback != nil:
+		return c.HostKeyCallback, nil
+	case c.HostKey != nil:
+		return ssh.FixedHostKey(c.HostKey), nil
+	case c.InsecureIgnoreHostKey:
+		return ssh.InsecureIgnoreHostKey(), nil //nolint:gosec
+	default:
+		return nil, ErrMissingHostKey
+	}
+}
+
+func (c Config) clientConfig() (*ssh.ClientConfig, error) {
+	signer, err := c.signer()
+	if err != nil {
+		return nil, err
+	}
+
+	hostKeyCallback, err := c.hostKeyCallback()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ssh.ClientConfig{
+		User:            c.User,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKeyCallback,
+	}, nil
+}
+
+// Connection is a SSH connection with its SFTP session. It should be shared
+// between every directory and file of the same storage.
+type Connection struct {
+	ssh  *ssh.Client
+	sftp *sftp.Client
+}
+
+// Connect opens a new SSH connection on the address and starts a SFTP
+// session on it.
+func Connect(addr string, config Config) (*Connection, error) {
+	clientConfig, err := config.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Connect to SSH server
+	sshClient, err := ssh.Dial("tcp", addr, clientConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// Start SFTP session
+	sftpClient, err := sftp.NewClient(sshClient)
+	if err != nil {
+		_ = sshClient.Close()
+		return nil, fmt.Errorf("starting sftp session: %w", err)
+	}
+
+	return &Connection{
+		ssh:  sshClient,
+		sftp: sftpClient,
+	}, nil
+}
+
+// Close closes the SFTP session and the SSH connection.
+func (c *Connection) Close() error {
+	return errors.Join(c.sftp.Close(), c.ssh.Close())
+}
@@ -0,0 +1,63 @@
+package sftp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestConnectionSuite(t *testing.T) {
+	suite.Run(t, new(ConnectionSuite))
+}
+
+type ConnectionSuite struct {
+	Server *testServer
+	suite.Suite
+}
+
+func (suite *ConnectionSuite) SetupTest() {
+	suite.Server = newTestServer(suite.T())
+}
+
+func (suite *ConnectionSuite) TearDownTest() {
+	suite.Server.Close()
+}
+
+func (suite *ConnectionSuite) TestConnectWithoutHostKey() {
+	_, err := Connect(suite.Server.listener.Addr().String(), Config{
+		User:       "user",
+		PrivateKey: suite.Server.clientKey,
+	})
+	suite.Require().ErrorIs(err, ErrMissingHostKey)
+}
+
+func (suite *ConnectionSuite) TestConnectWithHostKeyCallback() {
+	// Check a wrong host key is rejected
+	other, _ := newTestKey(suite.T())
+	_, err := Connect(suite.Server.listener.Addr().String(), Config{
+		User:            "user",
+		PrivateKey:      suite.Server.clientKey,
+		HostKeyCallback: ssh.FixedHostKey(other.PublicKey()),
+	})
+	suite.Require().Error(err)
+
+	// Check the right one is accepted
+	conn, err := Connect(suite.Server.listener.Addr().String(), Config{
+		User:            "user",
+		PrivateKey:      suite.Server.clientKey,
+		HostKeyCallback: ssh.FixedHostKey(suite.Server.hostKey),
+	})
+	suite.Require().NoError(err)
+	suite.Require().NoError(conn.Close())
+}
+
+func (suite *ConnectionSuite) TestConnectInsecure() {
+	conn, err := Connect(suite.Server.listener.Addr().String(), Config{
+		User:                  "user",
+		PrivateKey:            suite.Server.clientKey,
+		InsecureIgnoreHostKey: true,
+	})
+	suite.Require().NoError(err)
+	suite.Require().NoError(conn.Close())
+}
@@ -0,0 +1,368 @@
+package sftp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+var _ storage.Directory = (*directory)(nil)
+
+// attributesFileName is the name of the file holding the attributes of a
+// directory, inside of it.
+const attributesFileName = ".attributes"
+
+type directory struct {
+	conn *Connection
+	path string
+}
+
+// NewDirectory creates a new directory representation on the remote path,
+// using the SSH connection.
+func NewDirectory(conn *Connection, path string) storage.Directory {
+	return newDirectory(conn, path)
+}
+
+func newDirectory(conn *Connection, path string) *directory {
+	return &directory{
+		conn: conn,
+		path: path,
+	}
+}
+
+func (d *directory) getChildPath(name string) string {
+	return path.Join(d.path, name)
+}
+
+func (d *directory) getChildMetadataPath(name string) string {
+	return path.Join(d.getChildPath(name), metadataFileName)
+}
+
+func (d *directory) ensureChildDoesNotExists(name string) error {
+	path := d.getChildPath(name)
+	metadataPath := d.getChildMetadataPath(name)
+
+	_, err := d.conn.sftp.Stat(path)
+	if err == nil {
+		// Check if there is a metadata file
+		_, err = d.conn.sftp.Stat(metadataPath)
+		if err == nil {
+			return fmt.Errorf("%w: %q", storage.ErrFileAlreadyExists, name)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		return fmt.Errorf("%w: %q", storage.ErrDirectoryAlreadyExists, name)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+func (d *directory) writeChildMetadata(name string, info info.File) error {
+	return writeMetadata(d.conn, d.getChildPath(name), info)
+}
+
+func (d *directory) readChildMetadata(name string) (info.File, error) {
+	return readMetadata(d.conn, d.getChildPath(name))
+}
+
+// CreateDirectory creates a directory.
+func (d *directory) CreateDirectory(_ context.Context, name string) (storage.Directory, error) {
+	// Check if a file or a directory exists
+	if err := d.ensureChildDoesNotExists(name); err != nil {
+		return nil, err
+	}
+
+	// Create directory and return representation
+	path := d.getChildPath(name)
+	return newDirectory(d.conn, path), d.conn.sftp.Mkdir(path)
+}
+
+// GetDirectory returns a child directory of the directory.
+func (d *directory) GetDirectory(_ context.Context, name string) (storage.Directory, error) {
+	path := d.getChildPath(name)
+
+	// Check if directory exists
+	err := d.ensureChildDoesNotExists(name)
+	switch {
+	case err == nil:
+		return nil, fmt.Errorf("%w: %q", storage.ErrDirectoryNotFound, name)
+	case errors.Is(err, storage.ErrFileAlreadyExists):
+		return nil, fmt.Errorf("%w: %q", storage.ErrIsFile, name)
+	case !errors.Is(err, storage.ErrDirectoryAlreadyExists):
+		return nil, err
+	}
+
+	// Return representation
+	return newDirectory(d.conn, path), nil
+}
+
+// GetInfo returns the directory information.
+func (d *directory) GetInfo(_ context.Context) (info.Directory, error) {
+	// Read attributes, if they have been set
+	data, err := readRemoteFile(d.conn, path.Join(d.path, attributesFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return info.Directory{}, nil
+	} else if err != nil {
+		return info.Directory{}, err
+	}
+
+	var dirInfo info.Directory
+	if err := json.Unmarshal(data, &dirInfo.Attributes); err != nil {
+		return info.Directory{}, err
+	}
+
+	return dirInfo, nil
+}
+
+// SetAttributes sets the directory attributes.
+func (d *directory) SetAttributes(_ context.Context, attr info.Attributes) error {
+	data, err := json.Marshal(attr)
+	if err != nil {
+		return err
+	}
+
+	return writeRemoteFile(d.conn, path.Join(d.path, attributesFileName), data)
+}
+
+// CreateFile creates a file.
+func (d *directory) CreateFile(_ context.Context, name string, info info.File) (storage.File, error) {
+	path := d.getChildPath(name)
+
+	// Check if there is a file with this name
+	if err := d.ensureChildDoesNotExists(name); err != nil {
+		return nil, err
+	}
+
+	// Create file representation
+	f, err := newFile(d.conn, path, info)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create directory representing the file
+	if err := d.conn.sftp.Mkdir(path); err != nil {
+		return nil, err
+	}
+
+	return f, d.writeChildMetadata(name, info)
+}
+
+// GetFile returns a child file.
+func (d *directory) GetFile(_ context.Context, name string) (storage.File, error) {
+	// Check if file exists
+	err := d.ensureChildDoesNotExists(name)
+	switch {
+	case err == nil:
+		return nil, fmt.Errorf("%w: %q", storage.ErrFileNotFound, name)
+	case errors.Is(err, storage.ErrDirectoryAlreadyExists):
+		return nil, fmt.Errorf("%w: %q", storage.ErrIsDirectory, name)
+	case !errors.Is(err, storage.ErrFileAlreadyExists):
+		return nil, err
+	}
+
+	// Read metadata
+	info, err := d.readChildMetadata(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create file representation
+	path := d.getChildPath(name)
+	return newFile(d.conn, path, info)
+}
+
+// ListFiles returns a map of files.
+func (d *directory) ListFiles(_ context.Context) (map[string]storage.File, error) {
+	entries, err := d.conn.sftp.ReadDir(d.path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]storage.File)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		// Read metadata
+		info, err := d.readChildMetadata(entry.Name())
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		} else if errors.Is(err, fs.ErrNotExist) {
+			// This is a directory
+			continue
+		}
+
+		// Create file representation
+		f, err := newFile(d.conn, d.getChildPath(entry.Name()), info)
+		if err != nil {
+			return nil, err
+		}
+
+		files[entry.Name()] = f
+	}
+
+	return files, nil
+}
+
+// RemoveDirectory removes a directory.
+func (d *directory) RemoveDirectory(_ context.Context, name string) error {
+	// Check if directory exists
+	err := d.ensureChildDoesNotExists(name)
+	switch {
+	case err == nil:
+		return fmt.Errorf("%w: %q", storage.ErrDirectoryNotFound, name)
+	case errors.Is(err, storage.ErrFileAlreadyExists):
+		return fmt.Errorf("%w: %q", storage.ErrIsFile, name)
+	case !errors.Is(err, storage.ErrDirectoryAlreadyExists):
+		return err
+	}
+
+	// Remove the attributes, if this is the only thing in the directory
+	p := d.getChildPath(name)
+	entries, err := d.conn.sftp.ReadDir(p)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 1 && entries[0].Name() == attributesFileName {
+		if err := d.conn.sftp.Remove(path.Join(p, attributesFileName)); err != nil {
+			return err
+		}
+	}
+
+	// Remove directory
+	return d.conn.sftp.RemoveDirectory(p)
+}
+
+// ListDirectories returns a map of directories.
+func (d *directory) ListDirectories(_ context.Context) (map[string]storage.Directory, error) {
+	entries, err := d.conn.sftp.ReadDir(d.path)
+	if err != nil {
+		return nil, err
+	}
+
+	directories := make(map[string]storage.Directory)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		// Check if there is metadata
+		metadataPath := d.getChildMetadataPath(entry.Name())
+		_, err = d.conn.sftp.Stat(metadataPath)
+		if err == nil {
+			// This is a file
+			continue
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+
+		// Create directory representation
+		directories[entry.Name()] = newDirectory(d.conn, d.getChildPath(entry.Name()))
+	}
+
+	return directories, nil
+}
+
+// RemoveFile removes a file.
+func (d *directory) RemoveFile(_ context.Context, name string) error {
+	// Check if file or directory exists
+	err := d.ensureChildDoesNotExists(name)
+	switch {
+	case err == nil:
+		return fmt.Errorf("%w: %q", storage.ErrFileNotFound, name)
+	case errors.Is(err, storage.ErrDirectoryAlreadyExists):
+		return fmt.Errorf("%w: %q", storage.ErrIsDirectory, name)
+	case !errors.Is(err, storage.ErrFileAlreadyExists):
+		return err
+	}
+
+	return d.conn.sftp.RemoveAll(d.getChildPath(name))
+}
+
+// RenameFile renames a file.
+func (d *directory) RenameFile(
+	_ context.Context,
+	name string,
+	newParent storage.Directory,
+	newName string,
+	noReplace bool,
+) error {
+	// Check if file exists
+	err := d.ensureChildDoesNotExists(name)
+	switch {
+	case err == nil:
+		return fmt.Errorf("%w: %q", storage.ErrFileNotFound, name)
+	case errors.Is(err, storage.ErrDirectoryAlreadyExists):
+		return fmt.Errorf("%w: %q", storage.ErrIsDirectory, name)
+	case !errors.Is(err, storage.ErrFileAlreadyExists):
+		return err
+	}
+
+	// Check if there is a file with the new name
+	newPath := newParent.(*directory).getChildPath(newName)
+	err = newParent.(*directory).ensureChildDoesNotExists(newName)
+	switch {
+	case err == nil:
+		// Nothing to do
+	case !noReplace && (errors.Is(err, storage.ErrDirectoryAlreadyExists) ||
+		errors.Is(err, storage.ErrFileAlreadyExists)):
+		if err := d.conn.sftp.RemoveAll(newPath); err != nil {
+			return err
+		}
+	default:
+		return err
+	}
+
+	// Move the file
+	oldPath := d.getChildPath(name)
+	return d.conn.sftp.Rename(oldPath, newPath)
+}
+
+// RenameDirectory renames a directory.
+func (d *directory) RenameDirectory(
+	_ context.Context,
+	name string,
+	newParent storage.Directory,
+	newName string,
+	noReplace bool,
+) error {
+	// Check if directory exists
+	err := d.ensureChildDoesNotExists(name)
+	switch {
+	case err == nil:
+		return fmt.Errorf("%w: %q", storage.ErrDirectoryNotFound, name)
+	case errors.Is(err, storage.ErrFileAlreadyExists):
+		return fmt.Errorf("%w: %q", storage.ErrIsFile, name)
+	case !errors.Is(err, storage.ErrDirectoryAlreadyExists):
+		return err
+	}
+
+	// Check if there is a directory with the new name
+	newPath := newParent.(*directory).getChildPath(newName)
+	err = newParent.(*directory).ensureChildDoesNotExists(newName)
+	switch {
+	case err == nil:
+		// Nothing to do
+	case !noReplace && (errors.Is(err, storage.ErrDirectoryAlreadyExists) ||
+		errors.Is(err, storage.ErrFileAlreadyExists)):
+		if err := d.conn.sftp.RemoveAll(newPath); err != nil {
+			return err
+		}
+	default:
+		return err
+	}
+
+	// Move the directory
+	oldPath := d.getChildPath(name)
+	return d.conn.sftp.Rename(oldPath, newPath)
+}
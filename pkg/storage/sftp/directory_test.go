@@ -0,0 +1,51 @@
+package sftp
+
+import (
+	"testing"
+
+	"github.com/lerenn/chonkfs/pkg/storage/layer"
+	"github.com/lerenn/chonkfs/pkg/storage/mem"
+	"github.com/lerenn/chonkfs/pkg/storage/test"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestDirectorySuite(t *testing.T) {
+	suite.Run(t, new(DirectorySuite))
+	suite.Run(t, new(DirectoryAsUnderlayerSuite))
+}
+
+type DirectorySuite struct {
+	test.DirectorySuite
+	Server *testServer
+	Conn   *Connection
+}
+
+func (suite *DirectorySuite) SetupTest() {
+	suite.Server = newTestServer(suite.T())
+	suite.Conn = suite.Server.Connect(suite.T())
+	suite.Directory = NewDirectory(suite.Conn, suite.Server.root)
+}
+
+func (suite *DirectorySuite) TearDownTest() {
+	suite.Require().NoError(suite.Conn.Close())
+	suite.Server.Close()
+}
+
+type DirectoryAsUnderlayerSuite struct {
+	layer.DirectorySuite
+	Server *testServer
+	Conn   *Connection
+}
+
+func (suite *DirectoryAsUnderlayerSuite) SetupTest() {
+	suite.Server = newTestServer(suite.T())
+	suite.Conn = suite.Server.Connect(suite.T())
+	suite.Upperlayer = mem.NewDirectory()
+	suite.Underlayer = NewDirectory(suite.Conn, suite.Server.root)
+	suite.Directory, _ = layer.NewDirectory(suite.Upperlayer, suite.Underlayer)
+}
+
+func (suite *DirectoryAsUnderlayerSuite) TearDownTest() {
+	suite.Require().NoError(suite.Conn.Close())
+	suite.Server.Close()
+}
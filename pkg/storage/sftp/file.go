@@ -0,0 +1,383 @@
+package sftp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+const (
+	metadataFileName = ".metadata"
+)
+
+var _ storage.File = (*file)(nil)
+
+type file struct {
+	conn *Connection
+	path string
+}
+
+func newFile(conn *Connection, path string, info info.File) (*file, error) {
+	if info.ChunkSize <= 0 {
+		return nil, fmt.Errorf("%w: chunk size must be greater than 0", storage.ErrInvalidChunkSize)
+	}
+
+	return &file{
+		conn: conn,
+		path: path,
+	}, nil
+}
+
+func writeRemoteFile(conn *Connection, p string, data []byte) error {
+	// Create remote file
+	f, err := conn.sftp.Create(p)
+	if err != nil {
+		return err
+	}
+
+	// Write data
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func readRemoteFile(conn *Connection, p string) ([]byte, error) {
+	// Open remote file
+	f, err := conn.sftp.Open(p)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read data
+	data, err := io.ReadAll(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return data, f.Close()
+}
+
+func writeMetadata(conn *Connection, p string, info info.File) error {
+	metadataPath := path.Join(p, metadataFileName)
+
+	// Encode info
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	// Create metadata file
+	if err := writeRemoteFile(conn, metadataPath, data); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func readMetadata(conn *Connection, p string) (info.File, error) {
+	metadataPath := path.Join(p, metadataFileName)
+
+	// Read metadata
+	data, err := readRemoteFile(conn, metadataPath)
+	if err != nil {
+		return info.File{}, err
+	}
+
+	var info info.File
+	if err := json.Unmarshal(data, &info); err != nil {
+		return info, err
+	}
+
+	return info, nil
+}
+
+func getChunkName(nb int) string {
+	return fmt.Sprintf("chunk-%d.dat", nb)
+}
+
+func (f *file) getChunckPath(nb int) string {
+	return path.Join(f.path, getChunkName(nb))
+}
+
+func (f *file) checkImportChunkParams(info info.File, index int, data []byte) error {
+	// Check if chunk index is correct
+	if index < 0 || index >= info.ChunksCount {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkNb, index)
+	}
+
+	// Check if the chunk is empty
+	chunkPath := f.getChunckPath(index)
+	if _, err := f.conn.sftp.Stat(chunkPath); err == nil {
+		return fmt.Errorf("%w: %d", storage.ErrChunkAlreadyExists, index)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	// Check if length of data is correct
+	if (len(data) != info.ChunkSize && index != info.ChunksCount-1) || len(data) > info.ChunkSize {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkSize, len(data))
+	}
+
+	return nil
+}
+
+// ImportChunk imports a chunk of data.
+func (f *file) ImportChunk(ctx context.Context, index int, data []byte) error {
+	// Get info
+	info, err := f.GetInfo(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Check params
+	if err := f.checkImportChunkParams(info, index, data); err != nil {
+		return err
+	}
+
+	// Import data
+	chunkPath := f.getChunckPath(index)
+	if err := writeRemoteFile(f.conn, chunkPath, data); err != nil {
+		return err
+	}
+
+	// If this is the last chunk, set the last chunk size
+	if index == info.ChunksCount-1 {
+		info.LastChunkSize = len(data)
+		if err := f.saveInfo(info); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetInfo returns the file info.
+func (f *file) GetInfo(_ context.Context) (info.File, error) {
+	return readMetadata(f.conn, f.path)
+}
+
+// SetAttributes sets the file attributes.
+func (f *file) SetAttributes(_ context.Context, attr info.Attributes) error {
+	info, err := readMetadata(f.conn, f.path)
+	if err != nil {
+		return err
+	}
+
+	info.Attributes = attr
+	return f.saveInfo(info)
+}
+
+func (f *file) saveInfo(info info.File) error {
+	return writeMetadata(f.conn, f.path, info)
+}
+
+func (f *file) checkReadWriteChunkParams(info info.File, index int, offset int) error {
+	// Check if chunk index is correct
+	if index < 0 || index >= info.ChunksCount {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkNb, index)
+	}
+
+	// Check if there is data to read
+	chunkPath := f.getChunckPath(index)
+	if _, err := f.conn.sftp.Stat(chunkPath); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("%w", storage.ErrChunkNotFound)
+		}
+		return err
+	}
+
+	// Check if offset is correct
+	if offset < 0 || offset >= info.ChunkSize {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidOffset, offset)
+	}
+
+	// Check if this is the last chunk, that the offset is correct
+	if index == info.ChunksCount-1 && offset >= info.LastChunkSize {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidOffset, offset)
+	}
+
+	return nil
+}
+
+// WriteChunk writes a chunk of data.
+func (f *file) WriteChunk(ctx context.Context, index int, data []byte, offset int) (int, error) {
+	// Get info
+	info, err := f.GetInfo(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Check params
+	if err := f.checkReadWriteChunkParams(info, index, offset); err != nil {
+		return 0, err
+	}
+
+	// Open file
+	chunkPath := f.getChunckPath(index)
+	file, err := f.conn.sftp.OpenFile(chunkPath, os.O_WRONLY)
+	if err != nil {
+		return 0, err
+	}
+
+	// Limit data to write if it is too long
+	if len(data) > info.ChunkSize-offset {
+		data = data[:info.ChunkSize-offset]
+	}
+
+	// Write data
+	n, err := file.WriteAt(data, int64(offset))
+	if err != nil {
+		_ = file.Close()
+		return 0, err
+	}
+
+	// Close file
+	return n, file.Close()
+}
+
+// ReadChunk reads a chunk of data.
+func (f *file) ReadChunk(ctx context.Context, index int, data []byte, offset int) (int, error) {
+	// Get info
+	info, err := f.GetInfo(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Check params
+	if err := f.checkReadWriteChunkParams(info, index, offset); err != nil {
+		return 0, err
+	}
+
+	// Open file
+	chunkPath := f.getChunckPath(index)
+	file, err := f.conn.sftp.Open(chunkPath)
+	if err != nil {
+		return 0, err
+	}
+
+	// Limit data to read to the chunk size, to avoid a useless round trip
+	chunkSize := info.ChunkSize
+	if index == info.ChunksCount-1 {
+		chunkSize = info.LastChunkSize
+	}
+	if len(data) > chunkSize-offset {
+		data = data[:chunkSize-offset]
+	}
+
+	// Read data
+	n, err := file.ReadAt(data, int64(offset))
+	if err != nil && !errors.Is(err, io.EOF) {
+		_ = file.Close()
+		return 0, err
+	}
+
+	// Close file
+	return n, file.Close()
+}
+
+// ResizeChunksNb resizes the number of chunks.
+func (f *file) ResizeChunksNb(ctx context.Context, size int) error {
+	// Check size is correct
+	if size < 0 {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkNb, size)
+	}
+
+	// Get actual info
+	info, err := f.GetInfo(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Check the last chunk size is full
+	if info.ChunksCount > 0 && info.LastChunkSize != info.ChunkSize {
+		return fmt.Errorf("%w", storage.ErrLastChunkNotFull)
+	}
+
+	// Resize chunks
+	if size > info.ChunksCount {
+		// Add chunks
+		for i := info.ChunksCount; i < size; i++ {
+			path := f.getChunckPath(i)
+			if err := writeRemoteFile(f.conn, path, make([]byte, info.ChunkSize)); err != nil {
+				return err
+			}
+		}
+	} else {
+		// Remove chunks
+		for i := size; i < info.ChunksCount; i++ {
+			path := f.getChunckPath(i)
+			if err := f.conn.sftp.Remove(path); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Update info
+	info.ChunksCount = size
+	info.LastChunkSize = info.ChunkSize
+	return f.saveInfo(info)
+}
+
+func (f *file) checkResizeLastChunkParams(info info.File, size int) error {
+	// Check size is correct
+	if size < 0 || size > info.ChunkSize {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkSize, size)
+	}
+
+	// Check if there is a last chunk
+	if info.ChunksCount == 0 {
+		return fmt.Errorf("%w", storage.ErrNoChunk)
+	}
+
+	// Check if the last chunk is present
+	lastChunkPath := f.getChunckPath(info.ChunksCount - 1)
+	if _, err := f.conn.sftp.Stat(lastChunkPath); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("%w", storage.ErrChunkNotFound)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// ResizeLastChunk resizes the last chunk.
+func (f *file) ResizeLastChunk(ctx context.Context, size int) (changed int, err error) {
+	// Get actual info
+	info, err := f.GetInfo(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Check params
+	if err := f.checkResizeLastChunkParams(info, size); err != nil {
+		return 0, err
+	}
+
+	// Resize last chunk, truncate will fill with zeros if the size is bigger
+	lastChunkPath := f.getChunckPath(info.ChunksCount - 1)
+	lastChunkSize := info.LastChunkSize
+	if err := f.conn.sftp.Truncate(lastChunkPath, int64(size)); err != nil {
+		return 0, err
+	}
+
+	// Set size
+	info.LastChunkSize = size
+	if err := f.saveInfo(info); err != nil {
+		return 0, err
+	}
+
+	return size - lastChunkSize, nil
+}
@@ -0,0 +1,51 @@
+package sftp
+
+import (
+	"testing"
+
+	"github.com/lerenn/chonkfs/pkg/storage/layer"
+	"github.com/lerenn/chonkfs/pkg/storage/mem"
+	"github.com/lerenn/chonkfs/pkg/storage/test"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestFileSuite(t *testing.T) {
+	suite.Run(t, new(FileSuite))
+	suite.Run(t, new(FileAsUnderlayerSuite))
+}
+
+type FileSuite struct {
+	test.FileSuite
+	Server *testServer
+	Conn   *Connection
+}
+
+func (suite *FileSuite) SetupTest() {
+	suite.Server = newTestServer(suite.T())
+	suite.Conn = suite.Server.Connect(suite.T())
+	suite.Directory = NewDirectory(suite.Conn, suite.Server.root)
+}
+
+func (suite *FileSuite) TearDownTest() {
+	suite.Require().NoError(suite.Conn.Close())
+	suite.Server.Close()
+}
+
+type FileAsUnderlayerSuite struct {
+	layer.FileSuite
+	Server *testServer
+	Conn   *Connection
+}
+
+func (suite *FileAsUnderlayerSuite) SetupTest() {
+	suite.Server = newTestServer(suite.T())
+	suite.Conn = suite.Server.Connect(suite.T())
+	suite.Upperlayer = mem.NewDirectory()
+	suite.Underlayer = NewDirectory(suite.Conn, suite.Server.root)
+	suite.Directory, _ = layer.NewDirectory(suite.Upperlayer, suite.Underlayer)
+}
+
+func (suite *FileAsUnderlayerSuite) TearDownTest() {
+	suite.Require().NoError(suite.Conn.Close())
+	suite.Server.Close()
+}
@@ -0,0 +1,152 @@
+package sftp
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// testServer is an in-process SSH server, serving the SFTP subsystem on the
+// local file system, that only accepts one client key.
+type testServer struct {
+	listener  net.Listener
+	config    *ssh.ServerConfig
+	hostKey   ssh.PublicKey
+	clientKey []byte
+	root      string
+}
+
+func newTestKey(t *testing.T) (ssh.Signer, []byte) {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signer, pem.EncodeToMemory(block)
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	hostSigner, _ := newTestKey(t)
+	clientSigner, clientKey := newTestKey(t)
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(clientSigner.PublicKey().Marshal()) {
+				return nil, errors.New("unknown public key")
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &testServer{
+		listener:  l,
+		config:    config,
+		hostKey:   hostSigner.PublicKey(),
+		clientKey: clientKey,
+		root:      t.TempDir(),
+	}
+	go s.serve()
+
+	return s
+}
+
+// Connect creates a new connection to the server, with the accepted key.
+func (s *testServer) Connect(t *testing.T) *Connection {
+	t.Helper()
+
+	conn, err := Connect(s.listener.Addr().String(), Config{
+		User:       "user",
+		PrivateKey: s.clientKey,
+		HostKey:    s.hostKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return conn
+}
+
+func (s *testServer) Close() {
+	_ = s.listener.Close()
+}
+
+func (s *testServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go s.handle(conn)
+	}
+}
+
+func (s *testServer) handle(conn net.Conn) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+
+		go s.handleSession(channel, requests)
+	}
+}
+
+func (s *testServer) handleSession(channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+
+	for req := range requests {
+		// Only accept the SFTP subsystem
+		ok := req.Type == "subsystem" && string(req.Payload[4:]) == "sftp"
+		_ = req.Reply(ok, nil)
+		if !ok {
+			continue
+		}
+
+		server, err := sftp.NewServer(channel)
+		if err != nil {
+			return
+		}
+		_ = server.Serve()
+		_ = server.Close()
+		return
+	}
+}
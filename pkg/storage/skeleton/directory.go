@@ -32,6 +32,11 @@ func (d *directory) GetInfo(_ context.Context) (info.Directory, error) {
 	return info.Directory{}, fmt.Errorf("not implemented")
 }
 
+// SetAttributes sets the directory attributes.
+func (d *directory) SetAttributes(_ context.Context, _ info.Attributes) error {
+	return fmt.Errorf("not implemented")
+}
+
 // CreateFile creates a file.
 func (d *directory) CreateFile(_ context.Context, _ string, info info.File) (storage.File, error) {
 	_, _ = newFile(info)
@@ -84,3 +89,23 @@ func (d *directory) RenameDirectory(
 ) error {
 	return fmt.Errorf("not implemented")
 }
+
+// GetXattr returns the value of an extended attribute.
+func (d *directory) GetXattr(_ context.Context, _ string) ([]byte, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+// ListXattrs returns the names of the extended attributes.
+func (d *directory) ListXattrs(_ context.Context) ([]string, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+// SetXattr creates or replaces an extended attribute.
+func (d *directory) SetXattr(_ context.Context, _ string, _ []byte) error {
+	return fmt.Errorf("not implemented")
+}
+
+// RemoveXattr removes an extended attribute.
+func (d *directory) RemoveXattr(_ context.Context, _ string) error {
+	return fmt.Errorf("not implemented")
+}
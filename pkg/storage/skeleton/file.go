@@ -24,6 +24,10 @@ func (f *file) GetInfo(_ context.Context) (info.File, error) {
 	return info.File{}, fmt.Errorf("not implemented")
 }
 
+func (f *file) SetAttributes(_ context.Context, _ info.Attributes) error {
+	return fmt.Errorf("not implemented")
+}
+
 func (f *file) WriteChunk(_ context.Context, _ int, _ []byte, _ int) (int, error) {
 	return 0, fmt.Errorf("not implemented")
 }
@@ -39,3 +43,23 @@ func (f *file) ResizeChunksNb(_ context.Context, _ int) error {
 func (f *file) ResizeLastChunk(_ context.Context, _ int) (int, error) {
 	return 0, fmt.Errorf("not implemented")
 }
+
+// GetXattr returns the value of an extended attribute.
+func (f *file) GetXattr(_ context.Context, _ string) ([]byte, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+// ListXattrs returns the names of the extended attributes.
+func (f *file) ListXattrs(_ context.Context) ([]string, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+// SetXattr creates or replaces an extended attribute.
+func (f *file) SetXattr(_ context.Context, _ string, _ []byte) error {
+	return fmt.Errorf("not implemented")
+}
+
+// RemoveXattr removes an extended attribute.
+func (f *file) RemoveXattr(_ context.Context, _ string) error {
+	return fmt.Errorf("not implemented")
+}
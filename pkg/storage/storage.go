@@ -8,12 +8,15 @@ import (
 
 // Directory represents a directory in the storage.
 type Directory interface {
+	Xattrer
+
 	// Directories
 
 	CreateDirectory(ctx context.Context, name string) (Directory, error)
 	GetDirectory(ctx context.Context, name string) (Directory, error)
 	ListDirectories(ctx context.Context) (map[string]Directory, error)
 	GetInfo(ctx context.Context) (info.Directory, error)
+	SetAttributes(ctx context.Context, attr info.Attributes) error
 	RemoveDirectory(ctx context.Context, name string) error
 	RenameDirectory(ctx context.Context, name string, newParent Directory, newName string, noReplace bool) error
 
@@ -28,10 +31,53 @@ type Directory interface {
 
 // File represents a file in the storage.
 type File interface {
+	Xattrer
+
 	ImportChunk(ctx context.Context, index int, data []byte) error
 	WriteChunk(ctx context.Context, index int, data []byte, offset int) (int, error)
 	ReadChunk(ctx context.Context, index int, data []byte, offset int) (int, error)
 	ResizeChunksNb(ctx context.Context, size int) error
 	ResizeLastChunk(ctx context.Context, size int) (changed int, err error)
 	GetInfo(ctx context.Context) (info.File, error)
+	SetAttributes(ctx context.Context, attr info.Attributes) error
+}
+
+// SparseFile is a file that can tell which chunks hold data, as opposed to the
+// holes created by a resize.
+type SparseFile interface {
+	File
+
+	// HasChunkData returns true if the chunk holds data and false if this is a
+	// hole. It returns ErrChunkNotFound if the chunk is not present on the medium.
+	HasChunkData(ctx context.Context, index int) (bool, error)
+}
+
+// Syncer is a file that can keep data before writing it on its medium.
+type Syncer interface {
+	// Sync writes the kept data on the medium.
+	Sync(ctx context.Context) error
+}
+
+// ChunkPinner is a file that can drop chunks by itself, and that can be told
+// to keep some of them.
+type ChunkPinner interface {
+	// PinChunk prevents the chunk from being dropped.
+	PinChunk(ctx context.Context, index int) error
+	// UnpinChunk allows the chunk to be dropped again.
+	UnpinChunk(ctx context.Context, index int) error
+}
+
+// Xattrer is the extended attributes of a file or a directory. The storages
+// that cannot store them return ErrXattrNotSupported.
+type Xattrer interface {
+	// GetXattr returns the value of the extended attribute, or
+	// ErrXattrNotFound if there is none with this name.
+	GetXattr(ctx context.Context, name string) ([]byte, error)
+	// ListXattrs returns the names of the extended attributes.
+	ListXattrs(ctx context.Context) ([]string, error)
+	// SetXattr creates or replaces the extended attribute.
+	SetXattr(ctx context.Context, name string, value []byte) error
+	// RemoveXattr removes the extended attribute, or returns ErrXattrNotFound
+	// if there is none with this name.
+	RemoveXattr(ctx context.Context, name string) error
 }
@@ -0,0 +1,235 @@
+package stripe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+var (
+	// ErrNoStripe happens when a stripe directory is created without storage.
+	ErrNoStripe = errors.New("no stripe storage")
+	// ErrNotStripe happens when a stripe operation is called on another
+	// storage.
+	ErrNotStripe = errors.New("not a stripe storage")
+)
+
+var _ storage.Directory = (*directory)(nil)
+
+type directory struct {
+	stripes []storage.Directory
+}
+
+// NewDirectory creates a new directory representation, keeping the same tree
+// and files metadata on every stripe and spreading the chunks of the files
+// across them, the chunk at index i being stored on the stripe i mod N.
+func NewDirectory(stripes []storage.Directory) (storage.Directory, error) {
+	if len(stripes) == 0 {
+		return nil, ErrNoStripe
+	}
+
+	return newDirectory(stripes), nil
+}
+
+func newDirectory(stripes []storage.Directory) *directory {
+	return &directory{
+		stripes: stripes,
+	}
+}
+
+// CreateDirectory creates a directory.
+func (d *directory) CreateDirectory(ctx context.Context, name string) (storage.Directory, error) {
+	children, err := onAll(d.stripes, func(s storage.Directory) (storage.Directory, error) {
+		return s.CreateDirectory(ctx, name)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return newDirectory(children), nil
+}
+
+// GetDirectory returns a directory.
+func (d *directory) GetDirectory(ctx context.Context, name string) (storage.Directory, error) {
+	children, err := onAll(d.stripes, func(s storage.Directory) (storage.Directory, error) {
+		return s.GetDirectory(ctx, name)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return newDirectory(children), nil
+}
+
+// GetInfo returns the directory info.
+func (d *directory) GetInfo(ctx context.Context) (info.Directory, error) {
+	return d.stripes[0].GetInfo(ctx)
+}
+
+// SetAttributes sets the directory attributes, on every stripe.
+func (d *directory) SetAttributes(ctx context.Context, attr info.Attributes) error {
+	_, err := onAll(d.stripes, func(s storage.Directory) (struct{}, error) {
+		return struct{}{}, s.SetAttributes(ctx, attr)
+	})
+	return err
+}
+
+// CreateFile creates a file.
+func (d *directory) CreateFile(ctx context.Context, name string, info info.File) (storage.File, error) {
+	children, err := onAll(d.stripes, func(s storage.Directory) (storage.File, error) {
+		return s.CreateFile(ctx, name, info)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return newFile(children), nil
+}
+
+// GetFile returns a file.
+func (d *directory) GetFile(ctx context.Context, name string) (storage.File, error) {
+	children, err := onAll(d.stripes, func(s storage.Directory) (storage.File, error) {
+		return s.GetFile(ctx, name)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return newFile(children), nil
+}
+
+// ListFiles returns a map of files.
+func (d *directory) ListFiles(ctx context.Context) (map[string]storage.File, error) {
+	lists, err := onAll(d.stripes, func(s storage.Directory) (map[string]storage.File, error) {
+		return s.ListFiles(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]storage.File, len(lists[0]))
+	for name := range lists[0] {
+		children, err := pick(lists, name, storage.ErrFileNotFound)
+		if err != nil {
+			return nil, err
+		}
+		files[name] = newFile(children)
+	}
+
+	return files, nil
+}
+
+// RemoveDirectory removes a directory.
+func (d *directory) RemoveDirectory(ctx context.Context, name string) error {
+	_, err := onAll(d.stripes, func(s storage.Directory) (struct{}, error) {
+		return struct{}{}, s.RemoveDirectory(ctx, name)
+	})
+	return err
+}
+
+// ListDirectories returns a map of directories.
+func (d *directory) ListDirectories(ctx context.Context) (map[string]storage.Directory, error) {
+	lists, err := onAll(d.stripes, func(s storage.Directory) (map[string]storage.Directory, error) {
+		return s.ListDirectories(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := make(map[string]storage.Directory, len(lists[0]))
+	for name := range lists[0] {
+		children, err := pick(lists, name, storage.ErrDirectoryNotFound)
+		if err != nil {
+			return nil, err
+		}
+		dirs[name] = newDirectory(children)
+	}
+
+	return dirs, nil
+}
+
+// RemoveFile removes a file.
+func (d *directory) RemoveFile(ctx context.Context, name string) error {
+	_, err := onAll(d.stripes, func(s storage.Directory) (struct{}, error) {
+		return struct{}{}, s.RemoveFile(ctx, name)
+	})
+	return err
+}
+
+// RenameFile renames a file.
+func (d *directory) RenameFile(
+	ctx context.Context,
+	name string,
+	newParent storage.Directory,
+	newName string,
+	noReplace bool,
+) error {
+	np, ok := newParent.(*directory)
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrNotStripe, newParent)
+	}
+
+	for i, s := range d.stripes {
+		if err := s.RenameFile(ctx, name, np.stripes[i], newName, noReplace); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RenameDirectory renames a directory.
+func (d *directory) RenameDirectory(
+	ctx context.Context,
+	name string,
+	newParent storage.Directory,
+	newName string,
+	noReplace bool,
+) error {
+	np, ok := newParent.(*directory)
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrNotStripe, newParent)
+	}
+
+	for i, s := range d.stripes {
+		if err := s.RenameDirectory(ctx, name, np.stripes[i], newName, noReplace); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// onAll runs the function on every stripe, in order, and returns the results.
+// It stops on the first error, as the stripes cannot be used without each
+// other.
+func onAll[S, T any](stripes []S, fn func(s S) (T, error)) ([]T, error) {
+	results := make([]T, len(stripes))
+	for i, s := range stripes {
+		res, err := fn(s)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = res
+	}
+
+	return results, nil
+}
+
+// pick returns the element with the given name from every map, or the error
+// if a stripe does not have it.
+func pick[T any](maps []map[string]T, name string, notFound error) ([]T, error) {
+	elems := make([]T, len(maps))
+	for i, m := range maps {
+		e, ok := m[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q on stripe %d", notFound, name, i)
+		}
+		elems[i] = e
+	}
+
+	return elems, nil
+}
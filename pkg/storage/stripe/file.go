@@ -0,0 +1,161 @@
+package stripe
+
+import (
+	"context"
+	"errors"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+var (
+	_ storage.SparseFile  = (*file)(nil)
+	_ storage.Syncer      = (*file)(nil)
+	_ storage.ChunkPinner = (*file)(nil)
+)
+
+type file struct {
+	stripes []storage.File
+}
+
+func newFile(stripes []storage.File) *file {
+	return &file{
+		stripes: stripes,
+	}
+}
+
+// stripe returns the stripe storing the chunk. Invalid indexes are sent to the
+// first stripe, that will reject them.
+func (f *file) stripe(index int) storage.File {
+	if index < 0 {
+		return f.stripes[0]
+	}
+
+	return f.stripes[index%len(f.stripes)]
+}
+
+// GetInfo returns the file info, with the last chunk size from the stripe
+// that stores it and the allocated size of all stripes.
+func (f *file) GetInfo(ctx context.Context) (info.File, error) {
+	infos, err := onAll(f.stripes, func(s storage.File) (info.File, error) {
+		return s.GetInfo(ctx)
+	})
+	if err != nil {
+		return info.File{}, err
+	}
+
+	fileInfo := infos[0]
+	if fileInfo.ChunksCount > 0 {
+		last := (fileInfo.ChunksCount - 1) % len(f.stripes)
+		fileInfo.LastChunkSize = infos[last].LastChunkSize
+		fileInfo.Size = (fileInfo.ChunksCount-1)*fileInfo.ChunkSize + fileInfo.LastChunkSize
+	}
+
+	fileInfo.AllocatedSize = 0
+	for _, i := range infos {
+		fileInfo.AllocatedSize += i.AllocatedSize
+	}
+
+	return fileInfo, nil
+}
+
+// SetAttributes sets the file attributes, on every stripe.
+func (f *file) SetAttributes(ctx context.Context, attr info.Attributes) error {
+	_, err := onAll(f.stripes, func(s storage.File) (struct{}, error) {
+		return struct{}{}, s.SetAttributes(ctx, attr)
+	})
+	return err
+}
+
+// ReadChunk reads _ from a chunk.
+func (f *file) ReadChunk(ctx context.Context, index int, data []byte, offset int) (int, error) {
+	return f.stripe(index).ReadChunk(ctx, index, data, offset)
+}
+
+// HasChunkData returns true if the chunk holds data, false if this is a hole.
+// Any chunk is considered holding data if its stripe cannot tell.
+func (f *file) HasChunkData(ctx context.Context, index int) (bool, error) {
+	sf, ok := f.stripe(index).(storage.SparseFile)
+	if !ok {
+		return true, nil
+	}
+
+	return sf.HasChunkData(ctx, index)
+}
+
+// WriteChunk writes _ to a chunk.
+func (f *file) WriteChunk(ctx context.Context, index int, data []byte, offset int) (int, error) {
+	return f.stripe(index).WriteChunk(ctx, index, data, offset)
+}
+
+// ImportChunk imports a chunk.
+func (f *file) ImportChunk(ctx context.Context, index int, data []byte) error {
+	return f.stripe(index).ImportChunk(ctx, index, data)
+}
+
+// ResizeChunksNb resizes the number of chunks on every stripe.
+func (f *file) ResizeChunksNb(ctx context.Context, size int) error {
+	_, err := onAll(f.stripes, func(s storage.File) (struct{}, error) {
+		return struct{}{}, s.ResizeChunksNb(ctx, size)
+	})
+	return err
+}
+
+// ResizeLastChunk resizes the last chunk, on the stripe that stores it first.
+// The other stripes follow to keep the same metadata, unless they never had
+// this chunk.
+func (f *file) ResizeLastChunk(ctx context.Context, size int) (int, error) {
+	fileInfo, err := f.stripes[0].GetInfo(ctx)
+	if err != nil {
+		return 0, err
+	}
+	owner := f.stripe(fileInfo.ChunksCount - 1)
+
+	changed, err := owner.ResizeLastChunk(ctx, size)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, s := range f.stripes {
+		if s == owner {
+			continue
+		}
+
+		_, err := s.ResizeLastChunk(ctx, size)
+		if err != nil && !errors.Is(err, storage.ErrChunkNotFound) {
+			return 0, err
+		}
+	}
+
+	return changed, nil
+}
+
+// Sync syncs the stripes that keep data before writing it.
+func (f *file) Sync(ctx context.Context) error {
+	var errs []error
+	for _, s := range f.stripes {
+		if syncer, ok := s.(storage.Syncer); ok {
+			errs = append(errs, syncer.Sync(ctx))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// PinChunk prevents the chunk from being dropped by its stripe, if it can.
+func (f *file) PinChunk(ctx context.Context, index int) error {
+	if p, ok := f.stripe(index).(storage.ChunkPinner); ok {
+		return p.PinChunk(ctx, index)
+	}
+
+	return nil
+}
+
+// UnpinChunk allows the chunk to be dropped by its stripe again, if it can.
+func (f *file) UnpinChunk(ctx context.Context, index int) error {
+	if p, ok := f.stripe(index).(storage.ChunkPinner); ok {
+		return p.UnpinChunk(ctx, index)
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package stripe
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+	"github.com/lerenn/chonkfs/pkg/storage/mem"
+	"github.com/lerenn/chonkfs/pkg/storage/test"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestFileSuite(t *testing.T) {
+	suite.Run(t, new(FileSuite))
+}
+
+type FileSuite struct {
+	Stripes []storage.Directory
+	test.FileSuite
+}
+
+func (suite *FileSuite) SetupTest() {
+	suite.Stripes = []storage.Directory{mem.NewDirectory(), mem.NewDirectory(), mem.NewDirectory()}
+
+	d, err := NewDirectory(suite.Stripes)
+	suite.Require().NoError(err)
+	suite.Directory = d
+}
+
+func (suite *FileSuite) TestChunksSpreadOnStripes() {
+	// Create a file with a chunk per stripe and one more
+	f, err := suite.Directory.CreateFile(context.Background(), "file", info.File{
+		ChunkSize:     4,
+		ChunksCount:   4,
+		LastChunkSize: 2,
+	})
+	suite.Require().NoError(err)
+	for i, data := range []string{"AAAA", "BBBB", "CCCC", "DD"} {
+		suite.Require().NoError(f.ImportChunk(context.Background(), i, []byte(data)))
+	}
+
+	// Check each chunk is only on its stripe
+	for s, d := range suite.Stripes {
+		sf, err := d.GetFile(context.Background(), "file")
+		suite.Require().NoError(err)
+
+		for i := 0; i < 4; i++ {
+			_, err := sf.ReadChunk(context.Background(), i, make([]byte, 4), 0)
+			if i%len(suite.Stripes) == s {
+				suite.Require().NoError(err)
+			} else {
+				suite.Require().ErrorIs(err, storage.ErrChunkNotFound)
+			}
+		}
+	}
+
+	// Check the info is the one of the whole file
+	fileInfo, err := f.GetInfo(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal(info.File{
+		Size:          14,
+		AllocatedSize: 14,
+		ChunkSize:     4,
+		ChunksCount:   4,
+		LastChunkSize: 2,
+	}, fileInfo)
+}
+
+func (suite *FileSuite) TestResizeKeepsMetadataConsistent() {
+	f, err := suite.Directory.CreateFile(context.Background(), "file", info.File{ChunkSize: 4})
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.ResizeChunksNb(context.Background(), 5))
+	_, err = f.ResizeLastChunk(context.Background(), 3)
+	suite.Require().NoError(err)
+
+	for _, d := range suite.Stripes {
+		sf, err := d.GetFile(context.Background(), "file")
+		suite.Require().NoError(err)
+		fileInfo, err := sf.GetInfo(context.Background())
+		suite.Require().NoError(err)
+		suite.Require().Equal(5, fileInfo.ChunksCount)
+		suite.Require().Equal(3, fileInfo.LastChunkSize)
+	}
+}
+
+func (suite *FileSuite) TestNoStripe() {
+	_, err := NewDirectory(nil)
+	suite.Require().ErrorIs(err, ErrNoStripe)
+}
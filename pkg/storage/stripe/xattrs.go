@@ -0,0 +1,61 @@
+package stripe
+
+import (
+	"context"
+
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+// GetXattr returns the value of an extended attribute, from the first stripe.
+func (f *file) GetXattr(ctx context.Context, name string) ([]byte, error) {
+	return f.stripes[0].GetXattr(ctx, name)
+}
+
+// ListXattrs returns the names of the extended attributes, from the first
+// stripe.
+func (f *file) ListXattrs(ctx context.Context) ([]string, error) {
+	return f.stripes[0].ListXattrs(ctx)
+}
+
+// SetXattr creates or replaces an extended attribute, on every stripe.
+func (f *file) SetXattr(ctx context.Context, name string, value []byte) error {
+	_, err := onAll(f.stripes, func(s storage.File) (struct{}, error) {
+		return struct{}{}, s.SetXattr(ctx, name, value)
+	})
+	return err
+}
+
+// RemoveXattr removes an extended attribute, from every stripe.
+func (f *file) RemoveXattr(ctx context.Context, name string) error {
+	_, err := onAll(f.stripes, func(s storage.File) (struct{}, error) {
+		return struct{}{}, s.RemoveXattr(ctx, name)
+	})
+	return err
+}
+
+// GetXattr returns the value of an extended attribute, from the first stripe.
+func (d *directory) GetXattr(ctx context.Context, name string) ([]byte, error) {
+	return d.stripes[0].GetXattr(ctx, name)
+}
+
+// ListXattrs returns the names of the extended attributes, from the first
+// stripe.
+func (d *directory) ListXattrs(ctx context.Context) ([]string, error) {
+	return d.stripes[0].ListXattrs(ctx)
+}
+
+// SetXattr creates or replaces an extended attribute, on every stripe.
+func (d *directory) SetXattr(ctx context.Context, name string, value []byte) error {
+	_, err := onAll(d.stripes, func(s storage.Directory) (struct{}, error) {
+		return struct{}{}, s.SetXattr(ctx, name, value)
+	})
+	return err
+}
+
+// RemoveXattr removes an extended attribute, from every stripe.
+func (d *directory) RemoveXattr(ctx context.Context, name string) error {
+	_, err := onAll(d.stripes, func(s storage.Directory) (struct{}, error) {
+		return struct{}{}, s.RemoveXattr(ctx, name)
+	})
+	return err
+}
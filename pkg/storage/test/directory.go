@@ -2,6 +2,8 @@ package test
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/lerenn/chonkfs/pkg/info"
 	"github.com/lerenn/chonkfs/pkg/storage"
@@ -123,6 +125,67 @@ func (suite *DirectorySuite) TestGetInfo() {
 	suite.Require().Equal(info.Directory{}, dirInfo)
 }
 
+// TestSetAttributes tests the attributes are kept on a directory.
+func (suite *DirectorySuite) TestSetAttributes() {
+	_, err := suite.Directory.CreateDirectory(context.Background(), "dir")
+	suite.Require().NoError(err)
+	d, err := suite.Directory.GetDirectory(context.Background(), "dir")
+	suite.Require().NoError(err)
+
+	// Set the attributes
+	attr := info.Attributes{
+		Mode:  0o750,
+		UID:   1000,
+		GID:   100,
+		Atime: time.Unix(1700000000, 0).UTC(),
+		Mtime: time.Unix(1700000001, 0).UTC(),
+		Ctime: time.Unix(1700000002, 0).UTC(),
+	}
+	suite.Require().NoError(d.SetAttributes(context.Background(), attr))
+
+	// Check they are kept
+	d, err = suite.Directory.GetDirectory(context.Background(), "dir")
+	suite.Require().NoError(err)
+	dirInfo, err := d.GetInfo(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal(info.Directory{Attributes: attr}, dirInfo)
+
+	// Check the directory can still be removed
+	suite.Require().NoError(suite.Directory.RemoveDirectory(context.Background(), "dir"))
+}
+
+// TestXattrs tests the extended attributes are kept on a directory, if the
+// storage supports them.
+func (suite *DirectorySuite) TestXattrs() {
+	_, err := suite.Directory.CreateDirectory(context.Background(), "dir")
+	suite.Require().NoError(err)
+	d, err := suite.Directory.GetDirectory(context.Background(), "dir")
+	suite.Require().NoError(err)
+
+	// Set an extended attribute
+	err = d.SetXattr(context.Background(), "user.a", []byte("A"))
+	if errors.Is(err, storage.ErrXattrNotSupported) {
+		suite.T().Skip("extended attributes not supported")
+	}
+	suite.Require().NoError(err)
+
+	// Check it is kept
+	d, err = suite.Directory.GetDirectory(context.Background(), "dir")
+	suite.Require().NoError(err)
+	value, err := d.GetXattr(context.Background(), "user.a")
+	suite.Require().NoError(err)
+	suite.Require().Equal([]byte("A"), value)
+	names, err := d.ListXattrs(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal([]string{"user.a"}, names)
+
+	// Remove it and check the directory can still be removed
+	suite.Require().NoError(d.RemoveXattr(context.Background(), "user.a"))
+	_, err = d.GetXattr(context.Background(), "user.a")
+	suite.Require().ErrorIs(err, storage.ErrXattrNotFound)
+	suite.Require().NoError(suite.Directory.RemoveDirectory(context.Background(), "dir"))
+}
+
 // TestGetFile tests the retrieval of a file.
 func (suite *DirectorySuite) TestGetFile() {
 	// Create a file
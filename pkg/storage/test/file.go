@@ -2,6 +2,8 @@ package test
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/lerenn/chonkfs/pkg/info"
 	"github.com/lerenn/chonkfs/pkg/storage"
@@ -86,6 +88,69 @@ func (suite *FileSuite) TestGetInfoFromEmptyFile() {
 	}, fInfo)
 }
 
+// TestSetAttributes tests the attributes are kept on a file.
+func (suite *FileSuite) TestSetAttributes() {
+	f, err := suite.Directory.CreateFile(context.Background(), "file", info.File{
+		ChunkSize: 4096,
+	})
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.ResizeChunksNb(context.Background(), 1))
+
+	// Set the attributes
+	attr := info.Attributes{
+		Mode:  0o640,
+		UID:   1000,
+		GID:   100,
+		Atime: time.Unix(1700000000, 0).UTC(),
+		Mtime: time.Unix(1700000001, 0).UTC(),
+		Ctime: time.Unix(1700000002, 0).UTC(),
+	}
+	suite.Require().NoError(f.SetAttributes(context.Background(), attr))
+
+	// Check they are kept, without changing the chunks
+	f, err = suite.Directory.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+	fInfo, err := f.GetInfo(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal(attr, fInfo.Attributes)
+	suite.Require().Equal(1, fInfo.ChunksCount)
+}
+
+// TestXattrs tests the extended attributes are kept on a file, if the
+// storage supports them.
+func (suite *FileSuite) TestXattrs() {
+	f, err := suite.Directory.CreateFile(context.Background(), "file", info.File{
+		ChunkSize: 4096,
+	})
+	suite.Require().NoError(err)
+
+	// Set the extended attributes
+	err = f.SetXattr(context.Background(), "user.b", []byte("B"))
+	if errors.Is(err, storage.ErrXattrNotSupported) {
+		suite.T().Skip("extended attributes not supported")
+	}
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.SetXattr(context.Background(), "user.a", []byte("A")))
+	suite.Require().NoError(f.SetXattr(context.Background(), "user.a", []byte("AA")))
+
+	// Check they are kept
+	f, err = suite.Directory.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+	value, err := f.GetXattr(context.Background(), "user.a")
+	suite.Require().NoError(err)
+	suite.Require().Equal([]byte("AA"), value)
+	names, err := f.ListXattrs(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().ElementsMatch([]string{"user.a", "user.b"}, names)
+
+	// Remove one
+	suite.Require().NoError(f.RemoveXattr(context.Background(), "user.a"))
+	_, err = f.GetXattr(context.Background(), "user.a")
+	suite.Require().ErrorIs(err, storage.ErrXattrNotFound)
+	err = f.RemoveXattr(context.Background(), "user.a")
+	suite.Require().ErrorIs(err, storage.ErrXattrNotFound)
+}
+
 // TestResizeChunksNb tests the ResizeChunksNb method.
 func (suite *FileSuite) TestResizeChunksNb() {
 	f, err := suite.Directory.CreateFile(context.Background(), "file", info.File{
@@ -180,6 +245,36 @@ func (suite *FileSuite) TestReadWriteChunk() {
 	suite.Require().Equal(buf, rbuf[:len(buf)])
 }
 
+// TestReadChunkCreatedByResize tests that the chunks created by a resize read
+// as zeros.
+func (suite *FileSuite) TestReadChunkCreatedByResize() {
+	// Create file
+	f, err := suite.Directory.CreateFile(context.Background(), "file", info.File{
+		ChunkSize: 4096,
+	})
+	suite.Require().NoError(err)
+
+	// Resize to have two chunks, with a partial last chunk
+	err = f.ResizeChunksNb(context.Background(), 2)
+	suite.Require().NoError(err)
+	_, err = f.ResizeLastChunk(context.Background(), 1234)
+	suite.Require().NoError(err)
+
+	// Read full chunk
+	rbuf := make([]byte, 4096)
+	copy(rbuf, "Hello, World!")
+	n, err := f.ReadChunk(context.Background(), 0, rbuf, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal(4096, n)
+	suite.Require().Equal(make([]byte, 4096), rbuf)
+
+	// Read partial last chunk
+	n, err = f.ReadChunk(context.Background(), 1, rbuf, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal(1234, n)
+	suite.Require().Equal(make([]byte, 1234), rbuf[:n])
+}
+
 // TestImportChunk tests the ImportChunk method.
 func (suite *FileSuite) TestImportChunk() {
 	// Create file
@@ -0,0 +1,82 @@
+package tier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lerenn/chonkfs/pkg/storage"
+	"github.com/lerenn/chonkfs/pkg/storage/layer"
+	"github.com/lerenn/chonkfs/pkg/storage/readonly"
+)
+
+var (
+	// ErrNoTier happens when a tiered storage is created without any tier.
+	ErrNoTier = errors.New("no tier")
+	// ErrReadOnlyAboveTier happens when a tier other than the last one is
+	// read-only, as it could not copy the entries of the tiers below.
+	ErrReadOnlyAboveTier = errors.New("read-only tier above another tier")
+)
+
+// Tier is a storage in a tiered storage, with its policy regarding the tiers
+// below it. The policy is ignored for the last tier, except for ReadOnly.
+type Tier struct {
+	// Storage is the storage of the tier.
+	Storage storage.Directory
+	// WriteBack writes on this tier only, the written chunks being flushed to
+	// the tiers below at this interval and when the file is synced. Zero
+	// writes through all tiers.
+	WriteBack time.Duration
+	// PromoteOnRead copies on this tier the chunks read from the tiers below,
+	// so the next reads don't need them.
+	PromoteOnRead bool
+	// ReadOnly rejects any modification of this tier, and so of the tiers
+	// above it that write through it. Only the last tier can be read-only.
+	ReadOnly bool
+}
+
+func (t Tier) storage() storage.Directory {
+	if t.ReadOnly {
+		return readonly.NewDirectory(t.Storage)
+	}
+
+	return t.Storage
+}
+
+// stackOn creates a directory with this tier above the given directory.
+func (t Tier) stackOn(ctx context.Context, under storage.Directory) (storage.Directory, error) {
+	if t.PromoteOnRead {
+		return layer.NewDirectory(t.storage(), under,
+			layer.WithWriteBack(ctx, t.WriteBack))
+	}
+
+	return layer.NewDirectory(t.storage(), under,
+		layer.WithWriteBack(ctx, t.WriteBack),
+		layer.WithoutPromotion())
+}
+
+// NewDirectory creates a directory composing the tiers, from the first one
+// which is accessed first (the fastest one) to the last one (the slowest one).
+// The background write backs run until the context is done.
+func NewDirectory(ctx context.Context, tiers ...Tier) (storage.Directory, error) {
+	if len(tiers) == 0 {
+		return nil, ErrNoTier
+	}
+
+	// Stack the tiers, starting from the last one
+	d := tiers[len(tiers)-1].storage()
+	for i := len(tiers) - 2; i >= 0; i-- {
+		if tiers[i].ReadOnly {
+			return nil, fmt.Errorf("%w: tier %d", ErrReadOnlyAboveTier, i)
+		}
+
+		var err error
+		d, err = tiers[i].stackOn(ctx, d)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return d, nil
+}
@@ -0,0 +1,152 @@
+package tier
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+	"github.com/lerenn/chonkfs/pkg/storage/mem"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestTierSuite(t *testing.T) {
+	suite.Run(t, new(TierSuite))
+}
+
+type TierSuite struct {
+	Tiers []storage.Directory
+	suite.Suite
+}
+
+func (suite *TierSuite) SetupTest() {
+	suite.Tiers = []storage.Directory{
+		mem.NewDirectory(),
+		mem.NewDirectory(),
+		mem.NewDirectory(),
+	}
+}
+
+func (suite *TierSuite) readChunk(d storage.Directory, index int) (string, error) {
+	f, err := d.GetFile(context.Background(), "file")
+	if err != nil {
+		return "", err
+	}
+
+	data := make([]byte, 4)
+	_, err = f.ReadChunk(context.Background(), index, data, 0)
+	return string(data), err
+}
+
+func (suite *TierSuite) createFile(d storage.Directory) storage.File {
+	f, err := d.CreateFile(context.Background(), "file", info.File{ChunkSize: 4})
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.ResizeChunksNb(context.Background(), 2))
+	return f
+}
+
+func (suite *TierSuite) TestNoTier() {
+	_, err := NewDirectory(context.Background())
+	suite.Require().ErrorIs(err, ErrNoTier)
+}
+
+func (suite *TierSuite) TestWriteThrough() {
+	d, err := NewDirectory(context.Background(),
+		Tier{Storage: suite.Tiers[0]},
+		Tier{Storage: suite.Tiers[1]},
+		Tier{Storage: suite.Tiers[2]})
+	suite.Require().NoError(err)
+
+	// Write a chunk and check it is on every tier
+	_, err = suite.createFile(d).WriteChunk(context.Background(), 0, []byte("ABCD"), 0)
+	suite.Require().NoError(err)
+	for _, t := range suite.Tiers {
+		data, err := suite.readChunk(t, 0)
+		suite.Require().NoError(err)
+		suite.Require().Equal("ABCD", data)
+	}
+}
+
+func (suite *TierSuite) TestWriteBack() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	d, err := NewDirectory(ctx,
+		Tier{Storage: suite.Tiers[0], WriteBack: time.Hour},
+		Tier{Storage: suite.Tiers[1]},
+		Tier{Storage: suite.Tiers[2]})
+	suite.Require().NoError(err)
+
+	// Write a chunk and check it is only on the first tier
+	f := suite.createFile(d)
+	_, err = f.WriteChunk(context.Background(), 0, []byte("ABCD"), 0)
+	suite.Require().NoError(err)
+	data, err := suite.readChunk(suite.Tiers[2], 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal("\x00\x00\x00\x00", data)
+
+	// Sync and check it is on the last tier
+	suite.Require().NoError(f.(storage.Syncer).Sync(context.Background()))
+	data, err = suite.readChunk(suite.Tiers[2], 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal("ABCD", data)
+}
+
+func (suite *TierSuite) TestPromoteOnRead() {
+	// Create the file on the last tier only
+	_, err := suite.createFile(suite.Tiers[2]).WriteChunk(context.Background(), 0, []byte("ABCD"), 0)
+	suite.Require().NoError(err)
+
+	d, err := NewDirectory(context.Background(),
+		Tier{Storage: suite.Tiers[0]},
+		Tier{Storage: suite.Tiers[1], PromoteOnRead: true},
+		Tier{Storage: suite.Tiers[2]})
+	suite.Require().NoError(err)
+
+	// Read the chunk from the tiered storage
+	data, err := suite.readChunk(d, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal("ABCD", data)
+
+	// Check it has been promoted on the second tier only
+	data, err = suite.readChunk(suite.Tiers[1], 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal("ABCD", data)
+	_, err = suite.readChunk(suite.Tiers[0], 0)
+	suite.Require().ErrorIs(err, storage.ErrChunkNotFound)
+}
+
+func (suite *TierSuite) TestReadOnly() {
+	suite.createFile(suite.Tiers[1])
+
+	d, err := NewDirectory(context.Background(),
+		Tier{Storage: suite.Tiers[0], PromoteOnRead: true},
+		Tier{Storage: suite.Tiers[1], ReadOnly: true})
+	suite.Require().NoError(err)
+
+	// Check modifications are rejected
+	_, err = d.CreateFile(context.Background(), "new", info.File{ChunkSize: 4})
+	suite.Require().ErrorIs(err, storage.ErrReadOnly)
+
+	// Check reads are still promoted on the first tier
+	_, err = suite.readChunk(d, 1)
+	suite.Require().NoError(err)
+	_, err = suite.readChunk(suite.Tiers[0], 1)
+	suite.Require().NoError(err)
+}
+
+func (suite *TierSuite) TestReadOnlyAboveAnotherTier() {
+	// Check a read-only tier on top is rejected
+	_, err := NewDirectory(context.Background(),
+		Tier{Storage: suite.Tiers[0], ReadOnly: true},
+		Tier{Storage: suite.Tiers[1]})
+	suite.Require().ErrorIs(err, ErrReadOnlyAboveTier)
+
+	// Check a read-only tier in the middle is rejected
+	_, err = NewDirectory(context.Background(),
+		Tier{Storage: suite.Tiers[0]},
+		Tier{Storage: suite.Tiers[1], ReadOnly: true},
+		Tier{Storage: suite.Tiers[2]})
+	suite.Require().ErrorIs(err, ErrReadOnlyAboveTier)
+}
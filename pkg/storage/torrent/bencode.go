@@ -0,0 +1,120 @@
+package torrent
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// decoder decodes bencoded data into int64, string, []any and map[string]any
+// values.
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+func decodeBencode(data []byte) (any, error) {
+	d := &decoder{data: data}
+	v, err := d.decode()
+	if err != nil {
+		return nil, err
+	}
+
+	if d.pos != len(d.data) {
+		return nil, fmt.Errorf("%w: trailing data at %d", ErrInvalidMetainfo, d.pos)
+	}
+	return v, nil
+}
+
+func (d *decoder) decode() (any, error) {
+	if d.pos >= len(d.data) {
+		return nil, fmt.Errorf("%w: unexpected end of data", ErrInvalidMetainfo)
+	}
+
+	switch c := d.data[d.pos]; {
+	case c == 'i':
+		d.pos++
+		return d.decodeInt('e')
+	case c == 'l':
+		d.pos++
+		return d.decodeList()
+	case c == 'd':
+		d.pos++
+		return d.decodeDict()
+	case c >= '0' && c <= '9':
+		return d.decodeString()
+	default:
+		return nil, fmt.Errorf("%w: unexpected %q at %d", ErrInvalidMetainfo, c, d.pos)
+	}
+}
+
+func (d *decoder) decodeInt(end byte) (int64, error) {
+	i := bytes.IndexByte(d.data[d.pos:], end)
+	if i < 0 {
+		return 0, fmt.Errorf("%w: unterminated integer at %d", ErrInvalidMetainfo, d.pos)
+	}
+
+	v, err := strconv.ParseInt(string(d.data[d.pos:d.pos+i]), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid integer at %d", ErrInvalidMetainfo, d.pos)
+	}
+	d.pos += i + 1
+
+	return v, nil
+}
+
+func (d *decoder) decodeString() (string, error) {
+	length, err := d.decodeInt(':')
+	if err != nil {
+		return "", err
+	}
+
+	if length < 0 || length > int64(len(d.data)-d.pos) {
+		return "", fmt.Errorf("%w: invalid string length at %d", ErrInvalidMetainfo, d.pos)
+	}
+	s := string(d.data[d.pos : d.pos+int(length)])
+	d.pos += int(length)
+
+	return s, nil
+}
+
+func (d *decoder) decodeList() ([]any, error) {
+	list := []any{}
+	for d.pos < len(d.data) && d.data[d.pos] != 'e' {
+		v, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+
+	if d.pos >= len(d.data) {
+		return nil, fmt.Errorf("%w: unterminated list", ErrInvalidMetainfo)
+	}
+	d.pos++
+
+	return list, nil
+}
+
+func (d *decoder) decodeDict() (map[string]any, error) {
+	dict := map[string]any{}
+	for d.pos < len(d.data) && d.data[d.pos] != 'e' {
+		key, err := d.decodeString()
+		if err != nil {
+			return nil, err
+		}
+
+		v, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		dict[key] = v
+	}
+
+	if d.pos >= len(d.data) {
+		return nil, fmt.Errorf("%w: unterminated dictionary", ErrInvalidMetainfo)
+	}
+	d.pos++
+
+	return dict, nil
+}
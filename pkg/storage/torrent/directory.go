@@ -0,0 +1,223 @@
+package torrent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+// ErrNotTorrent happens when a torrent operation is called on another
+// storage.
+var ErrNotTorrent = errors.New("not a torrent storage")
+
+var _ storage.Directory = (*directory)(nil)
+
+type directory struct {
+	directory storage.Directory
+	path      string
+	state     *state
+}
+
+// NewDirectory creates a new directory representation, where the files of
+// the torrents are created with their piece length as chunk size, and their
+// pieces are verified against the torrents when imported or once entirely
+// written. The files of the torrents implement Completer.
+//
+// The files are at the path given by the torrent, from this directory. The
+// other files are not modified.
+func NewDirectory(d storage.Directory, torrents ...*Metainfo) storage.Directory {
+	return &directory{
+		directory: d,
+		state:     newState(torrents),
+	}
+}
+
+func (d *directory) newChildDirectory(name string, child storage.Directory) *directory {
+	return &directory{
+		directory: child,
+		path:      path.Join(d.path, name),
+		state:     d.state,
+	}
+}
+
+// newChildFile returns the file, wrapped if this is a file of a torrent which
+// chunks are the pieces.
+func (d *directory) newChildFile(ctx context.Context, name string, f storage.File) (storage.File, error) {
+	p := path.Join(d.path, name)
+	ref, ok := d.state.lookup(p)
+	if !ok {
+		return f, nil
+	}
+
+	fileInfo, err := f.GetInfo(ctx)
+	if err != nil {
+		return nil, err
+	} else if fileInfo.ChunkSize != ref.torrent.PieceLength {
+		return f, nil
+	}
+
+	return &file{
+		file:    f,
+		path:    p,
+		torrent: ref.torrent,
+		entry:   ref.entry,
+		state:   d.state,
+	}, nil
+}
+
+// CreateDirectory creates a directory.
+func (d *directory) CreateDirectory(ctx context.Context, name string) (storage.Directory, error) {
+	child, err := d.directory.CreateDirectory(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.newChildDirectory(name, child), nil
+}
+
+// GetDirectory returns a directory.
+func (d *directory) GetDirectory(ctx context.Context, name string) (storage.Directory, error) {
+	child, err := d.directory.GetDirectory(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.newChildDirectory(name, child), nil
+}
+
+// GetInfo returns the directory info.
+func (d *directory) GetInfo(ctx context.Context) (info.Directory, error) {
+	return d.directory.GetInfo(ctx)
+}
+
+// SetAttributes sets the directory attributes.
+func (d *directory) SetAttributes(ctx context.Context, attr info.Attributes) error {
+	return d.directory.SetAttributes(ctx, attr)
+}
+
+// CreateFile creates a file, with the piece length as chunk size if this is a
+// file of a torrent.
+func (d *directory) CreateFile(ctx context.Context, name string, fileInfo info.File) (storage.File, error) {
+	if ref, ok := d.state.lookup(path.Join(d.path, name)); ok {
+		fileInfo.ChunkSize = ref.torrent.PieceLength
+	}
+
+	f, err := d.directory.CreateFile(ctx, name, fileInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.newChildFile(ctx, name, f)
+}
+
+// GetFile returns a file.
+func (d *directory) GetFile(ctx context.Context, name string) (storage.File, error) {
+	f, err := d.directory.GetFile(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.newChildFile(ctx, name, f)
+}
+
+// ListFiles returns a map of files.
+func (d *directory) ListFiles(ctx context.Context) (map[string]storage.File, error) {
+	children, err := d.directory.ListFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]storage.File, len(children))
+	for name, f := range children {
+		if files[name], err = d.newChildFile(ctx, name, f); err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+// RemoveDirectory removes a directory.
+func (d *directory) RemoveDirectory(ctx context.Context, name string) error {
+	if err := d.directory.RemoveDirectory(ctx, name); err != nil {
+		return err
+	}
+
+	d.state.forget(path.Join(d.path, name))
+	return nil
+}
+
+// ListDirectories returns a map of directories.
+func (d *directory) ListDirectories(ctx context.Context) (map[string]storage.Directory, error) {
+	children, err := d.directory.ListDirectories(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := make(map[string]storage.Directory, len(children))
+	for name, child := range children {
+		dirs[name] = d.newChildDirectory(name, child)
+	}
+
+	return dirs, nil
+}
+
+// RemoveFile removes a file.
+func (d *directory) RemoveFile(ctx context.Context, name string) error {
+	if err := d.directory.RemoveFile(ctx, name); err != nil {
+		return err
+	}
+
+	d.state.forget(path.Join(d.path, name))
+	return nil
+}
+
+// RenameFile renames a file. The pieces need to be verified again, as the
+// file can now be another file of a torrent.
+func (d *directory) RenameFile(
+	ctx context.Context,
+	name string,
+	newParent storage.Directory,
+	newName string,
+	noReplace bool,
+) error {
+	np, ok := newParent.(*directory)
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrNotTorrent, newParent)
+	}
+
+	if err := d.directory.RenameFile(ctx, name, np.directory, newName, noReplace); err != nil {
+		return err
+	}
+
+	d.state.forget(path.Join(d.path, name))
+	d.state.forget(path.Join(np.path, newName))
+	return nil
+}
+
+// RenameDirectory renames a directory. The pieces of its files need to be
+// verified again, as they can now be other files of a torrent.
+func (d *directory) RenameDirectory(
+	ctx context.Context,
+	name string,
+	newParent storage.Directory,
+	newName string,
+	noReplace bool,
+) error {
+	np, ok := newParent.(*directory)
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrNotTorrent, newParent)
+	}
+
+	if err := d.directory.RenameDirectory(ctx, name, np.directory, newName, noReplace); err != nil {
+		return err
+	}
+
+	d.state.forget(path.Join(d.path, name))
+	d.state.forget(path.Join(np.path, newName))
+	return nil
+}
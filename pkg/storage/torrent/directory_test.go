@@ -0,0 +1,50 @@
+package torrent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage/mem"
+	"github.com/lerenn/chonkfs/pkg/storage/test"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestDirectorySuite(t *testing.T) {
+	suite.Run(t, new(DirectorySuite))
+}
+
+type DirectorySuite struct {
+	test.DirectorySuite
+}
+
+func (suite *DirectorySuite) SetupTest() {
+	suite.Directory = NewDirectory(mem.NewDirectory())
+}
+
+func (suite *DirectorySuite) TestCreateTorrentFilesWithPieceLength() {
+	m, err := ParseMetainfo(newV1Torrent("dir", 4,
+		[2]string{"a", "ABCD"},
+		[2]string{"b", "EFGH"}))
+	suite.Require().NoError(err)
+	d := NewDirectory(mem.NewDirectory(), m)
+
+	// Create a file of the torrent and another one
+	dir, err := d.CreateDirectory(context.Background(), "dir")
+	suite.Require().NoError(err)
+	f, err := dir.CreateFile(context.Background(), "b", info.File{ChunkSize: 16})
+	suite.Require().NoError(err)
+	other, err := dir.CreateFile(context.Background(), "other", info.File{ChunkSize: 16})
+	suite.Require().NoError(err)
+
+	// Check the chunk sizes
+	fileInfo, err := f.GetInfo(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal(4, fileInfo.ChunkSize)
+	suite.Require().Implements((*Completer)(nil), f)
+	fileInfo, err = other.GetInfo(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal(16, fileInfo.ChunkSize)
+	_, ok := other.(Completer)
+	suite.Require().False(ok)
+}
@@ -0,0 +1,225 @@
+package torrent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+// ErrCorruptedPiece happens when a piece doesn't match its hash in the
+// torrent.
+var ErrCorruptedPiece = fmt.Errorf("%w: piece doesn't match torrent", storage.ErrChunkCorrupted)
+
+// Completer is implemented by the files of a torrent, to tell which of their
+// pieces are verified.
+type Completer interface {
+	// Completion returns, for each piece of the file, true if it has been
+	// verified against the torrent.
+	Completion(ctx context.Context) ([]bool, error)
+}
+
+var (
+	_ storage.SparseFile = (*file)(nil)
+	_ storage.Syncer     = (*file)(nil)
+	_ Completer          = (*file)(nil)
+)
+
+// file is a file of a torrent, which chunks are its pieces.
+type file struct {
+	file    storage.File
+	path    string
+	torrent *Metainfo
+	entry   *Entry
+	state   *state
+}
+
+// pieceSize returns the size of the piece in the file.
+func (f *file) pieceSize(index int) int {
+	return min(f.torrent.PieceLength, f.entry.Length-index*f.torrent.PieceLength)
+}
+
+// getChunkSize returns the size of a chunk.
+func getChunkSize(fileInfo info.File, index int) int {
+	if index == fileInfo.ChunksCount-1 {
+		return fileInfo.LastChunkSize
+	}
+	return fileInfo.ChunkSize
+}
+
+// GetInfo returns the file info.
+func (f *file) GetInfo(ctx context.Context) (info.File, error) {
+	return f.file.GetInfo(ctx)
+}
+
+// SetAttributes sets the file attributes.
+func (f *file) SetAttributes(ctx context.Context, attr info.Attributes) error {
+	return f.file.SetAttributes(ctx, attr)
+}
+
+// ReadChunk reads _ from a chunk, failing if the piece failed its
+// verification.
+func (f *file) ReadChunk(ctx context.Context, index int, data []byte, offset int) (int, error) {
+	if f.state.isCorrupted(f.path, index) {
+		return 0, fmt.Errorf("%w: piece %d of %q", ErrCorruptedPiece, index, f.path)
+	}
+
+	return f.file.ReadChunk(ctx, index, data, offset)
+}
+
+// HasChunkData returns true if the chunk holds data, false if this is a hole.
+// Any chunk is considered holding data if the wrapped file cannot tell.
+func (f *file) HasChunkData(ctx context.Context, index int) (bool, error) {
+	sf, ok := f.file.(storage.SparseFile)
+	if !ok {
+		return true, nil
+	}
+
+	return sf.HasChunkData(ctx, index)
+}
+
+// WriteChunk writes _ to a chunk. Once the whole piece has been written, it is
+// verified and the write fails if it doesn't match the torrent: the piece is
+// then zeroed, like a hole, and cannot be read until it is written again.
+func (f *file) WriteChunk(ctx context.Context, index int, data []byte, offset int) (int, error) {
+	written, err := f.file.WriteChunk(ctx, index, data, offset)
+	if err != nil || !f.torrent.verifiable(f.entry, index) {
+		return written, err
+	}
+
+	// Verify the piece once it has been entirely written
+	size := f.pieceSize(index)
+	if !f.state.written(f.path, index, offset, offset+written, size) {
+		return written, nil
+	}
+
+	ok, err := f.verify(ctx, index, size)
+	if err != nil {
+		return written, err
+	} else if ok {
+		return written, nil
+	}
+
+	// Remove the corrupted data
+	if _, err := f.file.WriteChunk(ctx, index, make([]byte, size), 0); err != nil {
+		return written, err
+	}
+	return written, fmt.Errorf("%w: piece %d of %q", ErrCorruptedPiece, index, f.path)
+}
+
+// verify reads the piece and returns true if it matches the torrent, marking
+// it as verified or corrupted.
+func (f *file) verify(ctx context.Context, index, size int) (bool, error) {
+	data := make([]byte, size)
+	read, err := f.file.ReadChunk(ctx, index, data, 0)
+	if err != nil {
+		return false, err
+	}
+
+	ok := read == size && f.torrent.verify(f.entry, index, data)
+	if ok {
+		f.state.setVerified(f.path, index, true)
+	} else {
+		f.state.setCorrupted(f.path, index)
+	}
+	return ok, nil
+}
+
+// ImportChunk imports a chunk, if the piece matches the torrent.
+func (f *file) ImportChunk(ctx context.Context, index int, data []byte) error {
+	verifiable := f.torrent.verifiable(f.entry, index)
+	if verifiable && (len(data) != f.pieceSize(index) || !f.torrent.verify(f.entry, index, data)) {
+		return fmt.Errorf("%w: piece %d of %q", ErrCorruptedPiece, index, f.path)
+	}
+
+	if err := f.file.ImportChunk(ctx, index, data); err != nil {
+		return err
+	}
+
+	f.state.setVerified(f.path, index, verifiable)
+	return nil
+}
+
+// ResizeChunksNb resizes the number of chunks.
+func (f *file) ResizeChunksNb(ctx context.Context, size int) error {
+	if err := f.file.ResizeChunksNb(ctx, size); err != nil {
+		return err
+	}
+
+	f.state.forgetPieces(f.path, size)
+	return nil
+}
+
+// ResizeLastChunk resizes the last chunk.
+func (f *file) ResizeLastChunk(ctx context.Context, size int) (int, error) {
+	changed, err := f.file.ResizeLastChunk(ctx, size)
+	if err != nil {
+		return changed, err
+	}
+
+	fileInfo, err := f.file.GetInfo(ctx)
+	if err != nil {
+		return changed, err
+	}
+
+	// The last piece is not verified anymore, and what was written after the
+	// new size is lost
+	last := fileInfo.ChunksCount - 1
+	if changed < 0 {
+		f.state.forgetPieces(f.path, last)
+	} else if changed > 0 {
+		f.state.setVerified(f.path, last, false)
+	}
+
+	return changed, nil
+}
+
+// Sync syncs the wrapped file, if it keeps data before writing it.
+func (f *file) Sync(ctx context.Context) error {
+	if s, ok := f.file.(storage.Syncer); ok {
+		return s.Sync(ctx)
+	}
+
+	return nil
+}
+
+// Completion returns, for each piece of the file, true if it has been verified
+// against the torrent. The pieces present that have not been verified yet are
+// verified first.
+func (f *file) Completion(ctx context.Context) ([]bool, error) {
+	fileInfo, err := f.file.GetInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pieces := make([]bool, f.torrent.PiecesCount(f.entry))
+	for index := range pieces {
+		if f.state.isVerified(f.path, index) {
+			pieces[index] = true
+			continue
+		} else if f.state.isCorrupted(f.path, index) {
+			continue
+		}
+
+		// Check the piece is complete
+		size := f.pieceSize(index)
+		if !f.torrent.verifiable(f.entry, index) || index >= fileInfo.ChunksCount ||
+			getChunkSize(fileInfo, index) != size {
+			continue
+		}
+		hasData, err := f.HasChunkData(ctx, index)
+		if errors.Is(err, storage.ErrChunkNotFound) || (err == nil && !hasData) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		if pieces[index], err = f.verify(ctx, index, size); err != nil {
+			return nil, err
+		}
+	}
+
+	return pieces, nil
+}
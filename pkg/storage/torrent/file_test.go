@@ -0,0 +1,181 @@
+package torrent
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+	"github.com/lerenn/chonkfs/pkg/storage/mem"
+	"github.com/lerenn/chonkfs/pkg/storage/test"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestFileSuite(t *testing.T) {
+	suite.Run(t, new(FileSuite))
+}
+
+type FileSuite struct {
+	Wrapped storage.Directory
+	test.FileSuite
+}
+
+func (suite *FileSuite) SetupTest() {
+	suite.Wrapped = mem.NewDirectory()
+	suite.Directory = NewDirectory(suite.Wrapped)
+}
+
+// createTorrentFile creates the file of a single file torrent, with all its
+// pieces as holes.
+func (suite *FileSuite) createTorrentFile(torrent []byte, size int) storage.File {
+	m, err := ParseMetainfo(torrent)
+	suite.Require().NoError(err)
+	d := NewDirectory(suite.Wrapped, m)
+
+	f, err := d.CreateFile(context.Background(), "file", info.File{})
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.ResizeChunksNb(context.Background(), (size+m.PieceLength-1)/m.PieceLength))
+	if size%m.PieceLength != 0 {
+		_, err = f.ResizeLastChunk(context.Background(), size%m.PieceLength)
+		suite.Require().NoError(err)
+	}
+
+	return f
+}
+
+func (suite *FileSuite) completion(f storage.File) []bool {
+	pieces, err := f.(Completer).Completion(context.Background())
+	suite.Require().NoError(err)
+	return pieces
+}
+
+func (suite *FileSuite) TestImportPieces() {
+	m, err := ParseMetainfo(newV1Torrent("file", 4, [2]string{"file", "ABCDEF"}))
+	suite.Require().NoError(err)
+	f, err := NewDirectory(suite.Wrapped, m).CreateFile(context.Background(), "file", info.File{
+		ChunksCount: 2,
+	})
+	suite.Require().NoError(err)
+
+	// Import a corrupted piece
+	err = f.ImportChunk(context.Background(), 0, []byte("ABCX"))
+	suite.Require().ErrorIs(err, ErrCorruptedPiece)
+	suite.Require().ErrorIs(err, storage.ErrChunkCorrupted)
+	suite.Require().Equal([]bool{false, false}, suite.completion(f))
+
+	// Import the pieces
+	suite.Require().NoError(f.ImportChunk(context.Background(), 0, []byte("ABCD")))
+	suite.Require().Equal([]bool{true, false}, suite.completion(f))
+	suite.Require().NoError(f.ImportChunk(context.Background(), 1, []byte("EF")))
+	suite.Require().Equal([]bool{true, true}, suite.completion(f))
+}
+
+func (suite *FileSuite) TestWritePieces() {
+	f := suite.createTorrentFile(newV1Torrent("file", 4, [2]string{"file", "ABCDEF"}), 6)
+
+	// Write a piece in two parts, with corrupted data
+	_, err := f.WriteChunk(context.Background(), 0, []byte("AB"), 0)
+	suite.Require().NoError(err)
+	_, err = f.WriteChunk(context.Background(), 0, []byte("CX"), 2)
+	suite.Require().ErrorIs(err, ErrCorruptedPiece)
+	suite.Require().Equal([]bool{false, false}, suite.completion(f))
+
+	// Write it again
+	_, err = f.WriteChunk(context.Background(), 0, []byte("CD"), 2)
+	suite.Require().NoError(err)
+	_, err = f.WriteChunk(context.Background(), 0, []byte("AB"), 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal([]bool{true, false}, suite.completion(f))
+
+	// Modify it partially
+	_, err = f.WriteChunk(context.Background(), 0, []byte("x"), 1)
+	suite.Require().NoError(err)
+	suite.Require().Equal([]bool{false, false}, suite.completion(f))
+
+	// Write the last piece
+	_, err = f.WriteChunk(context.Background(), 1, []byte("EF"), 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal([]bool{false, true}, suite.completion(f))
+}
+
+func (suite *FileSuite) TestCorruptedPieceIsNotStored() {
+	f := suite.createTorrentFile(newV1Torrent("file", 4, [2]string{"file", "ABCDEF"}), 6)
+
+	// Write a corrupted piece
+	_, err := f.WriteChunk(context.Background(), 0, []byte("ABCX"), 0)
+	suite.Require().ErrorIs(err, ErrCorruptedPiece)
+
+	// Check it cannot be read, and that its data is not stored
+	_, err = f.ReadChunk(context.Background(), 0, make([]byte, 4), 0)
+	suite.Require().ErrorIs(err, ErrCorruptedPiece)
+	wf, err := suite.Wrapped.GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+	data := make([]byte, 4)
+	_, err = wf.ReadChunk(context.Background(), 0, data, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal(make([]byte, 4), data)
+
+	// Check it can be read once written again
+	_, err = f.WriteChunk(context.Background(), 0, []byte("ABCD"), 0)
+	suite.Require().NoError(err)
+	_, err = f.ReadChunk(context.Background(), 0, data, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal("ABCD", string(data))
+}
+
+func (suite *FileSuite) TestCompletionXattr() {
+	f := suite.createTorrentFile(newV1Torrent("file", 4, [2]string{"file", "ABCDEF"}), 6)
+	_, err := f.WriteChunk(context.Background(), 0, []byte("ABCD"), 0)
+	suite.Require().NoError(err)
+
+	// Check the completion is given with the other attributes
+	suite.Require().NoError(f.SetXattr(context.Background(), "user.a", []byte("A")))
+	names, err := f.ListXattrs(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Equal([]string{"user.a", CompletionXattr}, names)
+	value, err := f.GetXattr(context.Background(), CompletionXattr)
+	suite.Require().NoError(err)
+	suite.Require().Equal("1/2", string(value))
+
+	// Check it cannot be changed
+	err = f.SetXattr(context.Background(), CompletionXattr, []byte("2/2"))
+	suite.Require().ErrorIs(err, storage.ErrReadOnly)
+}
+
+func (suite *FileSuite) TestCompletionVerifiesStoredPieces() {
+	// Store the file without verification
+	f, err := suite.Directory.CreateFile(context.Background(), "file", info.File{
+		ChunkSize:     4,
+		ChunksCount:   3,
+		LastChunkSize: 2,
+	})
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.ImportChunk(context.Background(), 0, []byte("ABCD")))
+	suite.Require().NoError(f.ImportChunk(context.Background(), 1, []byte("EFGX")))
+
+	// Check the pieces are verified
+	m, err := ParseMetainfo(newV1Torrent("file", 4, [2]string{"file", "ABCDEFGHIJ"}))
+	suite.Require().NoError(err)
+	f, err = NewDirectory(suite.Wrapped, m).GetFile(context.Background(), "file")
+	suite.Require().NoError(err)
+	suite.Require().Equal([]bool{true, false, false}, suite.completion(f))
+}
+
+func (suite *FileSuite) TestWriteV2Pieces() {
+	content := bytes.Repeat([]byte("chonk"), 3*blockSize/5+1)
+	f := suite.createTorrentFile(newV2Torrent("file", 2*blockSize, [2]string{"file", string(content)}), len(content))
+
+	// Write the pieces by blocks
+	for start := 0; start < len(content); start += blockSize {
+		end := min(start+blockSize, len(content))
+		_, err := f.WriteChunk(context.Background(), start/(2*blockSize), content[start:end], start%(2*blockSize))
+		suite.Require().NoError(err)
+	}
+	suite.Require().Equal([]bool{true, true}, suite.completion(f))
+
+	// Corrupt the last piece
+	_, err := f.WriteChunk(context.Background(), 1, content[:len(content)-2*blockSize], 0)
+	suite.Require().ErrorIs(err, ErrCorruptedPiece)
+	suite.Require().Equal([]bool{true, false}, suite.completion(f))
+}
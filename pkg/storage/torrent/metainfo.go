@@ -0,0 +1,302 @@
+package torrent
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ErrInvalidMetainfo happens when a .torrent file cannot be parsed.
+var ErrInvalidMetainfo = errors.New("invalid metainfo")
+
+const (
+	sha1Size   = 20
+	sha256Size = 32
+)
+
+// Metainfo is the content of a .torrent file needed to verify its pieces.
+type Metainfo struct {
+	// Name is the name of the file, or of the directory of the files.
+	Name string
+	// PieceLength is the length of the pieces, used as the chunk size of the
+	// files.
+	PieceLength int
+	// Entries are the files of the torrent, padding files excluded.
+	Entries []Entry
+
+	// v2 is true if the pieces are verified with the merkle trees of the
+	// files, instead of the SHA-1 hashes of the pieces.
+	v2 bool
+	// pieces are the concatenated SHA-1 hashes of the pieces, for v1.
+	pieces []byte
+	// length is the total length of the files, padding files included, for
+	// v1.
+	length int
+}
+
+// Entry is a file of a torrent.
+type Entry struct {
+	// Path is the path of the file, from the directory associated to the
+	// torrent: the name of the torrent followed by the path of the file in the
+	// torrent for multi-file torrents.
+	Path []string
+	// Length is the length of the file.
+	Length int
+	// Offset is the offset of the file in the pieces, for v1.
+	Offset int
+
+	// padding is the length of the padding files following the file, for v1.
+	padding int
+	// piecesRoot is the root of the merkle tree of the file, for v2.
+	piecesRoot []byte
+	// pieceLayer are the concatenated hashes of the pieces of the file, for
+	// v2 files longer than a piece.
+	pieceLayer []byte
+}
+
+// PiecesCount returns the number of pieces of the file.
+func (m *Metainfo) PiecesCount(e *Entry) int {
+	return (e.Length + m.PieceLength - 1) / m.PieceLength
+}
+
+// Stream returns the torrent with its files, padding files included, as one
+// file named name, so the pieces spanning several files can be verified.
+// Only v1 pieces can span several files.
+func (m *Metainfo) Stream(name string) (*Metainfo, error) {
+	if m.v2 {
+		return nil, fmt.Errorf("%w: v2 pieces don't span files", ErrInvalidMetainfo)
+	}
+
+	return &Metainfo{
+		Name:        name,
+		PieceLength: m.PieceLength,
+		Entries:     []Entry{{Path: []string{name}, Length: m.length}},
+		pieces:      m.pieces,
+		length:      m.length,
+	}, nil
+}
+
+// ParseMetainfo parses the content of a .torrent file. Hybrid torrents are
+// verified with their v2 hashes.
+func ParseMetainfo(data []byte) (*Metainfo, error) {
+	v, err := decodeBencode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the info dictionary
+	root, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: not a dictionary", ErrInvalidMetainfo)
+	}
+	infoDict, ok := root["info"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: missing info", ErrInvalidMetainfo)
+	}
+
+	// Get the common fields
+	m := &Metainfo{}
+	if m.Name, ok = infoDict["name"].(string); !ok || !validName(m.Name) {
+		return nil, fmt.Errorf("%w: invalid name", ErrInvalidMetainfo)
+	}
+	pieceLength, ok := infoDict["piece length"].(int64)
+	if !ok || pieceLength <= 0 {
+		return nil, fmt.Errorf("%w: invalid piece length", ErrInvalidMetainfo)
+	}
+	m.PieceLength = int(pieceLength)
+
+	// Get the files
+	if version, _ := infoDict["meta version"].(int64); version == 2 {
+		err = m.parseV2(infoDict, root)
+	} else {
+		err = m.parseV1(infoDict)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (m *Metainfo) parseV1(infoDict map[string]any) error {
+	pieces, ok := infoDict["pieces"].(string)
+	if !ok || len(pieces)%sha1Size != 0 {
+		return fmt.Errorf("%w: invalid pieces", ErrInvalidMetainfo)
+	}
+	m.pieces = []byte(pieces)
+
+	// Single file torrent
+	if length, ok := infoDict["length"].(int64); ok {
+		if length < 0 {
+			return fmt.Errorf("%w: invalid length", ErrInvalidMetainfo)
+		}
+		m.Entries = []Entry{{Path: []string{m.Name}, Length: int(length)}}
+		m.length = int(length)
+		return m.checkPiecesCount()
+	}
+
+	// Multi-file torrent
+	files, ok := infoDict["files"].([]any)
+	if !ok {
+		return fmt.Errorf("%w: missing length or files", ErrInvalidMetainfo)
+	}
+	for _, f := range files {
+		fd, ok := f.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%w: invalid file", ErrInvalidMetainfo)
+		}
+		length, ok := fd["length"].(int64)
+		if !ok || length < 0 {
+			return fmt.Errorf("%w: invalid file length", ErrInvalidMetainfo)
+		}
+
+		// Padding files only move the next files
+		if attr, _ := fd["attr"].(string); strings.Contains(attr, "p") {
+			if len(m.Entries) > 0 {
+				m.Entries[len(m.Entries)-1].padding += int(length)
+			}
+			m.length += int(length)
+			continue
+		}
+
+		path, err := parsePath(fd["path"])
+		if err != nil {
+			return err
+		}
+		m.Entries = append(m.Entries, Entry{
+			Path:   append([]string{m.Name}, path...),
+			Length: int(length),
+			Offset: m.length,
+		})
+		m.length += int(length)
+	}
+
+	return m.checkPiecesCount()
+}
+
+func (m *Metainfo) checkPiecesCount() error {
+	if len(m.pieces)/sha1Size != (m.length+m.PieceLength-1)/m.PieceLength {
+		return fmt.Errorf("%w: pieces count doesn't match length", ErrInvalidMetainfo)
+	}
+	return nil
+}
+
+func parsePath(v any) ([]string, error) {
+	elems, ok := v.([]any)
+	if !ok || len(elems) == 0 {
+		return nil, fmt.Errorf("%w: invalid path", ErrInvalidMetainfo)
+	}
+
+	path := make([]string, 0, len(elems))
+	for _, e := range elems {
+		name, ok := e.(string)
+		if !ok || !validName(name) {
+			return nil, fmt.Errorf("%w: invalid path", ErrInvalidMetainfo)
+		}
+		path = append(path, name)
+	}
+
+	return path, nil
+}
+
+func (m *Metainfo) parseV2(infoDict, root map[string]any) error {
+	m.v2 = true
+
+	tree, ok := infoDict["file tree"].(map[string]any)
+	if !ok {
+		return fmt.Errorf("%w: missing file tree", ErrInvalidMetainfo)
+	}
+	layers, _ := root["piece layers"].(map[string]any)
+
+	if err := m.parseFileTree(tree, nil, layers); err != nil {
+		return err
+	}
+
+	// A single file at the root of the tree is the file named after the torrent
+	if len(m.Entries) == 1 && len(m.Entries[0].Path) == 1 {
+		m.Entries[0].Path = []string{m.Name}
+		return nil
+	}
+	for i := range m.Entries {
+		m.Entries[i].Path = append([]string{m.Name}, m.Entries[i].Path...)
+	}
+
+	return nil
+}
+
+func (m *Metainfo) parseFileTree(tree map[string]any, path []string, layers map[string]any) error {
+	// Sort the names, as the files are ordered in the torrent
+	names := make([]string, 0, len(tree))
+	for name := range tree {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		node, ok := tree[name].(map[string]any)
+		if !ok {
+			return fmt.Errorf("%w: invalid file tree", ErrInvalidMetainfo)
+		}
+
+		// Check if this is a directory
+		fd, ok := node[""].(map[string]any)
+		if !ok {
+			if !validName(name) {
+				return fmt.Errorf("%w: invalid path", ErrInvalidMetainfo)
+			}
+			if err := m.parseFileTree(node, append(slices.Clone(path), name), layers); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Get the file
+		e, err := m.parseV2File(fd, layers)
+		if err != nil {
+			return err
+		}
+		if !validName(name) {
+			return fmt.Errorf("%w: invalid path", ErrInvalidMetainfo)
+		}
+		e.Path = append(slices.Clone(path), name)
+		m.Entries = append(m.Entries, e)
+	}
+
+	return nil
+}
+
+func (m *Metainfo) parseV2File(fd map[string]any, layers map[string]any) (Entry, error) {
+	length, ok := fd["length"].(int64)
+	if !ok || length < 0 {
+		return Entry{}, fmt.Errorf("%w: invalid file length", ErrInvalidMetainfo)
+	}
+	e := Entry{Length: int(length)}
+
+	// Empty files have no hash
+	if length == 0 {
+		return e, nil
+	}
+
+	root, ok := fd["pieces root"].(string)
+	if !ok || len(root) != sha256Size {
+		return Entry{}, fmt.Errorf("%w: invalid pieces root", ErrInvalidMetainfo)
+	}
+	e.piecesRoot = []byte(root)
+
+	// Files longer than a piece have the hashes of their pieces
+	if e.Length > m.PieceLength {
+		layer, ok := layers[root].(string)
+		if !ok || len(layer) != m.PiecesCount(&e)*sha256Size {
+			return Entry{}, fmt.Errorf("%w: invalid piece layer", ErrInvalidMetainfo)
+		}
+		e.pieceLayer = []byte(layer)
+	}
+
+	return e, nil
+}
+
+// validName returns true if the name can be used as a file name.
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.Contains(name, "/")
+}
@@ -0,0 +1,231 @@
+package torrent
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// encodeBencode encodes int, string, []any and map[string]any values.
+func encodeBencode(v any) []byte {
+	var buf bytes.Buffer
+	switch v := v.(type) {
+	case int:
+		fmt.Fprintf(&buf, "i%de", v)
+	case string:
+		fmt.Fprintf(&buf, "%d:%s", len(v), v)
+	case []any:
+		buf.WriteByte('l')
+		for _, e := range v {
+			buf.Write(encodeBencode(e))
+		}
+		buf.WriteByte('e')
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+
+		buf.WriteByte('d')
+		for _, k := range keys {
+			buf.Write(encodeBencode(k))
+			buf.Write(encodeBencode(v[k]))
+		}
+		buf.WriteByte('e')
+	}
+	return buf.Bytes()
+}
+
+// newV1Torrent returns a v1 torrent of the files, given by name and content.
+// Names starting with "pad" are padding files.
+func newV1Torrent(name string, pieceLength int, files ...[2]string) []byte {
+	var stream []byte
+	fileList := make([]any, 0, len(files))
+	for _, f := range files {
+		fd := map[string]any{"length": len(f[1]), "path": []any{f[0]}}
+		if f[0][:min(3, len(f[0]))] == "pad" {
+			fd["attr"] = "p"
+		}
+		fileList = append(fileList, fd)
+		stream = append(stream, f[1]...)
+	}
+
+	var pieces []byte
+	for start := 0; start < len(stream); start += pieceLength {
+		sum := sha1.Sum(stream[start:min(start+pieceLength, len(stream))])
+		pieces = append(pieces, sum[:]...)
+	}
+
+	infoDict := map[string]any{
+		"name":         name,
+		"piece length": pieceLength,
+		"pieces":       string(pieces),
+	}
+	if len(files) == 1 && files[0][0] == name {
+		infoDict["length"] = len(files[0][1])
+	} else {
+		infoDict["files"] = fileList
+	}
+
+	return encodeBencode(map[string]any{"info": infoDict})
+}
+
+// newV2Torrent returns a v2 torrent of the files, given by name and content.
+func newV2Torrent(name string, pieceLength int, files ...[2]string) []byte {
+	tree := map[string]any{}
+	layers := map[string]any{}
+	for _, f := range files {
+		fd := map[string]any{"length": len(f[1])}
+		if len(f[1]) > 0 {
+			var root []byte
+			if len(f[1]) <= pieceLength {
+				root = merkleRoot([]byte(f[1]), nextPowerOfTwo((len(f[1])+blockSize-1)/blockSize))
+			} else {
+				var layer []byte
+				for start := 0; start < len(f[1]); start += pieceLength {
+					piece := []byte(f[1][start:min(start+pieceLength, len(f[1]))])
+					layer = append(layer, merkleRoot(piece, pieceLength/blockSize)...)
+				}
+				pieces := len(layer) / sha256Size
+				hashes := make([]byte, 0, nextPowerOfTwo(pieces)*sha256Size)
+				hashes = append(hashes, layer...)
+				padding := merkleRoot(nil, pieceLength/blockSize)
+				for i := pieces; i < nextPowerOfTwo(pieces); i++ {
+					hashes = append(hashes, padding...)
+				}
+				root = merkleRootOfHashes(hashes)
+				layers[string(root)] = string(layer)
+			}
+			fd["pieces root"] = string(root)
+		}
+		tree[f[0]] = map[string]any{"": fd}
+	}
+
+	return encodeBencode(map[string]any{
+		"info": map[string]any{
+			"name":         name,
+			"piece length": pieceLength,
+			"meta version": 2,
+			"file tree":    tree,
+		},
+		"piece layers": layers,
+	})
+}
+
+func merkleRootOfHashes(hashes []byte) []byte {
+	for len(hashes) > sha256Size {
+		next := make([]byte, 0, len(hashes)/2)
+		for i := 0; i < len(hashes); i += 2 * sha256Size {
+			sum := sha256.Sum256(hashes[i : i+2*sha256Size])
+			next = append(next, sum[:]...)
+		}
+		hashes = next
+	}
+	return hashes
+}
+
+func TestMetainfoSuite(t *testing.T) {
+	suite.Run(t, new(MetainfoSuite))
+}
+
+type MetainfoSuite struct {
+	suite.Suite
+}
+
+func (suite *MetainfoSuite) TestParseV1SingleFile() {
+	m, err := ParseMetainfo(newV1Torrent("file", 4, [2]string{"file", "ABCDEFGHIJ"}))
+	suite.Require().NoError(err)
+	suite.Require().Equal("file", m.Name)
+	suite.Require().Equal(4, m.PieceLength)
+	suite.Require().Len(m.Entries, 1)
+	suite.Require().Equal([]string{"file"}, m.Entries[0].Path)
+	suite.Require().Equal(3, m.PiecesCount(&m.Entries[0]))
+
+	suite.Require().True(m.verify(&m.Entries[0], 1, []byte("EFGH")))
+	suite.Require().False(m.verify(&m.Entries[0], 1, []byte("EFGX")))
+	suite.Require().True(m.verify(&m.Entries[0], 2, []byte("IJ")))
+}
+
+func (suite *MetainfoSuite) TestParseV1MultiFile() {
+	m, err := ParseMetainfo(newV1Torrent("dir", 4,
+		[2]string{"a", "ABCDEF"},
+		[2]string{"pad", "\x00\x00"},
+		[2]string{"b", "GHIJK"},
+		[2]string{"c", "LMN"}))
+	suite.Require().NoError(err)
+	suite.Require().Len(m.Entries, 3)
+	suite.Require().Equal([]string{"dir", "b"}, m.Entries[1].Path)
+	suite.Require().Equal(8, m.Entries[1].Offset)
+
+	// The last piece of a padded file is verified with the padding
+	suite.Require().True(m.verifiable(&m.Entries[0], 1))
+	suite.Require().True(m.verify(&m.Entries[0], 1, []byte("EF")))
+
+	// A piece with data of another file cannot be verified on its own
+	suite.Require().True(m.verifiable(&m.Entries[1], 0))
+	suite.Require().False(m.verifiable(&m.Entries[1], 1))
+	suite.Require().False(m.verifiable(&m.Entries[2], 0))
+}
+
+func (suite *MetainfoSuite) TestParseV2() {
+	big := string(bytes.Repeat([]byte("chonk"), 3*blockSize/5+1))
+	m, err := ParseMetainfo(newV2Torrent("dir", 2*blockSize,
+		[2]string{"big", big},
+		[2]string{"empty", ""},
+		[2]string{"small", "ABCD"}))
+	suite.Require().NoError(err)
+	suite.Require().Len(m.Entries, 3)
+	suite.Require().Equal([]string{"dir", "big"}, m.Entries[0].Path)
+	suite.Require().Equal(2, m.PiecesCount(&m.Entries[0]))
+	suite.Require().Equal(0, m.PiecesCount(&m.Entries[1]))
+
+	suite.Require().True(m.verify(&m.Entries[0], 0, []byte(big[:2*blockSize])))
+	suite.Require().True(m.verify(&m.Entries[0], 1, []byte(big[2*blockSize:])))
+	suite.Require().False(m.verify(&m.Entries[0], 1, []byte(big[:len(big)-2*blockSize])))
+	suite.Require().True(m.verify(&m.Entries[2], 0, []byte("ABCD")))
+}
+
+func (suite *MetainfoSuite) TestParseInvalid() {
+	for _, data := range []string{
+		"",
+		"d4:infoi1ee",
+		"d4:infod4:name4:file12:piece lengthi0eee",
+		"d4:infod4:name2:..12:piece lengthi4e6:pieces0:6:lengthi0eee",
+		"d4:infod4:name4:file12:piece lengthi4e6:pieces0:6:lengthi10eee",
+	} {
+		_, err := ParseMetainfo([]byte(data))
+		suite.Require().ErrorIs(err, ErrInvalidMetainfo, data)
+	}
+}
+
+func (suite *MetainfoSuite) TestStream() {
+	m, err := ParseMetainfo(newV1Torrent("dir", 4,
+		[2]string{"a", "ABCDEF"},
+		[2]string{"b", "GHIJK"}))
+	suite.Require().NoError(err)
+
+	s, err := m.Stream(".dir.stream")
+	suite.Require().NoError(err)
+	suite.Require().Len(s.Entries, 1)
+	suite.Require().Equal([]string{".dir.stream"}, s.Entries[0].Path)
+	suite.Require().Equal(11, s.Entries[0].Length)
+
+	// The pieces spanning several files can be verified in the stream
+	suite.Require().False(m.verifiable(&m.Entries[0], 1))
+	suite.Require().True(s.verifiable(&s.Entries[0], 1))
+	suite.Require().True(s.verify(&s.Entries[0], 1, []byte("EFGH")))
+	suite.Require().False(s.verify(&s.Entries[0], 1, []byte("EFGX")))
+	suite.Require().True(s.verify(&s.Entries[0], 2, []byte("IJK")))
+
+	// Files of v2 torrents are never spanned by pieces
+	m, err = ParseMetainfo(newV2Torrent("dir", blockSize, [2]string{"a", "ABCD"}))
+	suite.Require().NoError(err)
+	_, err = m.Stream(".dir.stream")
+	suite.Require().ErrorIs(err, ErrInvalidMetainfo)
+}
@@ -0,0 +1,95 @@
+package torrent
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+)
+
+// blockSize is the size of the leaves of the merkle trees of v2 torrents.
+const blockSize = 16 * 1024
+
+// verifiable returns true if the piece of the file can be verified on its
+// own. A v1 piece can only be verified if it doesn't hold data from other
+// files than padding files.
+func (m *Metainfo) verifiable(e *Entry, index int) bool {
+	if index < 0 || index >= m.PiecesCount(e) {
+		return false
+	} else if m.v2 {
+		return true
+	}
+
+	// Check the piece starts in this file
+	if e.Offset%m.PieceLength != 0 {
+		return false
+	}
+
+	// Check the piece ends in this file, its padding or the torrent
+	end := (index + 1) * m.PieceLength
+	return end <= e.Length+e.padding || e.Offset+e.Length+e.padding == m.length
+}
+
+// verify returns true if the data of the piece of the file matches its hash.
+// The piece must be verifiable.
+func (m *Metainfo) verify(e *Entry, index int, data []byte) bool {
+	if m.v2 {
+		return m.verifyV2(e, index, data)
+	}
+	return m.verifyV1(e, index, data)
+}
+
+func (m *Metainfo) verifyV1(e *Entry, index int, data []byte) bool {
+	piece := (e.Offset + index*m.PieceLength) / m.PieceLength
+
+	// Add the padding files data, that is zeros
+	length := min(m.PieceLength, m.length-piece*m.PieceLength)
+	if len(data) < length {
+		data = append(data[:len(data):len(data)], make([]byte, length-len(data))...)
+	}
+
+	sum := sha1.Sum(data)
+	return bytes.Equal(sum[:], m.pieces[piece*sha1Size:(piece+1)*sha1Size])
+}
+
+func (m *Metainfo) verifyV2(e *Entry, index int, data []byte) bool {
+	// A file that fits in a piece is verified with its root
+	if e.pieceLayer == nil {
+		blocks := (len(data) + blockSize - 1) / blockSize
+		return bytes.Equal(merkleRoot(data, nextPowerOfTwo(blocks)), e.piecesRoot)
+	}
+
+	root := merkleRoot(data, m.PieceLength/blockSize)
+	return bytes.Equal(root, e.pieceLayer[index*sha256Size:(index+1)*sha256Size])
+}
+
+// merkleRoot returns the root of the merkle tree with the hashes of the blocks
+// of the data as leaves, the leaves after the data being zeros.
+func merkleRoot(data []byte, leaves int) []byte {
+	hashes := make([][]byte, max(leaves, 1))
+	for i := range hashes {
+		if start := i * blockSize; start < len(data) {
+			sum := sha256.Sum256(data[start:min(start+blockSize, len(data))])
+			hashes[i] = sum[:]
+		} else {
+			hashes[i] = make([]byte, sha256Size)
+		}
+	}
+
+	for len(hashes) > 1 {
+		for i := 0; i < len(hashes)/2; i++ {
+			sum := sha256.Sum256(append(hashes[2*i][:sha256Size:sha256Size], hashes[2*i+1]...))
+			hashes[i] = sum[:]
+		}
+		hashes = hashes[:len(hashes)/2]
+	}
+
+	return hashes[0]
+}
+
+func nextPowerOfTwo(n int) int {
+	p := 1
+	for p < n {
+		p *= 2
+	}
+	return p
+}
@@ -0,0 +1,180 @@
+package torrent
+
+import (
+	"path"
+	"strings"
+	"sync"
+)
+
+// state holds the verification state of the files of the torrents, shared by
+// all directories and files.
+type state struct {
+	entries map[string]entryRef
+
+	mutex sync.Mutex
+	// verified is true for the verified pieces, and false for the ones that
+	// failed their verification.
+	verified map[string]map[int]bool
+	coverage map[string]map[int]*coverage
+}
+
+type entryRef struct {
+	torrent *Metainfo
+	entry   *Entry
+}
+
+func newState(torrents []*Metainfo) *state {
+	s := &state{
+		entries:  make(map[string]entryRef),
+		verified: make(map[string]map[int]bool),
+		coverage: make(map[string]map[int]*coverage),
+	}
+
+	for _, m := range torrents {
+		for i := range m.Entries {
+			s.entries[path.Join(m.Entries[i].Path...)] = entryRef{torrent: m, entry: &m.Entries[i]}
+		}
+	}
+
+	return s
+}
+
+// lookup returns the torrent file at the path, if any.
+func (s *state) lookup(p string) (entryRef, bool) {
+	ref, ok := s.entries[p]
+	return ref, ok
+}
+
+func (s *state) isVerified(p string, index int) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.verified[p][index]
+}
+
+// isCorrupted returns true if the piece failed its verification.
+func (s *state) isCorrupted(p string, index int) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	verified, ok := s.verified[p][index]
+	return ok && !verified
+}
+
+// setCorrupted marks the piece as failing its verification, until it is
+// written again.
+func (s *state) setCorrupted(p string, index int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.verified[p] == nil {
+		s.verified[p] = make(map[int]bool)
+	}
+	s.verified[p][index] = false
+}
+
+func (s *state) setVerified(p string, index int, verified bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !verified {
+		delete(s.verified[p], index)
+		return
+	}
+
+	if s.verified[p] == nil {
+		s.verified[p] = make(map[int]bool)
+	}
+	s.verified[p][index] = true
+}
+
+// written marks the piece as not verified and adds the written range to its
+// coverage. It returns true if the piece has been entirely written, its
+// coverage being reset to be verified.
+func (s *state) written(p string, index, start, end, size int) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.verified[p], index)
+
+	if s.coverage[p] == nil {
+		s.coverage[p] = make(map[int]*coverage)
+	}
+	c, ok := s.coverage[p][index]
+	if !ok {
+		c = &coverage{}
+		s.coverage[p][index] = c
+	}
+
+	c.add(start, end)
+	if !c.covers(size) {
+		return false
+	}
+
+	delete(s.coverage[p], index)
+	return true
+}
+
+// forgetPieces removes the state of the pieces of the file from the index.
+func (s *state) forgetPieces(p string, from int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for index := range s.verified[p] {
+		if index >= from {
+			delete(s.verified[p], index)
+		}
+	}
+	for index := range s.coverage[p] {
+		if index >= from {
+			delete(s.coverage[p], index)
+		}
+	}
+}
+
+// forget removes the state of the files at the path, or under it.
+func (s *state) forget(p string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	deleteUnder(s.verified, p)
+	deleteUnder(s.coverage, p)
+}
+
+// deleteUnder deletes the keys that are the path, or under it.
+func deleteUnder[V any](m map[string]V, p string) {
+	for k := range m {
+		if k == p || strings.HasPrefix(k, p+"/") {
+			delete(m, k)
+		}
+	}
+}
+
+// coverage is the sorted and merged ranges written in a piece.
+type coverage struct {
+	ranges [][2]int
+}
+
+func (c *coverage) add(start, end int) {
+	if start >= end {
+		return
+	}
+
+	merged := make([][2]int, 0, len(c.ranges)+1)
+	for _, r := range c.ranges {
+		switch {
+		case r[1] < start:
+			merged = append(merged, r)
+		case end < r[0]:
+			merged = append(merged, [2]int{start, end})
+			start, end = r[0], r[1]
+		default:
+			start, end = min(start, r[0]), max(end, r[1])
+		}
+	}
+	c.ranges = append(merged, [2]int{start, end})
+}
+
+func (c *coverage) covers(size int) bool {
+	return len(c.ranges) == 1 && c.ranges[0][0] == 0 && c.ranges[0][1] >= size
+}
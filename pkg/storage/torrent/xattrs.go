@@ -0,0 +1,92 @@
+package torrent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+// CompletionXattr is the read-only extended attribute of the files of the
+// torrents that gives their number of verified pieces, out of their number of
+// pieces, as "verified/total".
+const CompletionXattr = "user.chonkfs.completion"
+
+// completion returns the value of the completion attribute.
+func (f *file) completion(ctx context.Context) ([]byte, error) {
+	pieces, err := f.Completion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	verified := 0
+	for _, ok := range pieces {
+		if ok {
+			verified++
+		}
+	}
+	return []byte(strconv.Itoa(verified) + "/" + strconv.Itoa(len(pieces))), nil
+}
+
+// GetXattr returns the value of an extended attribute, the completion being
+// computed from the pieces.
+func (f *file) GetXattr(ctx context.Context, name string) ([]byte, error) {
+	if name == CompletionXattr {
+		return f.completion(ctx)
+	}
+
+	return f.file.GetXattr(ctx, name)
+}
+
+// ListXattrs returns the names of the extended attributes, with the
+// completion even if the wrapped file cannot store the others.
+func (f *file) ListXattrs(ctx context.Context) ([]string, error) {
+	names, err := f.file.ListXattrs(ctx)
+	if errors.Is(err, storage.ErrXattrNotSupported) {
+		return []string{CompletionXattr}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return slices.Sorted(slices.Values(append(names, CompletionXattr))), nil
+}
+
+// SetXattr creates or replaces an extended attribute, except the completion.
+func (f *file) SetXattr(ctx context.Context, name string, value []byte) error {
+	if name == CompletionXattr {
+		return fmt.Errorf("%w: %q", storage.ErrReadOnly, name)
+	}
+
+	return f.file.SetXattr(ctx, name, value)
+}
+
+// RemoveXattr removes an extended attribute, except the completion.
+func (f *file) RemoveXattr(ctx context.Context, name string) error {
+	if name == CompletionXattr {
+		return fmt.Errorf("%w: %q", storage.ErrReadOnly, name)
+	}
+
+	return f.file.RemoveXattr(ctx, name)
+}
+
+// GetXattr returns the value of an extended attribute of the directory.
+func (d *directory) GetXattr(ctx context.Context, name string) ([]byte, error) {
+	return d.directory.GetXattr(ctx, name)
+}
+
+// ListXattrs returns the names of the extended attributes of the directory.
+func (d *directory) ListXattrs(ctx context.Context) ([]string, error) {
+	return d.directory.ListXattrs(ctx)
+}
+
+// SetXattr creates or replaces an extended attribute of the directory.
+func (d *directory) SetXattr(ctx context.Context, name string, value []byte) error {
+	return d.directory.SetXattr(ctx, name, value)
+}
+
+// RemoveXattr removes an extended attribute of the directory.
+func (d *directory) RemoveXattr(ctx context.Context, name string) error {
+	return d.directory.RemoveXattr(ctx, name)
+}
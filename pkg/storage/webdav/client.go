@@ -0,0 +1,216 @@
+package webdav
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+var (
+	// ErrUnexpectedStatus is returned when the server answers with an
+	// unexpected status code.
+	ErrUnexpectedStatus = fmt.Errorf("%w: unexpected status", storage.ErrStorage)
+)
+
+const (
+	propfindBody = `<?xml version="1.0" encoding="utf-8"?>` +
+		`<D:propfind xmlns:D="DAV:"><D:prop><D:resourcetype/></D:prop></D:propfind>`
+)
+
+// multistatus is the body of a PROPFIND response, restricted to the
+// properties requested by the client.
+type multistatus struct {
+	Responses []response `xml:"DAV: response"`
+}
+
+type response struct {
+	Href     string     `xml:"DAV: href"`
+	Propstat []propstat `xml:"DAV: propstat"`
+}
+
+type propstat struct {
+	Prop struct {
+		ResourceType struct {
+			Collection *struct{} `xml:"DAV: collection"`
+		} `xml:"DAV: resourcetype"`
+	} `xml:"DAV: prop"`
+}
+
+func (r response) isCollection() bool {
+	for _, ps := range r.Propstat {
+		if ps.Prop.ResourceType.Collection != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// entry is a resource present on the server.
+type entry struct {
+	Name  string
+	IsDir bool
+}
+
+// client is a minimal WebDAV client, shared between every directory and
+// file of the same storage.
+type client struct {
+	http *http.Client
+	base *url.URL
+}
+
+func newClient(c *http.Client, base *url.URL) *client {
+	return &client{
+		http: c,
+		base: base,
+	}
+}
+
+func (c *client) do(
+	ctx context.Context,
+	method, p string,
+	body []byte,
+	headers map[string]string,
+	expected ...int,
+) ([]byte, error) {
+	// Create request
+	req, err := http.NewRequestWithContext(ctx, method, c.base.JoinPath(p).String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	// Execute it
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Read response
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check status
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %s %q", fs.ErrNotExist, method, p)
+	case resp.StatusCode == http.StatusPreconditionFailed:
+		return nil, fmt.Errorf("%w: %s %q", fs.ErrExist, method, p)
+	case !slices.Contains(expected, resp.StatusCode):
+		return nil, fmt.Errorf("%w: %s %q: %s", ErrUnexpectedStatus, method, p, resp.Status)
+	}
+
+	return data, nil
+}
+
+func (c *client) get(ctx context.Context, p string) ([]byte, error) {
+	return c.do(ctx, http.MethodGet, p, nil, nil, http.StatusOK)
+}
+
+func (c *client) put(ctx context.Context, p string, data []byte) error {
+	_, err := c.do(ctx, http.MethodPut, p, data, nil, http.StatusCreated, http.StatusNoContent, http.StatusOK)
+	return err
+}
+
+func (c *client) mkcol(ctx context.Context, p string) error {
+	_, err := c.do(ctx, "MKCOL", p, nil, nil, http.StatusCreated)
+	return err
+}
+
+func (c *client) delete(ctx context.Context, p string) error {
+	_, err := c.do(ctx, http.MethodDelete, p, nil, nil, http.StatusNoContent, http.StatusOK)
+	return err
+}
+
+// move moves a resource to a new path. If overwrite is false and there is
+// already a resource on the destination, an error wrapping fs.ErrExist is
+// returned.
+func (c *client) move(ctx context.Context, p, newPath string, overwrite bool) error {
+	headers := map[string]string{
+		"Destination": c.base.JoinPath(newPath).String(),
+		"Overwrite":   "F",
+	}
+	if overwrite {
+		headers["Overwrite"] = "T"
+	}
+
+	_, err := c.do(ctx, "MOVE", p, nil, headers, http.StatusCreated, http.StatusNoContent)
+	return err
+}
+
+func (c *client) propfind(ctx context.Context, p, depth string) (multistatus, error) {
+	headers := map[string]string{
+		"Content-Type": "application/xml; charset=utf-8",
+		"Depth":        depth,
+	}
+
+	data, err := c.do(ctx, "PROPFIND", p, []byte(propfindBody), headers, http.StatusMultiStatus)
+	if err != nil {
+		return multistatus{}, err
+	}
+
+	var ms multistatus
+	return ms, xml.Unmarshal(data, &ms)
+}
+
+// stat returns the entry corresponding to the path, or an error wrapping
+// fs.ErrNotExist if it doesn't exist.
+func (c *client) stat(ctx context.Context, p string) (entry, error) {
+	ms, err := c.propfind(ctx, p, "0")
+	if err != nil {
+		return entry{}, err
+	}
+
+	if len(ms.Responses) == 0 {
+		return entry{}, fmt.Errorf("%w: %q", fs.ErrNotExist, p)
+	}
+
+	return entry{
+		Name:  path.Base(p),
+		IsDir: ms.Responses[0].isCollection(),
+	}, nil
+}
+
+// list returns the entries contained in the collection.
+func (c *client) list(ctx context.Context, p string) ([]entry, error) {
+	ms, err := c.propfind(ctx, p, "1")
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]entry, 0, len(ms.Responses))
+	for _, r := range ms.Responses {
+		// Get the path from the reference
+		href, err := url.Parse(r.Href)
+		if err != nil {
+			return nil, err
+		}
+
+		// Ignore the collection itself
+		entryPath := strings.TrimSuffix(href.Path, "/")
+		if entryPath == strings.TrimSuffix(c.base.JoinPath(p).Path, "/") {
+			continue
+		}
+
+		entries = append(entries, entry{
+			Name:  path.Base(entryPath),
+			IsDir: r.isCollection(),
+		})
+	}
+
+	return entries, nil
+}
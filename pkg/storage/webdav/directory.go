@@ -0,0 +1,391 @@
+package webdav
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+// attributesFileName is the name of the file holding the attributes of a
+// directory, inside of it.
+const attributesFileName = ".attributes"
+
+var _ storage.Directory = (*directory)(nil)
+
+type directory struct {
+	client *client
+	path   string
+}
+
+// NewDirectory creates a new directory representation on a WebDAV server,
+// the URL being the one of the collection used as root. The HTTP client will
+// be shared by every child directory and file.
+func NewDirectory(c *http.Client, rawURL string) (storage.Directory, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// Separate the server from the path of the directory
+	p := u.Path
+	if p == "" {
+		p = "/"
+	}
+	u.Path, u.RawPath = "", ""
+
+	return newDirectory(newClient(c, u), p), nil
+}
+
+func newDirectory(c *client, path string) *directory {
+	return &directory{
+		client: c,
+		path:   path,
+	}
+}
+
+func (d *directory) getChildPath(name string) string {
+	return path.Join(d.path, name)
+}
+
+func (d *directory) getChildMetadataPath(name string) string {
+	return path.Join(d.getChildPath(name), metadataFileName)
+}
+
+func (d *directory) ensureChildDoesNotExists(ctx context.Context, name string) error {
+	entry, err := d.client.stat(ctx, d.getChildPath(name))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	// Chunks and metadata are always in a folder
+	if !entry.IsDir {
+		return fmt.Errorf("%w: %q is not a collection", storage.ErrStorage, name)
+	}
+
+	// Check if there is a metadata file
+	if _, err := d.client.stat(ctx, d.getChildMetadataPath(name)); err == nil {
+		return fmt.Errorf("%w: %q", storage.ErrFileAlreadyExists, name)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return fmt.Errorf("%w: %q", storage.ErrDirectoryAlreadyExists, name)
+}
+
+// CreateDirectory creates a directory.
+func (d *directory) CreateDirectory(ctx context.Context, name string) (storage.Directory, error) {
+	// Check if a file or a directory exists
+	if err := d.ensureChildDoesNotExists(ctx, name); err != nil {
+		return nil, err
+	}
+
+	// Create directory and return representation
+	path := d.getChildPath(name)
+	return newDirectory(d.client, path), d.client.mkcol(ctx, path)
+}
+
+// GetDirectory returns a child directory of the directory.
+func (d *directory) GetDirectory(ctx context.Context, name string) (storage.Directory, error) {
+	// Check if directory exists
+	err := d.ensureChildDoesNotExists(ctx, name)
+	switch {
+	case err == nil:
+		return nil, fmt.Errorf("%w: %q", storage.ErrDirectoryNotFound, name)
+	case errors.Is(err, storage.ErrFileAlreadyExists):
+		return nil, fmt.Errorf("%w: %q", storage.ErrIsFile, name)
+	case !errors.Is(err, storage.ErrDirectoryAlreadyExists):
+		return nil, err
+	}
+
+	// Return representation
+	return newDirectory(d.client, d.getChildPath(name)), nil
+}
+
+// GetInfo returns the directory information.
+func (d *directory) GetInfo(ctx context.Context) (info.Directory, error) {
+	// Download attributes, if they have been set
+	data, err := d.client.get(ctx, path.Join(d.path, attributesFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return info.Directory{}, nil
+	} else if err != nil {
+		return info.Directory{}, err
+	}
+
+	var dirInfo info.Directory
+	if err := json.Unmarshal(data, &dirInfo.Attributes); err != nil {
+		return info.Directory{}, err
+	}
+
+	return dirInfo, nil
+}
+
+// SetAttributes sets the directory attributes.
+func (d *directory) SetAttributes(ctx context.Context, attr info.Attributes) error {
+	data, err := json.Marshal(attr)
+	if err != nil {
+		return err
+	}
+
+	return d.client.put(ctx, path.Join(d.path, attributesFileName), data)
+}
+
+// CreateFile creates a file.
+func (d *directory) CreateFile(ctx context.Context, name string, info info.File) (storage.File, error) {
+	path := d.getChildPath(name)
+
+	// Check if there is a file with this name
+	if err := d.ensureChildDoesNotExists(ctx, name); err != nil {
+		return nil, err
+	}
+
+	// Create file representation
+	f, err := newFile(d.client, path, info)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create directory representing the file
+	if err := d.client.mkcol(ctx, path); err != nil {
+		return nil, err
+	}
+
+	return f, f.saveInfo(ctx, info)
+}
+
+// GetFile returns a child file.
+func (d *directory) GetFile(ctx context.Context, name string) (storage.File, error) {
+	// Check if file exists
+	err := d.ensureChildDoesNotExists(ctx, name)
+	switch {
+	case err == nil:
+		return nil, fmt.Errorf("%w: %q", storage.ErrFileNotFound, name)
+	case errors.Is(err, storage.ErrDirectoryAlreadyExists):
+		return nil, fmt.Errorf("%w: %q", storage.ErrIsDirectory, name)
+	case !errors.Is(err, storage.ErrFileAlreadyExists):
+		return nil, err
+	}
+
+	// Read metadata
+	path := d.getChildPath(name)
+	info, err := readMetadata(ctx, d.client, path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create file representation
+	return newFile(d.client, path, info)
+}
+
+// listChildren returns the child folders of the directory, sorted between
+// the ones representing files and the ones representing directories.
+func (d *directory) listChildren(ctx context.Context) (files, directories []string, err error) {
+	entries, err := d.client.list(ctx, d.path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir {
+			continue
+		}
+
+		// Check if there is metadata
+		_, err := d.client.stat(ctx, d.getChildMetadataPath(entry.Name))
+		switch {
+		case err == nil:
+			files = append(files, entry.Name)
+		case errors.Is(err, fs.ErrNotExist):
+			directories = append(directories, entry.Name)
+		default:
+			return nil, nil, err
+		}
+	}
+
+	return files, directories, nil
+}
+
+// ListFiles returns a map of files.
+func (d *directory) ListFiles(ctx context.Context) (map[string]storage.File, error) {
+	names, _, err := d.listChildren(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]storage.File, len(names))
+	for _, name := range names {
+		// Read metadata
+		path := d.getChildPath(name)
+		info, err := readMetadata(ctx, d.client, path)
+		if err != nil {
+			return nil, err
+		}
+
+		// Create file representation
+		f, err := newFile(d.client, path, info)
+		if err != nil {
+			return nil, err
+		}
+
+		files[name] = f
+	}
+
+	return files, nil
+}
+
+// RemoveDirectory removes a directory.
+func (d *directory) RemoveDirectory(ctx context.Context, name string) error {
+	// Check if directory exists
+	err := d.ensureChildDoesNotExists(ctx, name)
+	switch {
+	case err == nil:
+		return fmt.Errorf("%w: %q", storage.ErrDirectoryNotFound, name)
+	case errors.Is(err, storage.ErrFileAlreadyExists):
+		return fmt.Errorf("%w: %q", storage.ErrIsFile, name)
+	case !errors.Is(err, storage.ErrDirectoryAlreadyExists):
+		return err
+	}
+
+	// Remove directory
+	return d.client.delete(ctx, d.getChildPath(name))
+}
+
+// ListDirectories returns a map of directories.
+func (d *directory) ListDirectories(ctx context.Context) (map[string]storage.Directory, error) {
+	_, names, err := d.listChildren(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	directories := make(map[string]storage.Directory, len(names))
+	for _, name := range names {
+		directories[name] = newDirectory(d.client, d.getChildPath(name))
+	}
+
+	return directories, nil
+}
+
+// RemoveFile removes a file.
+func (d *directory) RemoveFile(ctx context.Context, name string) error {
+	// Check if file or directory exists
+	err := d.ensureChildDoesNotExists(ctx, name)
+	switch {
+	case err == nil:
+		return fmt.Errorf("%w: %q", storage.ErrFileNotFound, name)
+	case errors.Is(err, storage.ErrDirectoryAlreadyExists):
+		return fmt.Errorf("%w: %q", storage.ErrIsDirectory, name)
+	case !errors.Is(err, storage.ErrFileAlreadyExists):
+		return err
+	}
+
+	return d.client.delete(ctx, d.getChildPath(name))
+}
+
+// checkRenameDestination checks that the destination of a rename is free
+// or can be replaced, the replacement being done by the server.
+func (d *directory) checkRenameDestination(
+	ctx context.Context,
+	newParent storage.Directory,
+	newName string,
+	noReplace bool,
+) error {
+	err := newParent.(*directory).ensureChildDoesNotExists(ctx, newName)
+	switch {
+	case err == nil:
+		return nil
+	case !noReplace && (errors.Is(err, storage.ErrDirectoryAlreadyExists) ||
+		errors.Is(err, storage.ErrFileAlreadyExists)):
+		return nil
+	default:
+		return err
+	}
+}
+
+// move moves a child on the new parent with a MOVE request, using the
+// Overwrite header to let the server replace the destination if needed.
+func (d *directory) move(
+	ctx context.Context,
+	name string,
+	newParent storage.Directory,
+	newName string,
+	noReplace bool,
+) error {
+	newPath := newParent.(*directory).getChildPath(newName)
+	err := d.client.move(ctx, d.getChildPath(name), newPath, !noReplace)
+	if !errors.Is(err, fs.ErrExist) {
+		return err
+	}
+
+	// The destination has been created in the meantime: return the error
+	// corresponding to the existing child
+	if cErr := newParent.(*directory).ensureChildDoesNotExists(ctx, newName); cErr != nil {
+		return cErr
+	}
+	return err
+}
+
+// RenameFile renames a file.
+func (d *directory) RenameFile(
+	ctx context.Context,
+	name string,
+	newParent storage.Directory,
+	newName string,
+	noReplace bool,
+) error {
+	// Check if file exists
+	err := d.ensureChildDoesNotExists(ctx, name)
+	switch {
+	case err == nil:
+		return fmt.Errorf("%w: %q", storage.ErrFileNotFound, name)
+	case errors.Is(err, storage.ErrDirectoryAlreadyExists):
+		return fmt.Errorf("%w: %q", storage.ErrIsDirectory, name)
+	case !errors.Is(err, storage.ErrFileAlreadyExists):
+		return err
+	}
+
+	// Check the destination
+	if err := d.checkRenameDestination(ctx, newParent, newName, noReplace); err != nil {
+		return err
+	}
+
+	// Move the file
+	return d.move(ctx, name, newParent, newName, noReplace)
+}
+
+// RenameDirectory renames a directory.
+func (d *directory) RenameDirectory(
+	ctx context.Context,
+	name string,
+	newParent storage.Directory,
+	newName string,
+	noReplace bool,
+) error {
+	// Check if directory exists
+	err := d.ensureChildDoesNotExists(ctx, name)
+	switch {
+	case err == nil:
+		return fmt.Errorf("%w: %q", storage.ErrDirectoryNotFound, name)
+	case errors.Is(err, storage.ErrFileAlreadyExists):
+		return fmt.Errorf("%w: %q", storage.ErrIsFile, name)
+	case !errors.Is(err, storage.ErrDirectoryAlreadyExists):
+		return err
+	}
+
+	// Check the destination
+	if err := d.checkRenameDestination(ctx, newParent, newName, noReplace); err != nil {
+		return err
+	}
+
+	// Move the directory
+	return d.move(ctx, name, newParent, newName, noReplace)
+}
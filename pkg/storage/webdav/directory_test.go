@@ -0,0 +1,50 @@
+package webdav
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lerenn/chonkfs/pkg/storage/layer"
+	"github.com/lerenn/chonkfs/pkg/storage/mem"
+	"github.com/lerenn/chonkfs/pkg/storage/test"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestDirectorySuite(t *testing.T) {
+	suite.Run(t, new(DirectorySuite))
+	suite.Run(t, new(DirectoryAsUnderlayerSuite))
+}
+
+type DirectorySuite struct {
+	test.DirectorySuite
+	Server *httptest.Server
+}
+
+func (suite *DirectorySuite) SetupTest() {
+	var err error
+	suite.Server = newTestServer(suite.T())
+	suite.Directory, err = NewDirectory(suite.Server.Client(), suite.Server.URL)
+	suite.Require().NoError(err)
+}
+
+func (suite *DirectorySuite) TearDownTest() {
+	suite.Server.Close()
+}
+
+type DirectoryAsUnderlayerSuite struct {
+	layer.DirectorySuite
+	Server *httptest.Server
+}
+
+func (suite *DirectoryAsUnderlayerSuite) SetupTest() {
+	var err error
+	suite.Server = newTestServer(suite.T())
+	suite.Upperlayer = mem.NewDirectory()
+	suite.Underlayer, err = NewDirectory(suite.Server.Client(), suite.Server.URL)
+	suite.Require().NoError(err)
+	suite.Directory, _ = layer.NewDirectory(suite.Upperlayer, suite.Underlayer)
+}
+
+func (suite *DirectoryAsUnderlayerSuite) TearDownTest() {
+	suite.Server.Close()
+}
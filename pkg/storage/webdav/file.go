@@ -0,0 +1,328 @@
+package webdav
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+
+	"github.com/lerenn/chonkfs/pkg/info"
+	"github.com/lerenn/chonkfs/pkg/storage"
+)
+
+const (
+	metadataFileName = ".metadata"
+)
+
+var _ storage.File = (*file)(nil)
+
+type file struct {
+	client *client
+	path   string
+}
+
+func newFile(c *client, path string, info info.File) (*file, error) {
+	if info.ChunkSize <= 0 {
+		return nil, fmt.Errorf("%w: chunk size must be greater than 0", storage.ErrInvalidChunkSize)
+	}
+
+	return &file{
+		client: c,
+		path:   path,
+	}, nil
+}
+
+func writeMetadata(ctx context.Context, c *client, p string, info info.File) error {
+	// Encode info
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	// Upload metadata file
+	return c.put(ctx, path.Join(p, metadataFileName), data)
+}
+
+func readMetadata(ctx context.Context, c *client, p string) (info.File, error) {
+	// Download metadata
+	data, err := c.get(ctx, path.Join(p, metadataFileName))
+	if err != nil {
+		return info.File{}, err
+	}
+
+	var info info.File
+	if err := json.Unmarshal(data, &info); err != nil {
+		return info, err
+	}
+
+	return info, nil
+}
+
+func getChunkName(nb int) string {
+	return fmt.Sprintf("chunk-%d.dat", nb)
+}
+
+func (f *file) getChunkPath(nb int) string {
+	return path.Join(f.path, getChunkName(nb))
+}
+
+// isChunkPresent checks if the chunk exists on the WebDAV server.
+func (f *file) isChunkPresent(ctx context.Context, index int) (bool, error) {
+	_, err := f.client.stat(ctx, f.getChunkPath(index))
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, fs.ErrNotExist):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// GetInfo returns the file info.
+func (f *file) GetInfo(ctx context.Context) (info.File, error) {
+	return readMetadata(ctx, f.client, f.path)
+}
+
+// SetAttributes sets the file attributes.
+func (f *file) SetAttributes(ctx context.Context, attr info.Attributes) error {
+	info, err := f.GetInfo(ctx)
+	if err != nil {
+		return err
+	}
+
+	info.Attributes = attr
+	return f.saveInfo(ctx, info)
+}
+
+func (f *file) saveInfo(ctx context.Context, info info.File) error {
+	return writeMetadata(ctx, f.client, f.path, info)
+}
+
+func (f *file) checkImportChunkParams(ctx context.Context, info info.File, index int, data []byte) error {
+	// Check if chunk index is correct
+	if index < 0 || index >= info.ChunksCount {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkNb, index)
+	}
+
+	// Check if the chunk is empty
+	if present, err := f.isChunkPresent(ctx, index); err != nil {
+		return err
+	} else if present {
+		return fmt.Errorf("%w: %d", storage.ErrChunkAlreadyExists, index)
+	}
+
+	// Check if length of data is correct
+	if (len(data) != info.ChunkSize && index != info.ChunksCount-1) || len(data) > info.ChunkSize {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkSize, len(data))
+	}
+
+	return nil
+}
+
+// ImportChunk imports a chunk of data.
+func (f *file) ImportChunk(ctx context.Context, index int, data []byte) error {
+	// Get info
+	info, err := f.GetInfo(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Check params
+	if err := f.checkImportChunkParams(ctx, info, index, data); err != nil {
+		return err
+	}
+
+	// Import data
+	if err := f.client.put(ctx, f.getChunkPath(index), data); err != nil {
+		return err
+	}
+
+	// If this is the last chunk, set the last chunk size
+	if index == info.ChunksCount-1 {
+		info.LastChunkSize = len(data)
+		if err := f.saveInfo(ctx, info); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (f *file) checkReadWriteChunkParams(ctx context.Context, info info.File, index int, offset int) error {
+	// Check if chunk index is correct
+	if index < 0 || index >= info.ChunksCount {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkNb, index)
+	}
+
+	// Check if there is data to read
+	if present, err := f.isChunkPresent(ctx, index); err != nil {
+		return err
+	} else if !present {
+		return fmt.Errorf("%w", storage.ErrChunkNotFound)
+	}
+
+	// Check if offset is correct
+	if offset < 0 || offset >= info.ChunkSize {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidOffset, offset)
+	}
+
+	// Check if this is the last chunk, that the offset is correct
+	if index == info.ChunksCount-1 && offset >= info.LastChunkSize {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidOffset, offset)
+	}
+
+	return nil
+}
+
+// WriteChunk writes a chunk of data.
+func (f *file) WriteChunk(ctx context.Context, index int, data []byte, offset int) (int, error) {
+	// Get info
+	info, err := f.GetInfo(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Check params
+	if err := f.checkReadWriteChunkParams(ctx, info, index, offset); err != nil {
+		return 0, err
+	}
+
+	// Download the chunk, as WebDAV can't write in the middle of a file
+	chunkPath := f.getChunkPath(index)
+	chunkData, err := f.client.get(ctx, chunkPath)
+	if err != nil {
+		return 0, err
+	}
+
+	// Modify it and upload it back
+	n := copy(chunkData[offset:], data)
+	return n, f.client.put(ctx, chunkPath, chunkData)
+}
+
+// ReadChunk reads a chunk of data.
+func (f *file) ReadChunk(ctx context.Context, index int, data []byte, offset int) (int, error) {
+	// Get info
+	info, err := f.GetInfo(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Check params
+	if err := f.checkReadWriteChunkParams(ctx, info, index, offset); err != nil {
+		return 0, err
+	}
+
+	// Read data
+	chunkData, err := f.client.get(ctx, f.getChunkPath(index))
+	if err != nil {
+		return 0, err
+	}
+
+	return copy(data, chunkData[offset:]), nil
+}
+
+// ResizeChunksNb resizes the number of chunks.
+func (f *file) ResizeChunksNb(ctx context.Context, size int) error {
+	// Check size is correct
+	if size < 0 {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkNb, size)
+	}
+
+	// Get actual info
+	info, err := f.GetInfo(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Check the last chunk size is full
+	if info.ChunksCount > 0 && info.LastChunkSize != info.ChunkSize {
+		return fmt.Errorf("%w", storage.ErrLastChunkNotFull)
+	}
+
+	// Resize chunks
+	if size > info.ChunksCount {
+		// Add chunks
+		for i := info.ChunksCount; i < size; i++ {
+			if err := f.client.put(ctx, f.getChunkPath(i), make([]byte, info.ChunkSize)); err != nil {
+				return err
+			}
+		}
+	} else {
+		// Remove chunks
+		for i := size; i < info.ChunksCount; i++ {
+			err := f.client.delete(ctx, f.getChunkPath(i))
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+		}
+	}
+
+	// Update info
+	info.ChunksCount = size
+	info.LastChunkSize = info.ChunkSize
+	return f.saveInfo(ctx, info)
+}
+
+func (f *file) checkResizeLastChunkParams(ctx context.Context, info info.File, size int) error {
+	// Check size is correct
+	if size < 0 || size > info.ChunkSize {
+		return fmt.Errorf("%w: %d", storage.ErrInvalidChunkSize, size)
+	}
+
+	// Check if there is a last chunk
+	if info.ChunksCount == 0 {
+		return fmt.Errorf("%w", storage.ErrNoChunk)
+	}
+
+	// Check if the last chunk is present
+	if present, err := f.isChunkPresent(ctx, info.ChunksCount-1); err != nil {
+		return err
+	} else if !present {
+		return fmt.Errorf("%w", storage.ErrChunkNotFound)
+	}
+
+	return nil
+}
+
+// ResizeLastChunk resizes the last chunk.
+func (f *file) ResizeLastChunk(ctx context.Context, size int) (changed int, err error) {
+	// Get actual info
+	info, err := f.GetInfo(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Check params
+	if err := f.checkResizeLastChunkParams(ctx, info, size); err != nil {
+		return 0, err
+	}
+
+	// Download last chunk
+	lastChunkPath := f.getChunkPath(info.ChunksCount - 1)
+	data, err := f.client.get(ctx, lastChunkPath)
+	if err != nil {
+		return 0, err
+	}
+
+	// Resize it and upload it back
+	lastChunkSize := info.LastChunkSize
+	if size > len(data) {
+		data = append(data, make([]byte, size-len(data))...)
+	} else {
+		data = data[:size]
+	}
+	if err := f.client.put(ctx, lastChunkPath, data); err != nil {
+		return 0, err
+	}
+
+	// Set size
+	info.LastChunkSize = size
+	if err := f.saveInfo(ctx, info); err != nil {
+		return 0, err
+	}
+
+	return size - lastChunkSize, nil
+}
@@ -0,0 +1,50 @@
+package webdav
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lerenn/chonkfs/pkg/storage/layer"
+	"github.com/lerenn/chonkfs/pkg/storage/mem"
+	"github.com/lerenn/chonkfs/pkg/storage/test"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestFileSuite(t *testing.T) {
+	suite.Run(t, new(FileSuite))
+	suite.Run(t, new(FileAsUnderlayerSuite))
+}
+
+type FileSuite struct {
+	test.FileSuite
+	Server *httptest.Server
+}
+
+func (suite *FileSuite) SetupTest() {
+	var err error
+	suite.Server = newTestServer(suite.T())
+	suite.Directory, err = NewDirectory(suite.Server.Client(), suite.Server.URL)
+	suite.Require().NoError(err)
+}
+
+func (suite *FileSuite) TearDownTest() {
+	suite.Server.Close()
+}
+
+type FileAsUnderlayerSuite struct {
+	layer.FileSuite
+	Server *httptest.Server
+}
+
+func (suite *FileAsUnderlayerSuite) SetupTest() {
+	var err error
+	suite.Server = newTestServer(suite.T())
+	suite.Upperlayer = mem.NewDirectory()
+	suite.Underlayer, err = NewDirectory(suite.Server.Client(), suite.Server.URL)
+	suite.Require().NoError(err)
+	suite.Directory, _ = layer.NewDirectory(suite.Upperlayer, suite.Underlayer)
+}
+
+func (suite *FileAsUnderlayerSuite) TearDownTest() {
+	suite.Server.Close()
+}
@@ -0,0 +1,19 @@
+package webdav
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/webdav"
+)
+
+// newTestServer starts an in-process WebDAV server, backed by a temporary
+// local directory.
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(&webdav.Handler{
+		FileSystem: webdav.Dir(t.TempDir()),
+		LockSystem: webdav.NewMemLS(),
+	})
+}
@@ -5,7 +5,9 @@ import (
 	"math/rand"
 	"os"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/hanwen/go-fuse/v2/fs"
 	"github.com/hanwen/go-fuse/v2/fuse"
@@ -13,6 +15,7 @@ import (
 	fuse1 "github.com/lerenn/chonkfs/pkg/fuse"
 	"github.com/lerenn/chonkfs/pkg/storage/mem"
 	"github.com/stretchr/testify/suite"
+	"golang.org/x/sys/unix"
 )
 
 const (
@@ -54,10 +57,7 @@ func (suite *Suite) createChonkFS(
 		fuse1.WithDirectoryChunkSize(chunkSize))
 
 	// Mount the ChonkFS
-	server, err = fs.Mount(path, chFS, &fs.Options{
-		UID: uint32(os.Getuid()),
-		GID: uint32(os.Getgid()),
-	})
+	server, err = fs.Mount(path, chFS, &fs.Options{})
 	suite.Require().NoError(err)
 
 	// Append the mount point to the list
@@ -256,3 +256,115 @@ func (suite *Suite) TestCreateAnEmptyFileAndReopenIt() {
 	err = srv.Unmount()
 	suite.Require().NoError(err)
 }
+
+func (suite *Suite) TestChmodAndUtimes() {
+	// Mount chunkfs
+	c, err := chonker.NewDirectory(context.Background(), mem.NewDirectory())
+	suite.Require().NoError(err)
+	path, srv := suite.createChonkFS(c, 4096)
+
+	// Create a file and a directory
+	f, err := os.OpenFile(path+"/hello.txt", os.O_RDWR|os.O_CREATE, 0640)
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.Close())
+	suite.Require().NoError(os.Mkdir(path+"/dir", 0750))
+
+	// Check their mode and owner
+	fi, err := os.Stat(path + "/hello.txt")
+	suite.Require().NoError(err)
+	suite.Require().Equal(os.FileMode(0640), fi.Mode().Perm())
+	suite.Require().Equal(uint32(os.Getuid()), fi.Sys().(*syscall.Stat_t).Uid)
+	fi, err = os.Stat(path + "/dir")
+	suite.Require().NoError(err)
+	suite.Require().Equal(os.FileMode(0750), fi.Mode().Perm())
+	suite.Require().True(fi.IsDir())
+
+	// Change the mode and the times
+	mtime := time.Unix(1700000000, 0)
+	suite.Require().NoError(os.Chmod(path+"/hello.txt", 0600))
+	suite.Require().NoError(os.Chtimes(path+"/hello.txt", mtime, mtime))
+	fi, err = os.Stat(path + "/hello.txt")
+	suite.Require().NoError(err)
+	suite.Require().Equal(os.FileMode(0600), fi.Mode().Perm())
+	suite.Require().True(mtime.Equal(fi.ModTime()))
+
+	// Unmount chunkfs
+	err = srv.Unmount()
+	suite.Require().NoError(err)
+}
+
+func (suite *Suite) TestChown() {
+	if os.Getuid() != 0 {
+		suite.T().Skip("changing the owner needs root")
+	}
+
+	// Mount chunkfs
+	c, err := chonker.NewDirectory(context.Background(), mem.NewDirectory())
+	suite.Require().NoError(err)
+	path, srv := suite.createChonkFS(c, 4096)
+
+	// Create a file
+	f, err := os.Create(path + "/hello.txt")
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.Close())
+
+	// Change its owner and check it is kept, including root
+	for _, id := range []int{1000, 0} {
+		suite.Require().NoError(os.Chown(path+"/hello.txt", id, id))
+		fi, err := os.Stat(path + "/hello.txt")
+		suite.Require().NoError(err)
+		suite.Require().Equal(uint32(id), fi.Sys().(*syscall.Stat_t).Uid)
+		suite.Require().Equal(uint32(id), fi.Sys().(*syscall.Stat_t).Gid)
+	}
+
+	// Unmount chunkfs
+	err = srv.Unmount()
+	suite.Require().NoError(err)
+}
+
+func (suite *Suite) TestXattrs() {
+	// Mount chunkfs
+	c, err := chonker.NewDirectory(context.Background(), mem.NewDirectory())
+	suite.Require().NoError(err)
+	path, srv := suite.createChonkFS(c, 4096)
+
+	// Create a file and set an extended attribute
+	f, err := os.Create(path + "/hello.txt")
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.Close())
+	suite.Require().NoError(unix.Setxattr(path+"/hello.txt", "user.chonk", []byte("cat"), unix.XATTR_CREATE))
+	err = unix.Setxattr(path+"/hello.txt", "user.chonk", []byte("cat"), unix.XATTR_CREATE)
+	suite.Require().ErrorIs(err, unix.EEXIST)
+
+	// Check its size, then its value
+	size, err := unix.Getxattr(path+"/hello.txt", "user.chonk", nil)
+	suite.Require().NoError(err)
+	suite.Require().Equal(3, size)
+	_, err = unix.Getxattr(path+"/hello.txt", "user.chonk", make([]byte, 1))
+	suite.Require().ErrorIs(err, unix.ERANGE)
+	value := make([]byte, size)
+	_, err = unix.Getxattr(path+"/hello.txt", "user.chonk", value)
+	suite.Require().NoError(err)
+	suite.Require().Equal([]byte("cat"), value)
+
+	// List the attributes
+	list := make([]byte, 64)
+	size, err = unix.Listxattr(path+"/hello.txt", list)
+	suite.Require().NoError(err)
+	suite.Require().Equal("user.chonk\x00", string(list[:size]))
+
+	// Remove it
+	suite.Require().NoError(unix.Removexattr(path+"/hello.txt", "user.chonk"))
+	_, err = unix.Getxattr(path+"/hello.txt", "user.chonk", value)
+	suite.Require().ErrorIs(err, unix.ENODATA)
+
+	// Set one on a directory
+	suite.Require().NoError(os.Mkdir(path+"/dir", 0750))
+	suite.Require().NoError(unix.Setxattr(path+"/dir", "user.chonk", []byte("cat"), 0))
+	_, err = unix.Getxattr(path+"/dir", "user.chonk", value)
+	suite.Require().NoError(err)
+
+	// Unmount chunkfs
+	err = srv.Unmount()
+	suite.Require().NoError(err)
+}